@@ -6,14 +6,19 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
 	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/email"
 	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/handlers"
 	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/middleware"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/ratelimit"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
@@ -56,29 +61,99 @@ func main() {
 		ClientSecret: getEnv("AUTH0_CLIENT_SECRET", ""),
 		JWTSecret:    getEnv("JWT_SECRET", "your-secret-key"),
 		RedirectURL:  getEnv("AUTH0_REDIRECT_URL", ""),
+		Audience:     getEnv("AUTH0_AUDIENCE", ""),
+		Entra: auth.EntraConfig{
+			TenantID:     getEnv("ENTRA_TENANT_ID", "organizations"),
+			ClientID:     getEnv("ENTRA_CLIENT_ID", ""),
+			ClientSecret: getEnv("ENTRA_CLIENT_SECRET", ""),
+			RedirectURL:  getEnv("ENTRA_REDIRECT_URL", ""),
+			Authority:    getEnv("ENTRA_AUTHORITY", ""),
+		},
 	}
 
+	// Several connections (password, google, auth0, entra) can be offered side by side;
+	// the first one is the default
 	authFactory := &auth.DefaultAuthFactory{}
-	authProvider, err := authFactory.CreateProvider(authConfig)
+	authProvider, err := authFactory.CreateCompositeProvider(authConfig, strings.Split(getEnv("AUTH_CONNECTIONS", authConfig.Provider), ","))
 	if err != nil {
 		log.Fatalf("Failed to create auth provider: %v", err)
 	}
+	log.Printf("Auth connections: %s (default %s)", strings.Join(authProvider.Connections(), ", "), authProvider.DefaultConnection())
+
+	// Initialize SAML service provider (optional)
+	var samlHandler *handlers.SAMLHandler
+	if samlConfig, ok := loadSAMLConfig(); ok {
+		samlProvider, err := auth.NewSAMLServiceProvider(samlConfig)
+		if err != nil {
+			log.Fatalf("Failed to create SAML service provider: %v", err)
+		}
+		samlHandler = handlers.NewSAMLHandler(samlProvider, authProvider, dbProvider, getEnv("SAML_SUCCESS_REDIRECT_URL", ""))
+		log.Println("SAML single sign-on enabled")
+	}
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(authProvider, dbProvider)
+	authHandler := handlers.NewAuthHandler(authProvider, dbProvider, authConfig.JWTSecret)
+	webAuthn, err := auth.NewWebAuthn(auth.WebAuthnConfig{
+		RPID:          getEnv("WEBAUTHN_RP_ID", "localhost"),
+		RPDisplayName: getEnv("WEBAUTHN_RP_DISPLAY_NAME", "Admin Portal"),
+		RPOrigins:     strings.Split(getEnv("WEBAUTHN_RP_ORIGINS", "http://localhost:3000"), ","),
+	})
+	if err != nil {
+		log.Fatalf("Failed to configure passkeys: %v", err)
+	}
+	authHandler.SetWebAuthn(webAuthn)
+	mailer := newMailer()
+	appURL := getEnv("APP_URL", "http://localhost:3000")
+	authHandler.SetMailer(mailer, appURL)
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		breachedPasswords, err := auth.LoadBreachedPasswords(path)
+		if err != nil {
+			log.Fatalf("Failed to load breached passwords: %v", err)
+		}
+		log.Printf("Loaded %d breached password hashes", breachedPasswords.Len())
+		authHandler.SetBreachedPasswords(breachedPasswords)
+	}
 	companyHandler := handlers.NewCompanyHandler(dbProvider)
+	companyHandler.SetMailer(mailer, appURL)
 	userHandler := handlers.NewUserHandler(dbProvider, authProvider)
+	auditHandler := handlers.NewAuditHandler(dbProvider)
+	serviceAccountHandler := handlers.NewServiceAccountHandler(dbProvider)
+	roleHandler := handlers.NewRoleHandler(dbProvider)
+	groupHandler := handlers.NewGroupHandler(dbProvider)
+	delegatedAdminHandler := handlers.NewDelegatedAdminHandler(dbProvider)
+	operatorHandler := handlers.NewOperatorHandler(dbProvider)
 	invitationHandler := handlers.NewInvitationHandler(dbProvider, authProvider)
 	shortcutHandler := handlers.NewBrowserShortcutHandler(dbProvider)
 	setupHandler := handlers.NewSetupHandler(dbProvider)
+	scimHandler := handlers.NewSCIMHandler(dbProvider, authProvider, getEnv("PUBLIC_URL", "http://localhost:8080"))
+	ssoHandler := handlers.NewSSOHandler(authProvider, dbProvider, getEnv("PUBLIC_URL", "http://localhost:8080"), getEnv("SSO_SUCCESS_REDIRECT_URL", getEnv("SAML_SUCCESS_REDIRECT_URL", "")))
 
 	// Initialize middleware
 	authMiddleware := middleware.NewAuthMiddleware(authProvider)
+	authMiddleware.SetDatabaseProvider(dbProvider)
+	authMiddleware.EnableImpersonation(authConfig.JWTSecret)
+	authMiddleware.SetPlatformOperators(strings.Split(getEnv("PLATFORM_OPERATOR_EMAILS", ""), ","))
+
+	// Rate limits per route group, shared through Redis when REDIS_URL is set
+	rateLimitStore := newRateLimitStore()
+	publicRateLimit := middleware.RateLimit(rateLimitStore, rateLimitPolicy("public", middleware.RateLimitByIP, "RATE_LIMIT_PUBLIC", "60/1m"))
+	// Authenticated groups are also limited by IP before their credentials are checked, so
+	// requests with invalid tokens cannot hammer token validation and its database lookups
+	preAuthRateLimit := middleware.RateLimit(rateLimitStore, rateLimitPolicy("preauth", middleware.RateLimitByIP, "RATE_LIMIT_PREAUTH", "1200/1m"))
+	userRateLimit := middleware.RateLimit(rateLimitStore, rateLimitPolicy("user", middleware.RateLimitByUser, "RATE_LIMIT_USER", "600/1m"))
+	tenantRateLimit := middleware.RateLimit(rateLimitStore, rateLimitPolicy("tenant", middleware.RateLimitByTenant, "RATE_LIMIT_TENANT", "3000/1m"))
+	apiKeyRateLimit := middleware.RateLimit(rateLimitStore, rateLimitPolicy("api_key", middleware.RateLimitByAPIKey, "RATE_LIMIT_API_KEY", "300/1m"))
+	scimRateLimit := middleware.RateLimit(rateLimitStore, rateLimitPolicy("scim", middleware.RateLimitByTenant, "RATE_LIMIT_SCIM", "600/1m"))
 
 	// Set up Gin router
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
 
+	// Client IPs are only read from X-Forwarded-For when the request comes through a trusted proxy
+	if err := router.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
 	// Add CORS middleware
 	router.Use(middleware.CORS())
 
@@ -99,66 +174,233 @@ func main() {
 	{
 		// Public routes (no authentication required)
 		public := api.Group("")
+		public.Use(publicRateLimit)
 		{
 			public.POST("/auth/login", authHandler.Login)
+			public.POST("/auth/login/token", authHandler.LoginWithToken)
+			public.GET("/auth/connections", authHandler.GetConnections)
+			public.GET("/auth/connections/:connection/login", authHandler.StartConnectionLogin)
+			public.GET("/auth/connections/:connection/callback", authHandler.ConnectionCallback)
 			public.POST("/auth/register", authHandler.Register)
 			public.POST("/auth/reset-password", authHandler.ResetPassword)
+			public.POST("/auth/reset-password/confirm", authHandler.ConfirmPasswordReset)
 			public.GET("/auth/verify", authHandler.AuthenticateWithToken)
+
+			// Second login step and MFA enrollment required by the company
+			public.POST("/auth/mfa/verify", authHandler.VerifyMFA)
+			public.POST("/auth/mfa/enroll/totp", authHandler.EnrollTOTP)
+			public.POST("/auth/mfa/enroll/totp/confirm", authHandler.ConfirmTOTP)
+			public.POST("/auth/mfa/webauthn/begin", authHandler.BeginMFAPasskey)
+			public.POST("/auth/mfa/webauthn/finish", authHandler.FinishMFAPasskey)
+			public.POST("/auth/mfa/enroll/passkey", authHandler.BeginPasskeyRegistration)
+			public.POST("/auth/mfa/enroll/passkey/confirm", authHandler.FinishPasskeyRegistration)
+
+			// Passwordless login with a passkey
+			public.POST("/auth/passkey/login/begin", authHandler.BeginPasskeyLogin)
+			public.POST("/auth/passkey/login/finish", authHandler.FinishPasskeyLogin)
+
+			// Passwordless login with an emailed link
+			public.POST("/auth/magic-link", authHandler.RequestMagicLink)
+			public.POST("/auth/magic-link/verify", authHandler.VerifyMagicLink)
+			public.POST("/auth/verify-email", authHandler.VerifyEmail)
+
+			// Emailed confirmation of a company change request
+			public.POST("/change-requests/:id/confirm", companyHandler.ConfirmChangeRequest)
+
+			// Per-company single sign-on
+			public.POST("/auth/sso/discover", ssoHandler.Discover)
+			public.GET("/auth/sso/:companyID/login", ssoHandler.Login)
+			public.GET("/auth/sso/:companyID/saml/metadata", ssoHandler.SAMLMetadata)
+			public.POST("/auth/sso/:companyID/saml/acs", ssoHandler.SAMLACS)
+			public.GET("/auth/sso/:companyID/oidc/callback", ssoHandler.OIDCCallback)
+
+			// SAML single sign-on
+			if samlHandler != nil {
+				public.GET("/auth/saml/metadata", samlHandler.Metadata)
+				public.GET("/auth/saml/login", samlHandler.Login)
+				public.POST("/auth/saml/acs", samlHandler.ACS)
+			}
 		}
 
 		// Protected routes (authentication required)
 		protected := api.Group("")
+		protected.Use(preAuthRateLimit)
 		protected.Use(authMiddleware.Authenticate())
+		protected.Use(userRateLimit, tenantRateLimit, apiKeyRateLimit)
 		{
 			// Auth routes
 			protected.POST("/auth/logout", authHandler.Logout)
-			protected.POST("/auth/refresh", authHandler.RefreshToken)
-			protected.POST("/auth/change-password", authHandler.ChangePassword)
+			protected.GET("/auth/mfa", authHandler.GetMFAStatus)
+			protected.GET("/auth/passkeys", authHandler.GetPasskeys)
+			protected.GET("/auth/identities", authHandler.GetIdentities)
+			protected.GET("/auth/memberships", authHandler.GetMemberships)
 
 			// Company routes
-			protected.POST("/companies", companyHandler.CreateCompany)
-			protected.GET("/companies/me", companyHandler.GetCompany)
-			protected.PUT("/companies/me", companyHandler.UpdateCompany)
-			protected.DELETE("/companies/me", companyHandler.DeleteCompany)
-			protected.GET("/companies/stats", companyHandler.GetCompanyStats)
+			protected.GET("/companies/me", authMiddleware.RequirePermission(rbac.CompanyRead), companyHandler.GetCompany)
+			protected.PUT("/companies/me", authMiddleware.RequirePermission(rbac.CompanyWrite), companyHandler.UpdateCompany)
+			protected.GET("/companies/stats", authMiddleware.RequirePermission(rbac.CompanyRead), companyHandler.GetCompanyStats)
+			// Pending company deletion and domain changes
+			protected.GET("/companies/me/change-requests", authMiddleware.RequirePermission(rbac.CompanyRead), companyHandler.GetChangeRequests)
+			protected.GET("/companies/me/password-policy", authMiddleware.RequirePermission(rbac.SecurityManage), companyHandler.GetPasswordPolicy)
+			protected.GET("/companies/me/sso", authMiddleware.RequirePermission(rbac.SecurityManage), ssoHandler.GetConfig)
+			protected.GET("/companies/me/scim-tokens", authMiddleware.RequirePermission(rbac.SecurityManage), scimHandler.GetTokens)
 
 			// User routes
-			protected.GET("/users", userHandler.GetUsers)
+			protected.GET("/users", authMiddleware.RequireUserPermission(rbac.UsersRead), userHandler.GetUsers)
 			protected.GET("/users/:id", userHandler.GetUser)
 			protected.PUT("/users/:id", userHandler.UpdateUser)
-			protected.DELETE("/users/:id", userHandler.DeleteUser)
+			protected.DELETE("/users/:id", authMiddleware.RequireUserPermission(rbac.UsersWrite), userHandler.DeleteUser)
+			protected.POST("/users/:id/resend-verification", authMiddleware.RequireUserPermission(rbac.UsersInvite), authHandler.ResendUserVerification)
+			protected.POST("/users/:id/unlock", authMiddleware.RequireUserPermission(rbac.UsersWrite), authHandler.UnlockUser)
+
+			// Audit routes
+			protected.GET("/audit-events", authMiddleware.RequirePermission(rbac.AuditRead), auditHandler.GetAuditEvents)
+
+			// Service account and API key routes
+			protected.GET("/service-accounts", authMiddleware.RequirePermission(rbac.ServiceAccountsManage), serviceAccountHandler.GetServiceAccounts)
 
 			// Invitation routes
-			protected.POST("/invitations", invitationHandler.CreateInvitation)
-			protected.GET("/invitations", invitationHandler.GetInvitations)
-			protected.DELETE("/invitations/:id", invitationHandler.DeleteInvitation)
-			protected.POST("/invitations/:token/accept", invitationHandler.AcceptInvitation)
+			protected.POST("/invitations", authMiddleware.RequireUserPermission(rbac.UsersInvite), invitationHandler.CreateInvitation)
+			protected.GET("/invitations", authMiddleware.RequireUserPermission(rbac.UsersInvite), invitationHandler.GetInvitations)
+			protected.DELETE("/invitations/:id", authMiddleware.RequireUserPermission(rbac.UsersInvite), invitationHandler.DeleteInvitation)
+			protected.POST("/invitations/:id/resend", authMiddleware.RequireUserPermission(rbac.UsersInvite), invitationHandler.ResendInvitation)
 
 			// Browser shortcut routes
-			protected.GET("/shortcuts", shortcutHandler.GetShortcuts)
-			protected.POST("/shortcuts", shortcutHandler.CreateShortcut)
-			protected.PUT("/shortcuts/:id", shortcutHandler.UpdateShortcut)
-			protected.DELETE("/shortcuts/:id", shortcutHandler.DeleteShortcut)
-
-			// Setup and configuration routes
-			protected.GET("/setup/progress", setupHandler.GetSetupProgress)
-			protected.PUT("/setup/step", setupHandler.UpdateSetupStep)
-			protected.GET("/setup/stats", setupHandler.GetCompanyStats)
-			protected.PUT("/setup/config", setupHandler.UpdateConfigurationStatus)
-			protected.POST("/setup/generate-shortcuts", setupHandler.GenerateShortcuts)
-			protected.POST("/setup/nudge-users", setupHandler.NudgeUsers)
-			protected.GET("/setup/download-info", setupHandler.GetDownloadInfo)
+			protected.GET("/shortcuts", authMiddleware.RequirePermission(rbac.ShortcutsRead), shortcutHandler.GetShortcuts)
+			protected.POST("/shortcuts", authMiddleware.RequirePermission(rbac.ShortcutsWrite), shortcutHandler.CreateShortcut)
+			protected.PUT("/shortcuts/:id", authMiddleware.RequirePermission(rbac.ShortcutsWrite), shortcutHandler.UpdateShortcut)
+			protected.DELETE("/shortcuts/:id", authMiddleware.RequirePermission(rbac.ShortcutsWrite), shortcutHandler.DeleteShortcut)
+
+			// Role and permission routes
+			protected.GET("/auth/permissions", roleHandler.GetPermissions)
+			protected.GET("/roles", authMiddleware.RequirePermission(rbac.RolesManage), roleHandler.GetRoles)
+
+			// Group routes
+			protected.GET("/groups", authMiddleware.RequirePermission(rbac.UsersRead), groupHandler.GetGroups)
+			protected.POST("/groups", authMiddleware.RequirePermission(rbac.GroupsManage), groupHandler.CreateGroup)
+			protected.GET("/groups/:id", authMiddleware.RequirePermission(rbac.UsersRead), groupHandler.GetGroup)
+			protected.PUT("/groups/:id", authMiddleware.RequirePermission(rbac.GroupsManage), groupHandler.UpdateGroup)
+			protected.DELETE("/groups/:id", authMiddleware.RequirePermission(rbac.GroupsManage), groupHandler.DeleteGroup)
+			protected.POST("/groups/:id/members", authMiddleware.RequirePermission(rbac.GroupsManage), groupHandler.AddGroupMembers)
+			protected.DELETE("/groups/:id/users/:userID", authMiddleware.RequirePermission(rbac.GroupsManage), groupHandler.RemoveGroupUser)
+			protected.DELETE("/groups/:id/groups/:nestedID", authMiddleware.RequirePermission(rbac.GroupsManage), groupHandler.RemoveNestedGroup)
+			protected.GET("/users/:id/groups", groupHandler.GetUserGroups)
+
+			// Delegated admin routes
+			protected.GET("/delegated-admins", authMiddleware.RequirePermission(rbac.RolesManage), delegatedAdminHandler.GetDelegatedAdmins)
+		}
+
+		// Credential, security, privilege and destructive company routes (not available
+		// while impersonating a user)
+		sensitive := protected.Group("")
+		sensitive.Use(middleware.DenyImpersonation())
+		{
+			sensitive.DELETE("/companies/me", authMiddleware.RequirePermission(rbac.CompanyDelete), companyHandler.DeleteCompany)
+			// Ownership transfer; the owner nominates, the nominee accepts, either can cancel
+			sensitive.POST("/companies/me/ownership-transfer", companyHandler.TransferOwnership)
+			sensitive.POST("/companies/me/ownership-transfer/accept", companyHandler.AcceptOwnership)
+			sensitive.DELETE("/companies/me/ownership-transfer", companyHandler.CancelOwnershipTransfer)
+			// Approval of company deletion and domain changes; the permission each change needs is checked by the handler
+			sensitive.POST("/companies/me/change-requests/:id/approve", authMiddleware.RequirePermission(rbac.CompanyRead), companyHandler.ApproveChangeRequest)
+			sensitive.POST("/companies/me/change-requests/:id/reject", authMiddleware.RequirePermission(rbac.CompanyRead), companyHandler.RejectChangeRequest)
+
+			sensitive.PUT("/companies/me/security", authMiddleware.RequirePermission(rbac.SecurityManage), companyHandler.UpdateSecuritySettings)
+			sensitive.PUT("/companies/me/password-policy", authMiddleware.RequirePermission(rbac.SecurityManage), companyHandler.UpdatePasswordPolicy)
+			sensitive.PUT("/companies/me/sso", authMiddleware.RequirePermission(rbac.SecurityManage), ssoHandler.UpdateConfig)
+			sensitive.DELETE("/companies/me/sso", authMiddleware.RequirePermission(rbac.SecurityManage), ssoHandler.DeleteConfig)
+			sensitive.POST("/companies/me/scim-tokens", authMiddleware.RequirePermission(rbac.SecurityManage), scimHandler.CreateToken)
+			sensitive.DELETE("/companies/me/scim-tokens/:id", authMiddleware.RequirePermission(rbac.SecurityManage), scimHandler.DeleteToken)
+			sensitive.DELETE("/lockouts/ip/:ip", authMiddleware.RequirePermission(rbac.SecurityManage), authHandler.UnlockIP)
+
+			sensitive.POST("/service-accounts", authMiddleware.RequirePermission(rbac.ServiceAccountsManage), serviceAccountHandler.CreateServiceAccount)
+			sensitive.DELETE("/service-accounts/:id", authMiddleware.RequirePermission(rbac.ServiceAccountsManage), serviceAccountHandler.DeleteServiceAccount)
+			sensitive.POST("/service-accounts/:id/api-keys", authMiddleware.RequirePermission(rbac.ServiceAccountsManage), serviceAccountHandler.CreateAPIKey)
+			sensitive.DELETE("/service-accounts/:id/api-keys/:keyID", authMiddleware.RequirePermission(rbac.ServiceAccountsManage), serviceAccountHandler.DeleteAPIKey)
+
+			sensitive.POST("/roles", authMiddleware.RequirePermission(rbac.RolesManage), roleHandler.CreateRole)
+			sensitive.PUT("/roles/:id", authMiddleware.RequirePermission(rbac.RolesManage), roleHandler.UpdateRole)
+			sensitive.DELETE("/roles/:id", authMiddleware.RequirePermission(rbac.RolesManage), roleHandler.DeleteRole)
+			sensitive.POST("/delegated-admins", authMiddleware.RequirePermission(rbac.RolesManage), delegatedAdminHandler.CreateDelegatedAdmin)
+			sensitive.PUT("/delegated-admins/:id", authMiddleware.RequirePermission(rbac.RolesManage), delegatedAdminHandler.UpdateDelegatedAdmin)
+			sensitive.DELETE("/delegated-admins/:id", authMiddleware.RequirePermission(rbac.RolesManage), delegatedAdminHandler.DeleteDelegatedAdmin)
+		}
+
+		// Account security routes (not available while impersonating a user)
+		accountSecurity := protected.Group("")
+		accountSecurity.Use(middleware.DenyImpersonation())
+		{
+			accountSecurity.POST("/auth/refresh", authHandler.RefreshToken)
+			accountSecurity.POST("/auth/switch-company", authHandler.SwitchCompany)
+			accountSecurity.POST("/auth/change-password", authHandler.ChangePassword)
+			accountSecurity.POST("/auth/mfa/totp/enroll", authHandler.EnrollTOTP)
+			accountSecurity.POST("/auth/mfa/totp/confirm", authHandler.ConfirmTOTP)
+			accountSecurity.POST("/auth/mfa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+			accountSecurity.POST("/auth/mfa/disable", authHandler.DisableMFA)
+			accountSecurity.POST("/auth/passkeys/register/begin", authHandler.BeginPasskeyRegistration)
+			accountSecurity.POST("/auth/passkeys/register/finish", authHandler.FinishPasskeyRegistration)
+			accountSecurity.DELETE("/auth/passkeys/:id", authHandler.DeletePasskey)
+			accountSecurity.POST("/auth/identities", authHandler.LinkIdentity)
+			accountSecurity.DELETE("/auth/identities/:id", authHandler.UnlinkIdentity)
+			accountSecurity.POST("/auth/verify-email/resend", authHandler.ResendVerification)
+			accountSecurity.POST("/invitations/:token/accept", invitationHandler.AcceptInvitation)
+			accountSecurity.POST("/users/:id/impersonate", authMiddleware.RequirePermission(rbac.UsersImpersonate), authHandler.StartImpersonation)
+		}
+
+		// Company setup routes (verified email required)
+		setup := protected.Group("")
+		setup.Use(middleware.RequireVerifiedEmail(dbProvider))
+		{
+			setup.POST("/companies", companyHandler.CreateCompany)
+
+			setup.GET("/setup/progress", setupHandler.GetSetupProgress)
+			setup.PUT("/setup/step", setupHandler.UpdateSetupStep)
+			setup.GET("/setup/stats", setupHandler.GetCompanyStats)
+			setup.PUT("/setup/config", setupHandler.UpdateConfigurationStatus)
+			setup.POST("/setup/generate-shortcuts", setupHandler.GenerateShortcuts)
+			setup.POST("/setup/nudge-users", setupHandler.NudgeUsers)
+			setup.GET("/setup/download-info", setupHandler.GetDownloadInfo)
 		}
 
-		// Admin routes (admin role required)
+		// Operator console routes (platform operator required, tenant admins are not operators)
 		admin := api.Group("/admin")
+		admin.Use(preAuthRateLimit)
 		admin.Use(authMiddleware.Authenticate())
-		admin.Use(authMiddleware.RequireRole("admin"))
+		admin.Use(userRateLimit)
+		admin.Use(authMiddleware.RequirePlatformOperator())
 		{
-			admin.GET("/companies", companyHandler.ListCompanies)
+			admin.GET("/companies", operatorHandler.SearchCompanies)
+			admin.GET("/companies/:id/stats", operatorHandler.GetCompanyStats)
+			admin.POST("/companies/:id/suspend", operatorHandler.SuspendCompany)
+			admin.POST("/companies/:id/reactivate", operatorHandler.ReactivateCompany)
+			admin.POST("/companies/:id/ownership-transfer", operatorHandler.NominateOwner)
+			admin.POST("/companies/:id/extend-trial", operatorHandler.ExtendTrial)
+			admin.POST("/companies/:id/expire-sessions", operatorHandler.ExpireSessions)
 		}
 	}
 
+	// SCIM 2.0 provisioning routes (per-company SCIM bearer token required)
+	scim := router.Group("/scim/v2")
+	scim.Use(preAuthRateLimit)
+	scim.Use(middleware.SCIMAuth(dbProvider))
+	scim.Use(scimRateLimit)
+	{
+		scim.GET("/ServiceProviderConfig", scimHandler.ServiceProviderConfig)
+
+		scim.GET("/Users", scimHandler.ListUsers)
+		scim.POST("/Users", scimHandler.CreateUser)
+		scim.GET("/Users/:id", scimHandler.GetUser)
+		scim.PUT("/Users/:id", scimHandler.ReplaceUser)
+		scim.PATCH("/Users/:id", scimHandler.PatchUser)
+		scim.DELETE("/Users/:id", scimHandler.DeleteUser)
+
+		scim.GET("/Groups", scimHandler.ListGroups)
+		scim.POST("/Groups", scimHandler.CreateGroup)
+		scim.GET("/Groups/:id", scimHandler.GetGroup)
+		scim.PUT("/Groups/:id", scimHandler.ReplaceGroup)
+		scim.PATCH("/Groups/:id", scimHandler.PatchGroup)
+		scim.DELETE("/Groups/:id", scimHandler.DeleteGroup)
+	}
+
 	// Get server configuration
 	port := getEnv("PORT", "8080")
 	serverAddr := fmt.Sprintf(":%s", port)
@@ -190,6 +432,47 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvFile returns the contents of the file named by an environment variable
+func getEnvFile(key string) string {
+	path := os.Getenv(key)
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Failed to read %s (%s): %v", key, path, err)
+	}
+	return string(data)
+}
+
+// loadSAMLConfig builds the SAML configuration, reporting whether an IdP is configured
+func loadSAMLConfig() (auth.SAMLConfig, bool) {
+	idpMetadata := getEnvFile("SAML_IDP_METADATA_FILE")
+	idpSSOURL := getEnv("SAML_IDP_SSO_URL", "")
+	if idpMetadata == "" && idpSSOURL == "" {
+		return auth.SAMLConfig{}, false
+	}
+
+	publicURL := strings.TrimSuffix(getEnv("PUBLIC_URL", "http://localhost:8080"), "/")
+	return auth.SAMLConfig{
+		EntityID:       getEnv("SAML_ENTITY_ID", ""),
+		MetadataURL:    publicURL + "/api/v1/auth/saml/metadata",
+		ACSURL:         publicURL + "/api/v1/auth/saml/acs",
+		IdPMetadataXML: idpMetadata,
+		IdPEntityID:    getEnv("SAML_IDP_ENTITY_ID", ""),
+		IdPSSOURL:      idpSSOURL,
+		IdPCertificate: getEnvFile("SAML_IDP_CERT_FILE"),
+		SPCertificate:  getEnvFile("SAML_SP_CERT_FILE"),
+		SPPrivateKey:   getEnvFile("SAML_SP_KEY_FILE"),
+		AttributeMapping: auth.SAMLAttributeMapping{
+			Email:           getEnv("SAML_ATTR_EMAIL", ""),
+			Name:            getEnv("SAML_ATTR_NAME", ""),
+			Role:            getEnv("SAML_ATTR_ROLE", ""),
+			AdminRoleValues: strings.Split(getEnv("SAML_ADMIN_ROLE_VALUES", "admin"), ","),
+		},
+	}, true
+}
+
 func getEnvAsInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
 		if intValue, err := fmt.Sscanf(value, "%d", &defaultValue); err == nil && intValue == 1 {
@@ -199,3 +482,60 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// trustedProxies returns the proxy addresses and CIDR ranges listed in TRUSTED_PROXIES. No
+// proxy is trusted by default, so the client IP is the address of the connection.
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// newRateLimitStore returns a Redis rate limit store when REDIS_URL is set, otherwise
+// limits are kept in memory and apply per instance
+func newRateLimitStore() ratelimit.Store {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		return ratelimit.NewMemoryStore()
+	}
+
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		log.Fatalf("Invalid REDIS_URL: %v", err)
+	}
+	return ratelimit.NewRedisStore(redis.NewClient(options), "ratelimit:")
+}
+
+// rateLimitPolicy returns a rate limit policy whose rate is read from an environment
+// variable such as "100/1m"
+func rateLimitPolicy(name, by, key, defaultRate string) middleware.RateLimitPolicy {
+	rate, err := ratelimit.ParseRate(getEnv(key, defaultRate))
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	return middleware.RateLimitPolicy{
+		Name: name,
+		By:   by,
+		Rate: rate,
+	}
+}
+
+// newMailer returns an SMTP sender when SMTP_HOST is set, otherwise emails are only logged
+func newMailer() email.Sender {
+	host := getEnv("SMTP_HOST", "")
+	if host == "" {
+		log.Println("SMTP_HOST not set, emails will be logged instead of sent")
+		return email.LogSender{}
+	}
+
+	return email.NewSMTPSender(email.SMTPConfig{
+		Host:     host,
+		Port:     getEnvAsInt("SMTP_PORT", 587),
+		Username: getEnv("SMTP_USERNAME", ""),
+		Password: getEnv("SMTP_PASSWORD", ""),
+		From:     getEnv("SMTP_FROM", ""),
+	})
+}
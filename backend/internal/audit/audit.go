@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+)
+
+// Record stores an audit event. Failures are logged rather than returned so auditing
+// never blocks the action being audited.
+func Record(ctx context.Context, databaseProvider database.DatabaseProvider, event *database.AuditEvent) {
+	event.ID = uuid.New().String()
+	event.CreatedAt = time.Now()
+
+	log.Printf("Audit: %s company=%s actor=%s target=%s/%s ip=%s",
+		event.Action, event.CompanyID, event.ActorID, event.TargetType, event.TargetID, event.IPAddress)
+
+	if err := databaseProvider.CreateAuditEvent(ctx, event); err != nil {
+		log.Printf("Failed to store audit event %s: %v", event.Action, err)
+	}
+}
+
+// ForRequest returns an audit event for an action taken by the authenticated user of a
+// request. While impersonating, the event is attributed to the real actor and names the
+// impersonated user in its details.
+func ForRequest(c *gin.Context, action, targetType, targetID string) *database.AuditEvent {
+	event := &database.AuditEvent{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IPAddress:  c.ClientIP(),
+		Details:    map[string]interface{}{},
+	}
+
+	user, err := principal.Get(c)
+	if err != nil {
+		return event
+	}
+
+	event.CompanyID = user.CompanyID
+	event.ActorID = user.UserID
+	event.ActorEmail = user.Email
+	if user.ServiceAccountID != "" {
+		event.Details["api_key_id"] = user.APIKeyID
+	}
+	if user.Impersonating() {
+		event.ActorID = user.ActorID
+		event.ActorEmail = user.ActorEmail
+		event.Details["impersonated_user_id"] = user.UserID
+	}
+	return event
+}
@@ -0,0 +1,4 @@
+package auth
+
+// APIKeyPrefix starts every service account API key, which tells them apart from JWTs
+const APIKeyPrefix = "pak_"
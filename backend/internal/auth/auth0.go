@@ -6,28 +6,53 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
+// managementTokenRefreshMargin is how long before expiry a cached management token is replaced
+const managementTokenRefreshMargin = 60 * time.Second
+
 // Auth0Provider implements AuthProvider for Auth0
 type Auth0Provider struct {
 	config     AuthConfig
 	httpClient *http.Client
+	baseURL    string
+	keySet     *JWKSKeySet
+
+	mgmtMu        sync.Mutex
+	mgmtToken     *Auth0ManagementToken
+	mgmtExpiresAt time.Time
 }
 
 // NewAuth0Provider creates a new Auth0 provider
 func NewAuth0Provider(config AuthConfig) (*Auth0Provider, error) {
+	httpClient := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	baseURL := auth0BaseURL(config.Domain)
+
 	return &Auth0Provider{
-		config: config,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		config:     config,
+		httpClient: httpClient,
+		baseURL:    baseURL,
+		keySet:     NewJWKSKeySet(baseURL+"/.well-known/jwks.json", httpClient),
 	}, nil
 }
 
+// auth0BaseURL turns the configured domain into a base URL. A domain with an explicit
+// scheme is used as is so a local stand-in of the Auth0 endpoints can be configured.
+func auth0BaseURL(domain string) string {
+	domain = strings.TrimSuffix(domain, "/")
+	if strings.HasPrefix(domain, "http://") || strings.HasPrefix(domain, "https://") {
+		return domain
+	}
+	return "https://" + domain
+}
+
 // Auth0User represents a user from Auth0
 type Auth0User struct {
 	UserID      string `json:"user_id"`
@@ -64,21 +89,13 @@ func (a *Auth0Provider) Authenticate(ctx context.Context, email, password string
 // AuthenticateWithToken authenticates a user with an Auth0 token
 func (a *Auth0Provider) AuthenticateWithToken(ctx context.Context, token string) (*User, error) {
 	// Validate the JWT token
-	claims, err := a.validateJWT(token)
+	claims, err := a.validateJWT(ctx, token)
 	if err != nil {
 		return nil, fmt.Errorf("invalid token: %w", err)
 	}
 
 	// Extract user information from claims
-	user := &User{
-		ID:        claims["sub"].(string),
-		Email:     claims["email"].(string),
-		Name:      claims["name"].(string),
-		Picture:   claims["picture"].(string),
-		IsActive:  true,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
+	user := auth0UserFromClaims(claims)
 
 	// Check if user exists in our database
 	// This would typically be done by calling the database provider
@@ -90,7 +107,7 @@ func (a *Auth0Provider) AuthenticateWithToken(ctx context.Context, token string)
 // Register registers a new user with Auth0
 func (a *Auth0Provider) Register(ctx context.Context, email, password, name string) (*User, error) {
 	// Get management API token
-	mgmtToken, err := a.getManagementToken()
+	mgmtToken, err := a.getManagementToken(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get management token: %w", err)
 	}
@@ -108,7 +125,7 @@ func (a *Auth0Provider) Register(ctx context.Context, email, password, name stri
 
 	userData, _ := json.Marshal(auth0User)
 	req, err := http.NewRequestWithContext(ctx, "POST", 
-		fmt.Sprintf("https://%s/api/v2/users", a.config.Domain), 
+		a.baseURL+"/api/v2/users", 
 		strings.NewReader(string(userData)))
 	if err != nil {
 		return nil, err
@@ -150,21 +167,13 @@ func (a *Auth0Provider) Register(ctx context.Context, email, password, name stri
 // RegisterWithSocial registers a new user with social login
 func (a *Auth0Provider) RegisterWithSocial(ctx context.Context, provider, token string) (*User, error) {
 	// Validate the social token
-	claims, err := a.validateJWT(token)
+	claims, err := a.validateJWT(ctx, token)
 	if err != nil {
 		return nil, fmt.Errorf("invalid social token: %w", err)
 	}
 
 	// Extract user information from claims
-	user := &User{
-		ID:        claims["sub"].(string),
-		Email:     claims["email"].(string),
-		Name:      claims["name"].(string),
-		Picture:   claims["picture"].(string),
-		IsActive:  true,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
+	user := auth0UserFromClaims(claims)
 
 	return user, nil
 }
@@ -172,14 +181,14 @@ func (a *Auth0Provider) RegisterWithSocial(ctx context.Context, provider, token
 // GetUser retrieves a user by ID from Auth0
 func (a *Auth0Provider) GetUser(ctx context.Context, userID string) (*User, error) {
 	// Get management API token
-	mgmtToken, err := a.getManagementToken()
+	mgmtToken, err := a.getManagementToken(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get management token: %w", err)
 	}
 
 	// Get user from Auth0
 	req, err := http.NewRequestWithContext(ctx, "GET", 
-		fmt.Sprintf("https://%s/api/v2/users/%s", a.config.Domain, userID), nil)
+		fmt.Sprintf(a.baseURL+"/api/v2/users/%s", userID), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -218,14 +227,14 @@ func (a *Auth0Provider) GetUser(ctx context.Context, userID string) (*User, erro
 // GetUserByEmail retrieves a user by email from Auth0
 func (a *Auth0Provider) GetUserByEmail(ctx context.Context, email string) (*User, error) {
 	// Get management API token
-	mgmtToken, err := a.getManagementToken()
+	mgmtToken, err := a.getManagementToken(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get management token: %w", err)
 	}
 
 	// Search for user by email
 	req, err := http.NewRequestWithContext(ctx, "GET", 
-		fmt.Sprintf("https://%s/api/v2/users-by-email?email=%s", a.config.Domain, email), nil)
+		fmt.Sprintf(a.baseURL+"/api/v2/users-by-email?email=%s", email), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -269,7 +278,7 @@ func (a *Auth0Provider) GetUserByEmail(ctx context.Context, email string) (*User
 // UpdateUser updates user information in Auth0
 func (a *Auth0Provider) UpdateUser(ctx context.Context, user *User) error {
 	// Get management API token
-	mgmtToken, err := a.getManagementToken()
+	mgmtToken, err := a.getManagementToken(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get management token: %w", err)
 	}
@@ -282,7 +291,7 @@ func (a *Auth0Provider) UpdateUser(ctx context.Context, user *User) error {
 
 	userData, _ := json.Marshal(updateData)
 	req, err := http.NewRequestWithContext(ctx, "PATCH", 
-		fmt.Sprintf("https://%s/api/v2/users/%s", a.config.Domain, user.ID), 
+		fmt.Sprintf(a.baseURL+"/api/v2/users/%s", user.ID), 
 		strings.NewReader(string(userData)))
 	if err != nil {
 		return err
@@ -307,14 +316,14 @@ func (a *Auth0Provider) UpdateUser(ctx context.Context, user *User) error {
 // DeleteUser deletes a user from Auth0
 func (a *Auth0Provider) DeleteUser(ctx context.Context, userID string) error {
 	// Get management API token
-	mgmtToken, err := a.getManagementToken()
+	mgmtToken, err := a.getManagementToken(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get management token: %w", err)
 	}
 
 	// Delete user from Auth0
 	req, err := http.NewRequestWithContext(ctx, "DELETE", 
-		fmt.Sprintf("https://%s/api/v2/users/%s", a.config.Domain, userID), nil)
+		fmt.Sprintf(a.baseURL+"/api/v2/users/%s", userID), nil)
 	if err != nil {
 		return err
 	}
@@ -357,8 +366,21 @@ func (a *Auth0Provider) GenerateToken(user *User) (string, error) {
 	return tokenString, nil
 }
 
-// ValidateToken validates a JWT token and returns the user
+// ValidateToken validates a JWT token and returns the user. RS256 access tokens issued
+// by Auth0 are checked against its JWKS, our own HS256 session tokens against the secret.
 func (a *Auth0Provider) ValidateToken(tokenString string) (*User, error) {
+	unverified, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token: %w", err)
+	}
+	if _, ok := unverified.Method.(*jwt.SigningMethodHMAC); !ok {
+		claims, err := a.validateJWT(context.Background(), tokenString)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse token: %w", err)
+		}
+		return auth0UserFromClaims(claims), nil
+	}
+
 	// Parse and validate token
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		// Validate signing method
@@ -390,6 +412,11 @@ func (a *Auth0Provider) ValidateToken(tokenString string) (*User, error) {
 		IsActive: true,
 	}
 
+	// Record when the token was issued so revoked sessions can be rejected
+	if issuedAt, err := claims.GetIssuedAt(); err == nil && issuedAt != nil {
+		user.IssuedAt = issuedAt.Time
+	}
+
 	return user, nil
 }
 
@@ -403,7 +430,7 @@ func (a *Auth0Provider) RefreshToken(refreshToken string) (string, error) {
 // SendInvitation sends an invitation email to a user
 func (a *Auth0Provider) SendInvitation(ctx context.Context, email, companyID string, invitedBy string) error {
 	// Get management API token
-	mgmtToken, err := a.getManagementToken()
+	mgmtToken, err := a.getManagementToken(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get management token: %w", err)
 	}
@@ -421,7 +448,7 @@ func (a *Auth0Provider) SendInvitation(ctx context.Context, email, companyID str
 
 	data, _ := json.Marshal(invitationData)
 	req, err := http.NewRequestWithContext(ctx, "POST", 
-		fmt.Sprintf("https://%s/api/v2/jobs/verification-email", a.config.Domain), 
+		a.baseURL+"/api/v2/jobs/verification-email", 
 		strings.NewReader(string(data)))
 	if err != nil {
 		return err
@@ -443,32 +470,26 @@ func (a *Auth0Provider) SendInvitation(ctx context.Context, email, companyID str
 	return nil
 }
 
-// ActivateUser activates a user account
+// ActivateUser validates an email verification token and marks the email verified in Auth0
 func (a *Auth0Provider) ActivateUser(ctx context.Context, activationToken string) error {
-	// This would typically be handled by Auth0's email verification flow
-	// For now, we'll just return success
-	return nil
-}
+	claims, err := ParsePurposeToken(a.config.JWTSecret, PurposeEmailVerification, activationToken)
+	if err != nil {
+		return fmt.Errorf("invalid activation token: %w", err)
+	}
 
-// ResetPassword sends a password reset email
-func (a *Auth0Provider) ResetPassword(ctx context.Context, email string) error {
 	// Get management API token
-	mgmtToken, err := a.getManagementToken()
+	mgmtToken, err := a.getManagementToken(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get management token: %w", err)
 	}
 
-	// Send password reset email
-	resetData := map[string]interface{}{
-		"client_id": a.config.ClientID,
-		"email":     email,
-		"connection": "Username-Password-Authentication",
-	}
-
-	data, _ := json.Marshal(resetData)
-	req, err := http.NewRequestWithContext(ctx, "POST", 
-		fmt.Sprintf("https://%s/api/v2/jobs/verification-email", a.config.Domain), 
-		strings.NewReader(string(data)))
+	// Mark the email verified in Auth0
+	userData, _ := json.Marshal(map[string]interface{}{
+		"email_verified": true,
+	})
+	req, err := http.NewRequestWithContext(ctx, "PATCH",
+		fmt.Sprintf(a.baseURL+"/api/v2/users/%s", claims["sub"]),
+		strings.NewReader(string(userData)))
 	if err != nil {
 		return err
 	}
@@ -478,12 +499,12 @@ func (a *Auth0Provider) ResetPassword(ctx context.Context, email string) error {
 
 	resp, err := a.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send password reset: %w", err)
+		return fmt.Errorf("failed to activate Auth0 user: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("failed to send password reset: %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to activate Auth0 user: %d", resp.StatusCode)
 	}
 
 	return nil
@@ -491,8 +512,13 @@ func (a *Auth0Provider) ResetPassword(ctx context.Context, email string) error {
 
 // ChangePassword changes a user's password
 func (a *Auth0Provider) ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) error {
+	return a.SetPassword(ctx, userID, newPassword)
+}
+
+// SetPassword replaces a user's password without checking the current one
+func (a *Auth0Provider) SetPassword(ctx context.Context, userID, newPassword string) error {
 	// Get management API token
-	mgmtToken, err := a.getManagementToken()
+	mgmtToken, err := a.getManagementToken(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get management token: %w", err)
 	}
@@ -504,7 +530,7 @@ func (a *Auth0Provider) ChangePassword(ctx context.Context, userID, oldPassword,
 
 	data, _ := json.Marshal(passwordData)
 	req, err := http.NewRequestWithContext(ctx, "PATCH", 
-		fmt.Sprintf("https://%s/api/v2/users/%s", a.config.Domain, userID), 
+		fmt.Sprintf(a.baseURL+"/api/v2/users/%s", userID), 
 		strings.NewReader(string(data)))
 	if err != nil {
 		return err
@@ -515,54 +541,89 @@ func (a *Auth0Provider) ChangePassword(ctx context.Context, userID, oldPassword,
 
 	resp, err := a.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to change password: %w", err)
+		return fmt.Errorf("failed to set password: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to change password: %d", resp.StatusCode)
+		return fmt.Errorf("failed to set password: %d", resp.StatusCode)
 	}
 
 	return nil
 }
 
-// validateJWT validates an Auth0 JWT token
-func (a *Auth0Provider) validateJWT(tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// In a real implementation, you'd fetch the public key from Auth0
-		// For now, we'll use the JWT secret
-		return []byte(a.config.JWTSecret), nil
-	})
+// Issuer returns the issuer of tokens signed by the Auth0 tenant
+func (a *Auth0Provider) Issuer() string {
+	return a.baseURL + "/"
+}
 
+// validateJWT validates an Auth0-issued JWT against the tenant's JWKS, issuer and audience
+func (a *Auth0Provider) validateJWT(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	// Access tokens carry the API audience, ID tokens the client ID
+	audience := a.config.Audience
+	if audience == "" {
+		audience = a.config.ClientID
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, a.keySet.Keyfunc(ctx),
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(a.Issuer()),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	if !token.Valid {
-		return nil, fmt.Errorf("invalid token")
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, fmt.Errorf("token has no subject")
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, fmt.Errorf("invalid token claims")
+	return claims, nil
+}
+
+// auth0UserFromClaims builds a user from Auth0 token claims. Access tokens usually
+// only carry the subject, so the profile claims are optional.
+func auth0UserFromClaims(claims jwt.MapClaims) *User {
+	user := &User{
+		IsActive:  true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
 	}
+	user.ID, _ = claims["sub"].(string)
+	user.Email, _ = claims["email"].(string)
+	user.Name, _ = claims["name"].(string)
+	user.Picture, _ = claims["picture"].(string)
 
-	return claims, nil
+	if issuedAt, err := claims.GetIssuedAt(); err == nil && issuedAt != nil {
+		user.IssuedAt = issuedAt.Time
+	}
+
+	return user
 }
 
-// getManagementToken gets an Auth0 management API token
-func (a *Auth0Provider) getManagementToken() (*Auth0ManagementToken, error) {
+// getManagementToken returns a cached Auth0 management API token, requesting a new
+// one when none is cached or the cached one is about to expire
+func (a *Auth0Provider) getManagementToken(ctx context.Context) (*Auth0ManagementToken, error) {
+	a.mgmtMu.Lock()
+	defer a.mgmtMu.Unlock()
+
+	if a.mgmtToken != nil && time.Now().Before(a.mgmtExpiresAt.Add(-managementTokenRefreshMargin)) {
+		return a.mgmtToken, nil
+	}
+
 	// Request management API token
 	tokenData := map[string]string{
 		"client_id":     a.config.ClientID,
 		"client_secret": a.config.ClientSecret,
-		"audience":      fmt.Sprintf("https://%s/api/v2/", a.config.Domain),
+		"audience":      a.baseURL + "/api/v2/",
 		"grant_type":    "client_credentials",
 	}
 
 	data, _ := json.Marshal(tokenData)
-	req, err := http.NewRequest("POST", 
-		fmt.Sprintf("https://%s/oauth/token", a.config.Domain), 
+	req, err := http.NewRequestWithContext(ctx, "POST",
+		a.baseURL+"/oauth/token",
 		strings.NewReader(string(data)))
 	if err != nil {
 		return nil, err
@@ -585,6 +646,8 @@ func (a *Auth0Provider) getManagementToken() (*Auth0ManagementToken, error) {
 		return nil, fmt.Errorf("failed to decode management token: %w", err)
 	}
 
-	return &token, nil
-}
+	a.mgmtToken = &token
+	a.mgmtExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
 
+	return a.mgmtToken, nil
+}
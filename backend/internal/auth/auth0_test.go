@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// auth0Server stands in for the JWKS, token and management endpoints of an Auth0 tenant
+type auth0Server struct {
+	*httptest.Server
+	jwks *jwksServer
+
+	mu            sync.Mutex
+	tokenRequests int
+}
+
+func newAuth0Server(t *testing.T, signer *testSigner) *auth0Server {
+	t.Helper()
+	s := &auth0Server{jwks: &jwksServer{}}
+	s.jwks.setSigners(signer)
+
+	mux := http.NewServeMux()
+	mux.Handle("/.well-known/jwks.json", s.jwks)
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["grant_type"] != "client_credentials" || body["client_secret"] != "secret" {
+			http.Error(w, "access denied", http.StatusUnauthorized)
+			return
+		}
+
+		s.mu.Lock()
+		s.tokenRequests++
+		token := "mgmt-token-" + strconv.Itoa(s.tokenRequests)
+		s.mu.Unlock()
+
+		json.NewEncoder(w).Encode(Auth0ManagementToken{AccessToken: token, TokenType: "Bearer", ExpiresIn: 86400})
+	})
+	mux.HandleFunc("/api/v2/users/", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer mgmt-token-") {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(Auth0User{
+			UserID: strings.TrimPrefix(r.URL.Path, "/api/v2/users/"),
+			Email:  "alice@acme.com",
+			Name:   "Alice",
+		})
+	})
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *auth0Server) tokenRequestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokenRequests
+}
+
+// newTestAuth0Provider returns a provider for the stand-in tenant
+func newTestAuth0Provider(t *testing.T, server *auth0Server) *Auth0Provider {
+	t.Helper()
+	provider, err := NewAuth0Provider(AuthConfig{
+		Domain:       server.URL,
+		ClientID:     "client-id",
+		ClientSecret: "secret",
+		Audience:     "https://api.example.com",
+	})
+	if err != nil {
+		t.Fatalf("NewAuth0Provider() error = %v", err)
+	}
+	return provider
+}
+
+// auth0Claims returns valid claims for a token of the stand-in tenant
+func auth0Claims(server *auth0Server) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":   server.URL + "/",
+		"aud":   "https://api.example.com",
+		"sub":   "auth0|alice",
+		"email": "alice@acme.com",
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestAuth0ValidateJWT(t *testing.T) {
+	signer := newTestSigner(t, "tenant-key")
+	server := newAuth0Server(t, signer)
+	provider := newTestAuth0Provider(t, server)
+
+	user, err := provider.AuthenticateWithToken(context.Background(), signer.sign(t, auth0Claims(server)))
+	if err != nil {
+		t.Fatalf("AuthenticateWithToken() error = %v", err)
+	}
+	if user.ID != "auth0|alice" || user.Email != "alice@acme.com" {
+		t.Errorf("user = %+v, want auth0|alice with alice@acme.com", user)
+	}
+}
+
+func TestAuth0ValidateJWTRejects(t *testing.T) {
+	signer := newTestSigner(t, "tenant-key")
+	server := newAuth0Server(t, signer)
+	provider := newTestAuth0Provider(t, server)
+
+	tests := []struct {
+		name  string
+		token func() string
+	}{
+		{
+			name: "foreign issuer",
+			token: func() string {
+				claims := auth0Claims(server)
+				claims["iss"] = "https://attacker.auth0.com/"
+				return signer.sign(t, claims)
+			},
+		},
+		{
+			name: "other audience",
+			token: func() string {
+				claims := auth0Claims(server)
+				claims["aud"] = "https://other-api.example.com"
+				return signer.sign(t, claims)
+			},
+		},
+		{
+			name: "expired",
+			token: func() string {
+				claims := auth0Claims(server)
+				claims["exp"] = time.Now().Add(-time.Minute).Unix()
+				return signer.sign(t, claims)
+			},
+		},
+		{
+			name: "no expiry",
+			token: func() string {
+				claims := auth0Claims(server)
+				delete(claims, "exp")
+				return signer.sign(t, claims)
+			},
+		},
+		{
+			name: "no subject",
+			token: func() string {
+				claims := auth0Claims(server)
+				delete(claims, "sub")
+				return signer.sign(t, claims)
+			},
+		},
+		{
+			name: "key not published by the tenant",
+			token: func() string {
+				return newTestSigner(t, "tenant-key").sign(t, auth0Claims(server))
+			},
+		},
+		{
+			name: "HMAC signature",
+			token: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, auth0Claims(server))
+				token.Header["kid"] = "tenant-key"
+				signed, _ := token.SignedString([]byte("client-id"))
+				return signed
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := provider.validateJWT(context.Background(), tt.token()); err == nil {
+				t.Error("validateJWT() accepted the token")
+			}
+		})
+	}
+}
+
+func TestAuth0ManagementTokenCaching(t *testing.T) {
+	ctx := context.Background()
+	server := newAuth0Server(t, newTestSigner(t, "tenant-key"))
+	provider := newTestAuth0Provider(t, server)
+
+	for i := 0; i < 3; i++ {
+		if _, err := provider.GetUser(ctx, "auth0|alice"); err != nil {
+			t.Fatalf("GetUser() error = %v", err)
+		}
+	}
+	if got := server.tokenRequestCount(); got != 1 {
+		t.Errorf("token requests = %d, want 1 (cached)", got)
+	}
+
+	// A token about to expire is replaced before it is used
+	provider.mgmtExpiresAt = time.Now().Add(managementTokenRefreshMargin / 2)
+	if _, err := provider.GetUser(ctx, "auth0|alice"); err != nil {
+		t.Fatalf("GetUser() error = %v", err)
+	}
+	if got := server.tokenRequestCount(); got != 2 {
+		t.Errorf("token requests = %d, want 2 after expiry", got)
+	}
+	if provider.mgmtToken.AccessToken != "mgmt-token-2" {
+		t.Errorf("cached token = %q, want the new token", provider.mgmtToken.AccessToken)
+	}
+}
+
+func TestAuth0ManagementTokenErrorNotCached(t *testing.T) {
+	server := newAuth0Server(t, newTestSigner(t, "tenant-key"))
+	provider := newTestAuth0Provider(t, server)
+	provider.config.ClientSecret = "wrong"
+
+	if _, err := provider.GetUser(context.Background(), "auth0|alice"); err == nil {
+		t.Fatal("GetUser() succeeded with rejected client credentials")
+	}
+	if provider.mgmtToken != nil {
+		t.Error("failed token request was cached")
+	}
+}
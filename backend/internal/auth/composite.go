@@ -0,0 +1,329 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Connection names under which providers are registered in a CompositeProvider
+const (
+	ConnectionPassword = "password"
+	ConnectionGoogle   = "google"
+	ConnectionAuth0    = "auth0"
+	ConnectionEntra    = "entra"
+)
+
+const (
+	// connectionClaim is the session token claim recording which connection issued it
+	connectionClaim = "idp"
+	// tenantClaim is the session token claim naming the company the token is scoped to
+	tenantClaim = "tid"
+)
+
+// ErrUnknownConnection is returned when a request names a connection that is not configured
+var ErrUnknownConnection = errors.New("unknown connection")
+
+// TokenIssuer is implemented by providers whose own signed tokens are accepted
+// directly, so a CompositeProvider can dispatch them by their iss claim
+type TokenIssuer interface {
+	Issuer() string
+}
+
+type connectionContextKey struct{}
+
+// WithConnection returns a context selecting the connection a CompositeProvider uses
+func WithConnection(ctx context.Context, connection string) context.Context {
+	return context.WithValue(ctx, connectionContextKey{}, connection)
+}
+
+// ConnectionFromContext returns the connection selected with WithConnection
+func ConnectionFromContext(ctx context.Context) string {
+	connection, _ := ctx.Value(connectionContextKey{}).(string)
+	return connection
+}
+
+// CompositeProvider implements AuthProvider on top of several providers offered side
+// by side. Calls are dispatched to the connection selected in the context, or to the
+// default connection. Session tokens record the connection that issued them.
+type CompositeProvider struct {
+	jwtSecret         string
+	defaultConnection string
+	providers         map[string]AuthProvider
+}
+
+// NewCompositeProvider creates an empty composite provider. The first added
+// connection becomes the default.
+func NewCompositeProvider(jwtSecret string) *CompositeProvider {
+	return &CompositeProvider{
+		jwtSecret: jwtSecret,
+		providers: make(map[string]AuthProvider),
+	}
+}
+
+// AddConnection registers a provider under a connection name
+func (p *CompositeProvider) AddConnection(connection string, provider AuthProvider) {
+	if p.defaultConnection == "" {
+		p.defaultConnection = connection
+	}
+	p.providers[connection] = provider
+}
+
+// Connections returns the names of the registered connections
+func (p *CompositeProvider) Connections() []string {
+	connections := make([]string, 0, len(p.providers))
+	for connection := range p.providers {
+		connections = append(connections, connection)
+	}
+	sort.Strings(connections)
+	return connections
+}
+
+// DefaultConnection returns the connection used when none is selected
+func (p *CompositeProvider) DefaultConnection() string {
+	return p.defaultConnection
+}
+
+// Provider returns the provider registered under a connection name
+func (p *CompositeProvider) Provider(connection string) (AuthProvider, bool) {
+	provider, ok := p.providers[connection]
+	return provider, ok
+}
+
+// IdentityID returns the ID under which a provider identity is linked to a user
+func IdentityID(connection, subject string) string {
+	return HashToken(connection + "|" + subject)
+}
+
+// resolve returns the connection selected in the context and its provider
+func (p *CompositeProvider) resolve(ctx context.Context) (string, AuthProvider, error) {
+	connection := ConnectionFromContext(ctx)
+	if connection == "" {
+		connection = p.defaultConnection
+	}
+	provider, ok := p.providers[connection]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %s", ErrUnknownConnection, connection)
+	}
+	return connection, provider, nil
+}
+
+// Authenticate authenticates a user with email and password on the selected connection
+func (p *CompositeProvider) Authenticate(ctx context.Context, email, password string) (*User, error) {
+	connection, provider, err := p.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := provider.Authenticate(ctx, email, password)
+	if err != nil {
+		return nil, err
+	}
+	user.Connection = connection
+	return user, nil
+}
+
+// AuthenticateWithToken authenticates a user with a token of the selected connection
+func (p *CompositeProvider) AuthenticateWithToken(ctx context.Context, token string) (*User, error) {
+	connection, provider, err := p.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := provider.AuthenticateWithToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	user.Connection = connection
+	return user, nil
+}
+
+// Register registers a new user on the selected connection
+func (p *CompositeProvider) Register(ctx context.Context, email, password, name string) (*User, error) {
+	connection, provider, err := p.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := provider.Register(ctx, email, password, name)
+	if err != nil {
+		return nil, err
+	}
+	user.Connection = connection
+	return user, nil
+}
+
+// RegisterWithSocial registers a new user with the named social connection
+func (p *CompositeProvider) RegisterWithSocial(ctx context.Context, provider, token string) (*User, error) {
+	social, ok := p.providers[provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownConnection, provider)
+	}
+	user, err := social.RegisterWithSocial(ctx, provider, token)
+	if err != nil {
+		return nil, err
+	}
+	user.Connection = provider
+	return user, nil
+}
+
+// GetUser retrieves a user by ID from the selected connection
+func (p *CompositeProvider) GetUser(ctx context.Context, userID string) (*User, error) {
+	_, provider, err := p.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return provider.GetUser(ctx, userID)
+}
+
+// GetUserByEmail retrieves a user by email from the selected connection
+func (p *CompositeProvider) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	_, provider, err := p.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return provider.GetUserByEmail(ctx, email)
+}
+
+// UpdateUser updates user information on the selected connection
+func (p *CompositeProvider) UpdateUser(ctx context.Context, user *User) error {
+	_, provider, err := p.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return provider.UpdateUser(ctx, user)
+}
+
+// DeleteUser deletes a user from the selected connection
+func (p *CompositeProvider) DeleteUser(ctx context.Context, userID string) error {
+	_, provider, err := p.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return provider.DeleteUser(ctx, userID)
+}
+
+// GenerateToken generates a session token recording the connection the user signed in with
+func (p *CompositeProvider) GenerateToken(user *User) (string, error) {
+	connection := user.Connection
+	if connection == "" {
+		connection = p.defaultConnection
+	}
+
+	claims := jwt.MapClaims{
+		"sub":           user.ID,
+		"email":         user.Email,
+		"name":          user.Name,
+		connectionClaim: connection,
+		"iat":           time.Now().Unix(),
+		"exp":           time.Now().Add(24 * time.Hour).Unix(), // 24 hour expiry
+	}
+	if user.TenantID != "" {
+		claims[tenantClaim] = user.TenantID
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(p.jwtSecret))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
+// ValidateToken validates a token with the provider that issued it. Session tokens are
+// dispatched by their connection claim, provider-signed tokens by their issuer.
+func (p *CompositeProvider) ValidateToken(tokenString string) (*User, error) {
+	unverified, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token: %w", err)
+	}
+	claims, ok := unverified.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+
+	var connection, tenantID string
+	if _, ok := unverified.Method.(*jwt.SigningMethodHMAC); ok {
+		tenantID, _ = claims[tenantClaim].(string)
+		connection, _ = claims[connectionClaim].(string)
+		if connection == "" {
+			// Tokens issued before connections were recorded
+			connection = p.defaultConnection
+		}
+	} else {
+		issuer, _ := claims["iss"].(string)
+		connection = p.connectionForIssuer(issuer)
+		if connection == "" {
+			return nil, fmt.Errorf("unknown token issuer: %s", issuer)
+		}
+	}
+
+	provider, ok := p.providers[connection]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownConnection, connection)
+	}
+	user, err := provider.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	user.Connection = connection
+	user.TenantID = tenantID
+	return user, nil
+}
+
+// connectionForIssuer returns the connection whose provider signs tokens as issuer
+func (p *CompositeProvider) connectionForIssuer(issuer string) string {
+	if issuer == "" {
+		return ""
+	}
+	for connection, provider := range p.providers {
+		if tokenIssuer, ok := provider.(TokenIssuer); ok && strings.TrimSuffix(tokenIssuer.Issuer(), "/") == strings.TrimSuffix(issuer, "/") {
+			return connection
+		}
+	}
+	return ""
+}
+
+// RefreshToken refreshes a token with the default connection
+func (p *CompositeProvider) RefreshToken(refreshToken string) (string, error) {
+	return p.providers[p.defaultConnection].RefreshToken(refreshToken)
+}
+
+// SendInvitation sends an invitation through the selected connection
+func (p *CompositeProvider) SendInvitation(ctx context.Context, email, companyID string, invitedBy string) error {
+	_, provider, err := p.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return provider.SendInvitation(ctx, email, companyID, invitedBy)
+}
+
+// ActivateUser validates an email verification token with the selected connection
+func (p *CompositeProvider) ActivateUser(ctx context.Context, activationToken string) error {
+	_, provider, err := p.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return provider.ActivateUser(ctx, activationToken)
+}
+
+// SetPassword replaces a user's password on the selected connection
+func (p *CompositeProvider) SetPassword(ctx context.Context, userID, newPassword string) error {
+	_, provider, err := p.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return provider.SetPassword(ctx, userID, newPassword)
+}
+
+// ChangePassword changes a user's password on the selected connection
+func (p *CompositeProvider) ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) error {
+	_, provider, err := p.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return provider.ChangePassword(ctx, userID, oldPassword, newPassword)
+}
@@ -9,29 +9,32 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // CustomProvider implements AuthProvider for custom authentication
 type CustomProvider struct {
-	config AuthConfig
-	users  map[string]*User // In-memory user store for POC
+	config         AuthConfig
+	users          map[string]*User  // In-memory user store for POC
+	passwordHashes map[string][]byte // bcrypt hashes keyed by user ID
 }
 
 // NewCustomProvider creates a new custom auth provider
 func NewCustomProvider(config AuthConfig) (*CustomProvider, error) {
 	return &CustomProvider{
-		config: config,
-		users:  make(map[string]*User),
+		config:         config,
+		users:          make(map[string]*User),
+		passwordHashes: make(map[string][]byte),
 	}, nil
 }
 
 // Authenticate authenticates a user with email and password
 func (c *CustomProvider) Authenticate(ctx context.Context, email, password string) (*User, error) {
-	// In a real implementation, you'd hash the password and compare with stored hash
-	// For POC, we'll use a simple approach
 	for _, user := range c.users {
 		if user.Email == email {
-			// In real implementation, verify password hash
+			if err := c.checkPassword(user.ID, password); err != nil {
+				return nil, fmt.Errorf("invalid credentials")
+			}
 			return user, nil
 		}
 	}
@@ -67,6 +70,11 @@ func (c *CustomProvider) Register(ctx context.Context, email, password, name str
 		}
 	}
 
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
 	// Create new user
 	user := &User{
 		ID:        uuid.New().String(),
@@ -79,6 +87,7 @@ func (c *CustomProvider) Register(ctx context.Context, email, password, name str
 
 	// Store user in memory
 	c.users[user.ID] = user
+	c.passwordHashes[user.ID] = hash
 
 	return user, nil
 }
@@ -140,6 +149,7 @@ func (c *CustomProvider) DeleteUser(ctx context.Context, userID string) error {
 	}
 
 	delete(c.users, userID)
+	delete(c.passwordHashes, userID)
 	return nil
 }
 
@@ -199,6 +209,11 @@ func (c *CustomProvider) ValidateToken(tokenString string) (*User, error) {
 		IsActive: true,
 	}
 
+	// Record when the token was issued so revoked sessions can be rejected
+	if issuedAt, err := claims.GetIssuedAt(); err == nil && issuedAt != nil {
+		user.IssuedAt = issuedAt.Time
+	}
+
 	return user, nil
 }
 
@@ -217,26 +232,53 @@ func (c *CustomProvider) SendInvitation(ctx context.Context, email, companyID st
 	return nil
 }
 
-// ActivateUser activates a user account
+// ActivateUser validates an email verification token and activates the user it was issued for
 func (c *CustomProvider) ActivateUser(ctx context.Context, activationToken string) error {
-	// For POC, we'll just return success
-	// In a real implementation, you'd validate the activation token and activate the user
+	claims, err := ParsePurposeToken(c.config.JWTSecret, PurposeEmailVerification, activationToken)
+	if err != nil {
+		return fmt.Errorf("invalid activation token: %w", err)
+	}
+
+	// The in-memory store does not survive restarts, so a missing user is not an error
+	if user, exists := c.users[claims["sub"].(string)]; exists {
+		user.IsActive = true
+		user.UpdatedAt = time.Now()
+	}
 	return nil
 }
 
-// ResetPassword sends a password reset email
-func (c *CustomProvider) ResetPassword(ctx context.Context, email string) error {
-	// For POC, we'll just log the password reset request
-	// In a real implementation, you'd send a password reset email
-	fmt.Printf("Password reset requested for %s\n", email)
+// SetPassword replaces a user's password without checking the current one
+func (c *CustomProvider) SetPassword(ctx context.Context, userID, newPassword string) error {
+	user, exists := c.users[userID]
+	if !exists {
+		return fmt.Errorf("user not found")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	c.passwordHashes[userID] = hash
+	user.UpdatedAt = time.Now()
 	return nil
 }
 
 // ChangePassword changes a user's password
 func (c *CustomProvider) ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) error {
-	// For POC, we'll just return success
-	// In a real implementation, you'd validate the old password and update with the new password hash
-	return nil
+	if err := c.checkPassword(userID, oldPassword); err != nil {
+		return fmt.Errorf("invalid current password")
+	}
+	return c.SetPassword(ctx, userID, newPassword)
+}
+
+// checkPassword compares a password with the stored hash for a user
+func (c *CustomProvider) checkPassword(userID, password string) error {
+	hash, exists := c.passwordHashes[userID]
+	if !exists {
+		return fmt.Errorf("no password set")
+	}
+	return bcrypt.CompareHashAndPassword(hash, []byte(password))
 }
 
 // validateJWT validates a JWT token
@@ -0,0 +1,380 @@
+package auth
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// defaultEntraAuthority is the Microsoft identity platform login host
+const defaultEntraAuthority = "https://login.microsoftonline.com"
+
+// maxEntraKeySets bounds the tenant signing keys cached by multi-tenant apps. Tenant IDs
+// are read from tokens before they are verified, so the least recently used are evicted.
+const maxEntraKeySets = 256
+
+// EntraConfig holds the Microsoft Entra ID app registration used by the entra connection
+type EntraConfig struct {
+	TenantID     string `json:"tenant_id"`     // Directory ID, or "organizations"/"common" for multi-tenant apps
+	ClientID     string `json:"client_id"`     // Application (client) ID
+	ClientSecret string `json:"client_secret"` // Client secret of the app registration
+	RedirectURL  string `json:"redirect_url"`  // Redirect URI registered for the authorization code flow
+	Authority    string `json:"authority"`     // Login host, defaults to https://login.microsoftonline.com
+}
+
+// EntraProvider implements AuthProvider for Microsoft Entra ID using the v2.0
+// authorization code flow. ID tokens are validated against the signing keys of the
+// tenant that issued them.
+type EntraProvider struct {
+	config     AuthConfig
+	entra      EntraConfig
+	httpClient *http.Client
+
+	mu          sync.Mutex
+	keySets     map[string]*list.Element // Elements of keySetOrder holding an *entraKeySet
+	keySetOrder *list.List               // Most recently used first
+}
+
+// entraKeySet is the cached signing key set of a tenant
+type entraKeySet struct {
+	tenantID string
+	keySet   *JWKSKeySet
+}
+
+// NewEntraProvider creates a new Microsoft Entra ID provider
+func NewEntraProvider(config AuthConfig) (*EntraProvider, error) {
+	entra := config.Entra
+	if entra.ClientID == "" {
+		return nil, fmt.Errorf("Entra client ID is required")
+	}
+	if entra.TenantID == "" {
+		entra.TenantID = "organizations"
+	}
+	entra.Authority = strings.TrimSuffix(entra.Authority, "/")
+	if entra.Authority == "" {
+		entra.Authority = defaultEntraAuthority
+	}
+
+	return &EntraProvider{
+		config: config,
+		entra:  entra,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		keySets:     make(map[string]*list.Element),
+		keySetOrder: list.New(),
+	}, nil
+}
+
+// Authenticate authenticates a user with email and password
+func (e *EntraProvider) Authenticate(ctx context.Context, email, password string) (*User, error) {
+	// Entra ID signs users in through the authorization code flow
+	return nil, fmt.Errorf("direct authentication not supported with Microsoft Entra ID, use the authorization code flow")
+}
+
+// AuthenticateWithToken authenticates a user with an Entra ID token
+func (e *EntraProvider) AuthenticateWithToken(ctx context.Context, token string) (*User, error) {
+	claims, err := e.verifyIDToken(ctx, token, "")
+	if err != nil {
+		return nil, err
+	}
+	return entraUserFromClaims(claims), nil
+}
+
+// Register registers a new user with Microsoft Entra ID
+func (e *EntraProvider) Register(ctx context.Context, email, password, name string) (*User, error) {
+	// Accounts are managed in the customer's directory
+	return nil, fmt.Errorf("registration not supported with Microsoft Entra ID")
+}
+
+// RegisterWithSocial registers a new user with an Entra ID token
+func (e *EntraProvider) RegisterWithSocial(ctx context.Context, provider, token string) (*User, error) {
+	if provider != ConnectionEntra {
+		return nil, fmt.Errorf("unsupported provider: %s", provider)
+	}
+	return e.AuthenticateWithToken(ctx, token)
+}
+
+// GetUser retrieves a user by ID
+func (e *EntraProvider) GetUser(ctx context.Context, userID string) (*User, error) {
+	// Directory lookups need Microsoft Graph, users are stored in our database
+	return nil, fmt.Errorf("user lookup not supported with Microsoft Entra ID")
+}
+
+// GetUserByEmail retrieves a user by email
+func (e *EntraProvider) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	// Directory lookups need Microsoft Graph, users are stored in our database
+	return nil, fmt.Errorf("user lookup not supported with Microsoft Entra ID")
+}
+
+// UpdateUser updates user information
+func (e *EntraProvider) UpdateUser(ctx context.Context, user *User) error {
+	// Profiles are managed in the customer's directory, only local data is updated
+	return nil
+}
+
+// DeleteUser deletes a user
+func (e *EntraProvider) DeleteUser(ctx context.Context, userID string) error {
+	// Accounts are managed in the customer's directory, only local data is deleted
+	return nil
+}
+
+// GenerateToken generates a JWT token for a user
+func (e *EntraProvider) GenerateToken(user *User) (string, error) {
+	claims := jwt.MapClaims{
+		"sub":   user.ID,
+		"email": user.Email,
+		"name":  user.Name,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(24 * time.Hour).Unix(), // 24 hour expiry
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(e.config.JWTSecret))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
+// ValidateToken validates a session token and returns the user
+func (e *EntraProvider) ValidateToken(tokenString string) (*User, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(e.config.JWTSecret), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token: %w", err)
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+
+	user := &User{IsActive: true}
+	user.ID, _ = claims["sub"].(string)
+	user.Email, _ = claims["email"].(string)
+	user.Name, _ = claims["name"].(string)
+	if user.ID == "" {
+		return nil, fmt.Errorf("token has no subject")
+	}
+
+	// Record when the token was issued so revoked sessions can be rejected
+	if issuedAt, err := claims.GetIssuedAt(); err == nil && issuedAt != nil {
+		user.IssuedAt = issuedAt.Time
+	}
+
+	return user, nil
+}
+
+// RefreshToken refreshes a JWT token
+func (e *EntraProvider) RefreshToken(refreshToken string) (string, error) {
+	return "", fmt.Errorf("refresh token not implemented")
+}
+
+// SendInvitation sends an invitation email to a user
+func (e *EntraProvider) SendInvitation(ctx context.Context, email, companyID string, invitedBy string) error {
+	// Entra ID accounts accept the invitation stored by the portal by signing in with
+	// Microsoft, so there is nothing to send here
+	return nil
+}
+
+// ActivateUser validates an email verification token
+func (e *EntraProvider) ActivateUser(ctx context.Context, activationToken string) error {
+	if _, err := ParsePurposeToken(e.config.JWTSecret, PurposeEmailVerification, activationToken); err != nil {
+		return fmt.Errorf("invalid activation token: %w", err)
+	}
+	return nil
+}
+
+// SetPassword replaces a user's password without checking the current one
+func (e *EntraProvider) SetPassword(ctx context.Context, userID, newPassword string) error {
+	return fmt.Errorf("password resets are handled by Microsoft")
+}
+
+// ChangePassword changes a user's password
+func (e *EntraProvider) ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) error {
+	return fmt.Errorf("password changes are handled by Microsoft")
+}
+
+// AuthCodeURL returns the v2.0 authorization endpoint URL for the given state and nonce
+func (e *EntraProvider) AuthCodeURL(state, nonce string) string {
+	params := url.Values{}
+	params.Set("client_id", e.entra.ClientID)
+	params.Set("response_type", "code")
+	params.Set("response_mode", "query")
+	params.Set("redirect_uri", e.entra.RedirectURL)
+	params.Set("scope", "openid profile email")
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+
+	return e.endpoint(e.entra.TenantID, "/oauth2/v2.0/authorize") + "?" + params.Encode()
+}
+
+// Exchange redeems an authorization code and returns the user its ID token identifies
+func (e *EntraProvider) Exchange(ctx context.Context, code, nonce string) (*User, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", e.entra.RedirectURL)
+	form.Set("client_id", e.entra.ClientID)
+	form.Set("client_secret", e.entra.ClientSecret)
+	form.Set("scope", "openid profile email")
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.endpoint(e.entra.TenantID, "/oauth2/v2.0/token"), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to exchange authorization code: %d", resp.StatusCode)
+	}
+
+	var tokenResponse struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if tokenResponse.IDToken == "" {
+		return nil, fmt.Errorf("token response does not contain an ID token")
+	}
+
+	claims, err := e.verifyIDToken(ctx, tokenResponse.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return entraUserFromClaims(claims), nil
+}
+
+// verifyIDToken validates an ID token against the signing keys, issuer and audience of
+// the tenant that issued it. Apps registered for a single tenant only accept its tokens.
+func (e *EntraProvider) verifyIDToken(ctx context.Context, rawIDToken, nonce string) (jwt.MapClaims, error) {
+	unverified, _, err := jwt.NewParser().ParseUnverified(rawIDToken, jwt.MapClaims{})
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+	unverifiedClaims, ok := unverified.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid ID token claims")
+	}
+
+	// The tenant ID selects the key and issuer URLs, so it must be a directory ID
+	tenantID, _ := unverifiedClaims["tid"].(string)
+	if _, err := uuid.Parse(tenantID); err != nil {
+		return nil, fmt.Errorf("invalid ID token: missing or malformed tenant ID")
+	}
+	if e.singleTenant() && !strings.EqualFold(tenantID, e.entra.TenantID) {
+		return nil, fmt.Errorf("invalid ID token: tenant %s is not allowed", tenantID)
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims, e.tenantKeySet(tenantID).Keyfunc(ctx),
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(e.endpoint(tenantID, "/v2.0")),
+		jwt.WithAudience(e.entra.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	if nonce != "" {
+		if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+			return nil, fmt.Errorf("invalid ID token: nonce mismatch")
+		}
+	}
+	if oid, _ := claims["oid"].(string); oid == "" {
+		return nil, fmt.Errorf("invalid ID token: missing object ID")
+	}
+
+	return claims, nil
+}
+
+// singleTenant reports whether the app registration is restricted to one directory
+func (e *EntraProvider) singleTenant() bool {
+	switch strings.ToLower(e.entra.TenantID) {
+	case "common", "organizations", "consumers":
+		return false
+	}
+	return true
+}
+
+// tenantKeySet returns the cached signing keys of a tenant, evicting the least recently
+// used tenant once maxEntraKeySets are cached
+func (e *EntraProvider) tenantKeySet(tenantID string) *JWKSKeySet {
+	tenantID = strings.ToLower(tenantID)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if element, ok := e.keySets[tenantID]; ok {
+		e.keySetOrder.MoveToFront(element)
+		return element.Value.(*entraKeySet).keySet
+	}
+
+	keySet := NewJWKSKeySet(e.endpoint(tenantID, "/discovery/v2.0/keys"), e.httpClient)
+	e.keySets[tenantID] = e.keySetOrder.PushFront(&entraKeySet{tenantID: tenantID, keySet: keySet})
+	if e.keySetOrder.Len() > maxEntraKeySets {
+		oldest := e.keySetOrder.Back()
+		e.keySetOrder.Remove(oldest)
+		delete(e.keySets, oldest.Value.(*entraKeySet).tenantID)
+	}
+	return keySet
+}
+
+// endpoint returns the URL of a tenant-specific endpoint of the authority
+func (e *EntraProvider) endpoint(tenantID, path string) string {
+	return e.entra.Authority + "/" + url.PathEscape(tenantID) + path
+}
+
+// entraUserFromClaims builds a user from verified ID token claims. The object ID is the
+// stable identifier of the account; preferred_username is its sign-in name.
+func entraUserFromClaims(claims jwt.MapClaims) *User {
+	user := &User{
+		IsActive:  true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	user.ID, _ = claims["oid"].(string)
+	user.Name, _ = claims["name"].(string)
+	user.DirectoryID, _ = claims["tid"].(string)
+
+	user.Email, _ = claims["preferred_username"].(string)
+	if user.Email == "" {
+		user.Email, _ = claims["email"].(string)
+	}
+	user.Email = strings.ToLower(user.Email)
+	if user.Name == "" {
+		user.Name = user.Email
+	}
+
+	if issuedAt, err := claims.GetIssuedAt(); err == nil && issuedAt != nil {
+		user.IssuedAt = issuedAt.Time
+	}
+
+	return user
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	entraTestTenant  = "6f1d2c3b-0000-4000-8000-000000000001"
+	entraOtherTenant = "6f1d2c3b-0000-4000-8000-000000000002"
+)
+
+// entraServer stands in for the Microsoft identity platform. Each tenant publishes the
+// keys of its own signer, and the token endpoint returns the configured ID token.
+type entraServer struct {
+	*httptest.Server
+	tenants map[string]*jwksServer
+
+	mu      sync.Mutex
+	idToken string
+	form    map[string]string
+}
+
+func newEntraServer(t *testing.T, signers map[string]*testSigner) *entraServer {
+	t.Helper()
+	s := &entraServer{tenants: map[string]*jwksServer{}}
+	for tenantID, signer := range signers {
+		jwks := &jwksServer{}
+		jwks.setSigners(signer)
+		s.tenants[tenantID] = jwks
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		switch path {
+		case "discovery/v2.0/keys":
+			jwks, ok := s.tenants[tenantID]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			jwks.ServeHTTP(w, r)
+		case "oauth2/v2.0/token":
+			r.ParseForm()
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.form = map[string]string{}
+			for key := range r.PostForm {
+				s.form[key] = r.PostForm.Get(key)
+			}
+			json.NewEncoder(w).Encode(map[string]string{"id_token": s.idToken})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *entraServer) setIDToken(idToken string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.idToken = idToken
+}
+
+// newTestEntraProvider returns a provider for the stand-in authority
+func newTestEntraProvider(t *testing.T, server *entraServer, tenantID string) *EntraProvider {
+	t.Helper()
+	provider, err := NewEntraProvider(AuthConfig{Entra: EntraConfig{
+		TenantID:     tenantID,
+		ClientID:     "entra-client",
+		ClientSecret: "entra-secret",
+		RedirectURL:  "http://localhost:8080/callback",
+		Authority:    server.URL,
+	}})
+	if err != nil {
+		t.Fatalf("NewEntraProvider() error = %v", err)
+	}
+	return provider
+}
+
+// entraClaims returns valid ID token claims issued by a tenant of the stand-in authority
+func entraClaims(server *entraServer, tenantID string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":                server.URL + "/" + tenantID + "/v2.0",
+		"aud":                "entra-client",
+		"tid":                tenantID,
+		"oid":                "object-alice",
+		"preferred_username": "Alice@Acme.com",
+		"name":               "Alice",
+		"nonce":              "nonce-1",
+		"iat":                time.Now().Unix(),
+		"exp":                time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestEntraExchange(t *testing.T) {
+	signer := newTestSigner(t, "entra-key")
+	server := newEntraServer(t, map[string]*testSigner{entraTestTenant: signer})
+	provider := newTestEntraProvider(t, server, "organizations")
+	server.setIDToken(signer.sign(t, entraClaims(server, entraTestTenant)))
+
+	user, err := provider.Exchange(context.Background(), "code-1", "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if user.ID != "object-alice" || user.Email != "alice@acme.com" || user.DirectoryID != entraTestTenant {
+		t.Errorf("user = %+v, want object-alice alice@acme.com in the test tenant", user)
+	}
+	if server.form["code"] != "code-1" || server.form["client_secret"] != "entra-secret" {
+		t.Errorf("token request form = %v", server.form)
+	}
+}
+
+func TestEntraExchangeRejects(t *testing.T) {
+	signer := newTestSigner(t, "entra-key")
+	otherSigner := newTestSigner(t, "other-key")
+	server := newEntraServer(t, map[string]*testSigner{entraTestTenant: signer, entraOtherTenant: otherSigner})
+
+	tests := []struct {
+		name     string
+		tenantID string // Tenant the app registration is restricted to
+		token    func() string
+	}{
+		{
+			name:     "tenant outside a single-tenant app",
+			tenantID: entraTestTenant,
+			token: func() string {
+				return otherSigner.sign(t, entraClaims(server, entraOtherTenant))
+			},
+		},
+		{
+			name:     "tenant claim naming another tenant than the signer",
+			tenantID: "organizations",
+			token: func() string {
+				return signer.sign(t, entraClaims(server, entraOtherTenant))
+			},
+		},
+		{
+			name:     "issuer of another tenant",
+			tenantID: "organizations",
+			token: func() string {
+				claims := entraClaims(server, entraTestTenant)
+				claims["iss"] = server.URL + "/" + entraOtherTenant + "/v2.0"
+				return signer.sign(t, claims)
+			},
+		},
+		{
+			name:     "other audience",
+			tenantID: "organizations",
+			token: func() string {
+				claims := entraClaims(server, entraTestTenant)
+				claims["aud"] = "other-client"
+				return signer.sign(t, claims)
+			},
+		},
+		{
+			name:     "nonce mismatch",
+			tenantID: "organizations",
+			token: func() string {
+				claims := entraClaims(server, entraTestTenant)
+				claims["nonce"] = "replayed"
+				return signer.sign(t, claims)
+			},
+		},
+		{
+			name:     "malformed tenant ID",
+			tenantID: "organizations",
+			token: func() string {
+				claims := entraClaims(server, entraTestTenant)
+				claims["tid"] = "../" + entraTestTenant
+				return signer.sign(t, claims)
+			},
+		},
+		{
+			name:     "missing object ID",
+			tenantID: "organizations",
+			token: func() string {
+				claims := entraClaims(server, entraTestTenant)
+				delete(claims, "oid")
+				return signer.sign(t, claims)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newTestEntraProvider(t, server, tt.tenantID)
+			server.setIDToken(tt.token())
+			if _, err := provider.Exchange(context.Background(), "code-1", "nonce-1"); err == nil {
+				t.Error("Exchange() accepted the ID token")
+			}
+		})
+	}
+}
+
+func TestEntraKeySetCacheIsBounded(t *testing.T) {
+	provider, err := NewEntraProvider(AuthConfig{Entra: EntraConfig{ClientID: "entra-client"}})
+	if err != nil {
+		t.Fatalf("NewEntraProvider() error = %v", err)
+	}
+
+	first := provider.tenantKeySet(entraTestTenant)
+	second := provider.tenantKeySet(entraOtherTenant)
+	for i := 0; i < maxEntraKeySets; i++ {
+		// Using the first tenant keeps it cached while unverified tenants come and go
+		if provider.tenantKeySet(entraTestTenant) != first {
+			t.Fatal("recently used tenant key set was evicted")
+		}
+		provider.tenantKeySet(fmt.Sprintf("00000000-0000-4000-8000-%012d", i))
+	}
+
+	if got := len(provider.keySets); got != maxEntraKeySets {
+		t.Errorf("cached key sets = %d, want %d", got, maxEntraKeySets)
+	}
+	if provider.tenantKeySet(entraOtherTenant) == second {
+		t.Error("least recently used tenant key set was not evicted")
+	}
+}
@@ -8,13 +8,13 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
 )
 
 // GoogleProvider implements AuthProvider for Google OAuth
 type GoogleProvider struct {
-	config     AuthConfig
-	httpClient *http.Client
+	config      AuthConfig
+	httpClient  *http.Client
+	userInfoURL string
 }
 
 // NewGoogleProvider creates a new Google OAuth provider
@@ -24,6 +24,7 @@ func NewGoogleProvider(config AuthConfig) (*GoogleProvider, error) {
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		userInfoURL: "https://www.googleapis.com/oauth2/v2/userinfo",
 	}, nil
 }
 
@@ -37,6 +38,7 @@ type GoogleUserInfo struct {
 	FamilyName    string `json:"family_name"`
 	Picture       string `json:"picture"`
 	Locale        string `json:"locale"`
+	HostedDomain  string `json:"hd"` // Google Workspace domain, empty for consumer accounts
 }
 
 // Authenticate authenticates a user with email and password
@@ -53,18 +55,7 @@ func (g *GoogleProvider) AuthenticateWithToken(ctx context.Context, token string
 		return nil, fmt.Errorf("failed to get Google user info: %w", err)
 	}
 
-	// Create user from Google info
-	user := &User{
-		ID:        userInfo.ID,
-		Email:     userInfo.Email,
-		Name:      userInfo.Name,
-		Picture:   userInfo.Picture,
-		IsActive:  true,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-
-	return user, nil
+	return googleUser(userInfo)
 }
 
 // Register registers a new user with Google OAuth
@@ -86,44 +77,19 @@ func (g *GoogleProvider) RegisterWithSocial(ctx context.Context, provider, token
 		return nil, fmt.Errorf("failed to get Google user info: %w", err)
 	}
 
-	// Create user from Google info
-	user := &User{
-		ID:        userInfo.ID,
-		Email:     userInfo.Email,
-		Name:      userInfo.Name,
-		Picture:   userInfo.Picture,
-		IsActive:  true,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-
-	return user, nil
+	return googleUser(userInfo)
 }
 
 // GetUser retrieves a user by ID
 func (g *GoogleProvider) GetUser(ctx context.Context, userID string) (*User, error) {
-	// For Google OAuth, we'd need to store user info in our database
-	// For POC, we'll return a mock user
-	user := &User{
-		ID:       userID,
-		Email:    "user@example.com",
-		Name:     "Google User",
-		IsActive: true,
-	}
-	return user, nil
+	// Google offers no user lookup, users are stored in our database
+	return nil, fmt.Errorf("user lookup not supported with Google OAuth")
 }
 
 // GetUserByEmail retrieves a user by email
 func (g *GoogleProvider) GetUserByEmail(ctx context.Context, email string) (*User, error) {
-	// For Google OAuth, we'd need to store user info in our database
-	// For POC, we'll return a mock user
-	user := &User{
-		ID:       uuid.New().String(),
-		Email:    email,
-		Name:     "Google User",
-		IsActive: true,
-	}
-	return user, nil
+	// Google offers no user lookup, users are stored in our database
+	return nil, fmt.Errorf("user lookup not supported with Google OAuth")
 }
 
 // UpdateUser updates user information
@@ -196,6 +162,11 @@ func (g *GoogleProvider) ValidateToken(tokenString string) (*User, error) {
 		IsActive: true,
 	}
 
+	// Record when the token was issued so revoked sessions can be rejected
+	if issuedAt, err := claims.GetIssuedAt(); err == nil && issuedAt != nil {
+		user.IssuedAt = issuedAt.Time
+	}
+
 	return user, nil
 }
 
@@ -214,17 +185,19 @@ func (g *GoogleProvider) SendInvitation(ctx context.Context, email, companyID st
 	return nil
 }
 
-// ActivateUser activates a user account
+// ActivateUser validates an email verification token
 func (g *GoogleProvider) ActivateUser(ctx context.Context, activationToken string) error {
-	// For Google OAuth, user activation is handled by Google
+	// Google verifies addresses itself, only the token needs checking
+	if _, err := ParsePurposeToken(g.config.JWTSecret, PurposeEmailVerification, activationToken); err != nil {
+		return fmt.Errorf("invalid activation token: %w", err)
+	}
 	return nil
 }
 
-// ResetPassword sends a password reset email
-func (g *GoogleProvider) ResetPassword(ctx context.Context, email string) error {
-	// For Google OAuth, password reset is handled by Google
-	fmt.Printf("Password reset requested for %s (handled by Google)\n", email)
-	return nil
+// SetPassword replaces a user's password without checking the current one
+func (g *GoogleProvider) SetPassword(ctx context.Context, userID, newPassword string) error {
+	// For Google OAuth, passwords are managed by Google
+	return fmt.Errorf("password resets are handled by Google")
 }
 
 // ChangePassword changes a user's password
@@ -233,11 +206,29 @@ func (g *GoogleProvider) ChangePassword(ctx context.Context, userID, oldPassword
 	return fmt.Errorf("password changes are handled by Google")
 }
 
+// googleUser creates a user from Google user info. Unverified email addresses are
+// rejected since accounts are matched to companies by their email domain.
+func googleUser(userInfo *GoogleUserInfo) (*User, error) {
+	if userInfo.Email == "" || !userInfo.VerifiedEmail {
+		return nil, fmt.Errorf("Google account email address is not verified")
+	}
+
+	return &User{
+		ID:           userInfo.ID,
+		Email:        userInfo.Email,
+		Name:         userInfo.Name,
+		Picture:      userInfo.Picture,
+		IsActive:     true,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		HostedDomain: userInfo.HostedDomain,
+	}, nil
+}
+
 // getGoogleUserInfo gets user information from Google
 func (g *GoogleProvider) getGoogleUserInfo(ctx context.Context, accessToken string) (*GoogleUserInfo, error) {
 	// Make request to Google's userinfo endpoint
-	req, err := http.NewRequestWithContext(ctx, "GET", 
-		"https://www.googleapis.com/oauth2/v2/userinfo", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", g.userInfoURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -2,6 +2,8 @@ package auth
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -19,6 +21,11 @@ type User struct {
 	LastLoginAt  time.Time `json:"last_login_at,omitempty"`
 	OnboardedAt  time.Time `json:"onboarded_at,omitempty"`
 	Onboarded    bool      `json:"onboarded"`
+	IssuedAt     time.Time `json:"-"` // Set by ValidateToken from the token's iat claim
+	Connection   string    `json:"connection,omitempty"` // Connection that authenticated the user or issued the token
+	TenantID     string    `json:"-"` // Company a session token is scoped to after switching tenants
+	HostedDomain string    `json:"-"` // Google Workspace domain (hd claim) of the account, if any
+	DirectoryID  string    `json:"-"` // Entra ID tenant (tid claim) the account belongs to, if any
 }
 
 // UserRole represents the role of a user
@@ -68,11 +75,12 @@ type AuthProvider interface {
 	// SendInvitation sends an invitation email to a user
 	SendInvitation(ctx context.Context, email, companyID string, invitedBy string) error
 	
-	// ActivateUser activates a user account
+	// ActivateUser validates an email verification token and activates the user it was issued for
 	ActivateUser(ctx context.Context, activationToken string) error
 	
-	// ResetPassword sends a password reset email
-	ResetPassword(ctx context.Context, email string) error
+	// SetPassword replaces a user's password without checking the current one,
+	// used once a password reset token has been verified
+	SetPassword(ctx context.Context, userID, newPassword string) error
 	
 	// ChangePassword changes a user's password
 	ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) error
@@ -80,12 +88,24 @@ type AuthProvider interface {
 
 // AuthConfig holds configuration for authentication providers
 type AuthConfig struct {
-	Provider     string `json:"provider"`      // "auth0", "google", "custom"
+	Provider     string `json:"provider"`      // "auth0", "google", "entra", "custom"
 	Domain       string `json:"domain"`        // Auth0 domain or OAuth provider domain
 	ClientID     string `json:"client_id"`     // OAuth client ID
 	ClientSecret string `json:"client_secret"` // OAuth client secret
 	JWTSecret    string `json:"jwt_secret"`    // JWT signing secret
 	RedirectURL  string `json:"redirect_url"`  // OAuth redirect URL
+	Audience     string `json:"audience"`      // API audience expected in provider-issued access tokens
+	Entra        EntraConfig `json:"entra"`    // Microsoft Entra ID app registration
+}
+
+// CodeFlowProvider is implemented by providers that sign users in with the OAuth 2.0
+// authorization code flow
+type CodeFlowProvider interface {
+	// AuthCodeURL returns the URL the browser is redirected to for signing in
+	AuthCodeURL(state, nonce string) string
+
+	// Exchange redeems the authorization code the browser returns with
+	Exchange(ctx context.Context, code, nonce string) (*User, error)
 }
 
 // AuthFactory creates authentication providers
@@ -103,6 +123,8 @@ func (f *DefaultAuthFactory) CreateProvider(config AuthConfig) (AuthProvider, er
 		return NewAuth0Provider(config)
 	case "google":
 		return NewGoogleProvider(config)
+	case "entra":
+		return NewEntraProvider(config)
 	case "custom":
 		return NewCustomProvider(config)
 	default:
@@ -110,3 +132,51 @@ func (f *DefaultAuthFactory) CreateProvider(config AuthConfig) (AuthProvider, er
 	}
 }
 
+// CreateCompositeProvider creates a provider offering several connections side by side.
+// Connection names are provider names, with "password" selecting the custom provider.
+// The first connection is the default.
+func (f *DefaultAuthFactory) CreateCompositeProvider(config AuthConfig, connections []string) (*CompositeProvider, error) {
+	composite := NewCompositeProvider(config.JWTSecret)
+	for _, connection := range connections {
+		connection = strings.TrimSpace(connection)
+		if connection == "" {
+			continue
+		}
+		if connection == "custom" {
+			connection = ConnectionPassword
+		}
+
+		providerConfig := config
+		providerConfig.Provider = connection
+		if connection == ConnectionPassword {
+			providerConfig.Provider = "custom"
+		}
+		if _, exists := composite.Provider(connection); exists {
+			continue
+		}
+
+		var provider AuthProvider
+		var err error
+		switch providerConfig.Provider {
+		case "auth0":
+			provider, err = NewAuth0Provider(providerConfig)
+		case "google":
+			provider, err = NewGoogleProvider(providerConfig)
+		case "entra":
+			provider, err = NewEntraProvider(providerConfig)
+		case "custom":
+			provider, err = NewCustomProvider(providerConfig)
+		default:
+			return nil, fmt.Errorf("unknown connection: %s", connection)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s connection: %w", connection, err)
+		}
+		composite.AddConnection(connection, provider)
+	}
+
+	if len(composite.Connections()) == 0 {
+		return nil, fmt.Errorf("no auth connections configured")
+	}
+	return composite, nil
+}
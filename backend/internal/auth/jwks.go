@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// jwksCacheTTL is how long a fetched key set is trusted before it is refreshed
+	jwksCacheTTL = 1 * time.Hour
+	// jwksMinRefreshInterval throttles refreshes triggered by unknown key IDs
+	jwksMinRefreshInterval = 1 * time.Minute
+)
+
+// JSONWebKey represents a single key in a JWKS document
+type JSONWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSKeySet fetches and caches the signing keys published at a JWKS URL
+type JWKSKeySet struct {
+	url        string
+	httpClient *http.Client
+
+	mu          sync.RWMutex
+	keys        map[string]interface{}
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+// NewJWKSKeySet creates a new cached key set for the given JWKS URL
+func NewJWKSKeySet(url string, httpClient *http.Client) *JWKSKeySet {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &JWKSKeySet{
+		url:        url,
+		httpClient: httpClient,
+		keys:       make(map[string]interface{}),
+	}
+}
+
+// Key returns the public key with the given key ID, refreshing the cache when
+// the set is stale or the key is unknown (keys are rotated by the issuer). Refreshes
+// happen at most once per jwksMinRefreshInterval; until one succeeds the last key set
+// keeps being served.
+func (k *JWKSKeySet) Key(ctx context.Context, kid string) (interface{}, error) {
+	k.mu.RLock()
+	key, ok := k.keys[kid]
+	fresh := time.Since(k.fetchedAt) < jwksCacheTTL
+	k.mu.RUnlock()
+
+	if ok && fresh {
+		return key, nil
+	}
+
+	if k.claimRefresh() {
+		if err := k.refresh(ctx); err != nil {
+			// Fall back to a stale key rather than failing every request while the issuer is down
+			if ok {
+				return key, nil
+			}
+			return nil, err
+		}
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok = k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q not found", kid)
+	}
+	return key, nil
+}
+
+// claimRefresh reports whether the caller may refresh the key set now, recording the
+// attempt so concurrent callers do not refresh as well
+func (k *JWKSKeySet) claimRefresh() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if time.Since(k.lastAttempt) < jwksMinRefreshInterval {
+		return false
+	}
+	k.lastAttempt = time.Now()
+	return true
+}
+
+// Keyfunc returns a jwt.Keyfunc resolving keys by the token's kid header
+func (k *JWKSKeySet) Keyfunc(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return k.Key(ctx, kid)
+	}
+}
+
+// refresh downloads the key set, keeping the current keys if it fails
+func (k *JWKSKeySet) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", k.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := k.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: %d", resp.StatusCode)
+	}
+
+	var document struct {
+		Keys []JSONWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(document.Keys))
+	for _, jwk := range document.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			// Skip key types we do not support instead of rejecting the whole set
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.fetchedAt = time.Now()
+	k.mu.Unlock()
+
+	return nil
+}
+
+// PublicKey converts the JWK to an RSA or ECDSA public key
+func (j JSONWebKey) PublicKey() (interface{}, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA exponent: %w", err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", j.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC x coordinate: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC y coordinate: %w", err)
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", j.Kty)
+	}
+}
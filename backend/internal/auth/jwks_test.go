@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testSigner is an RSA key published in a JWKS document for signing test tokens
+type testSigner struct {
+	key *rsa.PrivateKey
+	kid string
+}
+
+// newTestSigner generates a signing key with the given key ID
+func newTestSigner(t *testing.T, kid string) *testSigner {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() error = %v", err)
+	}
+	return &testSigner{key: key, kid: kid}
+}
+
+// jwk returns the public key as a JWK
+func (s *testSigner) jwk() JSONWebKey {
+	return JSONWebKey{
+		Kid: s.kid,
+		Kty: "RSA",
+		Alg: "RS256",
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+	}
+}
+
+// sign returns an RS256 token with the claims
+func (s *testSigner) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = s.kid
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+	return signed
+}
+
+// jwksServer serves the keys of its signers as a JWKS document and counts the requests
+type jwksServer struct {
+	mu       sync.Mutex
+	signers  []*testSigner
+	failing  bool
+	requests int
+}
+
+func (s *jwksServer) setSigners(signers ...*testSigner) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signers = signers
+}
+
+func (s *jwksServer) setFailing(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = failing
+}
+
+func (s *jwksServer) requestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *jwksServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if s.failing {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	var document struct {
+		Keys []JSONWebKey `json:"keys"`
+	}
+	for _, signer := range s.signers {
+		document.Keys = append(document.Keys, signer.jwk())
+	}
+	json.NewEncoder(w).Encode(document)
+}
+
+func TestJWKSKeySetServesStaleKeysWhileRefreshFails(t *testing.T) {
+	ctx := context.Background()
+	signer := newTestSigner(t, "key-1")
+	jwks := &jwksServer{}
+	jwks.setSigners(signer)
+	server := httptest.NewServer(jwks)
+	defer server.Close()
+
+	keySet := NewJWKSKeySet(server.URL, server.Client())
+	if _, err := keySet.Key(ctx, "key-1"); err != nil {
+		t.Fatalf("Key() error = %v", err)
+	}
+
+	// The set goes stale while the issuer is down
+	jwks.setFailing(true)
+	keySet.fetchedAt = time.Now().Add(-jwksCacheTTL - time.Minute)
+	keySet.lastAttempt = time.Now().Add(-jwksMinRefreshInterval)
+
+	for i := 0; i < 5; i++ {
+		if _, err := keySet.Key(ctx, "key-1"); err != nil {
+			t.Fatalf("Key() with a stale set error = %v", err)
+		}
+	}
+	if got := jwks.requestCount(); got != 2 {
+		t.Errorf("JWKS requests = %d, want 2 (one refresh per interval)", got)
+	}
+
+	// Once the interval passed, the next request refreshes the set
+	jwks.setFailing(false)
+	rotated := newTestSigner(t, "key-2")
+	jwks.setSigners(rotated)
+	keySet.lastAttempt = time.Now().Add(-jwksMinRefreshInterval)
+	if _, err := keySet.Key(ctx, "key-2"); err != nil {
+		t.Fatalf("Key() after rotation error = %v", err)
+	}
+	if _, err := keySet.Key(ctx, "key-1"); err == nil {
+		t.Error("Key() returned a key removed from the refreshed set")
+	}
+}
+
+func TestJWKSKeySetThrottlesUnknownKeyRefreshes(t *testing.T) {
+	ctx := context.Background()
+	jwks := &jwksServer{}
+	jwks.setSigners(newTestSigner(t, "key-1"))
+	server := httptest.NewServer(jwks)
+	defer server.Close()
+
+	keySet := NewJWKSKeySet(server.URL, server.Client())
+	for i := 0; i < 5; i++ {
+		if _, err := keySet.Key(ctx, "unknown"); err == nil {
+			t.Fatal("Key() found an unknown key")
+		}
+	}
+	if got := jwks.requestCount(); got != 1 {
+		t.Errorf("JWKS requests = %d, want 1", got)
+	}
+}
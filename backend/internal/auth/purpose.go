@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Purposes of short-lived single-purpose tokens
+const (
+	PurposeMFAChallenge  = "mfa_challenge"
+	PurposeMFAEnrollment = "mfa_enrollment"
+
+	PurposeWebAuthnRegistration = "webauthn_registration"
+	PurposeWebAuthnLogin        = "webauthn_login"
+	PurposeWebAuthnMFA          = "webauthn_mfa"
+
+	PurposeMagicLink         = "magic_link"
+	PurposeEmailVerification = "email_verification"
+	PurposePasswordReset     = "password_reset"
+
+	PurposeImpersonation = "impersonation"
+)
+
+// SignPurposeToken signs a short-lived token that is only valid for one purpose. The
+// signing key is derived from the JWT secret and the purpose, so these tokens can never
+// be used as session tokens (or for another purpose).
+func SignPurposeToken(secret, purpose, subject string, ttl time.Duration, extra map[string]interface{}) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub": subject,
+		"typ": purpose,
+		"jti": uuid.New().String(),
+		"iat": now.Unix(),
+		"exp": now.Add(ttl).Unix(),
+	}
+	for key, value := range extra {
+		if _, reserved := claims[key]; !reserved {
+			claims[key] = value
+		}
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString(purposeKey(secret, purpose))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return tokenString, nil
+}
+
+// ParsePurposeToken validates a token signed by SignPurposeToken for the given purpose
+func ParsePurposeToken(secret, purpose, tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return purposeKey(secret, purpose), nil
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	if typ, _ := claims["typ"].(string); typ != purpose {
+		return nil, fmt.Errorf("invalid token purpose")
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, fmt.Errorf("invalid token subject")
+	}
+
+	return claims, nil
+}
+
+// purposeKey derives the signing key for a purpose from the JWT secret
+func purposeKey(secret, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("purpose:" + purpose))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,332 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/crewjam/saml"
+)
+
+// Well-known attribute names used by ADFS, Azure AD and Okta when no explicit mapping is configured
+var (
+	defaultSAMLEmailAttributes = []string{
+		"email",
+		"mail",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress",
+		"urn:oid:0.9.2342.19200300.100.1.3",
+	}
+	defaultSAMLNameAttributes = []string{
+		"name",
+		"displayName",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name",
+		"urn:oid:2.16.840.1.113730.3.1.241",
+	}
+	defaultSAMLRoleAttributes = []string{
+		"role",
+		"http://schemas.microsoft.com/ws/2008/06/identity/claims/role",
+	}
+)
+
+// SAMLConfig holds configuration for the SAML 2.0 service provider
+type SAMLConfig struct {
+	EntityID       string `json:"entity_id"`        // SP entity ID, defaults to the metadata URL
+	MetadataURL    string `json:"metadata_url"`     // Public URL of the SP metadata endpoint
+	ACSURL         string `json:"acs_url"`          // Public URL of the assertion consumer service
+	IdPMetadataXML string `json:"idp_metadata_xml"` // IdP metadata document, takes precedence over the fields below
+	IdPEntityID    string `json:"idp_entity_id"`    // IdP entity ID (issuer)
+	IdPSSOURL      string `json:"idp_sso_url"`      // IdP single sign-on URL (HTTP-Redirect binding)
+	IdPCertificate string `json:"idp_certificate"`  // IdP signing certificate, PEM or base64 DER
+	SPCertificate  string `json:"sp_certificate"`   // Optional SP certificate (PEM) published in metadata
+	SPPrivateKey   string `json:"sp_private_key"`   // Optional SP private key (PEM) used to sign AuthnRequests
+
+	AttributeMapping SAMLAttributeMapping `json:"attribute_mapping"`
+}
+
+// SAMLAttributeMapping maps SAML assertion attributes to user fields
+type SAMLAttributeMapping struct {
+	Email           string   `json:"email,omitempty"`
+	Name            string   `json:"name,omitempty"`
+	Role            string   `json:"role,omitempty"`
+	AdminRoleValues []string `json:"admin_role_values,omitempty"` // Role attribute values that grant the admin role
+}
+
+// SAMLIdentity represents the identity asserted by a SAML IdP
+type SAMLIdentity struct {
+	NameID       string   `json:"name_id"`
+	Email        string   `json:"email"`
+	Name         string   `json:"name"`
+	Role         UserRole `json:"role,omitempty"` // Empty when the assertion carries no role attribute
+	SessionIndex string   `json:"session_index,omitempty"`
+}
+
+// SAMLServiceProvider implements the SP side of SAML 2.0 Web Browser SSO
+type SAMLServiceProvider struct {
+	sp      *saml.ServiceProvider
+	mapping SAMLAttributeMapping
+}
+
+// NewSAMLServiceProvider creates a new SAML service provider
+func NewSAMLServiceProvider(config SAMLConfig) (*SAMLServiceProvider, error) {
+	if config.ACSURL == "" || config.MetadataURL == "" {
+		return nil, fmt.Errorf("SAML ACS URL and metadata URL are required")
+	}
+
+	acsURL, err := url.Parse(config.ACSURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAML ACS URL: %w", err)
+	}
+	metadataURL, err := url.Parse(config.MetadataURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAML metadata URL: %w", err)
+	}
+
+	idpMetadata, err := samlIdPMetadata(config)
+	if err != nil {
+		return nil, err
+	}
+
+	sp := &saml.ServiceProvider{
+		EntityID:          config.EntityID,
+		AcsURL:            *acsURL,
+		MetadataURL:       *metadataURL,
+		IDPMetadata:       idpMetadata,
+		AuthnNameIDFormat: saml.EmailAddressNameIDFormat,
+	}
+
+	if config.SPCertificate != "" && config.SPPrivateKey != "" {
+		keyPair, err := tls.X509KeyPair([]byte(config.SPCertificate), []byte(config.SPPrivateKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SAML SP key pair: %w", err)
+		}
+		cert, err := x509.ParseCertificate(keyPair.Certificate[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid SAML SP certificate: %w", err)
+		}
+		signer, ok := keyPair.PrivateKey.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("SAML SP private key cannot sign")
+		}
+		sp.Certificate = cert
+		sp.Key = signer
+	}
+
+	return &SAMLServiceProvider{
+		sp:      sp,
+		mapping: config.AttributeMapping,
+	}, nil
+}
+
+// Metadata returns the SP metadata document
+func (s *SAMLServiceProvider) Metadata() ([]byte, error) {
+	data, err := xml.MarshalIndent(s.sp.Metadata(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal SAML metadata: %w", err)
+	}
+	return data, nil
+}
+
+// AuthnRequestURL builds an HTTP-Redirect AuthnRequest and returns the IdP URL along with the request ID
+func (s *SAMLServiceProvider) AuthnRequestURL(relayState string) (string, string, error) {
+	ssoURL := s.sp.GetSSOBindingLocation(saml.HTTPRedirectBinding)
+	if ssoURL == "" {
+		return "", "", fmt.Errorf("IdP does not support the HTTP-Redirect binding")
+	}
+
+	req, err := s.sp.MakeAuthenticationRequest(ssoURL, saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create AuthnRequest: %w", err)
+	}
+
+	redirectURL, err := req.Redirect(relayState, s.sp)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode AuthnRequest: %w", err)
+	}
+
+	return redirectURL.String(), req.ID, nil
+}
+
+// ParseResponse validates a POST binding SAMLResponse and maps the assertion to an identity.
+// Signature, issuer, audience, destination, recipient, validity window and InResponseTo are
+// all checked, so only SP-initiated logins started through AuthnRequestURL are accepted.
+func (s *SAMLServiceProvider) ParseResponse(r *http.Request, requestIDs []string) (*SAMLIdentity, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, fmt.Errorf("failed to parse SAML form: %w", err)
+	}
+
+	encoded := r.PostForm.Get("SAMLResponse")
+	if encoded == "" {
+		return nil, fmt.Errorf("SAMLResponse is missing")
+	}
+
+	rawResponse, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode SAMLResponse: %w", err)
+	}
+
+	assertion, err := s.sp.ParseXMLResponse(rawResponse, requestIDs, s.sp.AcsURL)
+	if err != nil {
+		if invalid, ok := err.(*saml.InvalidResponseError); ok {
+			return nil, fmt.Errorf("invalid SAML response: %w", invalid.PrivateErr)
+		}
+		return nil, fmt.Errorf("invalid SAML response: %w", err)
+	}
+
+	return s.mapAssertion(assertion)
+}
+
+// mapAssertion extracts email, name and role from an assertion
+func (s *SAMLServiceProvider) mapAssertion(assertion *saml.Assertion) (*SAMLIdentity, error) {
+	identity := &SAMLIdentity{}
+
+	if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		identity.NameID = assertion.Subject.NameID.Value
+	}
+	for _, statement := range assertion.AuthnStatements {
+		if statement.SessionIndex != "" {
+			identity.SessionIndex = statement.SessionIndex
+			break
+		}
+	}
+
+	identity.Email = s.attribute(assertion, s.mapping.Email, defaultSAMLEmailAttributes)
+	if identity.Email == "" && strings.Contains(identity.NameID, "@") {
+		identity.Email = identity.NameID
+	}
+	if identity.Email == "" {
+		return nil, fmt.Errorf("SAML assertion does not contain an email address")
+	}
+	identity.Email = strings.ToLower(identity.Email)
+
+	identity.Name = s.attribute(assertion, s.mapping.Name, defaultSAMLNameAttributes)
+	if identity.Name == "" {
+		identity.Name = identity.Email
+	}
+
+	if roleValue := s.attribute(assertion, s.mapping.Role, defaultSAMLRoleAttributes); roleValue != "" {
+		identity.Role = RoleUser
+		for _, adminValue := range s.mapping.AdminRoleValues {
+			if strings.EqualFold(roleValue, adminValue) {
+				identity.Role = RoleAdmin
+				break
+			}
+		}
+		if strings.EqualFold(roleValue, string(RoleGuest)) {
+			identity.Role = RoleGuest
+		}
+	}
+
+	return identity, nil
+}
+
+// attribute returns the first value of the configured attribute, falling back to well-known names
+func (s *SAMLServiceProvider) attribute(assertion *saml.Assertion, configured string, fallbacks []string) string {
+	names := fallbacks
+	if configured != "" {
+		names = []string{configured}
+	}
+
+	for _, name := range names {
+		for _, statement := range assertion.AttributeStatements {
+			for _, attr := range statement.Attributes {
+				if attr.Name != name && attr.FriendlyName != name {
+					continue
+				}
+				for _, value := range attr.Values {
+					if v := strings.TrimSpace(value.Value); v != "" {
+						return v
+					}
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// User converts the asserted identity into an auth user
+func (i *SAMLIdentity) User(userID string) *User {
+	return &User{
+		ID:       userID,
+		Email:    i.Email,
+		Name:     i.Name,
+		Role:     i.Role,
+		IsActive: true,
+	}
+}
+
+// samlIdPMetadata builds the IdP entity descriptor from raw metadata or from explicit settings
+func samlIdPMetadata(config SAMLConfig) (*saml.EntityDescriptor, error) {
+	if config.IdPMetadataXML != "" {
+		var descriptor saml.EntityDescriptor
+		if err := xml.Unmarshal([]byte(config.IdPMetadataXML), &descriptor); err != nil {
+			return nil, fmt.Errorf("failed to parse IdP metadata: %w", err)
+		}
+		return &descriptor, nil
+	}
+
+	if config.IdPEntityID == "" || config.IdPSSOURL == "" || config.IdPCertificate == "" {
+		return nil, fmt.Errorf("IdP metadata or IdP entity ID, SSO URL and certificate are required")
+	}
+
+	certData, err := samlCertificateData(config.IdPCertificate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &saml.EntityDescriptor{
+		EntityID: config.IdPEntityID,
+		IDPSSODescriptors: []saml.IDPSSODescriptor{
+			{
+				SSODescriptor: saml.SSODescriptor{
+					RoleDescriptor: saml.RoleDescriptor{
+						ProtocolSupportEnumeration: "urn:oasis:names:tc:SAML:2.0:protocol",
+						KeyDescriptors: []saml.KeyDescriptor{
+							{
+								Use: "signing",
+								KeyInfo: saml.KeyInfo{
+									X509Data: saml.X509Data{
+										X509Certificates: []saml.X509Certificate{{Data: certData}},
+									},
+								},
+							},
+						},
+					},
+				},
+				SingleSignOnServices: []saml.Endpoint{
+					{
+						Binding:  saml.HTTPRedirectBinding,
+						Location: config.IdPSSOURL,
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// samlCertificateData normalizes a PEM or base64 DER certificate to base64 DER
+func samlCertificateData(certificate string) (string, error) {
+	raw := []byte(strings.TrimSpace(certificate))
+	if block, _ := pem.Decode(raw); block != nil {
+		raw = block.Bytes
+	} else {
+		decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(raw)), ""))
+		if err != nil {
+			return "", fmt.Errorf("invalid IdP certificate encoding: %w", err)
+		}
+		raw = decoded
+	}
+
+	if _, err := x509.ParseCertificate(raw); err != nil {
+		return "", fmt.Errorf("invalid IdP certificate: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
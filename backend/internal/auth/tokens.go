@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// GenerateOpaqueToken returns a random bearer token with the given prefix
+func GenerateOpaqueToken(prefix string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex-encoded SHA-256 hash under which an opaque token is stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SessionRevoked reports whether a token issued at issuedAt was invalidated by a
+// revocation at revokedAt. Token timestamps have second precision.
+func SessionRevoked(issuedAt, revokedAt time.Time) bool {
+	if revokedAt.IsZero() {
+		return false
+	}
+	return issuedAt.Before(revokedAt.Truncate(time.Second))
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// WebAuthnCeremonyTTL is how long a user has to complete a passkey ceremony
+const WebAuthnCeremonyTTL = 5 * time.Minute
+
+// WebAuthnConfig holds the relying party settings for passkeys
+type WebAuthnConfig struct {
+	RPID          string   // Domain passkeys are scoped to, e.g. "example.com"
+	RPDisplayName string   // Name shown by the browser during ceremonies
+	RPOrigins     []string // Origins allowed to run ceremonies, e.g. "https://app.example.com"
+}
+
+// NewWebAuthn creates a WebAuthn relying party. Ceremonies prefer user verification,
+// so passkeys created here can also be used for passwordless login.
+func NewWebAuthn(config WebAuthnConfig) (*webauthn.WebAuthn, error) {
+	relyingParty, err := webauthn.New(&webauthn.Config{
+		RPID:          config.RPID,
+		RPDisplayName: config.RPDisplayName,
+		RPOrigins:     config.RPOrigins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationPreferred,
+		},
+		AttestationPreference: protocol.PreferNoAttestation,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create WebAuthn relying party: %w", err)
+	}
+	return relyingParty, nil
+}
+
+// WebAuthnUser adapts a user and their registered passkeys to webauthn.User.
+// The user handle stored on authenticators is the user ID.
+type WebAuthnUser struct {
+	ID          string
+	Email       string
+	Name        string
+	Credentials []webauthn.Credential
+}
+
+// WebAuthnID returns the user handle
+func (u *WebAuthnUser) WebAuthnID() []byte {
+	return []byte(u.ID)
+}
+
+// WebAuthnName returns the account name shown by authenticators
+func (u *WebAuthnUser) WebAuthnName() string {
+	return u.Email
+}
+
+// WebAuthnDisplayName returns the display name shown by authenticators
+func (u *WebAuthnUser) WebAuthnDisplayName() string {
+	if u.Name == "" {
+		return u.Email
+	}
+	return u.Name
+}
+
+// WebAuthnCredentials returns the user's registered passkeys
+func (u *WebAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.Credentials
+}
+
+// SignWebAuthnSession carries the state of a passkey ceremony to the client in a
+// purpose token, so no server-side session storage is needed between its two steps
+func SignWebAuthnSession(secret, purpose, subject string, session *webauthn.SessionData) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode WebAuthn session: %w", err)
+	}
+
+	return SignPurposeToken(secret, purpose, subject, WebAuthnCeremonyTTL, map[string]interface{}{
+		"session": string(data),
+	})
+}
+
+// ParseWebAuthnSession validates a token from SignWebAuthnSession and returns its
+// subject and ceremony state
+func ParseWebAuthnSession(secret, purpose, token string) (string, *webauthn.SessionData, error) {
+	claims, err := ParsePurposeToken(secret, purpose, token)
+	if err != nil {
+		return "", nil, err
+	}
+
+	data, _ := claims["session"].(string)
+	var session webauthn.SessionData
+	if err := json.Unmarshal([]byte(data), &session); err != nil {
+		return "", nil, fmt.Errorf("invalid WebAuthn session: %w", err)
+	}
+
+	return claims["sub"].(string), &session, nil
+}
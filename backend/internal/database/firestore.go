@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/firestore"
@@ -122,6 +123,123 @@ func (f *FirestoreProvider) ListCompanies(ctx context.Context, limit, offset int
 	return companies, nil
 }
 
+// SearchCompanies lists companies whose name or domain contains a query, optionally with a
+// status. Firestore has no substring queries, so companies are filtered as they are read.
+func (f *FirestoreProvider) SearchCompanies(ctx context.Context, query, status string, limit, offset int) ([]*Company, error) {
+	iter := f.client.Collection("companies").OrderBy("created_at", firestore.Desc).Documents(ctx)
+	defer iter.Stop()
+
+	query = strings.ToLower(query)
+	var companies []*Company
+	for len(companies) < limit {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var company Company
+		if err := doc.DataTo(&company); err != nil {
+			continue
+		}
+		if status != "" && company.Status != status {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(company.Name), query) && !strings.Contains(strings.ToLower(company.Domain), query) {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		companies = append(companies, &company)
+	}
+
+	return companies, nil
+}
+
+// TransferCompanyOwnership makes the pending owner of a company its owner and ensures
+// they are an admin of it. The nomination and the nominee's standing as an active admin
+// are checked and the company and the new owner's role are updated in one transaction.
+func (f *FirestoreProvider) TransferCompanyOwnership(ctx context.Context, companyID, newOwnerID string) (*Company, error) {
+	companyRef := f.client.Collection("companies").Doc(companyID)
+	userRef := f.client.Collection("users").Doc(newOwnerID)
+	// Memberships of companies other than the home company are keyed by user and company
+	membershipRef := f.client.Collection("company_memberships").Doc(newOwnerID + "_" + companyID)
+
+	var company Company
+	err := f.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		companyDoc, err := tx.Get(companyRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return fmt.Errorf("company not found")
+			}
+			return err
+		}
+		if err := companyDoc.DataTo(&company); err != nil {
+			return err
+		}
+		if company.PendingOwnerID == "" || company.PendingOwnerID != newOwnerID {
+			return fmt.Errorf("ownership transfer not pending")
+		}
+
+		userDoc, err := tx.Get(userRef)
+		if err != nil {
+			return fmt.Errorf("user not found")
+		}
+		var user User
+		if err := userDoc.DataTo(&user); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var membership CompanyMembership
+		if user.CompanyID != companyID {
+			membershipDoc, err := tx.Get(membershipRef)
+			if err != nil {
+				return fmt.Errorf("company membership not found")
+			}
+			if err := membershipDoc.DataTo(&membership); err != nil {
+				return err
+			}
+		}
+
+		// The nominee may have been demoted or deactivated since the nomination
+		role := membership.Role
+		if user.CompanyID == companyID {
+			role = user.Role
+		}
+		if !user.IsActive || role != "admin" {
+			return fmt.Errorf("nominee is no longer an active admin")
+		}
+
+		company.AdminUserID = newOwnerID
+		company.PendingOwnerID = ""
+		company.OwnershipNominatedBy = ""
+		company.OwnershipNominatedAt = time.Time{}
+		company.UpdatedAt = now
+		if err := tx.Set(companyRef, company); err != nil {
+			return err
+		}
+
+		if user.CompanyID == companyID {
+			user.Role = "admin"
+			user.UpdatedAt = now
+			return tx.Set(userRef, user)
+		}
+		membership.Role = "admin"
+		membership.UpdatedAt = now
+		return tx.Set(membershipRef, membership)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &company, nil
+}
+
 // CreateUser creates a new user
 func (f *FirestoreProvider) CreateUser(ctx context.Context, user *User) error {
 	user.CreatedAt = time.Now()
@@ -917,3 +1035,903 @@ func (f *FirestoreProvider) GetCompanyConfigurationStatus(ctx context.Context, c
 	
 	return status, nil
 }
+
+// SSO Configuration Operations
+
+// GetSSOConfig gets a company's SSO configuration
+func (f *FirestoreProvider) GetSSOConfig(ctx context.Context, companyID string) (*SSOConfig, error) {
+	doc, err := f.client.Collection("sso_configs").Doc(companyID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, fmt.Errorf("sso config not found")
+		}
+		return nil, err
+	}
+
+	var config SSOConfig
+	if err := doc.DataTo(&config); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// UpdateSSOConfig creates or replaces a company's SSO configuration
+func (f *FirestoreProvider) UpdateSSOConfig(ctx context.Context, config *SSOConfig) error {
+	now := time.Now()
+	if config.CreatedAt.IsZero() {
+		config.CreatedAt = now
+	}
+	config.UpdatedAt = now
+
+	_, err := f.client.Collection("sso_configs").Doc(config.CompanyID).Set(ctx, config)
+	return err
+}
+
+// DeleteSSOConfig deletes a company's SSO configuration
+func (f *FirestoreProvider) DeleteSSOConfig(ctx context.Context, companyID string) error {
+	_, err := f.client.Collection("sso_configs").Doc(companyID).Delete(ctx)
+	return err
+}
+
+// SCIM Token Operations
+
+// CreateSCIMToken creates a new SCIM token
+func (f *FirestoreProvider) CreateSCIMToken(ctx context.Context, token *SCIMToken) error {
+	token.CreatedAt = time.Now()
+
+	_, err := f.client.Collection("scim_tokens").Doc(token.ID).Set(ctx, token)
+	return err
+}
+
+// GetSCIMTokenByHash retrieves a SCIM token by its hash
+func (f *FirestoreProvider) GetSCIMTokenByHash(ctx context.Context, tokenHash string) (*SCIMToken, error) {
+	iter := f.client.Collection("scim_tokens").Where("token_hash", "==", tokenHash).Limit(1).Documents(ctx)
+	defer iter.Stop()
+
+	doc, err := iter.Next()
+	if err != nil {
+		if err == iterator.Done {
+			return nil, fmt.Errorf("scim token not found")
+		}
+		return nil, err
+	}
+
+	var token SCIMToken
+	if err := doc.DataTo(&token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// GetSCIMTokensByCompany retrieves all SCIM tokens for a company
+func (f *FirestoreProvider) GetSCIMTokensByCompany(ctx context.Context, companyID string) ([]*SCIMToken, error) {
+	iter := f.client.Collection("scim_tokens").Where("company_id", "==", companyID).Documents(ctx)
+	defer iter.Stop()
+
+	var tokens []*SCIMToken
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var token SCIMToken
+		if err := doc.DataTo(&token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, &token)
+	}
+
+	return tokens, nil
+}
+
+// UpdateSCIMToken updates a SCIM token
+func (f *FirestoreProvider) UpdateSCIMToken(ctx context.Context, token *SCIMToken) error {
+	_, err := f.client.Collection("scim_tokens").Doc(token.ID).Set(ctx, token)
+	return err
+}
+
+// DeleteSCIMToken deletes a SCIM token
+func (f *FirestoreProvider) DeleteSCIMToken(ctx context.Context, tokenID string) error {
+	_, err := f.client.Collection("scim_tokens").Doc(tokenID).Delete(ctx)
+	return err
+}
+
+// WebAuthn Credential Operations
+
+// CreateWebAuthnCredential stores a new passkey
+func (f *FirestoreProvider) CreateWebAuthnCredential(ctx context.Context, credential *WebAuthnCredential) error {
+	credential.CreatedAt = time.Now()
+
+	_, err := f.client.Collection("webauthn_credentials").Doc(credential.ID).Set(ctx, credential)
+	return err
+}
+
+// GetWebAuthnCredentialsByUser retrieves all passkeys registered by a user
+func (f *FirestoreProvider) GetWebAuthnCredentialsByUser(ctx context.Context, userID string) ([]*WebAuthnCredential, error) {
+	iter := f.client.Collection("webauthn_credentials").Where("user_id", "==", userID).Documents(ctx)
+	defer iter.Stop()
+
+	var credentials []*WebAuthnCredential
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var credential WebAuthnCredential
+		if err := doc.DataTo(&credential); err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, &credential)
+	}
+
+	return credentials, nil
+}
+
+// UpdateWebAuthnCredential updates a passkey
+func (f *FirestoreProvider) UpdateWebAuthnCredential(ctx context.Context, credential *WebAuthnCredential) error {
+	_, err := f.client.Collection("webauthn_credentials").Doc(credential.ID).Set(ctx, credential)
+	return err
+}
+
+// DeleteWebAuthnCredential deletes a passkey
+func (f *FirestoreProvider) DeleteWebAuthnCredential(ctx context.Context, credentialID string) error {
+	_, err := f.client.Collection("webauthn_credentials").Doc(credentialID).Delete(ctx)
+	return err
+}
+
+// Auth Token Operations
+
+// CreateAuthToken stores a new single-use token
+func (f *FirestoreProvider) CreateAuthToken(ctx context.Context, token *AuthToken) error {
+	token.CreatedAt = time.Now()
+
+	_, err := f.client.Collection("auth_tokens").Doc(token.ID).Set(ctx, token)
+	return err
+}
+
+// ConsumeAuthToken marks an unused, unexpired token as used and returns it. The check
+// and update run in a transaction so a token can only be consumed once.
+func (f *FirestoreProvider) ConsumeAuthToken(ctx context.Context, tokenHash string) (*AuthToken, error) {
+	query := f.client.Collection("auth_tokens").Where("token_hash", "==", tokenHash).Limit(1)
+
+	var token AuthToken
+	err := f.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		docs, err := tx.Documents(query).GetAll()
+		if err != nil {
+			return err
+		}
+		if len(docs) == 0 {
+			return fmt.Errorf("auth token not found")
+		}
+
+		if err := docs[0].DataTo(&token); err != nil {
+			return err
+		}
+		if !token.UsedAt.IsZero() {
+			return fmt.Errorf("auth token already used")
+		}
+		if time.Now().After(token.ExpiresAt) {
+			return fmt.Errorf("auth token expired")
+		}
+
+		token.UsedAt = time.Now()
+		return tx.Set(docs[0].Ref, token)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// Login Throttle Operations
+
+// GetLoginThrottle retrieves the failed sign-in counter for a key
+func (f *FirestoreProvider) GetLoginThrottle(ctx context.Context, throttleID string) (*LoginThrottle, error) {
+	doc, err := f.client.Collection("login_throttles").Doc(throttleID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, fmt.Errorf("login throttle not found")
+		}
+		return nil, err
+	}
+
+	var throttle LoginThrottle
+	if err := doc.DataTo(&throttle); err != nil {
+		return nil, err
+	}
+
+	return &throttle, nil
+}
+
+// RecordLoginFailure increments the failed sign-in counter for a key and returns it. The
+// counter starts over when the last failure is older than resetAfter. The read and update
+// run in a transaction so concurrent failures on different instances are all counted.
+func (f *FirestoreProvider) RecordLoginFailure(ctx context.Context, throttleID, key string, resetAfter time.Duration) (*LoginThrottle, error) {
+	ref := f.client.Collection("login_throttles").Doc(throttleID)
+
+	var throttle LoginThrottle
+	err := f.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		now := time.Now()
+		throttle = LoginThrottle{ID: throttleID, Key: key}
+
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if err := doc.DataTo(&throttle); err != nil {
+				return err
+			}
+			if now.Sub(throttle.LastFailureAt) > resetAfter {
+				throttle.Failures = 0
+			}
+		}
+
+		if throttle.Failures == 0 {
+			throttle.FirstFailureAt = now
+		}
+		throttle.Failures++
+		throttle.LastFailureAt = now
+		return tx.Set(ref, throttle)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &throttle, nil
+}
+
+// DeleteLoginThrottle clears the failed sign-in counter for a key
+func (f *FirestoreProvider) DeleteLoginThrottle(ctx context.Context, throttleID string) error {
+	_, err := f.client.Collection("login_throttles").Doc(throttleID).Delete(ctx)
+	return err
+}
+
+// Audit Event Operations
+
+// CreateAuditEvent stores an audit event
+func (f *FirestoreProvider) CreateAuditEvent(ctx context.Context, event *AuditEvent) error {
+	_, err := f.client.Collection("audit_events").Doc(event.ID).Set(ctx, event)
+	return err
+}
+
+// GetAuditEventsByCompany retrieves the most recent audit events for a company
+func (f *FirestoreProvider) GetAuditEventsByCompany(ctx context.Context, companyID string, limit int) ([]*AuditEvent, error) {
+	iter := f.client.Collection("audit_events").
+		Where("company_id", "==", companyID).
+		OrderBy("created_at", firestore.Desc).
+		Limit(limit).
+		Documents(ctx)
+	defer iter.Stop()
+
+	var events []*AuditEvent
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var event AuditEvent
+		if err := doc.DataTo(&event); err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+
+	return events, nil
+}
+
+// Service Account Operations
+
+// CreateServiceAccount creates a new service account
+func (f *FirestoreProvider) CreateServiceAccount(ctx context.Context, account *ServiceAccount) error {
+	account.CreatedAt = time.Now()
+
+	_, err := f.client.Collection("service_accounts").Doc(account.ID).Set(ctx, account)
+	return err
+}
+
+// GetServiceAccount retrieves a service account by ID
+func (f *FirestoreProvider) GetServiceAccount(ctx context.Context, accountID string) (*ServiceAccount, error) {
+	doc, err := f.client.Collection("service_accounts").Doc(accountID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, fmt.Errorf("service account not found")
+		}
+		return nil, err
+	}
+
+	var account ServiceAccount
+	if err := doc.DataTo(&account); err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+// GetServiceAccountsByCompany retrieves all service accounts of a company
+func (f *FirestoreProvider) GetServiceAccountsByCompany(ctx context.Context, companyID string) ([]*ServiceAccount, error) {
+	iter := f.client.Collection("service_accounts").Where("company_id", "==", companyID).Documents(ctx)
+	defer iter.Stop()
+
+	var accounts []*ServiceAccount
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var account ServiceAccount
+		if err := doc.DataTo(&account); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, &account)
+	}
+
+	return accounts, nil
+}
+
+// DeleteServiceAccount deletes a service account
+func (f *FirestoreProvider) DeleteServiceAccount(ctx context.Context, accountID string) error {
+	_, err := f.client.Collection("service_accounts").Doc(accountID).Delete(ctx)
+	return err
+}
+
+// API Key Operations
+
+// CreateAPIKey stores a new API key
+func (f *FirestoreProvider) CreateAPIKey(ctx context.Context, key *APIKey) error {
+	key.CreatedAt = time.Now()
+
+	_, err := f.client.Collection("api_keys").Doc(key.ID).Set(ctx, key)
+	return err
+}
+
+// GetAPIKeyByHash retrieves an API key by the hash of its value
+func (f *FirestoreProvider) GetAPIKeyByHash(ctx context.Context, keyHash string) (*APIKey, error) {
+	iter := f.client.Collection("api_keys").Where("key_hash", "==", keyHash).Limit(1).Documents(ctx)
+	defer iter.Stop()
+
+	doc, err := iter.Next()
+	if err != nil {
+		if err == iterator.Done {
+			return nil, fmt.Errorf("API key not found")
+		}
+		return nil, err
+	}
+
+	var key APIKey
+	if err := doc.DataTo(&key); err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// GetAPIKeysByServiceAccount retrieves all API keys of a service account
+func (f *FirestoreProvider) GetAPIKeysByServiceAccount(ctx context.Context, accountID string) ([]*APIKey, error) {
+	iter := f.client.Collection("api_keys").Where("service_account_id", "==", accountID).Documents(ctx)
+	defer iter.Stop()
+
+	var keys []*APIKey
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var key APIKey
+		if err := doc.DataTo(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, &key)
+	}
+
+	return keys, nil
+}
+
+// UpdateAPIKey updates an API key
+func (f *FirestoreProvider) UpdateAPIKey(ctx context.Context, key *APIKey) error {
+	_, err := f.client.Collection("api_keys").Doc(key.ID).Set(ctx, key)
+	return err
+}
+
+// DeleteAPIKey deletes an API key
+func (f *FirestoreProvider) DeleteAPIKey(ctx context.Context, keyID string) error {
+	_, err := f.client.Collection("api_keys").Doc(keyID).Delete(ctx)
+	return err
+}
+
+// User Identity Operations
+
+// CreateUserIdentity links a provider identity to a user
+func (f *FirestoreProvider) CreateUserIdentity(ctx context.Context, identity *UserIdentity) error {
+	identity.CreatedAt = time.Now()
+
+	_, err := f.client.Collection("user_identities").Doc(identity.ID).Set(ctx, identity)
+	return err
+}
+
+// GetUserIdentity retrieves a user identity by ID
+func (f *FirestoreProvider) GetUserIdentity(ctx context.Context, identityID string) (*UserIdentity, error) {
+	doc, err := f.client.Collection("user_identities").Doc(identityID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, fmt.Errorf("user identity not found")
+		}
+		return nil, err
+	}
+
+	var identity UserIdentity
+	if err := doc.DataTo(&identity); err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+// GetUserIdentitiesByUser retrieves all identities linked to a user
+func (f *FirestoreProvider) GetUserIdentitiesByUser(ctx context.Context, userID string) ([]*UserIdentity, error) {
+	iter := f.client.Collection("user_identities").Where("user_id", "==", userID).Documents(ctx)
+	defer iter.Stop()
+
+	var identities []*UserIdentity
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var identity UserIdentity
+		if err := doc.DataTo(&identity); err != nil {
+			return nil, err
+		}
+		identities = append(identities, &identity)
+	}
+
+	return identities, nil
+}
+
+// UpdateUserIdentity updates a user identity
+func (f *FirestoreProvider) UpdateUserIdentity(ctx context.Context, identity *UserIdentity) error {
+	_, err := f.client.Collection("user_identities").Doc(identity.ID).Set(ctx, identity)
+	return err
+}
+
+// DeleteUserIdentity unlinks a provider identity
+func (f *FirestoreProvider) DeleteUserIdentity(ctx context.Context, identityID string) error {
+	_, err := f.client.Collection("user_identities").Doc(identityID).Delete(ctx)
+	return err
+}
+
+// Company Membership Operations
+
+// CreateCompanyMembership adds a user to a company
+func (f *FirestoreProvider) CreateCompanyMembership(ctx context.Context, membership *CompanyMembership) error {
+	membership.CreatedAt = time.Now()
+	membership.UpdatedAt = time.Now()
+
+	_, err := f.client.Collection("company_memberships").Doc(membership.ID).Set(ctx, membership)
+	return err
+}
+
+// GetCompanyMembership retrieves a company membership by ID
+func (f *FirestoreProvider) GetCompanyMembership(ctx context.Context, membershipID string) (*CompanyMembership, error) {
+	doc, err := f.client.Collection("company_memberships").Doc(membershipID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, fmt.Errorf("company membership not found")
+		}
+		return nil, err
+	}
+
+	var membership CompanyMembership
+	if err := doc.DataTo(&membership); err != nil {
+		return nil, err
+	}
+
+	return &membership, nil
+}
+
+// GetCompanyMembershipsByUser retrieves the company memberships of a user
+func (f *FirestoreProvider) GetCompanyMembershipsByUser(ctx context.Context, userID string) ([]*CompanyMembership, error) {
+	iter := f.client.Collection("company_memberships").Where("user_id", "==", userID).Documents(ctx)
+	defer iter.Stop()
+
+	var memberships []*CompanyMembership
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var membership CompanyMembership
+		if err := doc.DataTo(&membership); err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, &membership)
+	}
+
+	return memberships, nil
+}
+
+// GetCompanyMembershipsByCompany retrieves the memberships of users in a company
+func (f *FirestoreProvider) GetCompanyMembershipsByCompany(ctx context.Context, companyID string) ([]*CompanyMembership, error) {
+	iter := f.client.Collection("company_memberships").Where("company_id", "==", companyID).Documents(ctx)
+	defer iter.Stop()
+
+	var memberships []*CompanyMembership
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var membership CompanyMembership
+		if err := doc.DataTo(&membership); err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, &membership)
+	}
+
+	return memberships, nil
+}
+
+// UpdateCompanyMembership updates a company membership
+func (f *FirestoreProvider) UpdateCompanyMembership(ctx context.Context, membership *CompanyMembership) error {
+	membership.UpdatedAt = time.Now()
+
+	_, err := f.client.Collection("company_memberships").Doc(membership.ID).Set(ctx, membership)
+	return err
+}
+
+// DeleteCompanyMembership removes a user from a company
+func (f *FirestoreProvider) DeleteCompanyMembership(ctx context.Context, membershipID string) error {
+	_, err := f.client.Collection("company_memberships").Doc(membershipID).Delete(ctx)
+	return err
+}
+
+// GetInvitationsByEmail retrieves all invitations sent to an email address
+func (f *FirestoreProvider) GetInvitationsByEmail(ctx context.Context, email string) ([]*Invitation, error) {
+	iter := f.client.Collection("invitations").Where("email", "==", email).Documents(ctx)
+	defer iter.Stop()
+
+	var invitations []*Invitation
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var invitation Invitation
+		if err := doc.DataTo(&invitation); err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, &invitation)
+	}
+
+	return invitations, nil
+}
+
+// Custom Role Operations
+
+// CreateCustomRole creates a company-defined role
+func (f *FirestoreProvider) CreateCustomRole(ctx context.Context, role *CustomRole) error {
+	role.CreatedAt = time.Now()
+	role.UpdatedAt = time.Now()
+
+	_, err := f.client.Collection("custom_roles").Doc(role.ID).Set(ctx, role)
+	return err
+}
+
+// GetCustomRole retrieves a custom role by ID
+func (f *FirestoreProvider) GetCustomRole(ctx context.Context, roleID string) (*CustomRole, error) {
+	doc, err := f.client.Collection("custom_roles").Doc(roleID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, fmt.Errorf("custom role not found")
+		}
+		return nil, err
+	}
+
+	var role CustomRole
+	if err := doc.DataTo(&role); err != nil {
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+// GetCustomRolesByCompany retrieves the custom roles of a company
+func (f *FirestoreProvider) GetCustomRolesByCompany(ctx context.Context, companyID string) ([]*CustomRole, error) {
+	iter := f.client.Collection("custom_roles").Where("company_id", "==", companyID).Documents(ctx)
+	defer iter.Stop()
+
+	var roles []*CustomRole
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var role CustomRole
+		if err := doc.DataTo(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, &role)
+	}
+
+	return roles, nil
+}
+
+// UpdateCustomRole updates a custom role
+func (f *FirestoreProvider) UpdateCustomRole(ctx context.Context, role *CustomRole) error {
+	role.UpdatedAt = time.Now()
+
+	_, err := f.client.Collection("custom_roles").Doc(role.ID).Set(ctx, role)
+	return err
+}
+
+// DeleteCustomRole deletes a custom role
+func (f *FirestoreProvider) DeleteCustomRole(ctx context.Context, roleID string) error {
+	_, err := f.client.Collection("custom_roles").Doc(roleID).Delete(ctx)
+	return err
+}
+
+// Group Operations
+
+// CreateGroup creates a company group
+func (f *FirestoreProvider) CreateGroup(ctx context.Context, group *Group) error {
+	group.CreatedAt = time.Now()
+	group.UpdatedAt = time.Now()
+
+	_, err := f.client.Collection("groups").Doc(group.ID).Set(ctx, group)
+	return err
+}
+
+// GetGroup retrieves a group by ID
+func (f *FirestoreProvider) GetGroup(ctx context.Context, groupID string) (*Group, error) {
+	doc, err := f.client.Collection("groups").Doc(groupID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, fmt.Errorf("group not found")
+		}
+		return nil, err
+	}
+
+	var group Group
+	if err := doc.DataTo(&group); err != nil {
+		return nil, err
+	}
+
+	return &group, nil
+}
+
+// GetGroupsByCompany retrieves the groups of a company
+func (f *FirestoreProvider) GetGroupsByCompany(ctx context.Context, companyID string) ([]*Group, error) {
+	iter := f.client.Collection("groups").Where("company_id", "==", companyID).Documents(ctx)
+	defer iter.Stop()
+
+	var groups []*Group
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var group Group
+		if err := doc.DataTo(&group); err != nil {
+			return nil, err
+		}
+		groups = append(groups, &group)
+	}
+
+	return groups, nil
+}
+
+// UpdateGroup updates a group and its members
+func (f *FirestoreProvider) UpdateGroup(ctx context.Context, group *Group) error {
+	group.UpdatedAt = time.Now()
+
+	_, err := f.client.Collection("groups").Doc(group.ID).Set(ctx, group)
+	return err
+}
+
+// DeleteGroup deletes a group
+func (f *FirestoreProvider) DeleteGroup(ctx context.Context, groupID string) error {
+	_, err := f.client.Collection("groups").Doc(groupID).Delete(ctx)
+	return err
+}
+
+// Delegated Admin Operations
+
+// CreateDelegatedAdmin creates a delegated admin assignment
+func (f *FirestoreProvider) CreateDelegatedAdmin(ctx context.Context, delegation *DelegatedAdmin) error {
+	delegation.CreatedAt = time.Now()
+	delegation.UpdatedAt = time.Now()
+
+	_, err := f.client.Collection("delegated_admins").Doc(delegation.ID).Set(ctx, delegation)
+	return err
+}
+
+// GetDelegatedAdmin retrieves a delegated admin assignment by ID
+func (f *FirestoreProvider) GetDelegatedAdmin(ctx context.Context, delegationID string) (*DelegatedAdmin, error) {
+	doc, err := f.client.Collection("delegated_admins").Doc(delegationID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, fmt.Errorf("delegated admin assignment not found")
+		}
+		return nil, err
+	}
+
+	var delegation DelegatedAdmin
+	if err := doc.DataTo(&delegation); err != nil {
+		return nil, err
+	}
+
+	return &delegation, nil
+}
+
+// GetDelegatedAdminsByCompany retrieves the delegated admin assignments of a company
+func (f *FirestoreProvider) GetDelegatedAdminsByCompany(ctx context.Context, companyID string) ([]*DelegatedAdmin, error) {
+	iter := f.client.Collection("delegated_admins").Where("company_id", "==", companyID).Documents(ctx)
+	defer iter.Stop()
+
+	var delegations []*DelegatedAdmin
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var delegation DelegatedAdmin
+		if err := doc.DataTo(&delegation); err != nil {
+			return nil, err
+		}
+		delegations = append(delegations, &delegation)
+	}
+
+	return delegations, nil
+}
+
+// GetDelegatedAdminsByUser retrieves the delegated admin assignments held by a user
+func (f *FirestoreProvider) GetDelegatedAdminsByUser(ctx context.Context, userID string) ([]*DelegatedAdmin, error) {
+	iter := f.client.Collection("delegated_admins").Where("user_id", "==", userID).Documents(ctx)
+	defer iter.Stop()
+
+	var delegations []*DelegatedAdmin
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var delegation DelegatedAdmin
+		if err := doc.DataTo(&delegation); err != nil {
+			return nil, err
+		}
+		delegations = append(delegations, &delegation)
+	}
+
+	return delegations, nil
+}
+
+// UpdateDelegatedAdmin updates a delegated admin assignment
+func (f *FirestoreProvider) UpdateDelegatedAdmin(ctx context.Context, delegation *DelegatedAdmin) error {
+	delegation.UpdatedAt = time.Now()
+
+	_, err := f.client.Collection("delegated_admins").Doc(delegation.ID).Set(ctx, delegation)
+	return err
+}
+
+// DeleteDelegatedAdmin deletes a delegated admin assignment
+func (f *FirestoreProvider) DeleteDelegatedAdmin(ctx context.Context, delegationID string) error {
+	_, err := f.client.Collection("delegated_admins").Doc(delegationID).Delete(ctx)
+	return err
+}
+
+// Change Request Operations
+
+// CreateChangeRequest creates a change request
+func (f *FirestoreProvider) CreateChangeRequest(ctx context.Context, request *ChangeRequest) error {
+	request.CreatedAt = time.Now()
+	request.UpdatedAt = time.Now()
+
+	_, err := f.client.Collection("change_requests").Doc(request.ID).Set(ctx, request)
+	return err
+}
+
+// GetChangeRequest retrieves a change request by ID
+func (f *FirestoreProvider) GetChangeRequest(ctx context.Context, requestID string) (*ChangeRequest, error) {
+	doc, err := f.client.Collection("change_requests").Doc(requestID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, fmt.Errorf("change request not found")
+		}
+		return nil, err
+	}
+
+	var request ChangeRequest
+	if err := doc.DataTo(&request); err != nil {
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+// GetChangeRequestsByCompany retrieves the change requests of a company
+func (f *FirestoreProvider) GetChangeRequestsByCompany(ctx context.Context, companyID string) ([]*ChangeRequest, error) {
+	iter := f.client.Collection("change_requests").Where("company_id", "==", companyID).Documents(ctx)
+	defer iter.Stop()
+
+	var requests []*ChangeRequest
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var request ChangeRequest
+		if err := doc.DataTo(&request); err != nil {
+			return nil, err
+		}
+		requests = append(requests, &request)
+	}
+
+	return requests, nil
+}
+
+// UpdateChangeRequest updates a change request
+func (f *FirestoreProvider) UpdateChangeRequest(ctx context.Context, request *ChangeRequest) error {
+	request.UpdatedAt = time.Now()
+
+	_, err := f.client.Collection("change_requests").Doc(request.ID).Set(ctx, request)
+	return err
+}
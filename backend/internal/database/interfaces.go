@@ -16,6 +16,12 @@ type Company struct {
 	SubscriptionID  string    `json:"subscription_id,omitempty"`
 	Status          string    `json:"status"` // "active", "trial", "suspended", "cancelled"
 	TrialEndsAt     time.Time `json:"trial_ends_at,omitempty"`
+	SuspendedAt     time.Time `json:"suspended_at,omitempty"`
+	SuspensionReason string `json:"suspension_reason,omitempty"`
+	// Ownership transfer awaiting acceptance by the nominated admin
+	PendingOwnerID       string    `json:"pending_owner_id,omitempty"`
+	OwnershipNominatedBy string    `json:"ownership_nominated_by,omitempty"`
+	OwnershipNominatedAt time.Time `json:"ownership_nominated_at,omitempty"`
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 	OnboardedAt     time.Time `json:"onboarded_at,omitempty"`
@@ -32,6 +38,23 @@ type Company struct {
 	SubscriptionActive        bool `json:"subscription_active"`
 	UsersInvited              bool `json:"users_invited"`
 	DownloadReady             bool `json:"download_ready"`
+	// Security settings
+	MFARequirement string `json:"mfa_requirement,omitempty"` // "none", "admins", "all"
+	JITDefaultRole string `json:"jit_default_role,omitempty"` // Role of users provisioned on first Google sign-in, defaults to "user"
+	EntraTenantID  string `json:"entra_tenant_id,omitempty"`  // Entra ID tenant Microsoft sign-ins are restricted to, if set
+	PasswordPolicy PasswordPolicy `json:"password_policy"`
+}
+
+// PasswordPolicy holds a company's password requirements. Zero values fall back to the defaults.
+type PasswordPolicy struct {
+	MinLength        int  `json:"min_length"`
+	RequireUppercase bool `json:"require_uppercase"`
+	RequireLowercase bool `json:"require_lowercase"`
+	RequireDigit     bool `json:"require_digit"`
+	RequireSymbol    bool `json:"require_symbol"`
+	HistoryCount     int  `json:"history_count"`  // Number of previous passwords that cannot be reused
+	MaxAgeDays       int  `json:"max_age_days"`   // 0 means passwords never expire
+	BlockBreached    bool `json:"block_breached"` // Reject passwords found in the breached-password list
 }
 
 // User represents a user in the database
@@ -52,6 +75,22 @@ type User struct {
 	InvitationStatus string    `json:"invitation_status"` // "invited", "active", "pending"
 	InvitedAt        time.Time `json:"invited_at,omitempty"`
 	ActivatedAt      time.Time `json:"activated_at,omitempty"`
+	// Email verification fields
+	EmailVerificationPending bool      `json:"email_verification_pending"` // Set for self-registered accounts until the address is verified
+	EmailVerifiedAt          time.Time `json:"email_verified_at,omitempty"`
+	// Provisioning and session fields
+	ExternalID        string    `json:"external_id,omitempty"`         // Identifier assigned by the customer's IdP (SCIM externalId)
+	SessionsRevokedAt time.Time `json:"sessions_revoked_at,omitempty"` // Tokens issued before this time are rejected
+	// Multi-factor authentication fields
+	MFAEnabled         bool      `json:"mfa_enabled"`
+	MFAEnabledAt       time.Time `json:"mfa_enabled_at,omitempty"`
+	TOTPSecret         string    `json:"-"`
+	TOTPPendingSecret  string    `json:"-"` // Secret awaiting confirmation during enrollment
+	TOTPLastUsedStep   int64     `json:"-"` // Last accepted time step, prevents code reuse
+	RecoveryCodeHashes []string  `json:"-"`
+	// Password fields
+	PasswordHistory   []string  `json:"-"` // bcrypt hashes of the most recent passwords, newest first
+	PasswordChangedAt time.Time `json:"password_changed_at,omitempty"`
 }
 
 // Invitation represents a user invitation
@@ -121,6 +160,194 @@ type CompanySetupProgress struct {
 	LastUpdated                 time.Time `json:"last_updated"`
 }
 
+// SSOConfig represents a company's single sign-on configuration
+type SSOConfig struct {
+	CompanyID        string            `json:"company_id" firestore:"company_id"`
+	Protocol         string            `json:"protocol" firestore:"protocol"` // "oidc", "saml"
+	Enabled          bool              `json:"enabled" firestore:"enabled"`
+	Enforced         bool              `json:"enforced" firestore:"enforced"` // Password login is disabled for the company's users
+	OIDCIssuer       string            `json:"oidc_issuer,omitempty" firestore:"oidc_issuer"`
+	OIDCClientID     string            `json:"oidc_client_id,omitempty" firestore:"oidc_client_id"`
+	OIDCClientSecret string            `json:"oidc_client_secret,omitempty" firestore:"oidc_client_secret"`
+	SAMLMetadataXML  string            `json:"saml_metadata_xml,omitempty" firestore:"saml_metadata_xml"`
+	SAMLEntityID     string            `json:"saml_entity_id,omitempty" firestore:"saml_entity_id"`
+	SAMLSSOURL       string            `json:"saml_sso_url,omitempty" firestore:"saml_sso_url"`
+	SAMLCertificate  string            `json:"saml_certificate,omitempty" firestore:"saml_certificate"`
+	AttributeMapping map[string]string `json:"attribute_mapping,omitempty" firestore:"attribute_mapping"` // "email", "name", "role", "admin_role_values"
+	CreatedAt        time.Time         `json:"created_at" firestore:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at" firestore:"updated_at"`
+}
+
+// SCIMToken represents a per-company bearer token used by an IdP for SCIM provisioning
+type SCIMToken struct {
+	ID          string    `json:"id" firestore:"id"`
+	CompanyID   string    `json:"company_id" firestore:"company_id"`
+	Name        string    `json:"name" firestore:"name"`
+	TokenHash   string    `json:"-" firestore:"token_hash"` // SHA-256 of the token, the token itself is never stored
+	TokenPrefix string    `json:"token_prefix" firestore:"token_prefix"`
+	CreatedBy   string    `json:"created_by" firestore:"created_by"`
+	CreatedAt   time.Time `json:"created_at" firestore:"created_at"`
+	LastUsedAt  time.Time `json:"last_used_at,omitempty" firestore:"last_used_at"`
+}
+
+// WebAuthnCredential represents a passkey registered by a user
+type WebAuthnCredential struct {
+	ID              string    `json:"id" firestore:"id"`
+	UserID          string    `json:"user_id" firestore:"user_id"`
+	CredentialID    string    `json:"credential_id" firestore:"credential_id"` // Base64url-encoded credential ID
+	PublicKey       []byte    `json:"-" firestore:"public_key"`                // COSE-encoded public key
+	AttestationType string    `json:"attestation_type" firestore:"attestation_type"`
+	AAGUID          []byte    `json:"-" firestore:"aaguid"`
+	Transports      []string  `json:"transports,omitempty" firestore:"transports"`
+	SignCount       int64     `json:"sign_count" firestore:"sign_count"`
+	BackupEligible  bool      `json:"backup_eligible" firestore:"backup_eligible"`
+	BackupState     bool      `json:"backup_state" firestore:"backup_state"`
+	Name            string    `json:"name" firestore:"name"`
+	CreatedAt       time.Time `json:"created_at" firestore:"created_at"`
+	LastUsedAt      time.Time `json:"last_used_at,omitempty" firestore:"last_used_at"`
+}
+
+// AuthToken represents a single-use token emailed to a user, such as a magic link.
+// Only a hash of the token is stored.
+type AuthToken struct {
+	ID        string    `json:"id" firestore:"id"`
+	UserID    string    `json:"user_id" firestore:"user_id"`
+	Purpose   string    `json:"purpose" firestore:"purpose"`
+	TokenHash string    `json:"-" firestore:"token_hash"`
+	ExpiresAt time.Time `json:"expires_at" firestore:"expires_at"`
+	UsedAt    time.Time `json:"used_at,omitempty" firestore:"used_at"`
+	CreatedAt time.Time `json:"created_at" firestore:"created_at"`
+}
+
+// LoginThrottle tracks recent failed sign-in attempts for an account or IP address
+type LoginThrottle struct {
+	ID             string    `json:"id" firestore:"id"`   // SHA-256 of the key
+	Key            string    `json:"key" firestore:"key"` // "account:<email>", "ip:<address>" or "mfa_challenge:<jti>"
+	Failures       int       `json:"failures" firestore:"failures"`
+	FirstFailureAt time.Time `json:"first_failure_at" firestore:"first_failure_at"`
+	LastFailureAt  time.Time `json:"last_failure_at" firestore:"last_failure_at"`
+}
+
+// AuditEvent records a security-relevant action
+type AuditEvent struct {
+	ID         string                 `json:"id" firestore:"id"`
+	CompanyID  string                 `json:"company_id,omitempty" firestore:"company_id"`
+	Action     string                 `json:"action" firestore:"action"` // e.g. "login.account_locked"
+	ActorID    string                 `json:"actor_id,omitempty" firestore:"actor_id"`
+	ActorEmail string                 `json:"actor_email,omitempty" firestore:"actor_email"`
+	TargetType string                 `json:"target_type,omitempty" firestore:"target_type"`
+	TargetID   string                 `json:"target_id,omitempty" firestore:"target_id"`
+	IPAddress  string                 `json:"ip_address,omitempty" firestore:"ip_address"`
+	Details    map[string]interface{} `json:"details,omitempty" firestore:"details"`
+	CreatedAt  time.Time              `json:"created_at" firestore:"created_at"`
+}
+
+// ServiceAccount represents a non-human company identity used for automation
+type ServiceAccount struct {
+	ID          string    `json:"id" firestore:"id"`
+	CompanyID   string    `json:"company_id" firestore:"company_id"`
+	Name        string    `json:"name" firestore:"name"`
+	Description string    `json:"description,omitempty" firestore:"description"`
+	CreatedBy   string    `json:"created_by" firestore:"created_by"`
+	CreatedAt   time.Time `json:"created_at" firestore:"created_at"`
+}
+
+// APIKey represents a scoped bearer key of a service account
+type APIKey struct {
+	ID               string    `json:"id" firestore:"id"`
+	CompanyID        string    `json:"company_id" firestore:"company_id"`
+	ServiceAccountID string    `json:"service_account_id" firestore:"service_account_id"`
+	Name             string    `json:"name" firestore:"name"`
+	KeyHash          string    `json:"-" firestore:"key_hash"` // SHA-256 of the key, the key itself is never stored
+	KeyPrefix        string    `json:"key_prefix" firestore:"key_prefix"`
+	Scopes           []string  `json:"scopes" firestore:"scopes"`
+	ExpiresAt        time.Time `json:"expires_at,omitempty" firestore:"expires_at"` // Zero means the key does not expire
+	CreatedBy        string    `json:"created_by" firestore:"created_by"`
+	CreatedAt        time.Time `json:"created_at" firestore:"created_at"`
+	LastUsedAt       time.Time `json:"last_used_at,omitempty" firestore:"last_used_at"`
+}
+
+// UserIdentity links an account at an auth provider connection to a user
+type UserIdentity struct {
+	ID          string    `json:"id" firestore:"id"` // Hash of connection and subject, so each identity links to one user
+	UserID      string    `json:"user_id" firestore:"user_id"`
+	Connection  string    `json:"connection" firestore:"connection"`
+	Subject     string    `json:"subject" firestore:"subject"` // User ID at the provider
+	Email       string    `json:"email,omitempty" firestore:"email"`
+	CreatedAt   time.Time `json:"created_at" firestore:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at,omitempty" firestore:"last_login_at"`
+}
+
+// CompanyMembership grants a user a role in a company other than their home company.
+// The home company membership is the user's own CompanyID and Role.
+type CompanyMembership struct {
+	ID        string    `json:"id" firestore:"id"` // User ID and company ID joined by an underscore
+	UserID    string    `json:"user_id" firestore:"user_id"`
+	CompanyID string    `json:"company_id" firestore:"company_id"`
+	Role      string    `json:"role" firestore:"role"`
+	Home      bool      `json:"home" firestore:"-"` // Set for the membership derived from the user's home company
+	CreatedAt time.Time `json:"created_at" firestore:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" firestore:"updated_at"`
+}
+
+// CustomRole is a company-defined role granting a set of permissions. Users holding it
+// store the role's ID as their role.
+type CustomRole struct {
+	ID          string    `json:"id" firestore:"id"`
+	CompanyID   string    `json:"company_id" firestore:"company_id"`
+	Name        string    `json:"name" firestore:"name"`
+	Description string    `json:"description,omitempty" firestore:"description"`
+	Permissions []string  `json:"permissions" firestore:"permissions"`
+	CreatedAt   time.Time `json:"created_at" firestore:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" firestore:"updated_at"`
+}
+
+// Group is a company-scoped group of users. Groups can contain other groups; members of
+// a nested group are effective members of every group containing it.
+type Group struct {
+	ID          string    `json:"id" firestore:"id"`
+	CompanyID   string    `json:"company_id" firestore:"company_id"`
+	Name        string    `json:"name" firestore:"name"`
+	Description string    `json:"description,omitempty" firestore:"description"`
+	UserIDs     []string  `json:"user_ids" firestore:"user_ids"`   // Direct user members
+	GroupIDs    []string  `json:"group_ids" firestore:"group_ids"` // Nested groups
+	CreatedAt   time.Time `json:"created_at" firestore:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" firestore:"updated_at"`
+}
+
+// DelegatedAdmin grants a user the user-management permissions of a role over all users
+// of a company or over an explicit list of users and groups only
+type DelegatedAdmin struct {
+	ID        string    `json:"id" firestore:"id"`
+	CompanyID string    `json:"company_id" firestore:"company_id"`
+	UserID    string    `json:"user_id" firestore:"user_id"` // The delegated admin
+	Role      string    `json:"role" firestore:"role"`       // Built-in role or custom role ID granting the permissions
+	AllUsers  bool      `json:"all_users" firestore:"all_users"`
+	UserIDs   []string  `json:"user_ids" firestore:"user_ids"`
+	GroupIDs  []string  `json:"group_ids" firestore:"group_ids"`
+	CreatedBy string    `json:"created_by" firestore:"created_by"`
+	CreatedAt time.Time `json:"created_at" firestore:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" firestore:"updated_at"`
+}
+
+// ChangeRequest is a destructive company change awaiting approval by a second admin or
+// an emailed confirmation. The change is only made once the request is approved.
+type ChangeRequest struct {
+	ID               string    `json:"id" firestore:"id"`
+	CompanyID        string    `json:"company_id" firestore:"company_id"`
+	Action           string    `json:"action" firestore:"action"`                             // "company.delete" or "company.domain_change"
+	NewDomain        string    `json:"new_domain,omitempty" firestore:"new_domain,omitempty"` // Set for domain changes
+	Status           string    `json:"status" firestore:"status"`                             // "pending", "approved", "rejected" or "expired"
+	RequestedBy      string    `json:"requested_by" firestore:"requested_by"`
+	RequestedByEmail string    `json:"requested_by_email" firestore:"requested_by_email"`
+	DecidedBy        string    `json:"decided_by,omitempty" firestore:"decided_by,omitempty"`
+	DecidedAt        time.Time `json:"decided_at,omitempty" firestore:"decided_at,omitempty"`
+	ConfirmationHash string    `json:"-" firestore:"confirmation_hash"` // Hash of the emailed confirmation token
+	ExpiresAt        time.Time `json:"expires_at" firestore:"expires_at"`
+	CreatedAt        time.Time `json:"created_at" firestore:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at" firestore:"updated_at"`
+}
+
 // DatabaseProvider defines the interface for database providers
 type DatabaseProvider interface {
 	// Company operations
@@ -130,6 +357,8 @@ type DatabaseProvider interface {
 	UpdateCompany(ctx context.Context, company *Company) error
 	DeleteCompany(ctx context.Context, companyID string) error
 	ListCompanies(ctx context.Context, limit, offset int) ([]*Company, error)
+	SearchCompanies(ctx context.Context, query, status string, limit, offset int) ([]*Company, error)
+	TransferCompanyOwnership(ctx context.Context, companyID, newOwnerID string) (*Company, error)
 	
 	// User operations
 	CreateUser(ctx context.Context, user *User) error
@@ -196,6 +425,96 @@ type DatabaseProvider interface {
 	UpdateCompanyConfigurationStatus(ctx context.Context, companyID string, feature string, status bool) error
 	GetCompanyConfigurationStatus(ctx context.Context, companyID string) (map[string]bool, error)
 	
+	// SSO configuration operations
+	GetSSOConfig(ctx context.Context, companyID string) (*SSOConfig, error)
+	UpdateSSOConfig(ctx context.Context, config *SSOConfig) error
+	DeleteSSOConfig(ctx context.Context, companyID string) error
+	
+	// SCIM token operations
+	CreateSCIMToken(ctx context.Context, token *SCIMToken) error
+	GetSCIMTokenByHash(ctx context.Context, tokenHash string) (*SCIMToken, error)
+	GetSCIMTokensByCompany(ctx context.Context, companyID string) ([]*SCIMToken, error)
+	UpdateSCIMToken(ctx context.Context, token *SCIMToken) error
+	DeleteSCIMToken(ctx context.Context, tokenID string) error
+	
+	// WebAuthn credential operations
+	CreateWebAuthnCredential(ctx context.Context, credential *WebAuthnCredential) error
+	GetWebAuthnCredentialsByUser(ctx context.Context, userID string) ([]*WebAuthnCredential, error)
+	UpdateWebAuthnCredential(ctx context.Context, credential *WebAuthnCredential) error
+	DeleteWebAuthnCredential(ctx context.Context, credentialID string) error
+	
+	// Auth token operations
+	CreateAuthToken(ctx context.Context, token *AuthToken) error
+	ConsumeAuthToken(ctx context.Context, tokenHash string) (*AuthToken, error)
+	
+	// Login throttle operations
+	GetLoginThrottle(ctx context.Context, throttleID string) (*LoginThrottle, error)
+	RecordLoginFailure(ctx context.Context, throttleID, key string, resetAfter time.Duration) (*LoginThrottle, error)
+	DeleteLoginThrottle(ctx context.Context, throttleID string) error
+	
+	// Audit event operations
+	CreateAuditEvent(ctx context.Context, event *AuditEvent) error
+	GetAuditEventsByCompany(ctx context.Context, companyID string, limit int) ([]*AuditEvent, error)
+	
+	// Service account operations
+	CreateServiceAccount(ctx context.Context, account *ServiceAccount) error
+	GetServiceAccount(ctx context.Context, accountID string) (*ServiceAccount, error)
+	GetServiceAccountsByCompany(ctx context.Context, companyID string) ([]*ServiceAccount, error)
+	DeleteServiceAccount(ctx context.Context, accountID string) error
+	
+	// API key operations
+	CreateAPIKey(ctx context.Context, key *APIKey) error
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*APIKey, error)
+	GetAPIKeysByServiceAccount(ctx context.Context, accountID string) ([]*APIKey, error)
+	UpdateAPIKey(ctx context.Context, key *APIKey) error
+	DeleteAPIKey(ctx context.Context, keyID string) error
+	
+	// User identity operations
+	CreateUserIdentity(ctx context.Context, identity *UserIdentity) error
+	GetUserIdentity(ctx context.Context, identityID string) (*UserIdentity, error)
+	GetUserIdentitiesByUser(ctx context.Context, userID string) ([]*UserIdentity, error)
+	UpdateUserIdentity(ctx context.Context, identity *UserIdentity) error
+	DeleteUserIdentity(ctx context.Context, identityID string) error
+	
+	// Company membership operations
+	CreateCompanyMembership(ctx context.Context, membership *CompanyMembership) error
+	GetCompanyMembership(ctx context.Context, membershipID string) (*CompanyMembership, error)
+	GetCompanyMembershipsByUser(ctx context.Context, userID string) ([]*CompanyMembership, error)
+	GetCompanyMembershipsByCompany(ctx context.Context, companyID string) ([]*CompanyMembership, error)
+	UpdateCompanyMembership(ctx context.Context, membership *CompanyMembership) error
+	DeleteCompanyMembership(ctx context.Context, membershipID string) error
+	
+	// Invitation lookup by invitee
+	GetInvitationsByEmail(ctx context.Context, email string) ([]*Invitation, error)
+	
+	// Custom role operations
+	CreateCustomRole(ctx context.Context, role *CustomRole) error
+	GetCustomRole(ctx context.Context, roleID string) (*CustomRole, error)
+	GetCustomRolesByCompany(ctx context.Context, companyID string) ([]*CustomRole, error)
+	UpdateCustomRole(ctx context.Context, role *CustomRole) error
+	DeleteCustomRole(ctx context.Context, roleID string) error
+	
+	// Group operations
+	CreateGroup(ctx context.Context, group *Group) error
+	GetGroup(ctx context.Context, groupID string) (*Group, error)
+	GetGroupsByCompany(ctx context.Context, companyID string) ([]*Group, error)
+	UpdateGroup(ctx context.Context, group *Group) error
+	DeleteGroup(ctx context.Context, groupID string) error
+	
+	// Delegated admin operations
+	CreateDelegatedAdmin(ctx context.Context, delegation *DelegatedAdmin) error
+	GetDelegatedAdmin(ctx context.Context, delegationID string) (*DelegatedAdmin, error)
+	GetDelegatedAdminsByCompany(ctx context.Context, companyID string) ([]*DelegatedAdmin, error)
+	GetDelegatedAdminsByUser(ctx context.Context, userID string) ([]*DelegatedAdmin, error)
+	UpdateDelegatedAdmin(ctx context.Context, delegation *DelegatedAdmin) error
+	DeleteDelegatedAdmin(ctx context.Context, delegationID string) error
+	
+	// Change request operations
+	CreateChangeRequest(ctx context.Context, request *ChangeRequest) error
+	GetChangeRequest(ctx context.Context, requestID string) (*ChangeRequest, error)
+	GetChangeRequestsByCompany(ctx context.Context, companyID string) ([]*ChangeRequest, error)
+	UpdateChangeRequest(ctx context.Context, request *ChangeRequest) error
+	
 	// Transaction operations
 	BeginTransaction(ctx context.Context) (Transaction, error)
 	
@@ -48,6 +48,16 @@ func (p *PostgresProvider) ListCompanies(ctx context.Context, limit, offset int)
 	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
 }
 
+// SearchCompanies lists companies whose name or domain contains a query, optionally with a status
+func (p *PostgresProvider) SearchCompanies(ctx context.Context, query, status string, limit, offset int) ([]*Company, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// TransferCompanyOwnership makes the pending owner of a company its owner
+func (p *PostgresProvider) TransferCompanyOwnership(ctx context.Context, companyID, newOwnerID string) (*Company, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
 // CreateUser creates a new user
 func (p *PostgresProvider) CreateUser(ctx context.Context, user *User) error {
 	return fmt.Errorf("PostgreSQL provider not implemented yet")
@@ -299,3 +309,307 @@ func (p *PostgresProvider) UpdateCompanyConfigurationStatus(ctx context.Context,
 func (p *PostgresProvider) GetCompanyConfigurationStatus(ctx context.Context, companyID string) (map[string]bool, error) {
 	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
 }
+
+// SSO Configuration Operations
+
+// GetSSOConfig gets a company's SSO configuration
+func (p *PostgresProvider) GetSSOConfig(ctx context.Context, companyID string) (*SSOConfig, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// UpdateSSOConfig creates or replaces a company's SSO configuration
+func (p *PostgresProvider) UpdateSSOConfig(ctx context.Context, config *SSOConfig) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// DeleteSSOConfig deletes a company's SSO configuration
+func (p *PostgresProvider) DeleteSSOConfig(ctx context.Context, companyID string) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// SCIM Token Operations
+
+// CreateSCIMToken creates a new SCIM token
+func (p *PostgresProvider) CreateSCIMToken(ctx context.Context, token *SCIMToken) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetSCIMTokenByHash retrieves a SCIM token by its hash
+func (p *PostgresProvider) GetSCIMTokenByHash(ctx context.Context, tokenHash string) (*SCIMToken, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetSCIMTokensByCompany retrieves all SCIM tokens for a company
+func (p *PostgresProvider) GetSCIMTokensByCompany(ctx context.Context, companyID string) ([]*SCIMToken, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// UpdateSCIMToken updates a SCIM token
+func (p *PostgresProvider) UpdateSCIMToken(ctx context.Context, token *SCIMToken) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// DeleteSCIMToken deletes a SCIM token
+func (p *PostgresProvider) DeleteSCIMToken(ctx context.Context, tokenID string) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// CreateWebAuthnCredential stores a new passkey
+func (p *PostgresProvider) CreateWebAuthnCredential(ctx context.Context, credential *WebAuthnCredential) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetWebAuthnCredentialsByUser retrieves all passkeys registered by a user
+func (p *PostgresProvider) GetWebAuthnCredentialsByUser(ctx context.Context, userID string) ([]*WebAuthnCredential, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// UpdateWebAuthnCredential updates a passkey
+func (p *PostgresProvider) UpdateWebAuthnCredential(ctx context.Context, credential *WebAuthnCredential) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// DeleteWebAuthnCredential deletes a passkey
+func (p *PostgresProvider) DeleteWebAuthnCredential(ctx context.Context, credentialID string) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// CreateAuthToken stores a new single-use token
+func (p *PostgresProvider) CreateAuthToken(ctx context.Context, token *AuthToken) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// ConsumeAuthToken marks an unused, unexpired token as used and returns it
+func (p *PostgresProvider) ConsumeAuthToken(ctx context.Context, tokenHash string) (*AuthToken, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetLoginThrottle retrieves the failed sign-in counter for a key
+func (p *PostgresProvider) GetLoginThrottle(ctx context.Context, throttleID string) (*LoginThrottle, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// RecordLoginFailure increments the failed sign-in counter for a key and returns it
+func (p *PostgresProvider) RecordLoginFailure(ctx context.Context, throttleID, key string, resetAfter time.Duration) (*LoginThrottle, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// DeleteLoginThrottle clears the failed sign-in counter for a key
+func (p *PostgresProvider) DeleteLoginThrottle(ctx context.Context, throttleID string) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// CreateAuditEvent stores an audit event
+func (p *PostgresProvider) CreateAuditEvent(ctx context.Context, event *AuditEvent) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetAuditEventsByCompany retrieves the most recent audit events for a company
+func (p *PostgresProvider) GetAuditEventsByCompany(ctx context.Context, companyID string, limit int) ([]*AuditEvent, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// CreateServiceAccount creates a new service account
+func (p *PostgresProvider) CreateServiceAccount(ctx context.Context, account *ServiceAccount) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetServiceAccount retrieves a service account by ID
+func (p *PostgresProvider) GetServiceAccount(ctx context.Context, accountID string) (*ServiceAccount, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetServiceAccountsByCompany retrieves all service accounts of a company
+func (p *PostgresProvider) GetServiceAccountsByCompany(ctx context.Context, companyID string) ([]*ServiceAccount, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// DeleteServiceAccount deletes a service account
+func (p *PostgresProvider) DeleteServiceAccount(ctx context.Context, accountID string) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// CreateAPIKey stores a new API key
+func (p *PostgresProvider) CreateAPIKey(ctx context.Context, key *APIKey) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetAPIKeyByHash retrieves an API key by the hash of its value
+func (p *PostgresProvider) GetAPIKeyByHash(ctx context.Context, keyHash string) (*APIKey, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetAPIKeysByServiceAccount retrieves all API keys of a service account
+func (p *PostgresProvider) GetAPIKeysByServiceAccount(ctx context.Context, accountID string) ([]*APIKey, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// UpdateAPIKey updates an API key
+func (p *PostgresProvider) UpdateAPIKey(ctx context.Context, key *APIKey) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// DeleteAPIKey deletes an API key
+func (p *PostgresProvider) DeleteAPIKey(ctx context.Context, keyID string) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// CreateUserIdentity links a provider identity to a user
+func (p *PostgresProvider) CreateUserIdentity(ctx context.Context, identity *UserIdentity) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetUserIdentity retrieves a user identity by ID
+func (p *PostgresProvider) GetUserIdentity(ctx context.Context, identityID string) (*UserIdentity, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetUserIdentitiesByUser retrieves all identities linked to a user
+func (p *PostgresProvider) GetUserIdentitiesByUser(ctx context.Context, userID string) ([]*UserIdentity, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// UpdateUserIdentity updates a user identity
+func (p *PostgresProvider) UpdateUserIdentity(ctx context.Context, identity *UserIdentity) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// DeleteUserIdentity unlinks a provider identity
+func (p *PostgresProvider) DeleteUserIdentity(ctx context.Context, identityID string) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// CreateCompanyMembership adds a user to a company
+func (p *PostgresProvider) CreateCompanyMembership(ctx context.Context, membership *CompanyMembership) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetCompanyMembership retrieves a company membership by ID
+func (p *PostgresProvider) GetCompanyMembership(ctx context.Context, membershipID string) (*CompanyMembership, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetCompanyMembershipsByUser retrieves the company memberships of a user
+func (p *PostgresProvider) GetCompanyMembershipsByUser(ctx context.Context, userID string) ([]*CompanyMembership, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetCompanyMembershipsByCompany retrieves the memberships of users in a company
+func (p *PostgresProvider) GetCompanyMembershipsByCompany(ctx context.Context, companyID string) ([]*CompanyMembership, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// UpdateCompanyMembership updates a company membership
+func (p *PostgresProvider) UpdateCompanyMembership(ctx context.Context, membership *CompanyMembership) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// DeleteCompanyMembership removes a user from a company
+func (p *PostgresProvider) DeleteCompanyMembership(ctx context.Context, membershipID string) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetInvitationsByEmail retrieves all invitations sent to an email address
+func (p *PostgresProvider) GetInvitationsByEmail(ctx context.Context, email string) ([]*Invitation, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// CreateCustomRole creates a company-defined role
+func (p *PostgresProvider) CreateCustomRole(ctx context.Context, role *CustomRole) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetCustomRole retrieves a custom role by ID
+func (p *PostgresProvider) GetCustomRole(ctx context.Context, roleID string) (*CustomRole, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetCustomRolesByCompany retrieves the custom roles of a company
+func (p *PostgresProvider) GetCustomRolesByCompany(ctx context.Context, companyID string) ([]*CustomRole, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// UpdateCustomRole updates a custom role
+func (p *PostgresProvider) UpdateCustomRole(ctx context.Context, role *CustomRole) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// DeleteCustomRole deletes a custom role
+func (p *PostgresProvider) DeleteCustomRole(ctx context.Context, roleID string) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// CreateGroup creates a company group
+func (p *PostgresProvider) CreateGroup(ctx context.Context, group *Group) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetGroup retrieves a group by ID
+func (p *PostgresProvider) GetGroup(ctx context.Context, groupID string) (*Group, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetGroupsByCompany retrieves the groups of a company
+func (p *PostgresProvider) GetGroupsByCompany(ctx context.Context, companyID string) ([]*Group, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// UpdateGroup updates a group and its members
+func (p *PostgresProvider) UpdateGroup(ctx context.Context, group *Group) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// DeleteGroup deletes a group
+func (p *PostgresProvider) DeleteGroup(ctx context.Context, groupID string) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// CreateDelegatedAdmin creates a delegated admin assignment
+func (p *PostgresProvider) CreateDelegatedAdmin(ctx context.Context, delegation *DelegatedAdmin) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetDelegatedAdmin retrieves a delegated admin assignment by ID
+func (p *PostgresProvider) GetDelegatedAdmin(ctx context.Context, delegationID string) (*DelegatedAdmin, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetDelegatedAdminsByCompany retrieves the delegated admin assignments of a company
+func (p *PostgresProvider) GetDelegatedAdminsByCompany(ctx context.Context, companyID string) ([]*DelegatedAdmin, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetDelegatedAdminsByUser retrieves the delegated admin assignments held by a user
+func (p *PostgresProvider) GetDelegatedAdminsByUser(ctx context.Context, userID string) ([]*DelegatedAdmin, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// UpdateDelegatedAdmin updates a delegated admin assignment
+func (p *PostgresProvider) UpdateDelegatedAdmin(ctx context.Context, delegation *DelegatedAdmin) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// DeleteDelegatedAdmin deletes a delegated admin assignment
+func (p *PostgresProvider) DeleteDelegatedAdmin(ctx context.Context, delegationID string) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// CreateChangeRequest creates a change request
+func (p *PostgresProvider) CreateChangeRequest(ctx context.Context, request *ChangeRequest) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetChangeRequest retrieves a change request by ID
+func (p *PostgresProvider) GetChangeRequest(ctx context.Context, requestID string) (*ChangeRequest, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// GetChangeRequestsByCompany retrieves the change requests of a company
+func (p *PostgresProvider) GetChangeRequestsByCompany(ctx context.Context, companyID string) ([]*ChangeRequest, error) {
+	return nil, fmt.Errorf("PostgreSQL provider not implemented yet")
+}
+
+// UpdateChangeRequest updates a change request
+func (p *PostgresProvider) UpdateChangeRequest(ctx context.Context, request *ChangeRequest) error {
+	return fmt.Errorf("PostgreSQL provider not implemented yet")
+}
@@ -0,0 +1,80 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message represents a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender defines the interface for sending emails
+type Sender interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// SMTPConfig holds SMTP server settings
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPSender sends emails through an SMTP server
+type SMTPSender struct {
+	config SMTPConfig
+}
+
+// NewSMTPSender creates a new SMTP sender
+func NewSMTPSender(config SMTPConfig) *SMTPSender {
+	return &SMTPSender{config: config}
+}
+
+// Send sends a message. The server must support STARTTLS when credentials are configured.
+func (s *SMTPSender) Send(ctx context.Context, message Message) error {
+	var auth smtp.Auth
+	if s.config.Username != "" {
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+	}
+
+	var body strings.Builder
+	body.WriteString("From: " + headerValue(s.config.From) + "\r\n")
+	body.WriteString("To: " + headerValue(message.To) + "\r\n")
+	body.WriteString("Subject: " + headerValue(message.Subject) + "\r\n")
+	body.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	body.WriteString("\r\n")
+	body.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	addr := s.config.Host + ":" + strconv.Itoa(s.config.Port)
+	if err := smtp.SendMail(addr, auth, s.config.From, []string{message.To}, []byte(body.String())); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// LogSender writes emails to the log instead of sending them, for development
+type LogSender struct{}
+
+// Send logs a message
+func (LogSender) Send(ctx context.Context, message Message) error {
+	log.Printf("Email to %s: %s\n%s", message.To, message.Subject, message.Body)
+	return nil
+}
+
+// headerValue strips line breaks so values cannot inject extra headers
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
@@ -0,0 +1,164 @@
+// Package groups resolves company group membership. Groups list their direct user
+// members and the groups nested in them; a user's effective groups are the groups they
+// belong to directly and every group containing one of those, at any depth.
+package groups
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+)
+
+// Effective returns the IDs of the groups of a company a user effectively belongs to
+func Effective(ctx context.Context, databaseProvider database.DatabaseProvider, companyID, userID string) ([]string, error) {
+	if companyID == "" {
+		return nil, nil
+	}
+
+	companyGroups, err := databaseProvider.GetGroupsByCompany(ctx, companyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get groups: %w", err)
+	}
+	return Resolve(companyGroups, userID), nil
+}
+
+// Resolve returns the IDs of the groups a user effectively belongs to, in the order of
+// companyGroups
+func Resolve(companyGroups []*database.Group, userID string) []string {
+	member := map[string]bool{}
+	for _, group := range companyGroups {
+		if Contains(group.UserIDs, userID) {
+			member[group.ID] = true
+		}
+	}
+
+	// Add the groups containing a group the user belongs to until nothing changes
+	for changed := len(member) > 0; changed; {
+		changed = false
+		for _, group := range companyGroups {
+			if member[group.ID] {
+				continue
+			}
+			for _, nestedID := range group.GroupIDs {
+				if member[nestedID] {
+					member[group.ID] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	var groupIDs []string
+	for _, group := range companyGroups {
+		if member[group.ID] {
+			groupIDs = append(groupIDs, group.ID)
+		}
+	}
+	return groupIDs
+}
+
+// Members returns the IDs of the users who effectively belong to a group, directly or
+// through a nested group
+func Members(companyGroups []*database.Group, groupID string) []string {
+	byID := make(map[string]*database.Group, len(companyGroups))
+	for _, group := range companyGroups {
+		byID[group.ID] = group
+	}
+
+	seenGroups := map[string]bool{}
+	seenUsers := map[string]bool{}
+	var userIDs []string
+	pending := []string{groupID}
+	for len(pending) > 0 {
+		group, ok := byID[pending[0]]
+		pending = pending[1:]
+		if !ok || seenGroups[group.ID] {
+			continue
+		}
+		seenGroups[group.ID] = true
+
+		for _, userID := range group.UserIDs {
+			if !seenUsers[userID] {
+				seenUsers[userID] = true
+				userIDs = append(userIDs, userID)
+			}
+		}
+		pending = append(pending, group.GroupIDs...)
+	}
+	return userIDs
+}
+
+// CreatesCycle reports whether nesting group nestedID inside group groupID would make a
+// group contain itself
+func CreatesCycle(companyGroups []*database.Group, groupID, nestedID string) bool {
+	if groupID == nestedID {
+		return true
+	}
+
+	byID := make(map[string]*database.Group, len(companyGroups))
+	for _, group := range companyGroups {
+		byID[group.ID] = group
+	}
+
+	// A cycle appears if groupID is already nested, at any depth, inside nestedID
+	seen := map[string]bool{}
+	pending := []string{nestedID}
+	for len(pending) > 0 {
+		group, ok := byID[pending[0]]
+		pending = pending[1:]
+		if !ok || seen[group.ID] {
+			continue
+		}
+		seen[group.ID] = true
+
+		for _, childID := range group.GroupIDs {
+			if childID == groupID {
+				return true
+			}
+			pending = append(pending, childID)
+		}
+	}
+	return false
+}
+
+// RemoveUser removes a user from every group of a company
+func RemoveUser(ctx context.Context, databaseProvider database.DatabaseProvider, companyID, userID string) error {
+	companyGroups, err := databaseProvider.GetGroupsByCompany(ctx, companyID)
+	if err != nil {
+		return fmt.Errorf("failed to get groups: %w", err)
+	}
+
+	for _, group := range companyGroups {
+		if !Contains(group.UserIDs, userID) {
+			continue
+		}
+		group.UserIDs = Without(group.UserIDs, userID)
+		if err := databaseProvider.UpdateGroup(ctx, group); err != nil {
+			return fmt.Errorf("failed to update group: %w", err)
+		}
+	}
+	return nil
+}
+
+// Contains reports whether ids contains id
+func Contains(ids []string, id string) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Without returns ids without id
+func Without(ids []string, id string) []string {
+	remaining := make([]string, 0, len(ids))
+	for _, i := range ids {
+		if i != id {
+			remaining = append(remaining, i)
+		}
+	}
+	return remaining
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+)
+
+// Audit event limits for GetAuditEvents
+const (
+	defaultAuditEventLimit = 100
+	maxAuditEventLimit     = 500
+)
+
+// AuditHandler handles audit log requests
+type AuditHandler struct {
+	databaseProvider database.DatabaseProvider
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(databaseProvider database.DatabaseProvider) *AuditHandler {
+	return &AuditHandler{
+		databaseProvider: databaseProvider,
+	}
+}
+
+// GetAuditEvents returns the most recent audit events of the admin's company
+func (h *AuditHandler) GetAuditEvents(c *gin.Context) {
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	limit := defaultAuditEventLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "Invalid limit",
+			})
+			return
+		}
+		limit = parsed
+	}
+	if limit > maxAuditEventLimit {
+		limit = maxAuditEventLimit
+	}
+
+	events, err := h.databaseProvider.GetAuditEventsByCompany(c.Request.Context(), user.CompanyID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get audit events",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"events": events,
+		},
+	})
+}
@@ -1,29 +1,55 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
 	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/email"
 	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/ratelimit"
 )
 
 // AuthHandler handles authentication-related requests
 type AuthHandler struct {
 	authProvider     auth.AuthProvider
 	databaseProvider database.DatabaseProvider
+	jwtSecret        string
+	webAuthn         *webauthn.WebAuthn
+	mailer           email.Sender
+	appURL           string
+
+	emailLimiter     *ratelimit.Limiter // Emails sent per address
+	ipLimiter        *ratelimit.Limiter // Email-sending requests per client IP
+
+	breachedPasswords *auth.BreachedPasswords
 }
 
-// NewAuthHandler creates a new auth handler
-func NewAuthHandler(authProvider auth.AuthProvider, databaseProvider database.DatabaseProvider) *AuthHandler {
+// NewAuthHandler creates a new auth handler. jwtSecret signs short-lived
+// single-purpose tokens such as MFA challenges.
+func NewAuthHandler(authProvider auth.AuthProvider, databaseProvider database.DatabaseProvider, jwtSecret string) *AuthHandler {
 	return &AuthHandler{
 		authProvider:     authProvider,
 		databaseProvider: databaseProvider,
+		jwtSecret:        jwtSecret,
+		mailer:           email.LogSender{},
+		emailLimiter:     ratelimit.NewLimiter(emailLimit, emailLimitWindow),
+		ipLimiter:        ratelimit.NewLimiter(ipLimit, emailLimitWindow),
 	}
 }
 
+// SetMailer configures how emails are sent. appURL is the frontend base URL used
+// to build links in emails.
+func (h *AuthHandler) SetMailer(mailer email.Sender, appURL string) {
+	h.mailer = mailer
+	h.appURL = strings.TrimSuffix(appURL, "/")
+}
+
 // Login handles user login
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req models.LoginRequest
@@ -35,9 +61,36 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// Authenticate user
-	user, err := h.authProvider.Authenticate(c.Request.Context(), req.Email, req.Password)
+	// Accounts and IP addresses with too many failed attempts are locked for a while
+	if retryAfter := h.loginLockout(c.Request.Context(), ipThrottleKey(c.ClientIP()), accountThrottleKey(req.Email)); retryAfter > 0 {
+		respondLockedOut(c, retryAfter)
+		return
+	}
+
+	// Password login is disabled when the company requires SSO
+	if loginURL, required := enforcedSSOLoginPath(c.Request.Context(), h.databaseProvider, req.Email); required {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Single sign-on is required for this domain",
+			Data: gin.H{
+				"login_url": loginURL,
+			},
+		})
+		return
+	}
+
+	// Authenticate user with the requested connection
+	ctx := auth.WithConnection(c.Request.Context(), req.Connection)
+	user, err := h.authProvider.Authenticate(ctx, req.Email, req.Password)
+	if errors.Is(err, auth.ErrUnknownConnection) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Unknown connection",
+		})
+		return
+	}
 	if err != nil {
+		h.recordLoginFailure(c, req.Email)
 		c.JSON(http.StatusUnauthorized, models.APIResponse{
 			Success: false,
 			Error:   "Invalid credentials",
@@ -54,6 +107,24 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		})
 		return
 	}
+	h.clearLoginFailures(c.Request.Context(), req.Email)
+
+	// Expired passwords must be replaced through the password reset flow
+	if passwordExpired(h.passwordPolicyFor(c.Request.Context(), dbUser.CompanyID, dbUser.Email), dbUser) {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Your password has expired, please reset it",
+			Data: gin.H{
+				"password_expired": true,
+			},
+		})
+		return
+	}
+
+	// Users with MFA (or whose company requires it) must complete a second step
+	if h.requireSecondFactor(c, dbUser) {
+		return
+	}
 
 	// Generate JWT token
 	token, err := h.authProvider.GenerateToken(user)
@@ -71,12 +142,13 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		Data: gin.H{
 			"token": token,
 			"user": gin.H{
-				"id":         user.ID,
-				"email":      user.Email,
-				"name":       user.Name,
-				"picture":    user.Picture,
-				"company_id": dbUser.CompanyID,
-				"role":       dbUser.Role,
+				"id":             user.ID,
+				"email":          user.Email,
+				"name":           user.Name,
+				"picture":        user.Picture,
+				"company_id":     dbUser.CompanyID,
+				"role":           dbUser.Role,
+				"email_verified": !dbUser.EmailVerificationPending,
 			},
 		},
 	})
@@ -93,6 +165,18 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	// Password login is disabled when the company requires SSO
+	if loginURL, required := enforcedSSOLoginPath(c.Request.Context(), h.databaseProvider, req.Email); required {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Single sign-on is required for this domain",
+			Data: gin.H{
+				"login_url": loginURL,
+			},
+		})
+		return
+	}
+
 	// Check if user already exists
 	existingUser, err := h.databaseProvider.GetUserByEmail(c.Request.Context(), req.Email)
 	if err == nil && existingUser != nil {
@@ -103,6 +187,13 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	// The password must meet the policy of the company owning the email's domain
+	policy := h.passwordPolicyFor(c.Request.Context(), "", req.Email)
+	if violations := h.passwordPolicyViolations(policy, "password", req.Password, nil); len(violations) > 0 {
+		respondPasswordPolicyViolations(c, violations)
+		return
+	}
+
 	// Register user with auth provider
 	user, err := h.authProvider.Register(c.Request.Context(), req.Email, req.Password, req.Name)
 	if err != nil {
@@ -115,14 +206,18 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 	// Create user in database
 	dbUser := &database.User{
-		ID:        user.ID,
-		Email:     user.Email,
-		Name:      user.Name,
-		Picture:   user.Picture,
-		Role:      string(auth.RoleAdmin), // First user becomes admin
-		IsActive:  true,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
+		ID:                       user.ID,
+		Email:                    user.Email,
+		Name:                     user.Name,
+		Picture:                  user.Picture,
+		Role:                     string(auth.RoleAdmin), // First user becomes admin
+		IsActive:                 true,
+		CreatedAt:                user.CreatedAt,
+		UpdatedAt:                user.UpdatedAt,
+		EmailVerificationPending: true,
+	}
+	if err := recordPasswordChange(dbUser, req.Password, policy); err != nil {
+		log.Printf("Failed to record password history of user %s: %v", dbUser.ID, err)
 	}
 
 	if err := h.databaseProvider.CreateUser(c.Request.Context(), dbUser); err != nil {
@@ -133,6 +228,11 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	// Company setup stays blocked until the address is verified
+	if err := h.sendVerificationEmail(c.Request.Context(), dbUser); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", dbUser.ID, err)
+	}
+
 	// Generate JWT token
 	token, err := h.authProvider.GenerateToken(user)
 	if err != nil {
@@ -145,16 +245,18 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 	c.JSON(http.StatusCreated, models.APIResponse{
 		Success: true,
-		Message: "Registration successful",
+		Message: "Registration successful. Check your email to verify your address.",
 		Data: gin.H{
 			"token": token,
 			"user": gin.H{
-				"id":      user.ID,
-				"email":   user.Email,
-				"name":    user.Name,
-				"picture": user.Picture,
-				"role":    auth.RoleAdmin,
+				"id":             user.ID,
+				"email":          user.Email,
+				"name":           user.Name,
+				"picture":        user.Picture,
+				"role":           auth.RoleAdmin,
+				"email_verified": false,
 			},
+			"email_verification_required": true,
 		},
 	})
 }
@@ -202,6 +304,15 @@ func (h *AuthHandler) AuthenticateWithToken(c *gin.Context) {
 		return
 	}
 
+	// Reject tokens issued before the user's sessions were revoked
+	if auth.SessionRevoked(user.IssuedAt, dbUser.SessionsRevokedAt) {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Session has been revoked",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
 		Message: "Authentication successful",
@@ -263,41 +374,11 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	})
 }
 
-// ResetPassword handles password reset request
-func (h *AuthHandler) ResetPassword(c *gin.Context) {
-	var req struct {
-		Email string `json:"email" binding:"required,email"`
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.APIResponse{
-			Success: false,
-			Error:   "Invalid email address",
-		})
-		return
-	}
-
-	// Send password reset email
-	err := h.authProvider.ResetPassword(c.Request.Context(), req.Email)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
-			Success: false,
-			Error:   "Failed to send password reset email",
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, models.APIResponse{
-		Success: true,
-		Message: "Password reset email sent",
-	})
-}
-
 // ChangePassword handles password change
 func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	var req struct {
 		OldPassword string `json:"old_password" binding:"required"`
-		NewPassword string `json:"new_password" binding:"required,min=8"`
+		NewPassword string `json:"new_password" binding:"required,max=72"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -309,19 +390,28 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	}
 
 	// Get user from context (set by auth middleware)
-	userContext, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
+	user, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	dbUser, err := h.databaseProvider.GetUser(c.Request.Context(), user.UserID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
 			Success: false,
-			Error:   "User not authenticated",
+			Error:   "User not found",
 		})
 		return
 	}
 
-	user := userContext.(models.UserContext)
+	policy := h.passwordPolicyFor(c.Request.Context(), dbUser.CompanyID, dbUser.Email)
+	if violations := h.passwordPolicyViolations(policy, "new_password", req.NewPassword, dbUser.PasswordHistory); len(violations) > 0 {
+		respondPasswordPolicyViolations(c, violations)
+		return
+	}
 
 	// Change password
-	err := h.authProvider.ChangePassword(c.Request.Context(), user.UserID, req.OldPassword, req.NewPassword)
+	err = h.authProvider.ChangePassword(c.Request.Context(), user.UserID, req.OldPassword, req.NewPassword)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
 			Success: false,
@@ -330,6 +420,13 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
+	if err := recordPasswordChange(dbUser, req.NewPassword, policy); err == nil {
+		err = h.databaseProvider.UpdateUser(c.Request.Context(), dbUser)
+	}
+	if err != nil {
+		log.Printf("Failed to record password change of user %s: %v", dbUser.ID, err)
+	}
+
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
 		Message: "Password changed successfully",
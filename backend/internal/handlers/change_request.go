@@ -0,0 +1,450 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/audit"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/email"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/membership"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
+)
+
+// Destructive company changes that need a second approval
+const (
+	changeCompanyDelete       = "company.delete"
+	changeCompanyDomainChange = "company.domain_change"
+)
+
+// changeRequestTTL is how long a change request can be approved
+const changeRequestTTL = 24 * time.Hour
+
+// changeRequestPermissions maps each change to the permission needed to request and approve it
+var changeRequestPermissions = map[string]string{
+	changeCompanyDelete:       rbac.CompanyDelete,
+	changeCompanyDomainChange: rbac.CompanyWrite,
+}
+
+// GetChangeRequests lists the company's change requests, newest first
+func (h *CompanyHandler) GetChangeRequests(c *gin.Context) {
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	requests, err := h.databaseProvider.GetChangeRequestsByCompany(ctx, user.CompanyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get change requests",
+		})
+		return
+	}
+
+	status := c.Query("status")
+	list := []*database.ChangeRequest{}
+	for _, request := range requests {
+		h.expireChangeRequest(ctx, request)
+		if status == "" || request.Status == status {
+			list = append(list, request)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"change_requests": list,
+		},
+	})
+}
+
+// ApproveChangeRequest approves a pending change request and makes the change. The
+// approver must be a different admin holding the permission the change needs.
+func (h *CompanyHandler) ApproveChangeRequest(c *gin.Context) {
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	request, ok := h.pendingChangeRequest(c, user.CompanyID)
+	if !ok {
+		return
+	}
+	if !h.authorizeChangeRequest(c, user, request) {
+		return
+	}
+	approverID, _ := changeActor(user)
+	if approverID == request.RequestedBy {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Change requests must be approved by another admin or through the emailed confirmation",
+		})
+		return
+	}
+
+	h.approveChangeRequest(c, request, approverID, "admin")
+}
+
+// ConfirmChangeRequest approves a pending change request with the confirmation token
+// emailed to the admin who requested it, as long as they may still make the change
+func (h *CompanyHandler) ConfirmChangeRequest(c *gin.Context) {
+	var req models.ConfirmChangeRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	request, err := h.databaseProvider.GetChangeRequest(c.Request.Context(), c.Param("id"))
+	if err != nil || request.ConfirmationHash == "" ||
+		subtle.ConstantTimeCompare([]byte(auth.HashToken(req.Token)), []byte(request.ConfirmationHash)) != 1 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid or expired confirmation link",
+		})
+		return
+	}
+	if !h.checkPending(c, request) {
+		return
+	}
+	if !h.requesterCanApprove(c, request) {
+		return
+	}
+
+	h.approveChangeRequest(c, request, request.RequestedBy, "email")
+}
+
+// RejectChangeRequest rejects a pending change request. The admin who requested the
+// change can reject it to cancel it.
+func (h *CompanyHandler) RejectChangeRequest(c *gin.Context) {
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	request, ok := h.pendingChangeRequest(c, user.CompanyID)
+	if !ok {
+		return
+	}
+	if !h.authorizeChangeRequest(c, user, request) {
+		return
+	}
+
+	request.Status = "rejected"
+	request.DecidedBy, _ = changeActor(user)
+	request.DecidedAt = time.Now()
+	request.ConfirmationHash = ""
+	if err := h.databaseProvider.UpdateChangeRequest(c.Request.Context(), request); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update change request",
+		})
+		return
+	}
+
+	h.recordChangeRequest(c, "change_request.rejected", request)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Change request rejected",
+		Data: gin.H{
+			"change_request": request,
+		},
+	})
+}
+
+// requestChange creates a pending change request and emails its confirmation link to the
+// requester. Only one request per change can be pending at a time.
+func (h *CompanyHandler) requestChange(c *gin.Context, user models.UserContext, action, newDomain string) (*database.ChangeRequest, bool) {
+	ctx := c.Request.Context()
+
+	requests, err := h.databaseProvider.GetChangeRequestsByCompany(ctx, user.CompanyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get change requests",
+		})
+		return nil, false
+	}
+	for _, existing := range requests {
+		h.expireChangeRequest(ctx, existing)
+		if existing.Action == action && existing.Status == "pending" {
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Success: false,
+				Error:   "A change request for this change is already pending",
+				Data: gin.H{
+					"change_request": existing,
+				},
+			})
+			return nil, false
+		}
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to create change request",
+		})
+		return nil, false
+	}
+
+	requestedBy, requesterEmail := changeActor(user)
+	request := &database.ChangeRequest{
+		ID:               uuid.New().String(),
+		CompanyID:        user.CompanyID,
+		Action:           action,
+		NewDomain:        newDomain,
+		Status:           "pending",
+		RequestedBy:      requestedBy,
+		RequestedByEmail: requesterEmail,
+		ConfirmationHash: auth.HashToken(token),
+		ExpiresAt:        time.Now().Add(changeRequestTTL),
+	}
+	if err := h.databaseProvider.CreateChangeRequest(ctx, request); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to create change request",
+		})
+		return nil, false
+	}
+
+	if err := h.sendChangeConfirmation(ctx, request, token); err != nil {
+		// Another admin can still approve the request
+		log.Printf("Failed to send confirmation of change request %s: %v", request.ID, err)
+	}
+
+	h.recordChangeRequest(c, "change_request.created", request)
+	return request, true
+}
+
+// sendChangeConfirmation emails the requester a link confirming a change request
+func (h *CompanyHandler) sendChangeConfirmation(ctx context.Context, request *database.ChangeRequest, token string) error {
+	if request.RequestedByEmail == "" {
+		return fmt.Errorf("requester has no email address")
+	}
+
+	description := "delete your company"
+	if request.Action == changeCompanyDomainChange {
+		description = "change your company domain to " + request.NewDomain
+	}
+
+	link := h.appURL + "/company/change-requests/confirm?id=" + url.QueryEscape(request.ID) + "&token=" + url.QueryEscape(token)
+	return h.mailer.Send(ctx, email.Message{
+		To:      request.RequestedByEmail,
+		Subject: "Confirm your company change",
+		Body: fmt.Sprintf("You asked to %s. Nothing changes until another admin approves the request or you confirm it with the link below, which expires in %d hours.\n\n%s\n\nIf you did not make this request, ask another admin to reject it.\n",
+			description, int(changeRequestTTL.Hours()), link),
+	})
+}
+
+// pendingChangeRequest returns the change request named in the URL if it belongs to the
+// company and can still be decided
+func (h *CompanyHandler) pendingChangeRequest(c *gin.Context, companyID string) (*database.ChangeRequest, bool) {
+	request, err := h.databaseProvider.GetChangeRequest(c.Request.Context(), c.Param("id"))
+	if err != nil || request.CompanyID != companyID {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Change request not found",
+		})
+		return nil, false
+	}
+	if !h.checkPending(c, request) {
+		return nil, false
+	}
+	return request, true
+}
+
+// checkPending responds with an error unless a change request is pending and unexpired
+func (h *CompanyHandler) checkPending(c *gin.Context, request *database.ChangeRequest) bool {
+	h.expireChangeRequest(c.Request.Context(), request)
+	if request.Status == "expired" {
+		c.JSON(http.StatusGone, models.APIResponse{
+			Success: false,
+			Error:   "Change request has expired",
+		})
+		return false
+	}
+	if request.Status != "pending" {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   "Change request is already " + request.Status,
+		})
+		return false
+	}
+	return true
+}
+
+// authorizeChangeRequest checks that the caller holds the permission a change needs
+func (h *CompanyHandler) authorizeChangeRequest(c *gin.Context, user models.UserContext, request *database.ChangeRequest) bool {
+	permission := changeRequestPermissions[request.Action]
+	if permission == "" || !user.Can(permission) {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Insufficient permissions",
+			Data: gin.H{
+				"required_permission": permission,
+			},
+		})
+		return false
+	}
+	return true
+}
+
+// requesterCanApprove checks that the admin who requested a change is still an active
+// member of the company holding the permission it needs
+func (h *CompanyHandler) requesterCanApprove(c *gin.Context, request *database.ChangeRequest) bool {
+	ctx := c.Request.Context()
+
+	allowed := false
+	requester, err := h.databaseProvider.GetUser(ctx, request.RequestedBy)
+	if err == nil && requester.IsActive {
+		if companyMembership, err := membership.Get(ctx, h.databaseProvider, requester, request.CompanyID); err == nil {
+			permissions, err := rbac.Permissions(ctx, h.databaseProvider, request.CompanyID, companyMembership.Role)
+			permission := changeRequestPermissions[request.Action]
+			allowed = err == nil && permission != "" && rbac.Has(permissions, permission)
+		}
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "You can no longer approve this change, ask another admin to approve it",
+		})
+		return false
+	}
+	return true
+}
+
+// expireChangeRequest marks a pending change request expired once it can no longer be approved
+func (h *CompanyHandler) expireChangeRequest(ctx context.Context, request *database.ChangeRequest) {
+	if request.Status != "pending" || time.Now().Before(request.ExpiresAt) {
+		return
+	}
+	request.Status = "expired"
+	request.ConfirmationHash = ""
+	if err := h.databaseProvider.UpdateChangeRequest(ctx, request); err != nil {
+		log.Printf("Failed to expire change request %s: %v", request.ID, err)
+	}
+}
+
+// approveChangeRequest makes the requested change and marks the request approved. via
+// records whether it was approved by an admin or through the emailed confirmation.
+func (h *CompanyHandler) approveChangeRequest(c *gin.Context, request *database.ChangeRequest, approvedBy, via string) {
+	ctx := c.Request.Context()
+
+	company, err := h.databaseProvider.GetCompany(ctx, request.CompanyID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Company not found",
+		})
+		return
+	}
+
+	switch request.Action {
+	case changeCompanyDomainChange:
+		existingCompany, err := h.databaseProvider.GetCompanyByDomain(ctx, request.NewDomain)
+		if err == nil && existingCompany != nil && existingCompany.ID != company.ID {
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Success: false,
+				Error:   "Domain already taken",
+			})
+			return
+		}
+		company.Domain = request.NewDomain
+		if err := h.databaseProvider.UpdateCompany(ctx, company); err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to update company",
+			})
+			return
+		}
+	case changeCompanyDelete:
+		if err := h.databaseProvider.DeleteCompany(ctx, company.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to delete company",
+			})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Unknown change",
+		})
+		return
+	}
+
+	request.Status = "approved"
+	request.DecidedBy = approvedBy
+	request.DecidedAt = time.Now()
+	request.ConfirmationHash = ""
+	if err := h.databaseProvider.UpdateChangeRequest(ctx, request); err != nil {
+		// The change has been made, so the approval is reported regardless
+		log.Printf("Failed to mark change request %s approved: %v", request.ID, err)
+	}
+
+	event := audit.ForRequest(c, "change_request.approved", "change_request", request.ID)
+	event.CompanyID = request.CompanyID
+	if via == "email" {
+		event.ActorID = request.RequestedBy
+		event.ActorEmail = request.RequestedByEmail
+	}
+	event.Details["action"] = request.Action
+	event.Details["requested_by"] = request.RequestedBy
+	event.Details["approved_by"] = approvedBy
+	event.Details["via"] = via
+	if request.NewDomain != "" {
+		event.Details["new_domain"] = request.NewDomain
+	}
+	audit.Record(ctx, h.databaseProvider, event)
+
+	message := "Company domain changed"
+	if request.Action == changeCompanyDelete {
+		message = "Company deleted"
+	}
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: message,
+		Data: gin.H{
+			"change_request": request,
+		},
+	})
+}
+
+// changeActor returns the admin behind a request, who is the real admin while impersonating
+func changeActor(user models.UserContext) (string, string) {
+	if user.Impersonating() {
+		return user.ActorID, user.ActorEmail
+	}
+	return user.UserID, user.Email
+}
+
+// recordChangeRequest audits a change request
+func (h *CompanyHandler) recordChangeRequest(c *gin.Context, action string, request *database.ChangeRequest) {
+	event := audit.ForRequest(c, action, "change_request", request.ID)
+	event.CompanyID = request.CompanyID
+	event.Details["action"] = request.Action
+	event.Details["requested_by"] = request.RequestedBy
+	if request.NewDomain != "" {
+		event.Details["new_domain"] = request.NewDomain
+	}
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
+)
+
+// changeConfirmationToken is the token emailed for the test change request
+const changeConfirmationToken = "confirm-token"
+
+// newChangeRequestTestDatabase returns a company with two admins and a user, and a pending
+// request by the admin alice to change the company domain
+func newChangeRequestTestDatabase() *fakeDatabase {
+	db := newFakeDatabase()
+	db.companies["acme"] = &database.Company{ID: "acme", Domain: "acme.com", AdminUserID: "alice"}
+	for id, role := range map[string]string{"alice": "admin", "bob": "admin", "carol": "user"} {
+		db.users[id] = &database.User{ID: id, Email: id + "@acme.com", CompanyID: "acme", Role: role, IsActive: true}
+	}
+	db.changes["domain"] = &database.ChangeRequest{
+		ID:               "domain",
+		CompanyID:        "acme",
+		Action:           changeCompanyDomainChange,
+		NewDomain:        "acme.io",
+		Status:           "pending",
+		RequestedBy:      "alice",
+		RequestedByEmail: "alice@acme.com",
+		ConfirmationHash: auth.HashToken(changeConfirmationToken),
+		ExpiresAt:        time.Now().Add(time.Hour),
+	}
+	return db
+}
+
+// decideChangeRequest approves or rejects the test change request as a member of acme
+func decideChangeRequest(db *fakeDatabase, userID string, approve bool) int {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/companies/me/change-requests/domain", nil)
+	c.Params = gin.Params{{Key: "id", Value: "domain"}}
+	role := db.users[userID].Role
+	principal.Set(c, models.UserContext{UserID: userID, Email: userID + "@acme.com", CompanyID: "acme", Role: role, Permissions: rbac.BuiltInRoles()[role]})
+
+	h := NewCompanyHandler(db)
+	if approve {
+		h.ApproveChangeRequest(c)
+	} else {
+		h.RejectChangeRequest(c)
+	}
+	return w.Code
+}
+
+// confirmChangeRequest confirms the test change request with a token
+func confirmChangeRequest(db *fakeDatabase, token string) int {
+	body, _ := json.Marshal(models.ConfirmChangeRequestRequest{Token: token})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/change-requests/domain/confirm", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "domain"}}
+	NewCompanyHandler(db).ConfirmChangeRequest(c)
+	return w.Code
+}
+
+func TestApproveChangeRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		userID string
+		want   int
+		domain string
+	}{
+		{name: "requester", userID: "alice", want: http.StatusForbidden, domain: "acme.com"},
+		{name: "member without the permission", userID: "carol", want: http.StatusForbidden, domain: "acme.com"},
+		{name: "another admin", userID: "bob", want: http.StatusOK, domain: "acme.io"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newChangeRequestTestDatabase()
+			if got := decideChangeRequest(db, tt.userID, true); got != tt.want {
+				t.Fatalf("status = %d, want %d", got, tt.want)
+			}
+			if domain := db.companies["acme"].Domain; domain != tt.domain {
+				t.Errorf("domain = %q, want %q", domain, tt.domain)
+			}
+		})
+	}
+}
+
+func TestRejectChangeRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newChangeRequestTestDatabase()
+
+	// The requester cancels their own request
+	if got := decideChangeRequest(db, "alice", false); got != http.StatusOK {
+		t.Fatalf("reject status = %d, want %d", got, http.StatusOK)
+	}
+	if status := db.changes["domain"].Status; status != "rejected" {
+		t.Errorf("request status = %q, want rejected", status)
+	}
+
+	if got := decideChangeRequest(db, "bob", true); got != http.StatusConflict {
+		t.Errorf("approve after reject status = %d, want %d", got, http.StatusConflict)
+	}
+	if got := confirmChangeRequest(db, changeConfirmationToken); got != http.StatusBadRequest {
+		t.Errorf("confirm after reject status = %d, want %d", got, http.StatusBadRequest)
+	}
+	if domain := db.companies["acme"].Domain; domain != "acme.com" {
+		t.Errorf("domain = %q, want acme.com", domain)
+	}
+}
+
+func TestChangeRequestExpires(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newChangeRequestTestDatabase()
+	db.changes["domain"].ExpiresAt = time.Now().Add(-time.Minute)
+
+	if got := confirmChangeRequest(db, changeConfirmationToken); got != http.StatusGone {
+		t.Errorf("confirm status = %d, want %d", got, http.StatusGone)
+	}
+	if status := db.changes["domain"].Status; status != "expired" {
+		t.Errorf("request status = %q, want expired", status)
+	}
+	if got := decideChangeRequest(db, "bob", true); got != http.StatusGone {
+		t.Errorf("approve status = %d, want %d", got, http.StatusGone)
+	}
+	if domain := db.companies["acme"].Domain; domain != "acme.com" {
+		t.Errorf("domain = %q, want acme.com", domain)
+	}
+}
+
+func TestConfirmChangeRequestRechecksRequester(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		token  string
+		update func(requester *database.User)
+		want   int
+	}{
+		{name: "requester still an admin", token: changeConfirmationToken, want: http.StatusOK},
+		{name: "wrong token", token: "guess", want: http.StatusBadRequest},
+		{name: "requester demoted", token: changeConfirmationToken, update: func(requester *database.User) { requester.Role = "user" }, want: http.StatusForbidden},
+		{name: "requester deactivated", token: changeConfirmationToken, update: func(requester *database.User) { requester.IsActive = false }, want: http.StatusForbidden},
+		{name: "requester left the company", token: changeConfirmationToken, update: func(requester *database.User) { requester.CompanyID = "" }, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newChangeRequestTestDatabase()
+			if tt.update != nil {
+				tt.update(db.users["alice"])
+			}
+
+			if got := confirmChangeRequest(db, tt.token); got != tt.want {
+				t.Fatalf("status = %d, want %d", got, tt.want)
+			}
+			wantDomain := "acme.com"
+			if tt.want == http.StatusOK {
+				wantDomain = "acme.io"
+			}
+			if domain := db.companies["acme"].Domain; domain != wantDomain {
+				t.Errorf("domain = %q, want %q", domain, wantDomain)
+			}
+		})
+	}
+}
@@ -2,26 +2,39 @@ package handlers
 
 import (
 	"net/http"
-	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
 	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/email"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/membership"
 	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
 )
 
 // CompanyHandler handles company-related requests
 type CompanyHandler struct {
 	databaseProvider database.DatabaseProvider
+	mailer           email.Sender
+	appURL           string
 }
 
 // NewCompanyHandler creates a new company handler
 func NewCompanyHandler(databaseProvider database.DatabaseProvider) *CompanyHandler {
 	return &CompanyHandler{
 		databaseProvider: databaseProvider,
+		mailer:           email.LogSender{},
 	}
 }
 
+// SetMailer configures how change request confirmations are sent. appURL is the
+// frontend base URL used in confirmation links.
+func (h *CompanyHandler) SetMailer(mailer email.Sender, appURL string) {
+	h.mailer = mailer
+	h.appURL = strings.TrimSuffix(appURL, "/")
+}
+
 // CreateCompany handles company creation
 func (h *CompanyHandler) CreateCompany(c *gin.Context) {
 	var req models.CompanyCreateRequest
@@ -34,17 +47,11 @@ func (h *CompanyHandler) CreateCompany(c *gin.Context) {
 	}
 
 	// Get user from context (set by auth middleware)
-	userContext, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User not authenticated",
-		})
+	user, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
 
-	user := userContext.(models.UserContext)
-
 	// Check if domain is already taken
 	existingCompany, err := h.databaseProvider.GetCompanyByDomain(c.Request.Context(), req.Domain)
 	if err == nil && existingCompany != nil {
@@ -83,8 +90,8 @@ func (h *CompanyHandler) CreateCompany(c *gin.Context) {
 		return
 	}
 
-	dbUser.CompanyID = company.ID
-	if err := h.databaseProvider.UpdateUser(c.Request.Context(), dbUser); err != nil {
+	// Users who already belong to a company administer the new one as an additional tenant
+	if err := membership.Add(c.Request.Context(), h.databaseProvider, dbUser, company.ID, string(auth.RoleAdmin)); err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
 			Error:   "Failed to update user",
@@ -111,17 +118,11 @@ func (h *CompanyHandler) CreateCompany(c *gin.Context) {
 // GetCompany handles getting company details
 func (h *CompanyHandler) GetCompany(c *gin.Context) {
 	// Get user from context
-	userContext, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User not authenticated",
-		})
+	user, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
 
-	user := userContext.(models.UserContext)
-
 	// Get company by user's company ID
 	company, err := h.databaseProvider.GetCompany(c.Request.Context(), user.CompanyID)
 	if err != nil {
@@ -146,14 +147,18 @@ func (h *CompanyHandler) GetCompany(c *gin.Context) {
 				"created_at":    company.CreatedAt,
 				"updated_at":    company.UpdatedAt,
 				"onboarded":     company.Onboarded,
+				"mfa_requirement": mfaRequirement(company),
+				"jit_default_role": jitDefaultRole(company),
+				"entra_tenant_id": company.EntraTenantID,
+				"ownership":       companyOwnership(company),
 			},
 		},
 	})
 }
 
-// UpdateCompany handles company updates
-func (h *CompanyHandler) UpdateCompany(c *gin.Context) {
-	var req models.UpdateCompanyRequest
+// UpdateSecuritySettings handles updating company security settings (admin only)
+func (h *CompanyHandler) UpdateSecuritySettings(c *gin.Context) {
+	var req models.CompanySecurityRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
 			Success: false,
@@ -162,18 +167,11 @@ func (h *CompanyHandler) UpdateCompany(c *gin.Context) {
 		return
 	}
 
-	// Get user from context
-	userContext, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User not authenticated",
-		})
+	user, ok := companyUser(c)
+	if !ok {
 		return
 	}
 
-	user := userContext.(models.UserContext)
-
 	// Get company
 	company, err := h.databaseProvider.GetCompany(c.Request.Context(), user.CompanyID)
 	if err != nil {
@@ -184,173 +182,178 @@ func (h *CompanyHandler) UpdateCompany(c *gin.Context) {
 		return
 	}
 
-	// Check if user is admin
-	if user.Role != "admin" {
-		c.JSON(http.StatusForbidden, models.APIResponse{
-			Success: false,
-			Error:   "Only admins can update company settings",
-		})
-		return
+	company.MFARequirement = req.MFARequirement
+	if req.JITDefaultRole != "" {
+		company.JITDefaultRole = req.JITDefaultRole
 	}
-
-	// Update company fields
-	if req.Name != "" {
-		company.Name = req.Name
-	}
-	if req.Domain != "" {
-		// Check if new domain is already taken
-		if req.Domain != company.Domain {
-			existingCompany, err := h.databaseProvider.GetCompanyByDomain(c.Request.Context(), req.Domain)
-			if err == nil && existingCompany != nil {
-				c.JSON(http.StatusConflict, models.APIResponse{
+	if req.EntraTenantID != nil {
+		tenantID := strings.ToLower(strings.TrimSpace(*req.EntraTenantID))
+		if tenantID != "" {
+			if _, err := uuid.Parse(tenantID); err != nil {
+				c.JSON(http.StatusBadRequest, models.APIResponse{
 					Success: false,
-					Error:   "Domain already taken",
+					Error:   "Entra tenant ID must be a directory ID",
 				})
 				return
 			}
-			company.Domain = req.Domain
 		}
-	}
-	if req.ColorTheme != "" {
-		company.ColorTheme = req.ColorTheme
-	}
-	if req.LogoURL != "" {
-		company.LogoURL = req.LogoURL
+		company.EntraTenantID = tenantID
 	}
 
 	// Save updated company
 	if err := h.databaseProvider.UpdateCompany(c.Request.Context(), company); err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
-			Error:   "Failed to update company",
+			Error:   "Failed to update security settings",
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
-		Message: "Company updated successfully",
+		Message: "Security settings updated successfully",
 		Data: gin.H{
-			"company": gin.H{
-				"id":          company.ID,
-				"name":        company.Name,
-				"domain":      company.Domain,
-				"color_theme": company.ColorTheme,
-				"logo_url":    company.LogoURL,
-				"status":      company.Status,
-				"updated_at":  company.UpdatedAt,
+			"security": gin.H{
+				"mfa_requirement":  mfaRequirement(company),
+				"jit_default_role": jitDefaultRole(company),
+				"entra_tenant_id":  company.EntraTenantID,
 			},
 		},
 	})
 }
 
-// DeleteCompany handles company deletion
-func (h *CompanyHandler) DeleteCompany(c *gin.Context) {
-	// Get user from context
-	userContext, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User not authenticated",
-		})
-		return
+// mfaRequirement returns the company's MFA requirement, defaulting to "none"
+func mfaRequirement(company *database.Company) string {
+	if company.MFARequirement == "" {
+		return "none"
 	}
+	return company.MFARequirement
+}
 
-	user := userContext.(models.UserContext)
-
-	// Check if user is admin
-	if user.Role != "admin" {
-		c.JSON(http.StatusForbidden, models.APIResponse{
-			Success: false,
-			Error:   "Only admins can delete company",
-		})
-		return
+// jitDefaultRole returns the role given to users provisioned on their first Google
+// sign-in, defaulting to "user"
+func jitDefaultRole(company *database.Company) string {
+	if company.JITDefaultRole == "" {
+		return string(auth.RoleUser)
 	}
+	return company.JITDefaultRole
+}
 
-	// Delete company
-	if err := h.databaseProvider.DeleteCompany(c.Request.Context(), user.CompanyID); err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+// UpdateCompany handles company updates. A domain change is not applied immediately, it
+// creates a change request that must be approved first.
+func (h *CompanyHandler) UpdateCompany(c *gin.Context) {
+	var req models.UpdateCompanyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
 			Success: false,
-			Error:   "Failed to delete company",
+			Error:   "Invalid request data: " + err.Error(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, models.APIResponse{
-		Success: true,
-		Message: "Company deleted successfully",
-	})
-}
-
-// ListCompanies handles listing companies (admin only)
-func (h *CompanyHandler) ListCompanies(c *gin.Context) {
 	// Get user from context
-	userContext, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User not authenticated",
-		})
+	user, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
 
-	user := userContext.(models.UserContext)
-
-	// Check if user is admin (assuming admin users can see all companies)
-	if user.Role != "admin" {
-		c.JSON(http.StatusForbidden, models.APIResponse{
+	// Get company
+	company, err := h.databaseProvider.GetCompany(c.Request.Context(), user.CompanyID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
 			Success: false,
-			Error:   "Access denied",
+			Error:   "Company not found",
 		})
 		return
 	}
 
-	// Get pagination parameters
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-
-	if page < 1 {
-		page = 1
+	// Update company fields
+	if req.Name != "" {
+		company.Name = req.Name
 	}
-	if limit < 1 || limit > 100 {
-		limit = 10
+	domainChange := req.Domain != "" && req.Domain != company.Domain
+	if domainChange {
+		// Check if new domain is already taken
+		existingCompany, err := h.databaseProvider.GetCompanyByDomain(c.Request.Context(), req.Domain)
+		if err == nil && existingCompany != nil {
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Success: false,
+				Error:   "Domain already taken",
+			})
+			return
+		}
+	}
+	if req.ColorTheme != "" {
+		company.ColorTheme = req.ColorTheme
+	}
+	if req.LogoURL != "" {
+		company.LogoURL = req.LogoURL
 	}
 
-	offset := (page - 1) * limit
+	// Request the domain change first so nothing is saved if one is already pending
+	var changeRequest *database.ChangeRequest
+	if domainChange {
+		if changeRequest, ok = h.requestChange(c, user, changeCompanyDomainChange, req.Domain); !ok {
+			return
+		}
+	}
 
-	// Get companies
-	companies, err := h.databaseProvider.ListCompanies(c.Request.Context(), limit, offset)
-	if err != nil {
+	// Save updated company
+	if err := h.databaseProvider.UpdateCompany(c.Request.Context(), company); err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
-			Error:   "Failed to get companies",
+			Error:   "Failed to update company",
 		})
 		return
 	}
 
-	// Convert to response format
-	var companyList []gin.H
-	for _, company := range companies {
-		companyList = append(companyList, gin.H{
+	data := gin.H{
+		"company": gin.H{
 			"id":          company.ID,
 			"name":        company.Name,
 			"domain":      company.Domain,
 			"color_theme": company.ColorTheme,
+			"logo_url":    company.LogoURL,
 			"status":      company.Status,
-			"admin_user_id": company.AdminUserID,
-			"created_at":    company.CreatedAt,
-			"updated_at":    company.UpdatedAt,
+			"updated_at":  company.UpdatedAt,
+		},
+	}
+	if changeRequest != nil {
+		data["change_request"] = changeRequest
+		c.JSON(http.StatusAccepted, models.APIResponse{
+			Success: true,
+			Message: "Company updated; the domain change is awaiting approval",
+			Data:    data,
 		})
+		return
 	}
 
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
+		Message: "Company updated successfully",
+		Data:    data,
+	})
+}
+
+// DeleteCompany handles company deletion. The company is only deleted once the change
+// request created here is approved.
+func (h *CompanyHandler) DeleteCompany(c *gin.Context) {
+	// Get user from context
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	changeRequest, ok := h.requestChange(c, user, changeCompanyDelete, "")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.APIResponse{
+		Success: true,
+		Message: "Company deletion is awaiting approval",
 		Data: gin.H{
-			"companies": companyList,
-			"pagination": gin.H{
-				"page":  page,
-				"limit": limit,
-			},
+			"change_request": changeRequest,
 		},
 	})
 }
@@ -358,17 +361,11 @@ func (h *CompanyHandler) ListCompanies(c *gin.Context) {
 // GetCompanyStats handles getting company statistics
 func (h *CompanyHandler) GetCompanyStats(c *gin.Context) {
 	// Get user from context
-	userContext, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User not authenticated",
-		})
+	user, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
 
-	user := userContext.(models.UserContext)
-
 	// Get user count
 	userCount, err := h.databaseProvider.CountUsersByCompany(c.Request.Context(), user.CompanyID)
 	if err != nil {
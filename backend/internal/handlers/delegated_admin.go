@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/audit"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/membership"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
+)
+
+// DelegatedAdminHandler handles delegated admin assignments, which grant a user the
+// user-management permissions of a role over a scope of users
+type DelegatedAdminHandler struct {
+	databaseProvider database.DatabaseProvider
+}
+
+// NewDelegatedAdminHandler creates a new delegated admin handler
+func NewDelegatedAdminHandler(databaseProvider database.DatabaseProvider) *DelegatedAdminHandler {
+	return &DelegatedAdminHandler{
+		databaseProvider: databaseProvider,
+	}
+}
+
+// GetDelegatedAdmins lists the company's delegated admin assignments
+func (h *DelegatedAdminHandler) GetDelegatedAdmins(c *gin.Context) {
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	assignments, err := h.databaseProvider.GetDelegatedAdminsByCompany(c.Request.Context(), user.CompanyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get delegated admins",
+		})
+		return
+	}
+	if assignments == nil {
+		assignments = []*database.DelegatedAdmin{}
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"delegated_admins":        assignments,
+			"delegatable_permissions": rbac.UserScoped,
+		},
+	})
+}
+
+// CreateDelegatedAdmin assigns a user delegated admin permissions over a scope of users
+func (h *DelegatedAdminHandler) CreateDelegatedAdmin(c *gin.Context) {
+	var req models.DelegatedAdminRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	assignment := &database.DelegatedAdmin{
+		ID:        uuid.New().String(),
+		CompanyID: user.CompanyID,
+		CreatedBy: user.UserID,
+	}
+	if !h.applyDelegatedAdminRequest(c, assignment, req) {
+		return
+	}
+
+	if err := h.databaseProvider.CreateDelegatedAdmin(c.Request.Context(), assignment); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to create delegated admin",
+		})
+		return
+	}
+
+	h.record(c, "delegated_admin.created", assignment)
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Delegated admin created successfully",
+		Data: gin.H{
+			"delegated_admin": assignment,
+		},
+	})
+}
+
+// UpdateDelegatedAdmin replaces the user, role and scope of a delegated admin assignment
+func (h *DelegatedAdminHandler) UpdateDelegatedAdmin(c *gin.Context) {
+	var req models.DelegatedAdminRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	assignment, ok := h.companyAssignment(c)
+	if !ok {
+		return
+	}
+	if !h.applyDelegatedAdminRequest(c, assignment, req) {
+		return
+	}
+
+	if err := h.databaseProvider.UpdateDelegatedAdmin(c.Request.Context(), assignment); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update delegated admin",
+		})
+		return
+	}
+
+	h.record(c, "delegated_admin.updated", assignment)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Delegated admin updated successfully",
+		Data: gin.H{
+			"delegated_admin": assignment,
+		},
+	})
+}
+
+// DeleteDelegatedAdmin revokes a delegated admin assignment
+func (h *DelegatedAdminHandler) DeleteDelegatedAdmin(c *gin.Context) {
+	assignment, ok := h.companyAssignment(c)
+	if !ok {
+		return
+	}
+
+	if err := h.databaseProvider.DeleteDelegatedAdmin(c.Request.Context(), assignment.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to delete delegated admin",
+		})
+		return
+	}
+
+	h.record(c, "delegated_admin.deleted", assignment)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Delegated admin deleted successfully",
+	})
+}
+
+// companyAssignment returns the assignment named in the URL if it belongs to the user's company
+func (h *DelegatedAdminHandler) companyAssignment(c *gin.Context) (*database.DelegatedAdmin, bool) {
+	user, ok := companyUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	assignment, err := h.databaseProvider.GetDelegatedAdmin(c.Request.Context(), c.Param("id"))
+	if err != nil || assignment.CompanyID != user.CompanyID {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Delegated admin not found",
+		})
+		return nil, false
+	}
+
+	return assignment, true
+}
+
+// applyDelegatedAdminRequest validates a request and copies it onto an assignment. The
+// delegated admin and the users in scope must be members of the company, and the role
+// must grant at least one user-management permission. Callers can't delegate to
+// themselves or delegate permissions they do not hold.
+func (h *DelegatedAdminHandler) applyDelegatedAdminRequest(c *gin.Context, assignment *database.DelegatedAdmin, req models.DelegatedAdminRequest) bool {
+	ctx := c.Request.Context()
+
+	user, ok := companyUser(c)
+	if !ok {
+		return false
+	}
+	if req.UserID == user.UserID {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "You cannot delegate admin permissions to yourself",
+		})
+		return false
+	}
+
+	if !h.isMember(c, assignment.CompanyID, req.UserID) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Delegated admin is not a member of the company",
+		})
+		return false
+	}
+
+	permissions, err := rbac.DelegatedPermissions(ctx, h.databaseProvider, assignment.CompanyID, req.Role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Unknown role",
+		})
+		return false
+	}
+	if len(permissions) == 0 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Role grants no user management permissions",
+		})
+		return false
+	}
+	for _, permission := range permissions {
+		if !user.Can(permission) {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "Cannot delegate a permission you do not hold: " + permission,
+			})
+			return false
+		}
+	}
+
+	explicitScope := len(req.UserIDs) > 0 || len(req.GroupIDs) > 0
+	if req.AllUsers == explicitScope {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Set all_users or list user_ids and group_ids, but not both",
+		})
+		return false
+	}
+
+	for _, userID := range req.UserIDs {
+		if !h.isMember(c, assignment.CompanyID, userID) {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "User is not a member of the company: " + userID,
+			})
+			return false
+		}
+	}
+	if len(req.GroupIDs) > 0 {
+		companyGroups, err := h.databaseProvider.GetGroupsByCompany(ctx, assignment.CompanyID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to get groups",
+			})
+			return false
+		}
+		for _, groupID := range req.GroupIDs {
+			if !groupExists(companyGroups, groupID) {
+				c.JSON(http.StatusBadRequest, models.APIResponse{
+					Success: false,
+					Error:   "Group not found: " + groupID,
+				})
+				return false
+			}
+		}
+	}
+
+	assignment.UserID = req.UserID
+	assignment.Role = req.Role
+	assignment.AllUsers = req.AllUsers
+	assignment.UserIDs = append([]string{}, req.UserIDs...)
+	assignment.GroupIDs = append([]string{}, req.GroupIDs...)
+	return true
+}
+
+// isMember reports whether a user is a member of a company
+func (h *DelegatedAdminHandler) isMember(c *gin.Context, companyID, userID string) bool {
+	user, err := h.databaseProvider.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		return false
+	}
+	_, err = membership.Get(c.Request.Context(), h.databaseProvider, user, companyID)
+	return err == nil
+}
+
+// record audits a change to a delegated admin assignment
+func (h *DelegatedAdminHandler) record(c *gin.Context, action string, assignment *database.DelegatedAdmin) {
+	event := audit.ForRequest(c, action, "delegated_admin", assignment.ID)
+	event.Details["user_id"] = assignment.UserID
+	event.Details["role"] = assignment.Role
+	event.Details["all_users"] = assignment.AllUsers
+	event.Details["user_ids"] = assignment.UserIDs
+	event.Details["group_ids"] = assignment.GroupIDs
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+}
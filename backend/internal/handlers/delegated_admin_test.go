@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
+)
+
+func TestDelegatedAdminPermissionsLimitedToCaller(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	roleManager := []string{rbac.CompanyRead, rbac.UsersRead, rbac.RolesManage}
+
+	create := func(h *DelegatedAdminHandler, permissions []string, req models.DelegatedAdminRequest) int {
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/delegated-admins", bytes.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		principal.Set(c, models.UserContext{UserID: "manager", CompanyID: "acme", Role: "role-manager", Permissions: permissions})
+		h.CreateDelegatedAdmin(c)
+		return w.Code
+	}
+
+	tests := []struct {
+		name        string
+		permissions []string
+		req         models.DelegatedAdminRequest
+		want        int
+	}{
+		{
+			name:        "self-assignment",
+			permissions: roleManager,
+			req:         models.DelegatedAdminRequest{UserID: "manager", Role: "user", AllUsers: true},
+			want:        http.StatusForbidden,
+		},
+		{
+			name:        "self-assignment by an admin",
+			permissions: rbac.BuiltInRoles()["admin"],
+			req:         models.DelegatedAdminRequest{UserID: "manager", Role: "admin", AllUsers: true},
+			want:        http.StatusForbidden,
+		},
+		{
+			name:        "permissions the caller lacks",
+			permissions: roleManager,
+			req:         models.DelegatedAdminRequest{UserID: "member", Role: "admin", AllUsers: true},
+			want:        http.StatusForbidden,
+		},
+		{
+			name:        "permissions within the caller's",
+			permissions: roleManager,
+			req:         models.DelegatedAdminRequest{UserID: "member", Role: "user", AllUsers: true},
+			want:        http.StatusCreated,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newUserTestDatabase()
+			h := NewDelegatedAdminHandler(db)
+
+			if status := create(h, tt.permissions, tt.req); status != tt.want {
+				t.Fatalf("status = %d, want %d", status, tt.want)
+			}
+			if created := len(db.delegations) == 1; created != (tt.want == http.StatusCreated) {
+				t.Errorf("assignments = %d", len(db.delegations))
+			}
+		})
+	}
+}
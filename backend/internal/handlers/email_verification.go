@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/email"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
+)
+
+// emailVerificationTTL is how long a verification link stays valid
+const emailVerificationTTL = 24 * time.Hour
+
+// VerifyEmail consumes a verification token and marks the user's email verified
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	// The token is checked and consumed before the provider activates the account
+	dbUser, err := h.consumeEmailToken(c.Request.Context(), auth.PurposeEmailVerification, req.Token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid or expired verification link",
+		})
+		return
+	}
+
+	if err := h.authProvider.ActivateUser(c.Request.Context(), req.Token); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to verify email",
+		})
+		return
+	}
+
+	if dbUser.EmailVerificationPending {
+		dbUser.EmailVerificationPending = false
+		dbUser.EmailVerifiedAt = time.Now()
+		if err := h.databaseProvider.UpdateUser(c.Request.Context(), dbUser); err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to verify email",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Email verified",
+	})
+}
+
+// ResendVerification emails the current user a new verification link
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	dbUser, _, ok := h.mfaSubject(c, "")
+	if !ok {
+		return
+	}
+
+	h.resendVerification(c, dbUser)
+}
+
+// ResendUserVerification lets a company admin resend the verification link to one of their users
+func (h *AuthHandler) ResendUserVerification(c *gin.Context) {
+	admin, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	dbUser, ok := companyMember(c, h.databaseProvider, c.Param("id"), admin.CompanyID)
+	if !ok {
+		return
+	}
+	if !authorizeUserTarget(c, h.databaseProvider, admin, rbac.UsersInvite, dbUser) {
+		return
+	}
+
+	h.resendVerification(c, dbUser)
+}
+
+// resendVerification sends a new verification link to a user whose address is unverified
+func (h *AuthHandler) resendVerification(c *gin.Context, dbUser *database.User) {
+	if !dbUser.EmailVerificationPending {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Email address is already verified",
+		})
+		return
+	}
+
+	if allowed, retryAfter := h.emailLimiter.Allow(strings.ToLower(dbUser.Email)); !allowed {
+		tooManyRequests(c, retryAfter)
+		return
+	}
+
+	if err := h.sendVerificationEmail(c.Request.Context(), dbUser); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to send verification email",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Verification email sent",
+	})
+}
+
+// sendVerificationEmail issues a verification token and emails the link
+func (h *AuthHandler) sendVerificationEmail(ctx context.Context, dbUser *database.User) error {
+	token, err := h.issueEmailToken(ctx, dbUser, auth.PurposeEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := h.appURL + "/auth/verify-email?token=" + url.QueryEscape(token)
+	return h.mailer.Send(ctx, email.Message{
+		To:      dbUser.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Confirm your email address to finish setting up your account. The link expires in %d hours.\n\n%s\n",
+			int(emailVerificationTTL.Hours()), link),
+	})
+}
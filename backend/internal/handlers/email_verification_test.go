@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+)
+
+// verifyEmail posts a verification token and returns the response status
+func verifyEmail(h *AuthHandler, token string) int {
+	body, _ := json.Marshal(models.VerifyEmailRequest{Token: token})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/auth/verify-email", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	h.VerifyEmail(c)
+	return w.Code
+}
+
+func TestVerifyEmailActivatesOnlyWithUnusedToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := &fakeAuthProvider{}
+	db := newFakeDatabase()
+	db.users["bob"] = &database.User{ID: "bob", Email: "bob@acme.com", Role: "user", IsActive: true, EmailVerificationPending: true}
+	h := NewAuthHandler(provider, db, "test-secret")
+
+	dbUser, _ := db.GetUser(context.Background(), "bob")
+	token, err := h.issueEmailToken(context.Background(), dbUser, auth.PurposeEmailVerification, time.Hour)
+	if err != nil {
+		t.Fatalf("issueEmailToken() error = %v", err)
+	}
+
+	// A validly signed token that was never issued does not activate anyone
+	unissued, _ := auth.SignPurposeToken("test-secret", auth.PurposeEmailVerification, "bob", time.Hour, nil)
+	if status := verifyEmail(h, unissued); status != http.StatusBadRequest {
+		t.Errorf("unissued token status = %d, want 400", status)
+	}
+	if provider.activations != 0 {
+		t.Fatalf("unissued token activated the user %d times", provider.activations)
+	}
+
+	if status := verifyEmail(h, token); status != http.StatusOK {
+		t.Fatalf("verification status = %d, want 200", status)
+	}
+	if status := verifyEmail(h, token); status != http.StatusBadRequest {
+		t.Errorf("replayed token status = %d, want 400", status)
+	}
+	if provider.activations != 1 {
+		t.Errorf("activations = %d, want 1", provider.activations)
+	}
+	if db.users["bob"].EmailVerificationPending {
+		t.Error("email still pending verification")
+	}
+}
@@ -0,0 +1,502 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+)
+
+// fakeDatabase is an in-memory DatabaseProvider for handler tests. Only the operations the
+// tests use are implemented; calling any other panics through the nil embedded interface.
+type fakeDatabase struct {
+	database.DatabaseProvider
+
+	mu          sync.Mutex
+	companies   map[string]*database.Company
+	users       map[string]*database.User
+	memberships map[string]*database.CompanyMembership
+	invitations map[string]*database.Invitation
+	authTokens  map[string]*database.AuthToken
+	throttles   map[string]*database.LoginThrottle
+	roles       map[string]*database.CustomRole
+	identities  map[string]*database.UserIdentity
+	passkeys    map[string]*database.WebAuthnCredential
+	delegations map[string]*database.DelegatedAdmin
+	accounts    map[string]*database.ServiceAccount
+	apiKeys     map[string]*database.APIKey
+	groups      map[string]*database.Group
+	changes     map[string]*database.ChangeRequest
+	audit       []*database.AuditEvent
+}
+
+// newFakeDatabase returns an empty fake database
+func newFakeDatabase() *fakeDatabase {
+	return &fakeDatabase{
+		companies:   map[string]*database.Company{},
+		users:       map[string]*database.User{},
+		memberships: map[string]*database.CompanyMembership{},
+		invitations: map[string]*database.Invitation{},
+		authTokens:  map[string]*database.AuthToken{},
+		throttles:   map[string]*database.LoginThrottle{},
+		roles:       map[string]*database.CustomRole{},
+		identities:  map[string]*database.UserIdentity{},
+		passkeys:    map[string]*database.WebAuthnCredential{},
+		delegations: map[string]*database.DelegatedAdmin{},
+		accounts:    map[string]*database.ServiceAccount{},
+		apiKeys:     map[string]*database.APIKey{},
+		groups:      map[string]*database.Group{},
+		changes:     map[string]*database.ChangeRequest{},
+	}
+}
+
+// auditActions returns the actions of the recorded audit events
+func (f *fakeDatabase) auditActions() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var actions []string
+	for _, event := range f.audit {
+		actions = append(actions, event.Action)
+	}
+	return actions
+}
+
+func (f *fakeDatabase) GetCompany(ctx context.Context, companyID string) (*database.Company, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	company, ok := f.companies[companyID]
+	if !ok {
+		return nil, fmt.Errorf("company not found")
+	}
+	copied := *company
+	return &copied, nil
+}
+
+func (f *fakeDatabase) GetCompanyByDomain(ctx context.Context, domain string) (*database.Company, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, company := range f.companies {
+		if company.Domain == domain {
+			copied := *company
+			return &copied, nil
+		}
+	}
+	return nil, fmt.Errorf("company not found")
+}
+
+func (f *fakeDatabase) UpdateCompany(ctx context.Context, company *database.Company) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *company
+	f.companies[company.ID] = &copied
+	return nil
+}
+
+func (f *fakeDatabase) CreateUser(ctx context.Context, user *database.User) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *user
+	f.users[user.ID] = &copied
+	return nil
+}
+
+func (f *fakeDatabase) GetUser(ctx context.Context, userID string) (*database.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, ok := f.users[userID]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (f *fakeDatabase) GetUserByEmail(ctx context.Context, email string) (*database.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, user := range f.users {
+		if user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
+func (f *fakeDatabase) GetUsersByCompany(ctx context.Context, companyID string) ([]*database.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var users []*database.User
+	for _, user := range f.users {
+		if user.CompanyID == companyID {
+			copied := *user
+			users = append(users, &copied)
+		}
+	}
+	return users, nil
+}
+
+func (f *fakeDatabase) UpdateUser(ctx context.Context, user *database.User) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *user
+	f.users[user.ID] = &copied
+	return nil
+}
+
+func (f *fakeDatabase) CreateCompanyMembership(ctx context.Context, membership *database.CompanyMembership) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *membership
+	f.memberships[membership.ID] = &copied
+	return nil
+}
+
+func (f *fakeDatabase) GetCompanyMembership(ctx context.Context, membershipID string) (*database.CompanyMembership, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	membership, ok := f.memberships[membershipID]
+	if !ok {
+		return nil, fmt.Errorf("company membership not found")
+	}
+	copied := *membership
+	return &copied, nil
+}
+
+func (f *fakeDatabase) GetCompanyMembershipsByUser(ctx context.Context, userID string) ([]*database.CompanyMembership, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var memberships []*database.CompanyMembership
+	for _, membership := range f.memberships {
+		if membership.UserID == userID {
+			copied := *membership
+			memberships = append(memberships, &copied)
+		}
+	}
+	return memberships, nil
+}
+
+func (f *fakeDatabase) GetCompanyMembershipsByCompany(ctx context.Context, companyID string) ([]*database.CompanyMembership, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var memberships []*database.CompanyMembership
+	for _, membership := range f.memberships {
+		if membership.CompanyID == companyID {
+			copied := *membership
+			memberships = append(memberships, &copied)
+		}
+	}
+	return memberships, nil
+}
+
+func (f *fakeDatabase) UpdateCompanyMembership(ctx context.Context, membership *database.CompanyMembership) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *membership
+	f.memberships[membership.ID] = &copied
+	return nil
+}
+
+func (f *fakeDatabase) CreateInvitation(ctx context.Context, invitation *database.Invitation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *invitation
+	f.invitations[invitation.ID] = &copied
+	return nil
+}
+
+func (f *fakeDatabase) GetInvitationsByEmail(ctx context.Context, email string) ([]*database.Invitation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var invitations []*database.Invitation
+	for _, invitation := range f.invitations {
+		if invitation.Email == email {
+			copied := *invitation
+			invitations = append(invitations, &copied)
+		}
+	}
+	return invitations, nil
+}
+
+func (f *fakeDatabase) UpdateInvitation(ctx context.Context, invitation *database.Invitation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *invitation
+	f.invitations[invitation.ID] = &copied
+	return nil
+}
+
+func (f *fakeDatabase) CreateAuditEvent(ctx context.Context, event *database.AuditEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.audit = append(f.audit, event)
+	return nil
+}
+
+func (f *fakeDatabase) CreateAuthToken(ctx context.Context, token *database.AuthToken) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *token
+	f.authTokens[token.TokenHash] = &copied
+	return nil
+}
+
+func (f *fakeDatabase) ConsumeAuthToken(ctx context.Context, tokenHash string) (*database.AuthToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token, ok := f.authTokens[tokenHash]
+	if !ok {
+		return nil, fmt.Errorf("auth token not found")
+	}
+	if !token.UsedAt.IsZero() {
+		return nil, fmt.Errorf("auth token already used")
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return nil, fmt.Errorf("auth token expired")
+	}
+	token.UsedAt = time.Now()
+	copied := *token
+	return &copied, nil
+}
+
+func (f *fakeDatabase) GetLoginThrottle(ctx context.Context, throttleID string) (*database.LoginThrottle, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	throttle, ok := f.throttles[throttleID]
+	if !ok {
+		return nil, fmt.Errorf("login throttle not found")
+	}
+	copied := *throttle
+	return &copied, nil
+}
+
+func (f *fakeDatabase) RecordLoginFailure(ctx context.Context, throttleID, key string, resetAfter time.Duration) (*database.LoginThrottle, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	throttle, ok := f.throttles[throttleID]
+	if !ok || now.Sub(throttle.LastFailureAt) > resetAfter {
+		throttle = &database.LoginThrottle{ID: throttleID, Key: key, FirstFailureAt: now}
+		f.throttles[throttleID] = throttle
+	}
+	throttle.Failures++
+	throttle.LastFailureAt = now
+	copied := *throttle
+	return &copied, nil
+}
+
+func (f *fakeDatabase) DeleteLoginThrottle(ctx context.Context, throttleID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.throttles, throttleID)
+	return nil
+}
+
+func (f *fakeDatabase) CreateCustomRole(ctx context.Context, role *database.CustomRole) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *role
+	f.roles[role.ID] = &copied
+	return nil
+}
+
+func (f *fakeDatabase) GetCustomRole(ctx context.Context, roleID string) (*database.CustomRole, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	role, ok := f.roles[roleID]
+	if !ok {
+		return nil, fmt.Errorf("role not found")
+	}
+	copied := *role
+	return &copied, nil
+}
+
+func (f *fakeDatabase) GetCustomRolesByCompany(ctx context.Context, companyID string) ([]*database.CustomRole, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var roles []*database.CustomRole
+	for _, role := range f.roles {
+		if role.CompanyID == companyID {
+			copied := *role
+			roles = append(roles, &copied)
+		}
+	}
+	return roles, nil
+}
+
+func (f *fakeDatabase) UpdateCustomRole(ctx context.Context, role *database.CustomRole) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *role
+	f.roles[role.ID] = &copied
+	return nil
+}
+
+func (f *fakeDatabase) GetSSOConfig(ctx context.Context, companyID string) (*database.SSOConfig, error) {
+	return nil, fmt.Errorf("SSO config not found")
+}
+
+func (f *fakeDatabase) GetUserIdentity(ctx context.Context, identityID string) (*database.UserIdentity, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	identity, ok := f.identities[identityID]
+	if !ok {
+		return nil, fmt.Errorf("identity not found")
+	}
+	copied := *identity
+	return &copied, nil
+}
+
+func (f *fakeDatabase) UpdateUserIdentity(ctx context.Context, identity *database.UserIdentity) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *identity
+	f.identities[identity.ID] = &copied
+	return nil
+}
+
+func (f *fakeDatabase) CreateWebAuthnCredential(ctx context.Context, credential *database.WebAuthnCredential) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *credential
+	f.passkeys[credential.ID] = &copied
+	return nil
+}
+
+func (f *fakeDatabase) GetWebAuthnCredentialsByUser(ctx context.Context, userID string) ([]*database.WebAuthnCredential, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var credentials []*database.WebAuthnCredential
+	for _, credential := range f.passkeys {
+		if credential.UserID == userID {
+			copied := *credential
+			credentials = append(credentials, &copied)
+		}
+	}
+	return credentials, nil
+}
+
+func (f *fakeDatabase) UpdateWebAuthnCredential(ctx context.Context, credential *database.WebAuthnCredential) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *credential
+	f.passkeys[credential.ID] = &copied
+	return nil
+}
+
+func (f *fakeDatabase) CreateDelegatedAdmin(ctx context.Context, assignment *database.DelegatedAdmin) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *assignment
+	f.delegations[assignment.ID] = &copied
+	return nil
+}
+
+func (f *fakeDatabase) GetServiceAccount(ctx context.Context, accountID string) (*database.ServiceAccount, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	account, ok := f.accounts[accountID]
+	if !ok {
+		return nil, fmt.Errorf("service account not found")
+	}
+	copied := *account
+	return &copied, nil
+}
+
+func (f *fakeDatabase) CreateAPIKey(ctx context.Context, key *database.APIKey) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *key
+	f.apiKeys[key.ID] = &copied
+	return nil
+}
+
+func (f *fakeDatabase) GetDelegatedAdminsByUser(ctx context.Context, userID string) ([]*database.DelegatedAdmin, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var assignments []*database.DelegatedAdmin
+	for _, assignment := range f.delegations {
+		if assignment.UserID == userID {
+			copied := *assignment
+			assignments = append(assignments, &copied)
+		}
+	}
+	return assignments, nil
+}
+
+func (f *fakeDatabase) DeleteUser(ctx context.Context, userID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.users, userID)
+	return nil
+}
+
+func (f *fakeDatabase) GetGroupsByCompany(ctx context.Context, companyID string) ([]*database.Group, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var groups []*database.Group
+	for _, group := range f.groups {
+		if group.CompanyID == companyID {
+			copied := *group
+			groups = append(groups, &copied)
+		}
+	}
+	return groups, nil
+}
+
+func (f *fakeDatabase) UpdateGroup(ctx context.Context, group *database.Group) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *group
+	f.groups[group.ID] = &copied
+	return nil
+}
+
+func (f *fakeDatabase) GetDelegatedAdminsByCompany(ctx context.Context, companyID string) ([]*database.DelegatedAdmin, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var assignments []*database.DelegatedAdmin
+	for _, assignment := range f.delegations {
+		if assignment.CompanyID == companyID {
+			copied := *assignment
+			assignments = append(assignments, &copied)
+		}
+	}
+	return assignments, nil
+}
+
+func (f *fakeDatabase) UpdateDelegatedAdmin(ctx context.Context, assignment *database.DelegatedAdmin) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *assignment
+	f.delegations[assignment.ID] = &copied
+	return nil
+}
+
+func (f *fakeDatabase) DeleteDelegatedAdmin(ctx context.Context, assignmentID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.delegations, assignmentID)
+	return nil
+}
+
+func (f *fakeDatabase) GetChangeRequest(ctx context.Context, requestID string) (*database.ChangeRequest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	request, ok := f.changes[requestID]
+	if !ok {
+		return nil, fmt.Errorf("change request not found")
+	}
+	copied := *request
+	return &copied, nil
+}
+
+func (f *fakeDatabase) UpdateChangeRequest(ctx context.Context, request *database.ChangeRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copied := *request
+	f.changes[request.ID] = &copied
+	return nil
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/audit"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/membership"
+)
+
+// errGoogleDomainNotAllowed is returned when a Google account belongs to no company's
+// domain and holds no invitation
+var errGoogleDomainNotAllowed = errors.New("google account domain not allowed")
+
+// provisionGoogleUser resolves the account for a Google identity signing in for the first
+// time. Workspace accounts whose hosted domain (hd) and verified email match a company's
+// domain are provisioned just in time with the company's default role. Other accounts
+// need a pending invitation. The identity is linked to the resolved account.
+func (h *AuthHandler) provisionGoogleUser(c *gin.Context, external *auth.User) (*database.User, error) {
+	ctx := c.Request.Context()
+	email := strings.ToLower(strings.TrimSpace(external.Email))
+	domain := emailDomain(email)
+
+	var companyID, role string
+	var invitation *database.Invitation
+	if external.HostedDomain != "" && strings.EqualFold(external.HostedDomain, domain) {
+		if company, err := h.databaseProvider.GetCompanyByDomain(ctx, domain); err == nil && company != nil && strings.EqualFold(company.Domain, domain) {
+			companyID = company.ID
+			role = jitDefaultRole(company)
+		}
+	}
+	if companyID == "" {
+		invitation = h.pendingInvitation(c, email)
+		if invitation == nil {
+			return nil, errGoogleDomainNotAllowed
+		}
+		companyID = invitation.CompanyID
+		role = string(auth.RoleUser) // Default role for invited users
+	}
+
+	now := time.Now()
+	created := false
+	dbUser, err := h.databaseProvider.GetUserByEmail(ctx, email)
+	if err == nil && dbUser != nil {
+		if _, err := membership.Get(ctx, h.databaseProvider, dbUser, companyID); err != nil {
+			if err := membership.Add(ctx, h.databaseProvider, dbUser, companyID, role); err != nil {
+				return nil, fmt.Errorf("failed to add company membership: %w", err)
+			}
+		}
+	} else {
+		dbUser = &database.User{
+			ID:               uuid.New().String(),
+			Email:            email,
+			Name:             external.Name,
+			Picture:          external.Picture,
+			CompanyID:        companyID,
+			Role:             role,
+			IsActive:         true,
+			CreatedAt:        now,
+			UpdatedAt:        now,
+			InvitationStatus: "active",
+			ActivatedAt:      now,
+		}
+		if err := h.databaseProvider.CreateUser(ctx, dbUser); err != nil {
+			return nil, fmt.Errorf("failed to create user: %w", err)
+		}
+		created = true
+	}
+
+	if invitation != nil {
+		invitation.Status = "accepted"
+		invitation.AcceptedAt = now
+		if err := h.databaseProvider.UpdateInvitation(ctx, invitation); err != nil {
+			return nil, fmt.Errorf("failed to update invitation: %w", err)
+		}
+	}
+
+	identity := &database.UserIdentity{
+		ID:          auth.IdentityID(auth.ConnectionGoogle, external.ID),
+		UserID:      dbUser.ID,
+		Connection:  auth.ConnectionGoogle,
+		Subject:     external.ID,
+		Email:       email,
+		LastLoginAt: now,
+	}
+	if err := h.databaseProvider.CreateUserIdentity(ctx, identity); err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	action := "identity.linked"
+	if created {
+		action = "user.jit_provisioned"
+	}
+	event := audit.ForRequest(c, action, "user", dbUser.ID)
+	event.CompanyID = companyID
+	event.ActorID = dbUser.ID
+	event.ActorEmail = email
+	event.Details["connection"] = identity.Connection
+	event.Details["identity_id"] = identity.ID
+	event.Details["role"] = role
+	if invitation != nil {
+		event.Details["invitation_id"] = invitation.ID
+	} else {
+		event.Details["hosted_domain"] = domain
+	}
+	audit.Record(ctx, h.databaseProvider, event)
+
+	return dbUser, nil
+}
+
+// pendingInvitation returns an invitation for the email address that can still be accepted
+func (h *AuthHandler) pendingInvitation(c *gin.Context, email string) *database.Invitation {
+	invitations, err := h.databaseProvider.GetInvitationsByEmail(c.Request.Context(), email)
+	if err != nil {
+		return nil
+	}
+	for _, invitation := range invitations {
+		if invitation.Status != "accepted" && invitation.Status != "expired" && time.Now().Before(invitation.ExpiresAt) {
+			return invitation
+		}
+	}
+	return nil
+}
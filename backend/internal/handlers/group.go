@@ -0,0 +1,447 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/audit"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/groups"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/membership"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
+)
+
+// GroupHandler handles company groups and their members
+type GroupHandler struct {
+	databaseProvider database.DatabaseProvider
+}
+
+// NewGroupHandler creates a new group handler
+func NewGroupHandler(databaseProvider database.DatabaseProvider) *GroupHandler {
+	return &GroupHandler{
+		databaseProvider: databaseProvider,
+	}
+}
+
+// GetGroups lists the company's groups
+func (h *GroupHandler) GetGroups(c *gin.Context) {
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	companyGroups, ok := h.companyGroups(c, user.CompanyID)
+	if !ok {
+		return
+	}
+	if companyGroups == nil {
+		companyGroups = []*database.Group{}
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"groups": companyGroups,
+		},
+	})
+}
+
+// GetGroup returns a group with the users who effectively belong to it
+func (h *GroupHandler) GetGroup(c *gin.Context) {
+	group, companyGroups, ok := h.group(c)
+	if !ok {
+		return
+	}
+
+	members := groups.Members(companyGroups, group.ID)
+	if members == nil {
+		members = []string{}
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"group":              group,
+			"effective_user_ids": members,
+		},
+	})
+}
+
+// CreateGroup creates a group in the company
+func (h *GroupHandler) CreateGroup(c *gin.Context) {
+	var req models.GroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+	companyGroups, ok := h.companyGroups(c, user.CompanyID)
+	if !ok {
+		return
+	}
+
+	group := &database.Group{
+		ID:        uuid.New().String(),
+		CompanyID: user.CompanyID,
+		UserIDs:   []string{},
+		GroupIDs:  []string{},
+	}
+	if !applyGroupRequest(c, group, companyGroups, req) {
+		return
+	}
+
+	if err := h.databaseProvider.CreateGroup(c.Request.Context(), group); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to create group",
+		})
+		return
+	}
+
+	event := audit.ForRequest(c, "group.created", "group", group.ID)
+	event.Details["name"] = group.Name
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Group created successfully",
+		Data: gin.H{
+			"group": group,
+		},
+	})
+}
+
+// UpdateGroup renames a group or changes its description
+func (h *GroupHandler) UpdateGroup(c *gin.Context) {
+	var req models.GroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	group, companyGroups, ok := h.group(c)
+	if !ok {
+		return
+	}
+	if !applyGroupRequest(c, group, companyGroups, req) {
+		return
+	}
+
+	if !h.save(c, group) {
+		return
+	}
+
+	event := audit.ForRequest(c, "group.updated", "group", group.ID)
+	event.Details["name"] = group.Name
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Group updated successfully",
+		Data: gin.H{
+			"group": group,
+		},
+	})
+}
+
+// DeleteGroup deletes a group and removes it from the groups it was nested in. Groups
+// nested in it are kept.
+func (h *GroupHandler) DeleteGroup(c *gin.Context) {
+	group, companyGroups, ok := h.group(c)
+	if !ok {
+		return
+	}
+
+	for _, parent := range companyGroups {
+		if !groups.Contains(parent.GroupIDs, group.ID) {
+			continue
+		}
+		parent.GroupIDs = groups.Without(parent.GroupIDs, group.ID)
+		if !h.save(c, parent) {
+			return
+		}
+	}
+
+	if err := h.databaseProvider.DeleteGroup(c.Request.Context(), group.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to delete group",
+		})
+		return
+	}
+
+	event := audit.ForRequest(c, "group.deleted", "group", group.ID)
+	event.Details["name"] = group.Name
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Group deleted successfully",
+	})
+}
+
+// AddGroupMembers adds users of the company and nested groups to a group
+func (h *GroupHandler) AddGroupMembers(c *gin.Context) {
+	var req models.GroupMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+	if len(req.UserIDs) == 0 && len(req.GroupIDs) == 0 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Provide user_ids or group_ids",
+		})
+		return
+	}
+
+	group, companyGroups, ok := h.group(c)
+	if !ok {
+		return
+	}
+
+	for _, userID := range req.UserIDs {
+		if groups.Contains(group.UserIDs, userID) {
+			continue
+		}
+		user, err := h.databaseProvider.GetUser(c.Request.Context(), userID)
+		if err == nil {
+			_, err = membership.Get(c.Request.Context(), h.databaseProvider, user, group.CompanyID)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "User is not a member of the company: " + userID,
+			})
+			return
+		}
+		group.UserIDs = append(group.UserIDs, userID)
+	}
+
+	for _, nestedID := range req.GroupIDs {
+		if groups.Contains(group.GroupIDs, nestedID) {
+			continue
+		}
+		if !groupExists(companyGroups, nestedID) {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "Group not found: " + nestedID,
+			})
+			return
+		}
+		if groups.CreatesCycle(companyGroups, group.ID, nestedID) {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "A group cannot contain itself: " + nestedID,
+			})
+			return
+		}
+		group.GroupIDs = append(group.GroupIDs, nestedID)
+	}
+
+	if !h.save(c, group) {
+		return
+	}
+
+	event := audit.ForRequest(c, "group.members_added", "group", group.ID)
+	event.Details["user_ids"] = req.UserIDs
+	event.Details["group_ids"] = req.GroupIDs
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Members added successfully",
+		Data: gin.H{
+			"group": group,
+		},
+	})
+}
+
+// RemoveGroupUser removes a user from a group
+func (h *GroupHandler) RemoveGroupUser(c *gin.Context) {
+	h.removeMember(c, "user", c.Param("userID"))
+}
+
+// RemoveNestedGroup removes a nested group from a group
+func (h *GroupHandler) RemoveNestedGroup(c *gin.Context) {
+	h.removeMember(c, "group", c.Param("nestedID"))
+}
+
+// GetUserGroups returns the groups a user effectively belongs to. Users can always see
+// their own groups.
+func (h *GroupHandler) GetUserGroups(c *gin.Context) {
+	currentUser, ok := companyUser(c)
+	if !ok {
+		return
+	}
+	userID := c.Param("id")
+
+	if !currentUser.Can(rbac.UsersRead) && currentUser.UserID != userID {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Insufficient permissions",
+		})
+		return
+	}
+
+	companyGroups, ok := h.companyGroups(c, currentUser.CompanyID)
+	if !ok {
+		return
+	}
+
+	effective := groups.Resolve(companyGroups, userID)
+	userGroups := []*database.Group{}
+	for _, group := range companyGroups {
+		if groups.Contains(effective, group.ID) {
+			userGroups = append(userGroups, group)
+		}
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"groups": userGroups,
+		},
+	})
+}
+
+// removeMember removes a user or nested group from the group named in the URL
+func (h *GroupHandler) removeMember(c *gin.Context, memberType, memberID string) {
+	group, _, ok := h.group(c)
+	if !ok {
+		return
+	}
+
+	members := &group.UserIDs
+	if memberType == "group" {
+		members = &group.GroupIDs
+	}
+	if !groups.Contains(*members, memberID) {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Member not found",
+		})
+		return
+	}
+	*members = groups.Without(*members, memberID)
+
+	if !h.save(c, group) {
+		return
+	}
+
+	event := audit.ForRequest(c, "group.member_removed", "group", group.ID)
+	event.Details["member_type"] = memberType
+	event.Details["member_id"] = memberID
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Member removed successfully",
+		Data: gin.H{
+			"group": group,
+		},
+	})
+}
+
+// group returns the group named in the URL if it belongs to the user's company, along
+// with all groups of the company
+func (h *GroupHandler) group(c *gin.Context) (*database.Group, []*database.Group, bool) {
+	user, ok := companyUser(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	companyGroups, ok := h.companyGroups(c, user.CompanyID)
+	if !ok {
+		return nil, nil, false
+	}
+	for _, group := range companyGroups {
+		if group.ID == c.Param("id") {
+			return group, companyGroups, true
+		}
+	}
+
+	c.JSON(http.StatusNotFound, models.APIResponse{
+		Success: false,
+		Error:   "Group not found",
+	})
+	return nil, nil, false
+}
+
+// companyGroups returns all groups of a company
+func (h *GroupHandler) companyGroups(c *gin.Context, companyID string) ([]*database.Group, bool) {
+	companyGroups, err := h.databaseProvider.GetGroupsByCompany(c.Request.Context(), companyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get groups",
+		})
+		return nil, false
+	}
+	return companyGroups, true
+}
+
+// save stores a changed group
+func (h *GroupHandler) save(c *gin.Context, group *database.Group) bool {
+	if err := h.databaseProvider.UpdateGroup(c.Request.Context(), group); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update group",
+		})
+		return false
+	}
+	return true
+}
+
+// applyGroupRequest validates a group request and copies it onto the group. Names must
+// be unique within the company.
+func applyGroupRequest(c *gin.Context, group *database.Group, companyGroups []*database.Group, req models.GroupRequest) bool {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Group name is required",
+		})
+		return false
+	}
+
+	for _, other := range companyGroups {
+		if other.ID != group.ID && strings.EqualFold(other.Name, name) {
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Success: false,
+				Error:   "A group with this name already exists",
+			})
+			return false
+		}
+	}
+
+	group.Name = name
+	group.Description = req.Description
+	return true
+}
+
+// groupExists reports whether companyGroups contains a group
+func groupExists(companyGroups []*database.Group, groupID string) bool {
+	for _, group := range companyGroups {
+		if group.ID == groupID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/membership"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
+)
+
+// authenticatedUser returns the authenticated caller, responding with an error otherwise
+func authenticatedUser(c *gin.Context) (models.UserContext, bool) {
+	user, err := principal.Get(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "User not authenticated",
+		})
+		return models.UserContext{}, false
+	}
+	return user, true
+}
+
+// companyUser returns the authenticated caller of a company, responding with an error
+// otherwise. Permissions are checked by the routes' RequirePermission middleware.
+func companyUser(c *gin.Context) (models.UserContext, bool) {
+	user, ok := authenticatedUser(c)
+	if !ok {
+		return models.UserContext{}, false
+	}
+
+	if user.CompanyID == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "User is not associated with a company",
+		})
+		return models.UserContext{}, false
+	}
+
+	return user, true
+}
+
+// companyMember returns a member of a company with the role they hold in it, responding
+// with an error when the user does not exist or is not a member
+func companyMember(c *gin.Context, databaseProvider database.DatabaseProvider, userID, companyID string) (*database.User, bool) {
+	user, err := databaseProvider.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "User not found",
+		})
+		return nil, false
+	}
+
+	companyMembership, err := membership.Get(c.Request.Context(), databaseProvider, user, companyID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Access denied",
+		})
+		return nil, false
+	}
+
+	user.Role = companyMembership.Role
+	return user, true
+}
+
+// authorizeUserTarget checks that the caller holds a permission over a target user of
+// their company, responding with an error otherwise. Permissions granted by the caller's
+// role cover every user; delegated admin assignments only cover the users in their scope
+// and never users whose role is privileged.
+func authorizeUserTarget(c *gin.Context, databaseProvider database.DatabaseProvider, user models.UserContext, permission string, target *database.User) bool {
+	if user.Can(permission) {
+		return true
+	}
+
+	// Roles that no longer exist grant nothing
+	targetPermissions, _ := rbac.Permissions(c.Request.Context(), databaseProvider, user.CompanyID, target.Role)
+	if rbac.Privileged(targetPermissions) {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Delegated admins cannot manage admins",
+		})
+		return false
+	}
+
+	inScope, err := rbac.CanForUser(c.Request.Context(), databaseProvider, user, permission, target.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to check delegated admin scope",
+		})
+		return false
+	}
+	if !inScope {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "User is outside your delegated admin scope",
+		})
+		return false
+	}
+
+	return true
+}
+
+// sessionUser converts a database user into the auth user a session token is issued for
+func sessionUser(dbUser *database.User) *auth.User {
+	return &auth.User{
+		ID:        dbUser.ID,
+		Email:     dbUser.Email,
+		Name:      dbUser.Name,
+		Picture:   dbUser.Picture,
+		CompanyID: dbUser.CompanyID,
+		Role:      auth.UserRole(dbUser.Role),
+		IsActive:  dbUser.IsActive,
+	}
+}
@@ -0,0 +1,439 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/audit"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+)
+
+// connectionStateCookie holds the state and nonce of an outstanding authorization code login
+const connectionStateCookie = "connection_login_state"
+
+// GetConnections lists the auth connections users can sign in with
+func (h *AuthHandler) GetConnections(c *gin.Context) {
+	connections := []string{}
+	defaultConnection := ""
+	if composite, ok := h.authProvider.(*auth.CompositeProvider); ok {
+		connections = composite.Connections()
+		defaultConnection = composite.DefaultConnection()
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"connections": connections,
+			"default":     defaultConnection,
+		},
+	})
+}
+
+// LoginWithToken exchanges a token issued by an auth connection (such as a Google
+// access token) for a session. The identity must be linked to an account, except on a
+// first Google sign-in, which provisions the account just in time.
+func (h *AuthHandler) LoginWithToken(c *gin.Context) {
+	var req models.ConnectionTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	if retryAfter := h.loginLockout(c.Request.Context(), ipThrottleKey(c.ClientIP())); retryAfter > 0 {
+		respondLockedOut(c, retryAfter)
+		return
+	}
+
+	external, ok := h.authenticateConnectionToken(c, req)
+	if !ok {
+		return
+	}
+
+	h.completeConnectionLogin(c, req.Connection, external)
+}
+
+// StartConnectionLogin redirects the browser to the sign-in page of a connection that
+// uses the authorization code flow
+func (h *AuthHandler) StartConnectionLogin(c *gin.Context) {
+	connection := c.Param("connection")
+	provider, ok := h.codeFlowProvider(c, connection)
+	if !ok {
+		return
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to start login",
+		})
+		return
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to start login",
+		})
+		return
+	}
+
+	// The provider redirects back with a top-level GET, which SameSite=Lax allows
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(connectionStateCookie, state+"."+nonce, int((10 * time.Minute).Seconds()), connectionBasePath(connection), "", true, true)
+
+	c.Redirect(http.StatusFound, provider.AuthCodeURL(state, nonce))
+}
+
+// ConnectionCallback handles the authorization code redirect of a connection
+func (h *AuthHandler) ConnectionCallback(c *gin.Context) {
+	connection := c.Param("connection")
+
+	stateCookie, _ := c.Cookie(connectionStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(connectionStateCookie, "", -1, connectionBasePath(connection), "", true, true)
+
+	provider, ok := h.codeFlowProvider(c, connection)
+	if !ok {
+		return
+	}
+
+	if errorCode := c.Query("error"); errorCode != "" {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Identity provider returned an error: " + errorCode,
+		})
+		return
+	}
+
+	state, nonce, found := strings.Cut(stateCookie, ".")
+	if !found || state == "" || c.Query("state") != state {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Invalid login state",
+		})
+		return
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Authorization code is required",
+		})
+		return
+	}
+
+	if retryAfter := h.loginLockout(c.Request.Context(), ipThrottleKey(c.ClientIP())); retryAfter > 0 {
+		respondLockedOut(c, retryAfter)
+		return
+	}
+
+	external, err := provider.Exchange(c.Request.Context(), code, nonce)
+	if err != nil || external.ID == "" {
+		h.recordLoginFailure(c, "")
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Invalid login response",
+		})
+		return
+	}
+
+	h.completeConnectionLogin(c, connection, external)
+}
+
+// completeConnectionLogin signs in the account an authenticated external identity is
+// linked to, provisioning it on a first Google sign-in
+func (h *AuthHandler) completeConnectionLogin(c *gin.Context, connection string, external *auth.User) {
+	// The session token records the connection
+	c.Request = c.Request.WithContext(auth.WithConnection(c.Request.Context(), connection))
+
+	var dbUser *database.User
+	identity, err := h.databaseProvider.GetUserIdentity(c.Request.Context(), auth.IdentityID(connection, external.ID))
+	switch {
+	case err == nil:
+		dbUser, err = h.databaseProvider.GetUser(c.Request.Context(), identity.UserID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Error:   "User not found",
+			})
+			return
+		}
+	case connection == auth.ConnectionGoogle:
+		// First Google sign-in, the account is provisioned from the hosted domain or an invitation
+		if !h.checkSSONotEnforced(c, external.Email) {
+			return
+		}
+		dbUser, err = h.provisionGoogleUser(c, external)
+		if errors.Is(err, errGoogleDomainNotAllowed) {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "Your Google account's domain is not allowed to sign in without an invitation",
+			})
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to provision Google user %s: %v", external.Email, err)
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to provision user",
+			})
+			return
+		}
+	default:
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "No account is linked to this identity",
+			Data: gin.H{
+				"link_required": true,
+			},
+		})
+		return
+	}
+
+	if !h.checkSSONotEnforced(c, dbUser.Email) || !h.checkDirectoryTenant(c, dbUser, external) {
+		return
+	}
+
+	if identity != nil {
+		identity.LastLoginAt = time.Now()
+		if err := h.databaseProvider.UpdateUserIdentity(c.Request.Context(), identity); err != nil {
+			log.Printf("Failed to record sign-in of identity %s: %v", identity.ID, err)
+		}
+	}
+
+	if h.requireSecondFactor(c, dbUser) {
+		return
+	}
+
+	h.respondSession(c, dbUser, http.StatusOK, "Login successful", nil)
+}
+
+// checkSSONotEnforced responds with the SSO login path when the email's domain enforces
+// single sign-on
+func (h *AuthHandler) checkSSONotEnforced(c *gin.Context, email string) bool {
+	if loginURL, required := enforcedSSOLoginPath(c.Request.Context(), h.databaseProvider, email); required {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Single sign-on is required for this domain",
+			Data: gin.H{
+				"login_url": loginURL,
+			},
+		})
+		return false
+	}
+	return true
+}
+
+// checkDirectoryTenant rejects Entra ID accounts from a directory other than the one the
+// user's company restricts Microsoft sign-ins to
+func (h *AuthHandler) checkDirectoryTenant(c *gin.Context, dbUser *database.User, external *auth.User) bool {
+	if external.DirectoryID == "" || dbUser.CompanyID == "" {
+		return true
+	}
+
+	company, err := h.databaseProvider.GetCompany(c.Request.Context(), dbUser.CompanyID)
+	if err != nil || company.EntraTenantID == "" || strings.EqualFold(company.EntraTenantID, external.DirectoryID) {
+		return true
+	}
+
+	c.JSON(http.StatusForbidden, models.APIResponse{
+		Success: false,
+		Error:   "Your Microsoft account belongs to a tenant your company does not allow",
+	})
+	return false
+}
+
+// codeFlowProvider returns the provider of a connection that uses the authorization code flow
+func (h *AuthHandler) codeFlowProvider(c *gin.Context, connection string) (auth.CodeFlowProvider, bool) {
+	if composite, ok := h.authProvider.(*auth.CompositeProvider); ok {
+		if provider, ok := composite.Provider(connection); ok {
+			if codeFlow, ok := provider.(auth.CodeFlowProvider); ok {
+				return codeFlow, true
+			}
+		}
+	}
+
+	c.JSON(http.StatusNotFound, models.APIResponse{
+		Success: false,
+		Error:   "Unknown connection",
+	})
+	return nil, false
+}
+
+// connectionBasePath returns the path of a connection's login endpoints
+func connectionBasePath(connection string) string {
+	return "/api/v1/auth/connections/" + url.PathEscape(connection)
+}
+
+// GetIdentities lists the external identities linked to the current user
+func (h *AuthHandler) GetIdentities(c *gin.Context) {
+	dbUser, _, ok := h.mfaSubject(c, "")
+	if !ok {
+		return
+	}
+
+	identities, err := h.databaseProvider.GetUserIdentitiesByUser(c.Request.Context(), dbUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get identities",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"identities": identities,
+		},
+	})
+}
+
+// LinkIdentity links the identity a connection token belongs to with the current user
+func (h *AuthHandler) LinkIdentity(c *gin.Context) {
+	dbUser, _, ok := h.mfaSubject(c, "")
+	if !ok {
+		return
+	}
+
+	var req models.ConnectionTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	external, ok := h.authenticateConnectionToken(c, req)
+	if !ok {
+		return
+	}
+
+	if !h.checkDirectoryTenant(c, dbUser, external) {
+		return
+	}
+
+	identityID := auth.IdentityID(req.Connection, external.ID)
+	if existing, err := h.databaseProvider.GetUserIdentity(c.Request.Context(), identityID); err == nil {
+		if existing.UserID != dbUser.ID {
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Success: false,
+				Error:   "This identity is already linked to another account",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, models.APIResponse{
+			Success: true,
+			Message: "Identity already linked",
+			Data:    existing,
+		})
+		return
+	}
+
+	identity := &database.UserIdentity{
+		ID:         identityID,
+		UserID:     dbUser.ID,
+		Connection: req.Connection,
+		Subject:    external.ID,
+		Email:      external.Email,
+	}
+	if err := h.databaseProvider.CreateUserIdentity(c.Request.Context(), identity); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to link identity",
+		})
+		return
+	}
+
+	event := audit.ForRequest(c, "identity.linked", "user", dbUser.ID)
+	event.Details["connection"] = identity.Connection
+	event.Details["identity_id"] = identity.ID
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Identity linked",
+		Data:    identity,
+	})
+}
+
+// UnlinkIdentity removes one of the current user's linked identities
+func (h *AuthHandler) UnlinkIdentity(c *gin.Context) {
+	dbUser, _, ok := h.mfaSubject(c, "")
+	if !ok {
+		return
+	}
+
+	identity, err := h.databaseProvider.GetUserIdentity(c.Request.Context(), c.Param("id"))
+	if err != nil || identity.UserID != dbUser.ID {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Identity not found",
+		})
+		return
+	}
+
+	if err := h.databaseProvider.DeleteUserIdentity(c.Request.Context(), identity.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to unlink identity",
+		})
+		return
+	}
+
+	event := audit.ForRequest(c, "identity.unlinked", "user", dbUser.ID)
+	event.Details["connection"] = identity.Connection
+	event.Details["identity_id"] = identity.ID
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Identity unlinked",
+	})
+}
+
+// authenticateConnectionToken validates a token with the connection it names, responding
+// with an error when the connection is unknown or the token is invalid
+func (h *AuthHandler) authenticateConnectionToken(c *gin.Context, req models.ConnectionTokenRequest) (*auth.User, bool) {
+	// Tokens of the password connection are our own session tokens
+	if req.Connection == auth.ConnectionPassword {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "The password connection signs in with email and password",
+		})
+		return nil, false
+	}
+
+	ctx := auth.WithConnection(c.Request.Context(), req.Connection)
+	external, err := h.authProvider.AuthenticateWithToken(ctx, req.Token)
+	if errors.Is(err, auth.ErrUnknownConnection) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Unknown connection",
+		})
+		return nil, false
+	}
+	if err != nil || external.ID == "" {
+		h.recordLoginFailure(c, "")
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Invalid token",
+		})
+		return nil, false
+	}
+
+	return external, true
+}
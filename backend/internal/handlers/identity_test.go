@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+)
+
+func TestCompleteConnectionLoginChecksDirectoryTenant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const (
+		allowedTenant = "6f1d2c3b-0000-4000-8000-000000000001"
+		foreignTenant = "6f1d2c3b-0000-4000-8000-000000000002"
+	)
+
+	tests := []struct {
+		name        string
+		directoryID string
+		want        int
+	}{
+		{name: "company's tenant", directoryID: allowedTenant, want: http.StatusOK},
+		{name: "foreign tenant", directoryID: foreignTenant, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeDatabase()
+			db.companies["acme"] = &database.Company{ID: "acme", Domain: "acme.com", EntraTenantID: allowedTenant}
+			db.users["alice"] = &database.User{ID: "alice", Email: "alice@acme.com", CompanyID: "acme", Role: "user", IsActive: true}
+			identityID := auth.IdentityID(auth.ConnectionEntra, "object-alice")
+			db.identities[identityID] = &database.UserIdentity{ID: identityID, UserID: "alice", Connection: auth.ConnectionEntra, Subject: "object-alice"}
+			h := NewAuthHandler(&fakeAuthProvider{}, db, "test-secret")
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, connectionBasePath(auth.ConnectionEntra)+"/callback", nil)
+			h.completeConnectionLogin(c, auth.ConnectionEntra, &auth.User{
+				ID:          "object-alice",
+				Email:       "alice@acme.com",
+				DirectoryID: tt.directoryID,
+			})
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
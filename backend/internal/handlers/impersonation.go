@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/audit"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
+)
+
+// defaultImpersonationDuration applies when an admin does not choose how long to impersonate
+const defaultImpersonationDuration = 30 * time.Minute
+
+// StartImpersonation issues a time-boxed token that lets a company admin act as one of
+// their users. The token carries both identities and every change made with it is audited
+// against the admin.
+func (h *AuthHandler) StartImpersonation(c *gin.Context) {
+	var req models.ImpersonationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	admin, ok := companyUser(c)
+	if !ok {
+		return
+	}
+	if admin.ServiceAccountID != "" {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Service accounts cannot impersonate users",
+		})
+		return
+	}
+
+	subject, err := h.databaseProvider.GetUser(c.Request.Context(), c.Param("id"))
+	if err != nil || subject.CompanyID != admin.CompanyID {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "User not found",
+		})
+		return
+	}
+
+	// Admins can only impersonate active users with fewer privileges than their own
+	if subject.ID == admin.UserID || subject.Role == string(auth.RoleAdmin) {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Admins cannot be impersonated",
+		})
+		return
+	}
+	subjectPermissions, err := rbac.Held(c.Request.Context(), h.databaseProvider, admin.CompanyID, subject.ID, subject.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get user permissions",
+		})
+		return
+	}
+	if !rbac.HasAll(admin.Permissions, subjectPermissions) {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Cannot impersonate a user with permissions you do not hold",
+		})
+		return
+	}
+	if !subject.IsActive {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "User account is inactive",
+		})
+		return
+	}
+
+	duration := defaultImpersonationDuration
+	if req.DurationMinutes > 0 {
+		duration = time.Duration(req.DurationMinutes) * time.Minute
+	}
+	expiresAt := time.Now().Add(duration)
+
+	token, err := auth.SignPurposeToken(h.jwtSecret, auth.PurposeImpersonation, subject.ID, duration, map[string]interface{}{
+		"act": map[string]interface{}{"sub": admin.UserID},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to generate token",
+		})
+		return
+	}
+
+	event := audit.ForRequest(c, "impersonation.started", "user", subject.ID)
+	event.Details["reason"] = req.Reason
+	event.Details["expires_at"] = expiresAt
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Impersonation started",
+		Data: gin.H{
+			"token":      token,
+			"expires_at": expiresAt,
+			"user": gin.H{
+				"id":         subject.ID,
+				"email":      subject.Email,
+				"name":       subject.Name,
+				"company_id": subject.CompanyID,
+				"role":       subject.Role,
+			},
+			"actor": gin.H{
+				"id":    admin.UserID,
+				"email": admin.Email,
+			},
+		},
+	})
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
+)
+
+func TestImpersonationLimitedToSubjectsWithinActorPermissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	support := []string{rbac.CompanyRead, rbac.UsersRead, rbac.ShortcutsRead, rbac.UsersImpersonate}
+
+	tests := []struct {
+		name       string
+		role       string
+		delegation bool
+		want       int
+	}{
+		{name: "plain user", role: "user", want: http.StatusOK},
+		{name: "custom role with security:manage", role: "security-officer", want: http.StatusForbidden},
+		{name: "delegated users:write", role: "user", delegation: true, want: http.StatusForbidden},
+		{name: "admin", role: "admin", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newUserTestDatabase()
+			db.roles["security-officer"] = &database.CustomRole{ID: "security-officer", CompanyID: "acme", Name: "Security officer", Permissions: []string{rbac.CompanyRead, rbac.SecurityManage}}
+			db.users["subject"] = &database.User{ID: "subject", Email: "subject@acme.com", CompanyID: "acme", Role: tt.role, IsActive: true}
+			if tt.delegation {
+				db.delegations["helpdesk"] = &database.DelegatedAdmin{ID: "helpdesk", CompanyID: "acme", UserID: "subject", Role: "user-manager", AllUsers: true}
+			}
+			h := NewAuthHandler(&fakeAuthProvider{}, db, "test-secret")
+
+			body, _ := json.Marshal(models.ImpersonationRequest{Reason: "Ticket #1"})
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/users/subject/impersonate", bytes.NewReader(body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Params = gin.Params{{Key: "id", Value: "subject"}}
+			principal.Set(c, models.UserContext{UserID: "support", CompanyID: "acme", Role: "support", Permissions: support})
+			h.StartImpersonation(c)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"github.com/google/uuid"
 	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
 	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/membership"
 	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
 )
 
 // InvitationHandler handles invitation-related requests
@@ -37,23 +39,11 @@ func (h *InvitationHandler) CreateInvitation(c *gin.Context) {
 	}
 
 	// Get user from context
-	userContext, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User not authenticated",
-		})
+	currentUser, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
-
-	currentUser := userContext.(models.UserContext)
-
-	// Check if user is admin
-	if currentUser.Role != "admin" {
-		c.JSON(http.StatusForbidden, models.APIResponse{
-			Success: false,
-			Error:   "Only admins can invite users",
-		})
+	if !invitationAccess(c, currentUser) {
 		return
 	}
 
@@ -61,11 +51,12 @@ func (h *InvitationHandler) CreateInvitation(c *gin.Context) {
 
 	// Create invitations for each email
 	for _, email := range req.Emails {
-		// Check if user already exists
+		// Skip users who are already members of the company; users of other companies can be invited
 		existingUser, err := h.databaseProvider.GetUserByEmail(c.Request.Context(), email)
 		if err == nil && existingUser != nil {
-			// User already exists, skip
-			continue
+			if _, err := membership.Get(c.Request.Context(), h.databaseProvider, existingUser, currentUser.CompanyID); err == nil {
+				continue
+			}
 		}
 
 		// Create invitation
@@ -113,23 +104,11 @@ func (h *InvitationHandler) CreateInvitation(c *gin.Context) {
 // GetInvitations handles getting all invitations for a company
 func (h *InvitationHandler) GetInvitations(c *gin.Context) {
 	// Get user from context
-	userContext, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User not authenticated",
-		})
+	currentUser, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
-
-	currentUser := userContext.(models.UserContext)
-
-	// Check if user is admin
-	if currentUser.Role != "admin" {
-		c.JSON(http.StatusForbidden, models.APIResponse{
-			Success: false,
-			Error:   "Only admins can view invitations",
-		})
+	if !invitationAccess(c, currentUser) {
 		return
 	}
 
@@ -168,26 +147,14 @@ func (h *InvitationHandler) GetInvitations(c *gin.Context) {
 // DeleteInvitation handles deleting an invitation
 func (h *InvitationHandler) DeleteInvitation(c *gin.Context) {
 	// Get user from context
-	userContext, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User not authenticated",
-		})
+	currentUser, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
-
-	currentUser := userContext.(models.UserContext)
-	invitationID := c.Param("id")
-
-	// Check if user is admin
-	if currentUser.Role != "admin" {
-		c.JSON(http.StatusForbidden, models.APIResponse{
-			Success: false,
-			Error:   "Only admins can delete invitations",
-		})
+	if !invitationAccess(c, currentUser) {
 		return
 	}
+	invitationID := c.Param("id")
 
 	// Get invitation
 	invitation, err := h.databaseProvider.GetInvitation(c.Request.Context(), invitationID)
@@ -223,6 +190,84 @@ func (h *InvitationHandler) DeleteInvitation(c *gin.Context) {
 	})
 }
 
+// ResendInvitation sends a pending invitation again and restarts its expiry
+func (h *InvitationHandler) ResendInvitation(c *gin.Context) {
+	// Get user from context
+	currentUser, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+	if !invitationAccess(c, currentUser) {
+		return
+	}
+
+	// Get invitation
+	invitation, err := h.databaseProvider.GetInvitation(c.Request.Context(), c.Param("id"))
+	if err != nil || invitation.CompanyID != currentUser.CompanyID {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Invitation not found",
+		})
+		return
+	}
+	if invitation.Status != "pending" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Only pending invitations can be resent",
+		})
+		return
+	}
+
+	invitation.ExpiresAt = time.Now().AddDate(0, 0, 7) // 7 days expiry
+	if err := h.databaseProvider.UpdateInvitation(c.Request.Context(), invitation); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to resend invitation",
+		})
+		return
+	}
+	if err := h.databaseProvider.ResendInvitation(c.Request.Context(), invitation.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to resend invitation",
+		})
+		return
+	}
+
+	if err := h.authProvider.SendInvitation(c.Request.Context(), invitation.Email, currentUser.CompanyID, currentUser.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to send invitation",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Invitation resent successfully",
+		Data: gin.H{
+			"id":         invitation.ID,
+			"email":      invitation.Email,
+			"expires_at": invitation.ExpiresAt,
+		},
+	})
+}
+
+// invitationAccess checks that the caller may manage invitations, responding with an
+// error otherwise. Invitees aren't users yet, so delegated admins need an assignment
+// scoped to all users.
+func invitationAccess(c *gin.Context, user models.UserContext) bool {
+	if rbac.CanForAllUsers(user, rbac.UsersInvite) {
+		return true
+	}
+
+	c.JSON(http.StatusForbidden, models.APIResponse{
+		Success: false,
+		Error:   "Managing invitations requires a delegated admin scope of all users",
+	})
+	return false
+}
+
 // AcceptInvitation handles accepting an invitation
 func (h *InvitationHandler) AcceptInvitation(c *gin.Context) {
 	token := c.Param("token")
@@ -256,17 +301,11 @@ func (h *InvitationHandler) AcceptInvitation(c *gin.Context) {
 	}
 
 	// Get user from context (user should be authenticated to accept invitation)
-	userContext, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User not authenticated",
-		})
+	currentUser, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
 
-	currentUser := userContext.(models.UserContext)
-
 	// Check if user email matches invitation email
 	if currentUser.Email != invitation.Email {
 		c.JSON(http.StatusForbidden, models.APIResponse{
@@ -286,9 +325,8 @@ func (h *InvitationHandler) AcceptInvitation(c *gin.Context) {
 		return
 	}
 
-	user.CompanyID = invitation.CompanyID
-	user.Role = "user" // Default role for invited users
-	if err := h.databaseProvider.UpdateUser(c.Request.Context(), user); err != nil {
+	// Users who already belong to a company become members of this one as well
+	if err := membership.Add(c.Request.Context(), h.databaseProvider, user, invitation.CompanyID, "user"); err != nil { // Default role for invited users
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
 			Error:   "Failed to update user",
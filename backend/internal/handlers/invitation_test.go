@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
+)
+
+func TestCreateInvitationSkipsOnlyMembers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newFakeDatabase()
+	db.users["member"] = &database.User{ID: "member", Email: "member@acme.com", CompanyID: "acme", Role: "user", IsActive: true}
+	db.users["other"] = &database.User{ID: "other", Email: "consultant@globex.com", CompanyID: "globex", Role: "admin", IsActive: true}
+	h := NewInvitationHandler(db, &fakeAuthProvider{})
+
+	body, _ := json.Marshal(models.InviteUserRequest{Emails: []string{"member@acme.com", "consultant@globex.com", "new@acme.com"}})
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/invitations", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	principal.Set(c, models.UserContext{UserID: "admin", CompanyID: "acme", Role: "admin", Permissions: []string{rbac.UsersInvite}})
+
+	h.CreateInvitation(c)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", w.Code, w.Body.String())
+	}
+
+	invited := map[string]bool{}
+	for _, invitation := range db.invitations {
+		if invitation.CompanyID != "acme" {
+			t.Errorf("invitation for %s is for company %q", invitation.Email, invitation.CompanyID)
+		}
+		invited[invitation.Email] = true
+	}
+	if invited["member@acme.com"] {
+		t.Error("invited a user who is already a member")
+	}
+	if !invited["consultant@globex.com"] || !invited["new@acme.com"] {
+		t.Errorf("invitations = %v, want the user of another company and the new user", invited)
+	}
+}
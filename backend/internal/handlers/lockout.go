@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/audit"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
+)
+
+const (
+	// accountLockoutThreshold and ipLockoutThreshold are the number of failed attempts
+	// after which the account or IP address is locked
+	accountLockoutThreshold = 5
+	ipLockoutThreshold      = 20
+	// The first lockout lasts lockoutBaseDelay and doubles with every further failure
+	lockoutBaseDelay = 30 * time.Second
+	lockoutMaxDelay  = time.Hour
+	// lockoutResetAfter is how long without failures before the counters start over
+	lockoutResetAfter = 24 * time.Hour
+)
+
+// loginThrottleKey is a failed-attempt counter and the number of failures it allows
+type loginThrottleKey struct {
+	key       string
+	threshold int
+}
+
+// accountThrottleKey returns the failed-attempt counter of an email address
+func accountThrottleKey(emailAddress string) loginThrottleKey {
+	return loginThrottleKey{key: "account:" + strings.ToLower(strings.TrimSpace(emailAddress)), threshold: accountLockoutThreshold}
+}
+
+// ipThrottleKey returns the failed-attempt counter of an IP address
+func ipThrottleKey(ip string) loginThrottleKey {
+	return loginThrottleKey{key: "ip:" + ip, threshold: ipLockoutThreshold}
+}
+
+// mfaChallengeThrottleKey returns the wrong-code counter of an MFA challenge
+func mfaChallengeThrottleKey(challengeID string) loginThrottleKey {
+	return loginThrottleKey{key: "mfa_challenge:" + challengeID, threshold: mfaChallengeMaxAttempts}
+}
+
+// id returns the document ID of the counter, which keeps email addresses out of IDs
+func (k loginThrottleKey) id() string {
+	return auth.HashToken(k.key)
+}
+
+// lockoutDelay returns how long a counter stays locked after its latest failure
+func lockoutDelay(failures, threshold int) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+
+	delay := lockoutBaseDelay
+	for i := threshold; i < failures && delay < lockoutMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > lockoutMaxDelay {
+		delay = lockoutMaxDelay
+	}
+	return delay
+}
+
+// lockedUntil returns when a counter's lockout ends, or the zero time if it is not locked
+func lockedUntil(throttle *database.LoginThrottle, threshold int) time.Time {
+	delay := lockoutDelay(throttle.Failures, threshold)
+	if delay == 0 {
+		return time.Time{}
+	}
+	return throttle.LastFailureAt.Add(delay)
+}
+
+// loginLockout returns how long the longest active lockout among the keys still lasts
+func (h *AuthHandler) loginLockout(ctx context.Context, keys ...loginThrottleKey) time.Duration {
+	var remaining time.Duration
+	for _, key := range keys {
+		throttle, err := h.databaseProvider.GetLoginThrottle(ctx, key.id())
+		if err != nil {
+			continue
+		}
+		if left := time.Until(lockedUntil(throttle, key.threshold)); left > remaining {
+			remaining = left
+		}
+	}
+	return remaining
+}
+
+// respondLockedOut responds that sign-in is temporarily locked
+func respondLockedOut(c *gin.Context, retryAfter time.Duration) {
+	setRetryAfter(c, retryAfter)
+	c.JSON(http.StatusTooManyRequests, models.APIResponse{
+		Success: false,
+		Error:   "Too many failed attempts, please try again later",
+	})
+}
+
+// recordLoginFailure counts a failed attempt against the client IP and, when given, the
+// email address, emitting an audit event whenever a counter becomes locked
+func (h *AuthHandler) recordLoginFailure(c *gin.Context, emailAddress string) {
+	ctx := c.Request.Context()
+
+	keys := []loginThrottleKey{ipThrottleKey(c.ClientIP())}
+	if emailAddress != "" {
+		keys = append(keys, accountThrottleKey(emailAddress))
+	}
+
+	for _, key := range keys {
+		throttle, err := h.databaseProvider.RecordLoginFailure(ctx, key.id(), key.key, lockoutResetAfter)
+		if err != nil {
+			log.Printf("Failed to record failed login attempt: %v", err)
+			continue
+		}
+
+		until := lockedUntil(throttle, key.threshold)
+		if until.IsZero() {
+			continue
+		}
+
+		event := &database.AuditEvent{
+			Action:     "login.ip_locked",
+			TargetType: "ip",
+			TargetID:   c.ClientIP(),
+			IPAddress:  c.ClientIP(),
+			Details: map[string]interface{}{
+				"failures":     throttle.Failures,
+				"locked_until": until,
+			},
+		}
+		if strings.HasPrefix(key.key, "account:") {
+			event.Action = "login.account_locked"
+			event.TargetType = "user"
+			event.TargetID = ""
+			event.Details["email"] = emailAddress
+			if dbUser, err := h.databaseProvider.GetUserByEmail(ctx, emailAddress); err == nil && dbUser != nil {
+				event.CompanyID = dbUser.CompanyID
+				event.TargetID = dbUser.ID
+			}
+		}
+		audit.Record(ctx, h.databaseProvider, event)
+	}
+}
+
+// clearLoginFailures resets the failed-attempt counter of an email address after a successful sign-in
+func (h *AuthHandler) clearLoginFailures(ctx context.Context, emailAddress string) {
+	key := accountThrottleKey(emailAddress)
+	if _, err := h.databaseProvider.GetLoginThrottle(ctx, key.id()); err != nil {
+		return
+	}
+	if err := h.databaseProvider.DeleteLoginThrottle(ctx, key.id()); err != nil {
+		log.Printf("Failed to clear failed login attempts: %v", err)
+	}
+}
+
+// UnlockUser lets a company admin clear the sign-in lockout of one of their users
+func (h *AuthHandler) UnlockUser(c *gin.Context) {
+	admin, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	dbUser, ok := companyMember(c, h.databaseProvider, c.Param("id"), admin.CompanyID)
+	if !ok {
+		return
+	}
+	if !authorizeUserTarget(c, h.databaseProvider, admin, rbac.UsersWrite, dbUser) {
+		return
+	}
+
+	if err := h.databaseProvider.DeleteLoginThrottle(c.Request.Context(), accountThrottleKey(dbUser.Email).id()); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to unlock user",
+		})
+		return
+	}
+
+	audit.Record(c.Request.Context(), h.databaseProvider, audit.ForRequest(c, "login.account_unlocked", "user", dbUser.ID))
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "User unlocked",
+	})
+}
+
+// UnlockIP lets a company admin clear the sign-in lockout of an IP address, such as
+// their office's shared address
+func (h *AuthHandler) UnlockIP(c *gin.Context) {
+	_, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	ip := c.Param("ip")
+	if net.ParseIP(ip) == nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid IP address",
+		})
+		return
+	}
+
+	if err := h.databaseProvider.DeleteLoginThrottle(c.Request.Context(), ipThrottleKey(ip).id()); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to unlock IP address",
+		})
+		return
+	}
+
+	audit.Record(c.Request.Context(), h.databaseProvider, audit.ForRequest(c, "login.ip_unlocked", "ip", ip))
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "IP address unlocked",
+	})
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/membership"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
+)
+
+func TestUnlockUserResolvesActiveMembership(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newFakeDatabase()
+	db.users["consultant"] = &database.User{ID: "consultant", Email: "consultant@globex.com", CompanyID: "globex", Role: "user", IsActive: true}
+	db.users["stranger"] = &database.User{ID: "stranger", Email: "stranger@globex.com", CompanyID: "globex", Role: "user", IsActive: true}
+	db.memberships[membership.ID("consultant", "acme")] = &database.CompanyMembership{
+		ID:        membership.ID("consultant", "acme"),
+		UserID:    "consultant",
+		CompanyID: "acme",
+		Role:      "user",
+	}
+	h := NewAuthHandler(&fakeAuthProvider{}, db, "test-secret")
+
+	unlock := func(userID string) int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/users/"+userID+"/unlock", nil)
+		c.Params = gin.Params{{Key: "id", Value: userID}}
+		principal.Set(c, models.UserContext{UserID: "admin", CompanyID: "acme", Role: "admin", Permissions: []string{rbac.UsersWrite}})
+		h.UnlockUser(c)
+		return w.Code
+	}
+
+	key := accountThrottleKey("consultant@globex.com")
+	db.throttles[key.id()] = &database.LoginThrottle{ID: key.id(), Key: key.key, Failures: accountLockoutThreshold}
+
+	if status := unlock("consultant"); status != http.StatusOK {
+		t.Fatalf("unlocking a member of a secondary company status = %d, want 200", status)
+	}
+	if _, ok := db.throttles[key.id()]; ok {
+		t.Error("lockout of the member was not cleared")
+	}
+	if status := unlock("stranger"); status == http.StatusOK {
+		t.Error("unlocked a user who is not a member of the company")
+	}
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/email"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+)
+
+const (
+	// magicLinkTTL is how long a sign-in link stays valid
+	magicLinkTTL = 10 * time.Minute
+	// emailLimit and ipLimit cap emails sent per address and requests per IP per window
+	emailLimit       = 5
+	ipLimit          = 20
+	emailLimitWindow = 15 * time.Minute
+)
+
+// RequestMagicLink emails a single-use sign-in link. The response is the same whether
+// or not an account exists for the email.
+func (h *AuthHandler) RequestMagicLink(c *gin.Context) {
+	var req models.MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid email address",
+		})
+		return
+	}
+	emailAddress := strings.TrimSpace(req.Email)
+
+	if allowed, retryAfter := h.ipLimiter.Allow(c.ClientIP()); !allowed {
+		tooManyRequests(c, retryAfter)
+		return
+	}
+	if allowed, retryAfter := h.emailLimiter.Allow(strings.ToLower(emailAddress)); !allowed {
+		tooManyRequests(c, retryAfter)
+		return
+	}
+
+	// Local sign-in is disabled when the company requires SSO
+	if loginURL, required := enforcedSSOLoginPath(c.Request.Context(), h.databaseProvider, emailAddress); required {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Single sign-on is required for this domain",
+			Data: gin.H{
+				"login_url": loginURL,
+			},
+		})
+		return
+	}
+
+	dbUser, err := h.databaseProvider.GetUserByEmail(c.Request.Context(), emailAddress)
+	if err == nil && dbUser != nil && dbUser.IsActive {
+		if err := h.sendMagicLink(c.Request.Context(), dbUser); err != nil {
+			log.Printf("Failed to send sign-in link to user %s: %v", dbUser.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "If an account exists for this email, a sign-in link has been sent",
+	})
+}
+
+// VerifyMagicLink exchanges a sign-in link token for a session
+func (h *AuthHandler) VerifyMagicLink(c *gin.Context) {
+	var req models.MagicLinkVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	dbUser, err := h.consumeEmailToken(c.Request.Context(), auth.PurposeMagicLink, req.Token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Invalid or expired sign-in link",
+		})
+		return
+	}
+
+	if loginURL, required := enforcedSSOLoginPath(c.Request.Context(), h.databaseProvider, dbUser.Email); required {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Single sign-on is required for this domain",
+			Data: gin.H{
+				"login_url": loginURL,
+			},
+		})
+		return
+	}
+
+	// Receiving the link proves the user owns the address
+	if dbUser.EmailVerificationPending {
+		dbUser.EmailVerificationPending = false
+		dbUser.EmailVerifiedAt = time.Now()
+		if err := h.databaseProvider.UpdateUser(c.Request.Context(), dbUser); err != nil {
+			log.Printf("Failed to mark email of user %s verified: %v", dbUser.ID, err)
+		}
+	}
+
+	// The link replaces the password, not the second factor
+	if h.requireSecondFactor(c, dbUser) {
+		return
+	}
+
+	h.respondSession(c, dbUser, http.StatusOK, "Login successful", nil)
+}
+
+// sendMagicLink issues a sign-in token and emails the link
+func (h *AuthHandler) sendMagicLink(ctx context.Context, dbUser *database.User) error {
+	token, err := h.issueEmailToken(ctx, dbUser, auth.PurposeMagicLink, magicLinkTTL)
+	if err != nil {
+		return err
+	}
+
+	link := h.appURL + "/auth/magic-link?token=" + url.QueryEscape(token)
+	return h.mailer.Send(ctx, email.Message{
+		To:      dbUser.Email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf("Use the link below to sign in. It expires in %d minutes and can only be used once.\n\n%s\n\nIf you did not request this link, you can ignore this email.\n",
+			int(magicLinkTTL.Minutes()), link),
+	})
+}
+
+// issueEmailToken creates a signed single-use token for a user and stores its hash
+func (h *AuthHandler) issueEmailToken(ctx context.Context, dbUser *database.User, purpose string, ttl time.Duration) (string, error) {
+	token, err := auth.SignPurposeToken(h.jwtSecret, purpose, dbUser.ID, ttl, nil)
+	if err != nil {
+		return "", err
+	}
+
+	err = h.databaseProvider.CreateAuthToken(ctx, &database.AuthToken{
+		ID:        uuid.New().String(),
+		UserID:    dbUser.ID,
+		Purpose:   purpose,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store token: %w", err)
+	}
+
+	return token, nil
+}
+
+// consumeEmailToken validates a token from issueEmailToken, marks it used and returns its user
+func (h *AuthHandler) consumeEmailToken(ctx context.Context, purpose, token string) (*database.User, error) {
+	claims, err := auth.ParsePurposeToken(h.jwtSecret, purpose, token)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := h.databaseProvider.ConsumeAuthToken(ctx, auth.HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if stored.Purpose != purpose || stored.UserID != claims["sub"].(string) {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	return h.databaseProvider.GetUser(ctx, stored.UserID)
+}
+
+// tooManyRequests responds that a rate limit was exceeded
+func tooManyRequests(c *gin.Context, retryAfter time.Duration) {
+	setRetryAfter(c, retryAfter)
+	c.JSON(http.StatusTooManyRequests, models.APIResponse{
+		Success: false,
+		Error:   "Too many requests, please try again later",
+	})
+}
+
+// setRetryAfter sets the Retry-After header in whole seconds
+func setRetryAfter(c *gin.Context, retryAfter time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+}
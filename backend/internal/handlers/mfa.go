@@ -0,0 +1,602 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/membership"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+)
+
+const (
+	// mfaTokenTTL is how long a user has to complete the second login step
+	mfaTokenTTL = 5 * time.Minute
+	// mfaIssuer is the issuer shown in authenticator apps
+	mfaIssuer = "Admin Portal"
+	// mfaChallengeMaxAttempts is the number of wrong codes after which an MFA challenge
+	// can no longer be used
+	mfaChallengeMaxAttempts = 5
+)
+
+// GetMFAStatus handles getting the current user's MFA status
+func (h *AuthHandler) GetMFAStatus(c *gin.Context) {
+	dbUser, _, ok := h.mfaSubject(c, "")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"mfa_enabled":              dbUser.MFAEnabled,
+			"mfa_enabled_at":           dbUser.MFAEnabledAt,
+			"mfa_required":             h.companyRequiresMFA(c, dbUser),
+			"methods":                  h.mfaMethods(c.Request.Context(), dbUser),
+			"recovery_codes_remaining": len(dbUser.RecoveryCodeHashes),
+		},
+	})
+}
+
+// VerifyMFA completes a login with a TOTP or recovery code
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req models.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	dbUser, challengeKey, ok := h.mfaChallengeUser(c, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	// Wrong codes count towards the same lockouts as wrong passwords
+	ctx := c.Request.Context()
+	if retryAfter := h.loginLockout(ctx, ipThrottleKey(c.ClientIP()), accountThrottleKey(dbUser.Email)); retryAfter > 0 {
+		respondLockedOut(c, retryAfter)
+		return
+	}
+
+	code := req.Code
+	if code == "" {
+		code = req.RecoveryCode
+	}
+	if !h.verifySecondFactor(ctx, dbUser, code) {
+		h.recordMFAFailure(c, dbUser, challengeKey)
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Invalid verification code",
+		})
+		return
+	}
+
+	h.completeMFAChallenge(c, dbUser, req.MFAToken)
+}
+
+// mfaChallengeUser resolves the user of an MFA challenge that can still be completed,
+// responding with an error otherwise
+func (h *AuthHandler) mfaChallengeUser(c *gin.Context, mfaToken string) (*database.User, loginThrottleKey, bool) {
+	claims, err := auth.ParsePurposeToken(h.jwtSecret, auth.PurposeMFAChallenge, mfaToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Invalid or expired MFA token",
+		})
+		return nil, loginThrottleKey{}, false
+	}
+
+	ctx := c.Request.Context()
+	challengeID, _ := claims["jti"].(string)
+	challengeKey := mfaChallengeThrottleKey(challengeID)
+
+	dbUser, err := h.databaseProvider.GetUser(ctx, claims["sub"].(string))
+	if err != nil || !dbUser.MFAEnabled || challengeID == "" || h.mfaChallengeExhausted(ctx, challengeKey) {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Invalid or expired MFA token",
+		})
+		return nil, loginThrottleKey{}, false
+	}
+	return dbUser, challengeKey, true
+}
+
+// recordMFAFailure counts a failed second factor towards the login lockouts and the
+// attempts left on the MFA challenge
+func (h *AuthHandler) recordMFAFailure(c *gin.Context, dbUser *database.User, challengeKey loginThrottleKey) {
+	h.recordLoginFailure(c, dbUser.Email)
+	if _, err := h.databaseProvider.RecordLoginFailure(c.Request.Context(), challengeKey.id(), challengeKey.key, mfaTokenTTL); err != nil {
+		log.Printf("Failed to record failed MFA attempt: %v", err)
+	}
+}
+
+// completeMFAChallenge consumes a verified MFA challenge and issues the session
+func (h *AuthHandler) completeMFAChallenge(c *gin.Context, dbUser *database.User, mfaToken string) {
+	// Each challenge completes a single login
+	ctx := c.Request.Context()
+	if _, err := h.databaseProvider.ConsumeAuthToken(ctx, auth.HashToken(mfaToken)); err != nil {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Invalid or expired MFA token",
+		})
+		return
+	}
+	h.clearLoginFailures(ctx, dbUser.Email)
+
+	h.respondSession(c, dbUser, http.StatusOK, "Login successful", nil)
+}
+
+// EnrollTOTP starts TOTP enrollment and returns the secret and otpauth URI. It is
+// used by signed-in users and, with an enrollment token, during login when the
+// company requires MFA.
+func (h *AuthHandler) EnrollTOTP(c *gin.Context) {
+	var req models.MFAEnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	dbUser, _, ok := h.mfaSubject(c, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	if dbUser.TOTPSecret != "" {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   "Authenticator app is already enabled",
+		})
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to start MFA enrollment",
+		})
+		return
+	}
+
+	dbUser.TOTPPendingSecret = secret
+	if err := h.databaseProvider.UpdateUser(c.Request.Context(), dbUser); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to start MFA enrollment",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Scan the QR code with your authenticator app and confirm with a code",
+		Data: gin.H{
+			"secret":      secret,
+			"otpauth_uri": auth.TOTPURI(mfaIssuer, dbUser.Email, secret),
+		},
+	})
+}
+
+// ConfirmTOTP finishes TOTP enrollment and returns the recovery codes. When enrolling
+// during login the response also contains the session token.
+func (h *AuthHandler) ConfirmTOTP(c *gin.Context) {
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	dbUser, duringLogin, ok := h.mfaSubject(c, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	if dbUser.TOTPPendingSecret == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "No MFA enrollment in progress",
+		})
+		return
+	}
+
+	step, valid := auth.ValidateTOTP(dbUser.TOTPPendingSecret, req.Code, time.Now())
+	if !valid {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid verification code",
+		})
+		return
+	}
+
+	codes, hashes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to generate recovery codes",
+		})
+		return
+	}
+
+	dbUser.TOTPSecret = dbUser.TOTPPendingSecret
+	dbUser.TOTPPendingSecret = ""
+	dbUser.TOTPLastUsedStep = step
+	dbUser.RecoveryCodeHashes = hashes
+	if !dbUser.MFAEnabled {
+		dbUser.MFAEnabled = true
+		dbUser.MFAEnabledAt = time.Now()
+	}
+
+	if err := h.databaseProvider.UpdateUser(c.Request.Context(), dbUser); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to enable MFA",
+		})
+		return
+	}
+
+	if duringLogin {
+		h.respondSession(c, dbUser, http.StatusOK, "MFA enabled, login successful", gin.H{
+			"recovery_codes": codes,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "MFA enabled. Store the recovery codes now, they will not be shown again.",
+		Data: gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// RegenerateRecoveryCodes replaces the current user's recovery codes
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	dbUser, _, ok := h.mfaSubject(c, "")
+	if !ok {
+		return
+	}
+
+	if !dbUser.MFAEnabled || !h.verifySecondFactor(c.Request.Context(), dbUser, req.Code) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid verification code",
+		})
+		return
+	}
+
+	codes, hashes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to generate recovery codes",
+		})
+		return
+	}
+
+	dbUser.RecoveryCodeHashes = hashes
+	if err := h.databaseProvider.UpdateUser(c.Request.Context(), dbUser); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to save recovery codes",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Recovery codes regenerated",
+		Data: gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// DisableMFA turns off MFA for the current user unless the company requires it.
+// Registered passkeys are kept for passwordless login.
+func (h *AuthHandler) DisableMFA(c *gin.Context) {
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	dbUser, _, ok := h.mfaSubject(c, "")
+	if !ok {
+		return
+	}
+
+	if h.companyRequiresMFA(c, dbUser) {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Your company requires MFA",
+		})
+		return
+	}
+
+	if !dbUser.MFAEnabled || !h.verifySecondFactor(c.Request.Context(), dbUser, req.Code) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid verification code",
+		})
+		return
+	}
+
+	dbUser.MFAEnabled = false
+	dbUser.MFAEnabledAt = time.Time{}
+	dbUser.TOTPSecret = ""
+	dbUser.TOTPPendingSecret = ""
+	dbUser.TOTPLastUsedStep = 0
+	dbUser.RecoveryCodeHashes = nil
+
+	if err := h.databaseProvider.UpdateUser(c.Request.Context(), dbUser); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to disable MFA",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "MFA disabled",
+	})
+}
+
+// requireSecondFactor responds with an MFA challenge (or an enrollment requirement)
+// instead of a session when the user must complete a second step. It reports whether
+// a response was written.
+func (h *AuthHandler) requireSecondFactor(c *gin.Context, dbUser *database.User) bool {
+	purpose := ""
+	data := gin.H{}
+	switch {
+	case dbUser.MFAEnabled:
+		purpose = auth.PurposeMFAChallenge
+		data["mfa_required"] = true
+		data["methods"] = h.mfaMethods(c.Request.Context(), dbUser)
+	case h.companyRequiresMFA(c, dbUser):
+		purpose = auth.PurposeMFAEnrollment
+		data["mfa_enrollment_required"] = true
+	default:
+		return false
+	}
+
+	var mfaToken string
+	var err error
+	if purpose == auth.PurposeMFAChallenge {
+		// Challenges are stored so they can only be completed once
+		mfaToken, err = h.issueEmailToken(c.Request.Context(), dbUser, purpose, mfaTokenTTL)
+	} else {
+		mfaToken, err = auth.SignPurposeToken(h.jwtSecret, purpose, dbUser.ID, mfaTokenTTL, nil)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to generate token",
+		})
+		return true
+	}
+	data["mfa_token"] = mfaToken
+	data["expires_in"] = int(mfaTokenTTL.Seconds())
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Multi-factor authentication required",
+		Data:    data,
+	})
+	return true
+}
+
+// companyRequiresMFA reports whether the company the user acts in requires MFA for them:
+// the active company when signed in, otherwise the home company a login signs in to
+func (h *AuthHandler) companyRequiresMFA(c *gin.Context, dbUser *database.User) bool {
+	companyID := dbUser.CompanyID
+	if user, err := principal.Get(c); err == nil && user.UserID == dbUser.ID {
+		companyID = user.CompanyID
+	}
+	return h.companyRequiresMFAIn(c.Request.Context(), dbUser, companyID)
+}
+
+// companyRequiresMFAIn reports whether a company requires MFA for a user, given the role
+// the user holds in it
+func (h *AuthHandler) companyRequiresMFAIn(ctx context.Context, dbUser *database.User, companyID string) bool {
+	if companyID == "" {
+		return false
+	}
+
+	companyMembership, err := membership.Get(ctx, h.databaseProvider, dbUser, companyID)
+	if err != nil {
+		return false
+	}
+	company, err := h.databaseProvider.GetCompany(ctx, companyID)
+	if err != nil {
+		return false
+	}
+
+	switch company.MFARequirement {
+	case "all":
+		return true
+	case "admins":
+		return companyMembership.Role == string(auth.RoleAdmin)
+	default:
+		return false
+	}
+}
+
+// mfaChallengeExhausted reports whether too many wrong codes were entered for an MFA challenge
+func (h *AuthHandler) mfaChallengeExhausted(ctx context.Context, key loginThrottleKey) bool {
+	throttle, err := h.databaseProvider.GetLoginThrottle(ctx, key.id())
+	return err == nil && throttle.Failures >= key.threshold
+}
+
+// mfaSubject resolves the user an MFA request applies to: the signed-in user, or
+// the subject of an enrollment token during login. It reports whether the token was used.
+func (h *AuthHandler) mfaSubject(c *gin.Context, mfaToken string) (*database.User, bool, bool) {
+	userID := ""
+	duringLogin := false
+
+	if user, err := principal.Get(c); err == nil {
+		userID = user.UserID
+	} else if mfaToken != "" {
+		claims, err := auth.ParsePurposeToken(h.jwtSecret, auth.PurposeMFAEnrollment, mfaToken)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Error:   "Invalid or expired MFA token",
+			})
+			return nil, false, false
+		}
+		userID = claims["sub"].(string)
+		duringLogin = true
+	} else {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "User not authenticated",
+		})
+		return nil, false, false
+	}
+
+	dbUser, err := h.databaseProvider.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "User not found",
+		})
+		return nil, false, false
+	}
+
+	return dbUser, duringLogin, true
+}
+
+// verifySecondFactor checks a TOTP code or consumes a recovery code
+func (h *AuthHandler) verifySecondFactor(ctx context.Context, dbUser *database.User, code string) bool {
+	code = strings.TrimSpace(code)
+	if code == "" || !dbUser.MFAEnabled {
+		return false
+	}
+
+	if isTOTPCode(code) {
+		if dbUser.TOTPSecret == "" {
+			return false
+		}
+		step, valid := auth.ValidateTOTP(dbUser.TOTPSecret, code, time.Now())
+		// Each code may only be used once
+		if !valid || step <= dbUser.TOTPLastUsedStep {
+			return false
+		}
+		dbUser.TOTPLastUsedStep = step
+		return h.databaseProvider.UpdateUser(ctx, dbUser) == nil
+	}
+
+	hash := auth.HashRecoveryCode(code)
+	for i, stored := range dbUser.RecoveryCodeHashes {
+		if stored == hash {
+			dbUser.RecoveryCodeHashes = append(dbUser.RecoveryCodeHashes[:i:i], dbUser.RecoveryCodeHashes[i+1:]...)
+			return h.databaseProvider.UpdateUser(ctx, dbUser) == nil
+		}
+	}
+	return false
+}
+
+// respondSession issues a session token for a user and writes the login response
+func (h *AuthHandler) respondSession(c *gin.Context, dbUser *database.User, status int, message string, extra gin.H) {
+	if !dbUser.IsActive {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "User account is inactive",
+		})
+		return
+	}
+
+	// Generate JWT token recording the connection the user signed in with
+	user := sessionUser(dbUser)
+	user.Connection = auth.ConnectionFromContext(c.Request.Context())
+	token, err := h.authProvider.GenerateToken(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to generate token",
+		})
+		return
+	}
+
+	data := gin.H{
+		"token": token,
+		"user": gin.H{
+			"id":             dbUser.ID,
+			"email":          dbUser.Email,
+			"name":           dbUser.Name,
+			"picture":        dbUser.Picture,
+			"company_id":     dbUser.CompanyID,
+			"role":           dbUser.Role,
+			"email_verified": !dbUser.EmailVerificationPending,
+		},
+	}
+	for key, value := range extra {
+		data[key] = value
+	}
+
+	c.JSON(status, models.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    data,
+	})
+}
+
+// mfaMethods lists the second factors available to a user
+func (h *AuthHandler) mfaMethods(ctx context.Context, dbUser *database.User) []string {
+	var methods []string
+	if dbUser.TOTPSecret != "" {
+		methods = append(methods, "totp")
+	}
+	if h.webAuthn != nil {
+		if passkeys, err := h.databaseProvider.GetWebAuthnCredentialsByUser(ctx, dbUser.ID); err == nil && len(passkeys) > 0 {
+			methods = append(methods, "webauthn")
+		}
+	}
+	if len(dbUser.RecoveryCodeHashes) > 0 {
+		methods = append(methods, "recovery_code")
+	}
+	return methods
+}
+
+// isTOTPCode reports whether a code looks like a six-digit TOTP code
+func isTOTPCode(code string) bool {
+	if len(code) != 6 {
+		return false
+	}
+	for _, ch := range code {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
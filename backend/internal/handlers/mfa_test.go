@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/membership"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+)
+
+// fakeAuthProvider issues placeholder session tokens and counts activations. Other
+// operations panic through the nil embedded interface.
+type fakeAuthProvider struct {
+	auth.AuthProvider
+
+	activations int
+}
+
+func (p *fakeAuthProvider) GenerateToken(user *auth.User) (string, error) {
+	return "session-" + user.ID, nil
+}
+
+func (p *fakeAuthProvider) SendInvitation(ctx context.Context, email, companyID string, invitedBy string) error {
+	return nil
+}
+
+func (p *fakeAuthProvider) DeleteUser(ctx context.Context, userID string) error {
+	return nil
+}
+
+func (p *fakeAuthProvider) ActivateUser(ctx context.Context, activationToken string) error {
+	p.activations++
+	return nil
+}
+
+// newMFATestHandler returns an auth handler and a user with MFA enabled and the given
+// recovery codes
+func newMFATestHandler(t *testing.T) (*AuthHandler, *fakeDatabase, []string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	codes, hashes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes() error = %v", err)
+	}
+
+	db := newFakeDatabase()
+	db.users["alice"] = &database.User{
+		ID:                 "alice",
+		Email:              "alice@acme.com",
+		Role:               "user",
+		IsActive:           true,
+		MFAEnabled:         true,
+		RecoveryCodeHashes: hashes,
+	}
+	return NewAuthHandler(&fakeAuthProvider{}, db, "test-secret"), db, codes
+}
+
+// mfaChallenge starts a login for a user and returns the MFA token of the challenge
+func mfaChallenge(t *testing.T, h *AuthHandler, db *fakeDatabase, userID string) string {
+	t.Helper()
+	dbUser, _ := db.GetUser(context.Background(), userID)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+	if !h.requireSecondFactor(c, dbUser) {
+		t.Fatal("requireSecondFactor() did not require a second factor")
+	}
+
+	var resp struct {
+		Data struct {
+			MFAToken string `json:"mfa_token"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Data.MFAToken == "" {
+		t.Fatalf("challenge response %s has no mfa_token", w.Body.String())
+	}
+	return resp.Data.MFAToken
+}
+
+// verifyMFA posts a code for a challenge and returns the response status
+func verifyMFA(h *AuthHandler, mfaToken, code string) int {
+	body, _ := json.Marshal(models.MFAVerifyRequest{MFAToken: mfaToken, RecoveryCode: code})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/auth/mfa/verify", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	h.VerifyMFA(c)
+	return w.Code
+}
+
+func TestVerifyMFAChallengeIsSingleUse(t *testing.T) {
+	h, db, codes := newMFATestHandler(t)
+	mfaToken := mfaChallenge(t, h, db, "alice")
+
+	if status := verifyMFA(h, mfaToken, codes[0]); status != http.StatusOK {
+		t.Fatalf("first verification status = %d, want 200", status)
+	}
+	if status := verifyMFA(h, mfaToken, codes[1]); status != http.StatusUnauthorized {
+		t.Errorf("reused challenge status = %d, want 401", status)
+	}
+}
+
+func TestVerifyMFAInvalidatesChallengeAfterWrongCodes(t *testing.T) {
+	h, db, codes := newMFATestHandler(t)
+	mfaToken := mfaChallenge(t, h, db, "alice")
+
+	for i := 0; i < mfaChallengeMaxAttempts; i++ {
+		if status := verifyMFA(h, mfaToken, "wrong-code"); status != http.StatusUnauthorized {
+			t.Fatalf("wrong code %d status = %d, want 401", i+1, status)
+		}
+	}
+	if status := verifyMFA(h, mfaToken, codes[0]); status == http.StatusOK {
+		t.Error("exhausted challenge accepted a valid code")
+	}
+
+	// Wrong codes lock the account like wrong passwords
+	if retryAfter := h.loginLockout(context.Background(), accountThrottleKey("alice@acme.com")); retryAfter <= 0 {
+		t.Error("wrong MFA codes did not lock the account")
+	}
+	if status := verifyMFA(h, mfaChallenge(t, h, db, "alice"), codes[0]); status != http.StatusTooManyRequests {
+		t.Errorf("locked account status = %d, want 429", status)
+	}
+}
+
+func TestCompanyRequiresMFAUsesActiveMembership(t *testing.T) {
+	h, db, _ := newMFATestHandler(t)
+	db.users["alice"].CompanyID = "home"
+	db.companies["home"] = &database.Company{ID: "home"}
+	db.companies["strict"] = &database.Company{ID: "strict", MFARequirement: "admins"}
+	db.memberships[membership.ID("alice", "strict")] = &database.CompanyMembership{
+		ID:        membership.ID("alice", "strict"),
+		UserID:    "alice",
+		CompanyID: "strict",
+		Role:      string(auth.RoleAdmin),
+	}
+	dbUser, _ := db.GetUser(context.Background(), "alice")
+
+	requiresMFA := func(activeCompanyID string) bool {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/auth/mfa", nil)
+		if activeCompanyID != "" {
+			principal.Set(c, models.UserContext{UserID: "alice", CompanyID: activeCompanyID})
+		}
+		return h.companyRequiresMFA(c, dbUser)
+	}
+
+	if !requiresMFA("strict") {
+		t.Error("admin membership of a company requiring MFA for admins is not enforced")
+	}
+	if requiresMFA("home") {
+		t.Error("home company without an MFA requirement requires MFA")
+	}
+	if requiresMFA("") {
+		t.Error("login to the home company requires MFA")
+	}
+	if requiresMFA("elsewhere") {
+		t.Error("company the user is not a member of requires MFA")
+	}
+}
@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/audit"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/membership"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+)
+
+// companyStatuses lists the statuses tenants can be searched by
+var companyStatuses = map[string]bool{
+	"active":    true,
+	"trial":     true,
+	"suspended": true,
+	"cancelled": true,
+}
+
+// OperatorHandler handles the platform operator console. Every action is audited
+// against the tenant it concerns.
+type OperatorHandler struct {
+	databaseProvider database.DatabaseProvider
+}
+
+// NewOperatorHandler creates a new operator handler
+func NewOperatorHandler(databaseProvider database.DatabaseProvider) *OperatorHandler {
+	return &OperatorHandler{
+		databaseProvider: databaseProvider,
+	}
+}
+
+// SearchCompanies lists tenants whose name or domain contains the "q" query parameter,
+// optionally filtered by "status"
+func (h *OperatorHandler) SearchCompanies(c *gin.Context) {
+	query := c.Query("q")
+	status := c.Query("status")
+	if status != "" && !companyStatuses[status] {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid status",
+		})
+		return
+	}
+
+	// Get pagination parameters
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	companies, err := h.databaseProvider.SearchCompanies(c.Request.Context(), query, status, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get companies",
+		})
+		return
+	}
+
+	companyList := []gin.H{}
+	for _, company := range companies {
+		companyList = append(companyList, operatorCompany(company))
+	}
+
+	event := audit.ForRequest(c, "operator.companies_searched", "company", "")
+	event.Details["platform_operator"] = true
+	event.Details["query"] = query
+	event.Details["status"] = status
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"companies": companyList,
+			"pagination": gin.H{
+				"page":  page,
+				"limit": limit,
+			},
+		},
+	})
+}
+
+// GetCompanyStats returns the statistics of any tenant
+func (h *OperatorHandler) GetCompanyStats(c *gin.Context) {
+	company, ok := h.company(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	users, err := membership.Users(ctx, h.databaseProvider, company.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get user count",
+		})
+		return
+	}
+	activeUsers := 0
+	for _, user := range users {
+		if user.IsActive {
+			activeUsers++
+		}
+	}
+	pendingInvitations, err := h.databaseProvider.CountPendingInvitationsByCompany(ctx, company.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get invitation count",
+		})
+		return
+	}
+
+	h.record(c, "operator.company_viewed", company)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"company": operatorCompany(company),
+			"stats": gin.H{
+				"total_users":         len(users),
+				"active_users":        activeUsers,
+				"pending_invitations": pendingInvitations,
+				"onboarded":           company.Onboarded,
+				"setup_completed":     company.SetupCompleted,
+			},
+		},
+	})
+}
+
+// SuspendCompany locks a tenant's users, API keys and SCIM tokens out until it is reactivated
+func (h *OperatorHandler) SuspendCompany(c *gin.Context) {
+	var req models.SuspendCompanyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	company, ok := h.company(c)
+	if !ok {
+		return
+	}
+	if company.Status == "suspended" {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   "Company is already suspended",
+		})
+		return
+	}
+
+	previousStatus := company.Status
+	company.Status = "suspended"
+	company.SuspendedAt = time.Now()
+	company.SuspensionReason = req.Reason
+	if !h.save(c, company) {
+		return
+	}
+
+	event := h.event(c, "operator.company_suspended", company)
+	event.Details["reason"] = req.Reason
+	event.Details["previous_status"] = previousStatus
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Company suspended",
+		Data: gin.H{
+			"company": operatorCompany(company),
+		},
+	})
+}
+
+// ReactivateCompany lifts a suspension. Companies without a subscription return to
+// their trial.
+func (h *OperatorHandler) ReactivateCompany(c *gin.Context) {
+	company, ok := h.company(c)
+	if !ok {
+		return
+	}
+	if company.Status != "suspended" {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   "Company is not suspended",
+		})
+		return
+	}
+
+	company.Status = "active"
+	if !company.SubscriptionActive && !company.TrialEndsAt.IsZero() {
+		company.Status = "trial"
+	}
+	company.SuspendedAt = time.Time{}
+	company.SuspensionReason = ""
+	if !h.save(c, company) {
+		return
+	}
+
+	h.record(c, "operator.company_reactivated", company)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Company reactivated",
+		Data: gin.H{
+			"company": operatorCompany(company),
+		},
+	})
+}
+
+// ExtendTrial extends a tenant's trial by a number of days, counted from today if the
+// trial has already ended
+func (h *OperatorHandler) ExtendTrial(c *gin.Context) {
+	var req models.ExtendTrialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	company, ok := h.company(c)
+	if !ok {
+		return
+	}
+	if company.Status != "trial" {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   "Company is not in a trial",
+		})
+		return
+	}
+
+	previousTrialEndsAt := company.TrialEndsAt
+	trialEndsAt := company.TrialEndsAt
+	if now := time.Now(); trialEndsAt.Before(now) {
+		trialEndsAt = now
+	}
+	company.TrialEndsAt = trialEndsAt.AddDate(0, 0, req.Days)
+	if !h.save(c, company) {
+		return
+	}
+
+	event := h.event(c, "operator.trial_extended", company)
+	event.Details["days"] = req.Days
+	event.Details["previous_trial_ends_at"] = previousTrialEndsAt
+	event.Details["trial_ends_at"] = company.TrialEndsAt
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Trial extended",
+		Data: gin.H{
+			"company": operatorCompany(company),
+		},
+	})
+}
+
+// ExpireSessions ends the active sessions of every member of a tenant. Sessions are not
+// scoped to a tenant, so members of several companies are signed out everywhere.
+func (h *OperatorHandler) ExpireSessions(c *gin.Context) {
+	company, ok := h.company(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	members, err := membership.Users(ctx, h.databaseProvider, company.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get users",
+		})
+		return
+	}
+
+	now := time.Now()
+	expired := 0
+	for _, member := range members {
+		// Reload the user, the member carries their role in this company rather than their own
+		user, err := h.databaseProvider.GetUser(ctx, member.ID)
+		if err != nil {
+			continue
+		}
+		user.SessionsRevokedAt = now
+		user.UpdatedAt = now
+		if err := h.databaseProvider.UpdateUser(ctx, user); err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to expire sessions",
+			})
+			return
+		}
+		expired++
+	}
+
+	event := h.event(c, "operator.sessions_expired", company)
+	event.Details["users"] = expired
+	audit.Record(ctx, h.databaseProvider, event)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Sessions expired",
+		Data: gin.H{
+			"users": expired,
+		},
+	})
+}
+
+// company returns the tenant named in the URL
+func (h *OperatorHandler) company(c *gin.Context) (*database.Company, bool) {
+	company, err := h.databaseProvider.GetCompany(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Company not found",
+		})
+		return nil, false
+	}
+	return company, true
+}
+
+// save stores a tenant changed by an operator
+func (h *OperatorHandler) save(c *gin.Context, company *database.Company) bool {
+	company.UpdatedAt = time.Now()
+	if err := h.databaseProvider.UpdateCompany(c.Request.Context(), company); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update company",
+		})
+		return false
+	}
+	return true
+}
+
+// event returns an audit event for an operator action. It is recorded in the tenant's
+// audit log so the tenant can see what the operator did.
+func (h *OperatorHandler) event(c *gin.Context, action string, company *database.Company) *database.AuditEvent {
+	event := audit.ForRequest(c, action, "company", company.ID)
+	event.CompanyID = company.ID
+	event.Details["platform_operator"] = true
+	return event
+}
+
+// record audits an operator action without further details
+func (h *OperatorHandler) record(c *gin.Context, action string, company *database.Company) {
+	audit.Record(c.Request.Context(), h.databaseProvider, h.event(c, action, company))
+}
+
+// operatorCompany converts a tenant into its operator console representation
+func operatorCompany(company *database.Company) gin.H {
+	return gin.H{
+		"id":                company.ID,
+		"name":              company.Name,
+		"domain":            company.Domain,
+		"status":            company.Status,
+		"admin_user_id":     company.AdminUserID,
+		"pending_owner_id":  company.PendingOwnerID,
+		"trial_ends_at":     company.TrialEndsAt,
+		"suspended_at":      company.SuspendedAt,
+		"suspension_reason": company.SuspensionReason,
+		"created_at":        company.CreatedAt,
+		"updated_at":        company.UpdatedAt,
+	}
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/audit"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/membership"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+)
+
+// ownershipTransferTTL is how long a nominated admin has to accept ownership
+const ownershipTransferTTL = 7 * 24 * time.Hour
+
+// TransferOwnership nominates an admin of the company as its new owner. Only the current
+// owner can nominate; the transfer completes when the nominee accepts.
+func (h *CompanyHandler) TransferOwnership(c *gin.Context) {
+	var req models.TransferOwnershipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+	company, ok := h.ownCompany(c, user)
+	if !ok {
+		return
+	}
+	if user.Impersonating() || company.AdminUserID != user.UserID {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Only the company owner can transfer ownership",
+		})
+		return
+	}
+
+	if !nominateOwner(c, h.databaseProvider, company, req.UserID, user.UserID) {
+		return
+	}
+
+	event := audit.ForRequest(c, "company.ownership_transfer_requested", "company", company.ID)
+	event.Details["owner_id"] = company.AdminUserID
+	event.Details["nominee_id"] = company.PendingOwnerID
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Ownership transfer is awaiting acceptance",
+		Data: gin.H{
+			"ownership": companyOwnership(company),
+		},
+	})
+}
+
+// AcceptOwnership makes the nominated admin the owner of the company
+func (h *CompanyHandler) AcceptOwnership(c *gin.Context) {
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+	company, ok := h.ownCompany(c, user)
+	if !ok {
+		return
+	}
+	if company.PendingOwnerID == "" || user.Impersonating() || company.PendingOwnerID != user.UserID {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "No ownership transfer is pending for you",
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if time.Since(company.OwnershipNominatedAt) > ownershipTransferTTL {
+		clearOwnershipNomination(company)
+		if err := h.databaseProvider.UpdateCompany(ctx, company); err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to update company",
+			})
+			return
+		}
+		c.JSON(http.StatusGone, models.APIResponse{
+			Success: false,
+			Error:   "Ownership transfer has expired",
+		})
+		return
+	}
+
+	previousOwnerID := company.AdminUserID
+	company, err := h.databaseProvider.TransferCompanyOwnership(ctx, company.ID, user.UserID)
+	if err != nil {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   "Failed to transfer ownership: " + err.Error(),
+		})
+		return
+	}
+
+	event := audit.ForRequest(c, "company.ownership_transferred", "company", company.ID)
+	event.Details["previous_owner_id"] = previousOwnerID
+	event.Details["owner_id"] = company.AdminUserID
+	audit.Record(ctx, h.databaseProvider, event)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "You are now the company owner",
+		Data: gin.H{
+			"ownership": companyOwnership(company),
+		},
+	})
+}
+
+// CancelOwnershipTransfer withdraws a pending ownership transfer. The owner can cancel it
+// and the nominee can decline it.
+func (h *CompanyHandler) CancelOwnershipTransfer(c *gin.Context) {
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+	company, ok := h.ownCompany(c, user)
+	if !ok {
+		return
+	}
+	if company.PendingOwnerID == "" {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "No ownership transfer is pending",
+		})
+		return
+	}
+	if user.Impersonating() || (user.UserID != company.AdminUserID && user.UserID != company.PendingOwnerID) {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Only the company owner or the nominee can cancel an ownership transfer",
+		})
+		return
+	}
+
+	nomineeID := company.PendingOwnerID
+	clearOwnershipNomination(company)
+	if err := h.databaseProvider.UpdateCompany(c.Request.Context(), company); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update company",
+		})
+		return
+	}
+
+	event := audit.ForRequest(c, "company.ownership_transfer_cancelled", "company", company.ID)
+	event.Details["nominee_id"] = nomineeID
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Ownership transfer cancelled",
+		Data: gin.H{
+			"ownership": companyOwnership(company),
+		},
+	})
+}
+
+// NominateOwner nominates an admin of any tenant as its new owner, for tenants whose owner
+// has left. The transfer completes when the nominee accepts.
+func (h *OperatorHandler) NominateOwner(c *gin.Context) {
+	var req models.TransferOwnershipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	company, ok := h.company(c)
+	if !ok {
+		return
+	}
+	operator, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	if !nominateOwner(c, h.databaseProvider, company, req.UserID, operator.UserID) {
+		return
+	}
+
+	event := h.event(c, "operator.ownership_transfer_requested", company)
+	event.Details["owner_id"] = company.AdminUserID
+	event.Details["nominee_id"] = company.PendingOwnerID
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Ownership transfer is awaiting acceptance",
+		Data: gin.H{
+			"ownership": companyOwnership(company),
+		},
+	})
+}
+
+// ownCompany returns the caller's company
+func (h *CompanyHandler) ownCompany(c *gin.Context, user models.UserContext) (*database.Company, bool) {
+	company, err := h.databaseProvider.GetCompany(c.Request.Context(), user.CompanyID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Company not found",
+		})
+		return nil, false
+	}
+	return company, true
+}
+
+// nominateOwner records an active admin of a company as its pending owner, replacing any
+// earlier nomination, and responds with an error otherwise
+func nominateOwner(c *gin.Context, databaseProvider database.DatabaseProvider, company *database.Company, nomineeID, nominatedBy string) bool {
+	ctx := c.Request.Context()
+
+	if nomineeID == company.AdminUserID {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "User already owns the company",
+		})
+		return false
+	}
+
+	nominee, err := databaseProvider.GetUser(ctx, nomineeID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "User is not a member of the company",
+		})
+		return false
+	}
+	companyMembership, err := membership.Get(ctx, databaseProvider, nominee, company.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "User is not a member of the company",
+		})
+		return false
+	}
+	if companyMembership.Role != string(auth.RoleAdmin) || !nominee.IsActive {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Ownership can only be transferred to an active admin",
+		})
+		return false
+	}
+
+	company.PendingOwnerID = nominee.ID
+	company.OwnershipNominatedBy = nominatedBy
+	company.OwnershipNominatedAt = time.Now()
+	if err := databaseProvider.UpdateCompany(ctx, company); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update company",
+		})
+		return false
+	}
+	return true
+}
+
+// clearOwnershipNomination removes a pending ownership transfer from a company
+func clearOwnershipNomination(company *database.Company) {
+	company.PendingOwnerID = ""
+	company.OwnershipNominatedBy = ""
+	company.OwnershipNominatedAt = time.Time{}
+}
+
+// companyOwnership returns the owner and any pending ownership transfer of a company
+func companyOwnership(company *database.Company) gin.H {
+	ownership := gin.H{
+		"owner_id": company.AdminUserID,
+	}
+	if company.PendingOwnerID != "" {
+		ownership["pending_owner_id"] = company.PendingOwnerID
+		ownership["nominated_by"] = company.OwnershipNominatedBy
+		ownership["nominated_at"] = company.OwnershipNominatedAt
+		ownership["expires_at"] = company.OwnershipNominatedAt.Add(ownershipTransferTTL)
+	}
+	return ownership
+}
+
+// isCompanyOwner reports whether a user owns a company, responding with an error if the
+// company cannot be loaded
+func isCompanyOwner(c *gin.Context, databaseProvider database.DatabaseProvider, companyID, userID string) (bool, bool) {
+	owner, err := companyOwner(c.Request.Context(), databaseProvider, companyID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get company",
+		})
+		return false, false
+	}
+	return owner, true
+}
+
+// companyOwner reports whether a user owns a company
+func companyOwner(ctx context.Context, databaseProvider database.DatabaseProvider, companyID, userID string) (bool, error) {
+	company, err := databaseProvider.GetCompany(ctx, companyID)
+	if err != nil {
+		return false, err
+	}
+	return company.AdminUserID == userID, nil
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// defaultPasswordMinLength applies when a company has not set a minimum length
+const defaultPasswordMinLength = 8
+
+// SetBreachedPasswords sets the list of breached passwords that companies can block
+func (h *AuthHandler) SetBreachedPasswords(list *auth.BreachedPasswords) {
+	h.breachedPasswords = list
+}
+
+// effectivePasswordPolicy returns a company's password policy with defaults applied.
+// Users without a company get the default policy.
+func effectivePasswordPolicy(company *database.Company) database.PasswordPolicy {
+	var policy database.PasswordPolicy
+	if company != nil {
+		policy = company.PasswordPolicy
+	}
+	if policy.MinLength < defaultPasswordMinLength {
+		policy.MinLength = defaultPasswordMinLength
+	}
+	return policy
+}
+
+// passwordPolicyFor returns the password policy of a company, or of the company owning
+// the email's domain when companyID is empty
+func (h *AuthHandler) passwordPolicyFor(ctx context.Context, companyID, emailAddress string) database.PasswordPolicy {
+	var company *database.Company
+	if companyID != "" {
+		company, _ = h.databaseProvider.GetCompany(ctx, companyID)
+	} else if domain := emailDomain(emailAddress); domain != "" {
+		company, _ = h.databaseProvider.GetCompanyByDomain(ctx, domain)
+	}
+	return effectivePasswordPolicy(company)
+}
+
+// passwordPolicyViolations checks a new password against a policy and the user's previous
+// passwords, returning every rule it fails
+func (h *AuthHandler) passwordPolicyViolations(policy database.PasswordPolicy, field, password string, history []string) []models.PasswordPolicyViolation {
+	var violations []models.PasswordPolicyViolation
+	fail := func(rule, message string) {
+		violations = append(violations, models.PasswordPolicyViolation{Field: field, Rule: rule, Message: message})
+	}
+
+	if len([]rune(password)) < policy.MinLength {
+		fail("min_length", fmt.Sprintf("Password must be at least %d characters long", policy.MinLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if policy.RequireUppercase && !hasUpper {
+		fail("require_uppercase", "Password must contain an uppercase letter")
+	}
+	if policy.RequireLowercase && !hasLower {
+		fail("require_lowercase", "Password must contain a lowercase letter")
+	}
+	if policy.RequireDigit && !hasDigit {
+		fail("require_digit", "Password must contain a digit")
+	}
+	if policy.RequireSymbol && !hasSymbol {
+		fail("require_symbol", "Password must contain a symbol")
+	}
+
+	for i, hash := range history {
+		if i >= policy.HistoryCount {
+			break
+		}
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			fail("history", fmt.Sprintf("Password must differ from your last %d passwords", policy.HistoryCount))
+			break
+		}
+	}
+
+	if policy.BlockBreached && h.breachedPasswords.Contains(password) {
+		fail("breached", "Password has appeared in a data breach, choose a different one")
+	}
+
+	return violations
+}
+
+// respondPasswordPolicyViolations responds that a password does not meet the policy
+func respondPasswordPolicyViolations(c *gin.Context, violations []models.PasswordPolicyViolation) {
+	c.JSON(http.StatusBadRequest, models.APIResponse{
+		Success: false,
+		Error:   "Password does not meet the password policy",
+		Data: gin.H{
+			"errors": violations,
+		},
+	})
+}
+
+// recordPasswordChange updates a user's password age and history after a new password was set
+func recordPasswordChange(dbUser *database.User, password string, policy database.PasswordPolicy) error {
+	dbUser.PasswordChangedAt = time.Now()
+
+	if policy.HistoryCount == 0 {
+		dbUser.PasswordHistory = nil
+		return nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	history := append([]string{string(hash)}, dbUser.PasswordHistory...)
+	if len(history) > policy.HistoryCount {
+		history = history[:policy.HistoryCount]
+	}
+	dbUser.PasswordHistory = history
+	return nil
+}
+
+// passwordExpired reports whether a user's password is older than the policy allows.
+// Passwords set before their age was tracked count from account creation.
+func passwordExpired(policy database.PasswordPolicy, dbUser *database.User) bool {
+	if policy.MaxAgeDays == 0 {
+		return false
+	}
+
+	changedAt := dbUser.PasswordChangedAt
+	if changedAt.IsZero() {
+		changedAt = dbUser.CreatedAt
+	}
+	return time.Since(changedAt) > time.Duration(policy.MaxAgeDays)*24*time.Hour
+}
+
+// GetPasswordPolicy returns the company's password policy (admin only)
+func (h *CompanyHandler) GetPasswordPolicy(c *gin.Context) {
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	company, err := h.databaseProvider.GetCompany(c.Request.Context(), user.CompanyID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Company not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"password_policy": effectivePasswordPolicy(company),
+		},
+	})
+}
+
+// UpdatePasswordPolicy replaces the company's password policy (admin only)
+func (h *CompanyHandler) UpdatePasswordPolicy(c *gin.Context) {
+	var req models.PasswordPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	company, err := h.databaseProvider.GetCompany(c.Request.Context(), user.CompanyID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Company not found",
+		})
+		return
+	}
+
+	company.PasswordPolicy = database.PasswordPolicy{
+		MinLength:        req.MinLength,
+		RequireUppercase: req.RequireUppercase,
+		RequireLowercase: req.RequireLowercase,
+		RequireDigit:     req.RequireDigit,
+		RequireSymbol:    req.RequireSymbol,
+		HistoryCount:     req.HistoryCount,
+		MaxAgeDays:       req.MaxAgeDays,
+		BlockBreached:    req.BlockBreached,
+	}
+
+	if err := h.databaseProvider.UpdateCompany(c.Request.Context(), company); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update password policy",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Password policy updated successfully",
+		Data: gin.H{
+			"password_policy": effectivePasswordPolicy(company),
+		},
+	})
+}
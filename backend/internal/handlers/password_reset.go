@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/email"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+)
+
+// passwordResetTTL is how long a password reset link stays valid
+const passwordResetTTL = 30 * time.Minute
+
+// ResetPassword emails a single-use password reset link. The response is the same
+// whether or not an account exists for the email.
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req models.PasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid email address",
+		})
+		return
+	}
+	emailAddress := strings.TrimSpace(req.Email)
+
+	if retryAfter := h.loginLockout(c.Request.Context(), ipThrottleKey(c.ClientIP())); retryAfter > 0 {
+		respondLockedOut(c, retryAfter)
+		return
+	}
+	if allowed, retryAfter := h.ipLimiter.Allow(c.ClientIP()); !allowed {
+		tooManyRequests(c, retryAfter)
+		return
+	}
+	if allowed, retryAfter := h.emailLimiter.Allow(strings.ToLower(emailAddress)); !allowed {
+		tooManyRequests(c, retryAfter)
+		return
+	}
+
+	// Passwords of SSO-enforced domains are managed by the identity provider, so no
+	// link is sent, but the response stays the same
+	_, ssoRequired := enforcedSSOLoginPath(c.Request.Context(), h.databaseProvider, emailAddress)
+
+	dbUser, err := h.databaseProvider.GetUserByEmail(c.Request.Context(), emailAddress)
+	if err == nil && dbUser != nil && dbUser.IsActive && !ssoRequired {
+		if err := h.sendPasswordReset(c.Request.Context(), dbUser); err != nil {
+			log.Printf("Failed to send password reset link to user %s: %v", dbUser.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "If an account exists for this email, a password reset link has been sent",
+	})
+}
+
+// ConfirmPasswordReset sets a new password with a reset token and ends all existing sessions
+func (h *AuthHandler) ConfirmPasswordReset(c *gin.Context) {
+	var req models.PasswordResetConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	if retryAfter := h.loginLockout(c.Request.Context(), ipThrottleKey(c.ClientIP())); retryAfter > 0 {
+		respondLockedOut(c, retryAfter)
+		return
+	}
+
+	// The policy is checked before the token is used up, so a rejected password can be retried
+	var dbUser *database.User
+	var policy database.PasswordPolicy
+	claims, err := auth.ParsePurposeToken(h.jwtSecret, auth.PurposePasswordReset, req.Token)
+	if err == nil {
+		dbUser, err = h.databaseProvider.GetUser(c.Request.Context(), claims["sub"].(string))
+	}
+	if err == nil {
+		policy = h.passwordPolicyFor(c.Request.Context(), dbUser.CompanyID, dbUser.Email)
+		if violations := h.passwordPolicyViolations(policy, "new_password", req.NewPassword, dbUser.PasswordHistory); len(violations) > 0 {
+			respondPasswordPolicyViolations(c, violations)
+			return
+		}
+		dbUser, err = h.consumeEmailToken(c.Request.Context(), auth.PurposePasswordReset, req.Token)
+	}
+	if err != nil || !dbUser.IsActive {
+		// Guessing reset tokens counts against the client IP
+		h.recordLoginFailure(c, "")
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid or expired reset link",
+		})
+		return
+	}
+
+	if err := h.authProvider.SetPassword(c.Request.Context(), dbUser.ID, req.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to reset password",
+		})
+		return
+	}
+
+	// Sessions started with the old password end, and receiving the link proves
+	// the user owns the address
+	now := time.Now()
+	dbUser.SessionsRevokedAt = now
+	if dbUser.EmailVerificationPending {
+		dbUser.EmailVerificationPending = false
+		dbUser.EmailVerifiedAt = now
+	}
+	dbUser.UpdatedAt = now
+	if err := recordPasswordChange(dbUser, req.NewPassword, policy); err != nil {
+		log.Printf("Failed to record password history of user %s: %v", dbUser.ID, err)
+	}
+	if err := h.databaseProvider.UpdateUser(c.Request.Context(), dbUser); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to reset password",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Password has been reset, please log in with your new password",
+	})
+}
+
+// sendPasswordReset issues a password reset token and emails the link
+func (h *AuthHandler) sendPasswordReset(ctx context.Context, dbUser *database.User) error {
+	token, err := h.issueEmailToken(ctx, dbUser, auth.PurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	link := h.appURL + "/auth/reset-password?token=" + url.QueryEscape(token)
+	return h.mailer.Send(ctx, email.Message{
+		To:      dbUser.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Use the link below to choose a new password. It expires in %d minutes and can only be used once.\n\n%s\n\nIf you did not request a password reset, you can ignore this email.\n",
+			int(passwordResetTTL.Minutes()), link),
+	})
+}
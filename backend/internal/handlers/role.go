@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/audit"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/membership"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
+)
+
+// RoleHandler handles the permission catalog and company-defined roles
+type RoleHandler struct {
+	databaseProvider database.DatabaseProvider
+}
+
+// NewRoleHandler creates a new role handler
+func NewRoleHandler(databaseProvider database.DatabaseProvider) *RoleHandler {
+	return &RoleHandler{
+		databaseProvider: databaseProvider,
+	}
+}
+
+// GetPermissions returns the current user's role and the permissions it grants
+func (h *RoleHandler) GetPermissions(c *gin.Context) {
+	user, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	permissions := user.Permissions
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"role":        user.Role,
+			"permissions": permissions,
+		},
+	})
+}
+
+// GetRoles lists the permission catalog, the built-in roles and the company's custom roles
+func (h *RoleHandler) GetRoles(c *gin.Context) {
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	customRoles, err := h.databaseProvider.GetCustomRolesByCompany(c.Request.Context(), user.CompanyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get roles",
+		})
+		return
+	}
+	if customRoles == nil {
+		customRoles = []*database.CustomRole{}
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"permissions":    rbac.Catalog,
+			"built_in_roles": rbac.BuiltInRoles(),
+			"custom_roles":   customRoles,
+		},
+	})
+}
+
+// CreateRole defines a custom role for the company
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req models.CustomRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	role := &database.CustomRole{
+		ID:        uuid.New().String(),
+		CompanyID: user.CompanyID,
+	}
+	if !h.applyRoleRequest(c, role, req) {
+		return
+	}
+
+	if err := h.databaseProvider.CreateCustomRole(c.Request.Context(), role); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to create role",
+		})
+		return
+	}
+
+	event := audit.ForRequest(c, "role.created", "role", role.ID)
+	event.Details["name"] = role.Name
+	event.Details["permissions"] = role.Permissions
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Role created successfully",
+		Data: gin.H{
+			"role": role,
+		},
+	})
+}
+
+// UpdateRole replaces the name, description and permissions of a custom role
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	var req models.CustomRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	role, ok := h.companyRole(c)
+	if !ok {
+		return
+	}
+	if !h.applyRoleRequest(c, role, req) {
+		return
+	}
+
+	if err := h.databaseProvider.UpdateCustomRole(c.Request.Context(), role); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update role",
+		})
+		return
+	}
+
+	event := audit.ForRequest(c, "role.updated", "role", role.ID)
+	event.Details["name"] = role.Name
+	event.Details["permissions"] = role.Permissions
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Role updated successfully",
+		Data: gin.H{
+			"role": role,
+		},
+	})
+}
+
+// DeleteRole deletes a custom role that is no longer assigned to anyone
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	role, ok := h.companyRole(c)
+	if !ok {
+		return
+	}
+
+	users, err := membership.Users(c.Request.Context(), h.databaseProvider, role.CompanyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to delete role",
+		})
+		return
+	}
+	for _, u := range users {
+		if u.Role == role.ID {
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Success: false,
+				Error:   "Role is still assigned to users",
+			})
+			return
+		}
+	}
+
+	assignments, err := h.databaseProvider.GetDelegatedAdminsByCompany(c.Request.Context(), role.CompanyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to delete role",
+		})
+		return
+	}
+	for _, assignment := range assignments {
+		if assignment.Role == role.ID {
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Success: false,
+				Error:   "Role is still used by delegated admins",
+			})
+			return
+		}
+	}
+
+	if err := h.databaseProvider.DeleteCustomRole(c.Request.Context(), role.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to delete role",
+		})
+		return
+	}
+
+	event := audit.ForRequest(c, "role.deleted", "role", role.ID)
+	event.Details["name"] = role.Name
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Role deleted successfully",
+	})
+}
+
+// companyRole returns the custom role named in the URL if it belongs to the user's company
+func (h *RoleHandler) companyRole(c *gin.Context) (*database.CustomRole, bool) {
+	user, ok := companyUser(c)
+	if !ok {
+		return nil, false
+	}
+
+	role, err := h.databaseProvider.GetCustomRole(c.Request.Context(), c.Param("id"))
+	if err != nil || role.CompanyID != user.CompanyID {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Role not found",
+		})
+		return nil, false
+	}
+
+	return role, true
+}
+
+// applyRoleRequest validates a role request and copies it onto the role. Names must be
+// unique within the company and cannot shadow a built-in role, and the caller must hold
+// every permission the role grants.
+func (h *RoleHandler) applyRoleRequest(c *gin.Context, role *database.CustomRole, req models.CustomRoleRequest) bool {
+	user, ok := companyUser(c)
+	if !ok {
+		return false
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || rbac.IsBuiltInRole(strings.ToLower(name)) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Role name is required and cannot be the name of a built-in role",
+		})
+		return false
+	}
+
+	var permissions []string
+	for _, permission := range req.Permissions {
+		if !rbac.ValidPermission(permission) {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "Unknown permission: " + permission,
+			})
+			return false
+		}
+		// Roles cannot grant more than the caller holds
+		if !user.Can(permission) {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "Cannot grant a permission you do not hold: " + permission,
+			})
+			return false
+		}
+		if !rbac.Has(permissions, permission) {
+			permissions = append(permissions, permission)
+		}
+	}
+
+	existing, err := h.databaseProvider.GetCustomRolesByCompany(c.Request.Context(), role.CompanyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get roles",
+		})
+		return false
+	}
+	for _, other := range existing {
+		if other.ID != role.ID && strings.EqualFold(other.Name, name) {
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Success: false,
+				Error:   "A role with this name already exists",
+			})
+			return false
+		}
+	}
+
+	role.Name = name
+	role.Description = req.Description
+	role.Permissions = permissions
+	return true
+}
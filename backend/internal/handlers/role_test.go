@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
+)
+
+func TestCustomRolePermissionsLimitedToCaller(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	roleManager := []string{rbac.CompanyRead, rbac.UsersRead, rbac.RolesManage}
+
+	send := func(h *RoleHandler, method, roleID string, req models.CustomRoleRequest) int {
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(method, "/roles/"+roleID, bytes.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "id", Value: roleID}}
+		principal.Set(c, models.UserContext{UserID: "manager", CompanyID: "acme", Role: "user-manager", Permissions: roleManager})
+		if method == http.MethodPost {
+			h.CreateRole(c)
+		} else {
+			h.UpdateRole(c)
+		}
+		return w.Code
+	}
+
+	db := newUserTestDatabase()
+	h := NewRoleHandler(db)
+
+	escalating := models.CustomRoleRequest{Name: "Auditor", Permissions: []string{rbac.UsersRead, rbac.AuditRead}}
+	if status := send(h, http.MethodPost, "", escalating); status != http.StatusForbidden {
+		t.Errorf("creating a role with a permission the caller lacks status = %d, want 403", status)
+	}
+	if len(db.roles) != 1 {
+		t.Errorf("roles = %d, want only the existing role", len(db.roles))
+	}
+
+	if status := send(h, http.MethodPut, "user-manager", models.CustomRoleRequest{Name: "User manager", Permissions: []string{rbac.UsersRead, rbac.SecurityManage}}); status != http.StatusForbidden {
+		t.Errorf("adding a permission the caller lacks status = %d, want 403", status)
+	}
+	if got := db.roles["user-manager"].Permissions; len(got) != len(userManagerPermissions) {
+		t.Errorf("role permissions changed to %v", got)
+	}
+
+	if status := send(h, http.MethodPost, "", models.CustomRoleRequest{Name: "Reader", Permissions: []string{rbac.CompanyRead, rbac.UsersRead}}); status != http.StatusCreated {
+		t.Errorf("creating a role within the caller's permissions status = %d, want 201", status)
+	}
+}
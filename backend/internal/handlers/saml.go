@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+)
+
+// samlRequestCookie holds the outstanding AuthnRequest ID between login and ACS
+const samlRequestCookie = "saml_request_id"
+
+// SAMLHandler handles SAML 2.0 single sign-on requests
+type SAMLHandler struct {
+	samlProvider     *auth.SAMLServiceProvider
+	authProvider     auth.AuthProvider
+	databaseProvider database.DatabaseProvider
+	redirectURL      string
+}
+
+// NewSAMLHandler creates a new SAML handler. When redirectURL is set the ACS
+// redirects the browser there with the session token in the URL fragment.
+func NewSAMLHandler(samlProvider *auth.SAMLServiceProvider, authProvider auth.AuthProvider, databaseProvider database.DatabaseProvider, redirectURL string) *SAMLHandler {
+	return &SAMLHandler{
+		samlProvider:     samlProvider,
+		authProvider:     authProvider,
+		databaseProvider: databaseProvider,
+		redirectURL:      redirectURL,
+	}
+}
+
+// Metadata serves the SP metadata document
+func (h *SAMLHandler) Metadata(c *gin.Context) {
+	metadata, err := h.samlProvider.Metadata()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to generate SAML metadata",
+		})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// Login redirects the browser to the IdP with an AuthnRequest
+func (h *SAMLHandler) Login(c *gin.Context) {
+	redirectURL, requestID, err := h.samlProvider.AuthnRequestURL("")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to start SAML login",
+		})
+		return
+	}
+
+	// The IdP posts back cross-site, so the cookie must be SameSite=None
+	c.SetSameSite(http.SameSiteNoneMode)
+	c.SetCookie(samlRequestCookie, requestID, int((10 * time.Minute).Seconds()), "/", "", true, true)
+
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+// ACS handles the POST binding assertion consumer service
+func (h *SAMLHandler) ACS(c *gin.Context) {
+	var requestIDs []string
+	if requestID, err := c.Cookie(samlRequestCookie); err == nil && requestID != "" {
+		requestIDs = append(requestIDs, requestID)
+	}
+	c.SetSameSite(http.SameSiteNoneMode)
+	c.SetCookie(samlRequestCookie, "", -1, "/", "", true, true)
+
+	identity, err := h.samlProvider.ParseResponse(c.Request, requestIDs)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Invalid SAML response",
+		})
+		return
+	}
+
+	dbUser, err := provisionSSOUser(c.Request.Context(), h.databaseProvider, identity.Email, identity.Name, identity.Role, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get user data",
+		})
+		return
+	}
+
+	respondSSOLogin(c, h.authProvider, dbUser, h.redirectURL)
+}
+
+// emailDomain returns the lower-cased domain part of an email address
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(email[at+1:])
+}
@@ -0,0 +1,933 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+)
+
+const (
+	// scimDefaultCount is the page size used when the client does not send count
+	scimDefaultCount = 100
+	// scimMaxCount caps the page size of list responses
+	scimMaxCount = 200
+)
+
+// scimRoleGroups are the SCIM groups exposed by the portal; membership sets the user's role
+var scimRoleGroups = []string{string(auth.RoleAdmin), string(auth.RoleUser), string(auth.RoleGuest)}
+
+// SCIMHandler handles SCIM 2.0 provisioning requests and SCIM token management
+type SCIMHandler struct {
+	databaseProvider database.DatabaseProvider
+	authProvider     auth.AuthProvider
+	baseURL          string
+}
+
+// NewSCIMHandler creates a new SCIM handler. publicURL is used to build resource locations.
+func NewSCIMHandler(databaseProvider database.DatabaseProvider, authProvider auth.AuthProvider, publicURL string) *SCIMHandler {
+	return &SCIMHandler{
+		databaseProvider: databaseProvider,
+		authProvider:     authProvider,
+		baseURL:          strings.TrimSuffix(publicURL, "/") + "/scim/v2",
+	}
+}
+
+// ServiceProviderConfig describes the supported SCIM features
+func (h *SCIMHandler) ServiceProviderConfig(c *gin.Context) {
+	scimJSON(c, http.StatusOK, gin.H{
+		"schemas":        []string{models.SCIMSchemaSPConfig},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": scimMaxCount},
+		"changePassword": gin.H{"supported": false},
+		"sort":           gin.H{"supported": false},
+		"etag":           gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{
+			{
+				"type":        "oauthbearertoken",
+				"name":        "OAuth Bearer Token",
+				"description": "Per-company SCIM token created by a company admin",
+			},
+		},
+	})
+}
+
+// ListUsers handles GET /Users with filtering and pagination
+func (h *SCIMHandler) ListUsers(c *gin.Context) {
+	companyID := c.GetString("scim_company_id")
+
+	filter, ok := scimQueryFilter(c)
+	if !ok {
+		return
+	}
+
+	users, err := h.databaseProvider.GetUsersByCompany(c.Request.Context(), companyID)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to get users")
+		return
+	}
+
+	var resources []interface{}
+	for _, u := range users {
+		resource := h.userResource(u)
+		if filter == nil || filter.Matches(resource) {
+			resources = append(resources, resource)
+		}
+	}
+
+	scimList(c, resources)
+}
+
+// GetUser handles GET /Users/:id
+func (h *SCIMHandler) GetUser(c *gin.Context) {
+	user, ok := h.companyUser(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	scimJSON(c, http.StatusOK, h.userResource(user))
+}
+
+// CreateUser handles POST /Users
+func (h *SCIMHandler) CreateUser(c *gin.Context) {
+	companyID := c.GetString("scim_company_id")
+
+	var req models.SCIMUser
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", "Invalid request data: "+err.Error())
+		return
+	}
+
+	email := scimEmail(&req)
+	if email == "" {
+		scimError(c, http.StatusBadRequest, "invalidValue", "userName or emails must contain an email address")
+		return
+	}
+
+	// Check if user already exists
+	existingUser, err := h.databaseProvider.GetUserByEmail(c.Request.Context(), email)
+	if err == nil && existingUser != nil {
+		scimError(c, http.StatusConflict, "uniqueness", "User already exists")
+		return
+	}
+
+	now := time.Now()
+	user := &database.User{
+		ID:               uuid.New().String(),
+		Email:            email,
+		Name:             scimDisplayName(&req, email),
+		CompanyID:        companyID,
+		Role:             string(auth.RoleUser),
+		IsActive:         req.Active == nil || *req.Active,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		InvitationStatus: "active",
+		ActivatedAt:      now,
+		ExternalID:       req.ExternalID,
+	}
+
+	if err := h.databaseProvider.CreateUser(c.Request.Context(), user); err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to create user")
+		return
+	}
+
+	c.Header("Location", h.baseURL+"/Users/"+user.ID)
+	scimJSON(c, http.StatusCreated, h.userResource(user))
+}
+
+// ReplaceUser handles PUT /Users/:id
+func (h *SCIMHandler) ReplaceUser(c *gin.Context) {
+	user, ok := h.companyUser(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req models.SCIMUser
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", "Invalid request data: "+err.Error())
+		return
+	}
+
+	email := scimEmail(&req)
+	if email == "" {
+		scimError(c, http.StatusBadRequest, "invalidValue", "userName or emails must contain an email address")
+		return
+	}
+
+	wasActive := user.IsActive
+	user.Email = email
+	user.Name = scimDisplayName(&req, email)
+	user.ExternalID = req.ExternalID
+	user.IsActive = req.Active == nil || *req.Active
+
+	h.saveUser(c, user, wasActive, http.StatusOK)
+}
+
+// PatchUser handles PATCH /Users/:id
+func (h *SCIMHandler) PatchUser(c *gin.Context) {
+	user, ok := h.companyUser(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req models.SCIMPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", "Invalid request data: "+err.Error())
+		return
+	}
+
+	wasActive := user.IsActive
+	name := &models.SCIMName{}
+	for _, operation := range req.Operations {
+		if err := applySCIMUserPatch(user, name, operation); err != nil {
+			scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+			return
+		}
+	}
+
+	// Partial name updates are combined into the single display name
+	if name.GivenName != "" || name.FamilyName != "" {
+		user.Name = strings.TrimSpace(name.GivenName + " " + name.FamilyName)
+	}
+
+	h.saveUser(c, user, wasActive, http.StatusOK)
+}
+
+// DeleteUser handles DELETE /Users/:id. Users are removed the same way as through the
+// portal, and the company owner can only be removed once ownership is transferred.
+func (h *SCIMHandler) DeleteUser(c *gin.Context) {
+	user, ok := h.companyUser(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if !h.notOwner(c, user, "Cannot delete the company owner, transfer ownership first") {
+		return
+	}
+
+	if err := removeCompanyUser(c.Request.Context(), h.databaseProvider, h.authProvider, user.CompanyID, user); err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to delete user")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListGroups handles GET /Groups
+func (h *SCIMHandler) ListGroups(c *gin.Context) {
+	companyID := c.GetString("scim_company_id")
+
+	filter, ok := scimQueryFilter(c)
+	if !ok {
+		return
+	}
+
+	users, err := h.databaseProvider.GetUsersByCompany(c.Request.Context(), companyID)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to get users")
+		return
+	}
+
+	var resources []interface{}
+	for _, role := range scimRoleGroups {
+		resource := h.groupResource(role, users)
+		if filter == nil || filter.Matches(resource) {
+			resources = append(resources, resource)
+		}
+	}
+
+	scimList(c, resources)
+}
+
+// GetGroup handles GET /Groups/:id
+func (h *SCIMHandler) GetGroup(c *gin.Context) {
+	role, ok := scimGroupRole(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	users, err := h.databaseProvider.GetUsersByCompany(c.Request.Context(), c.GetString("scim_company_id"))
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to get users")
+		return
+	}
+
+	scimJSON(c, http.StatusOK, h.groupResource(role, users))
+}
+
+// CreateGroup handles POST /Groups. Groups map to the fixed portal roles, so only
+// existing role groups can be linked and arbitrary groups are rejected.
+func (h *SCIMHandler) CreateGroup(c *gin.Context) {
+	var req models.SCIMGroup
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", "Invalid request data: "+err.Error())
+		return
+	}
+
+	for _, role := range scimRoleGroups {
+		if strings.EqualFold(req.DisplayName, role) {
+			scimError(c, http.StatusConflict, "uniqueness", fmt.Sprintf("Group %q already exists with id %q", role, role))
+			return
+		}
+	}
+
+	scimError(c, http.StatusBadRequest, "invalidValue", "Only the role groups admin, user and guest are supported")
+}
+
+// ReplaceGroup handles PUT /Groups/:id by replacing the group's members
+func (h *SCIMHandler) ReplaceGroup(c *gin.Context) {
+	role, ok := scimGroupRole(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req models.SCIMGroup
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", "Invalid request data: "+err.Error())
+		return
+	}
+
+	members := make([]string, 0, len(req.Members))
+	for _, member := range req.Members {
+		members = append(members, member.Value)
+	}
+
+	h.updateGroupMembers(c, role, "replace", members)
+}
+
+// PatchGroup handles PATCH /Groups/:id member additions and removals
+func (h *SCIMHandler) PatchGroup(c *gin.Context) {
+	role, ok := scimGroupRole(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req models.SCIMPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", "Invalid request data: "+err.Error())
+		return
+	}
+
+	for _, operation := range req.Operations {
+		op := strings.ToLower(operation.Op)
+		path := strings.ToLower(operation.Path)
+
+		var members []string
+		switch {
+		case strings.HasPrefix(path, "members[") && strings.HasSuffix(path, "]"):
+			// members[value eq "id"]
+			filter, err := parseSCIMFilter(operation.Path[len("members[") : len(operation.Path)-1])
+			if err != nil || len(filter) != 1 || len(filter[0]) != 1 || filter[0][0].op != "eq" {
+				scimError(c, http.StatusBadRequest, "invalidFilter", "Unsupported member filter")
+				return
+			}
+			members = []string{filter[0][0].value}
+		case path == "members":
+			var values []models.SCIMMultiValue
+			if len(operation.Value) > 0 {
+				if err := json.Unmarshal(operation.Value, &values); err != nil {
+					scimError(c, http.StatusBadRequest, "invalidValue", "members must be a list")
+					return
+				}
+			}
+			for _, value := range values {
+				members = append(members, value.Value)
+			}
+			// Removing "members" without a value clears the group
+			if op == "remove" && len(operation.Value) == 0 {
+				op = "replace"
+			}
+		case path == "":
+			var value models.SCIMGroup
+			if err := json.Unmarshal(operation.Value, &value); err != nil {
+				scimError(c, http.StatusBadRequest, "invalidValue", "Invalid group value")
+				return
+			}
+			if value.DisplayName != "" && !strings.EqualFold(value.DisplayName, role) {
+				scimError(c, http.StatusBadRequest, "mutability", "Group names cannot be changed")
+				return
+			}
+			if value.Members == nil {
+				continue
+			}
+			for _, member := range value.Members {
+				members = append(members, member.Value)
+			}
+		case path == "displayname":
+			scimError(c, http.StatusBadRequest, "mutability", "Group names cannot be changed")
+			return
+		default:
+			scimError(c, http.StatusBadRequest, "invalidPath", "Unsupported path "+operation.Path)
+			return
+		}
+
+		if !h.updateGroupMembers(c, role, op, members) {
+			return
+		}
+	}
+
+	users, err := h.databaseProvider.GetUsersByCompany(c.Request.Context(), c.GetString("scim_company_id"))
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to get users")
+		return
+	}
+
+	scimJSON(c, http.StatusOK, h.groupResource(role, users))
+}
+
+// DeleteGroup handles DELETE /Groups/:id; role groups cannot be deleted
+func (h *SCIMHandler) DeleteGroup(c *gin.Context) {
+	if _, ok := scimGroupRole(c, c.Param("id")); !ok {
+		return
+	}
+
+	scimError(c, http.StatusBadRequest, "mutability", "Role groups cannot be deleted")
+}
+
+// CreateToken handles creating a SCIM bearer token for the current company
+func (h *SCIMHandler) CreateToken(c *gin.Context) {
+	var req models.SCIMTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	plaintext, err := auth.GenerateOpaqueToken("scim_")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to generate token",
+		})
+		return
+	}
+
+	token := &database.SCIMToken{
+		ID:          uuid.New().String(),
+		CompanyID:   user.CompanyID,
+		Name:        req.Name,
+		TokenHash:   auth.HashToken(plaintext),
+		TokenPrefix: plaintext[:len("scim_")+6],
+		CreatedBy:   user.UserID,
+	}
+
+	if err := h.databaseProvider.CreateSCIMToken(c.Request.Context(), token); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to create token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "SCIM token created successfully. Store it now, it will not be shown again.",
+		Data: gin.H{
+			"token":         plaintext,
+			"scim_base_url": h.baseURL,
+			"scim_token":    scimTokenResponse(token),
+		},
+	})
+}
+
+// GetTokens handles listing the current company's SCIM tokens
+func (h *SCIMHandler) GetTokens(c *gin.Context) {
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.databaseProvider.GetSCIMTokensByCompany(c.Request.Context(), user.CompanyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get tokens",
+		})
+		return
+	}
+
+	// Convert to response format
+	var tokenList []gin.H
+	for _, token := range tokens {
+		tokenList = append(tokenList, scimTokenResponse(token))
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"scim_base_url": h.baseURL,
+			"scim_tokens":   tokenList,
+		},
+	})
+}
+
+// DeleteToken handles revoking a SCIM token
+func (h *SCIMHandler) DeleteToken(c *gin.Context) {
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	tokenID := c.Param("id")
+
+	// Check that the token belongs to the company
+	tokens, err := h.databaseProvider.GetSCIMTokensByCompany(c.Request.Context(), user.CompanyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get tokens",
+		})
+		return
+	}
+
+	found := false
+	for _, token := range tokens {
+		if token.ID == tokenID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Token not found",
+		})
+		return
+	}
+
+	if err := h.databaseProvider.DeleteSCIMToken(c.Request.Context(), tokenID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to delete token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "SCIM token revoked successfully",
+	})
+}
+
+// companyUser loads a user of the token's company, responding with 404 otherwise
+func (h *SCIMHandler) companyUser(c *gin.Context, userID string) (*database.User, bool) {
+	user, err := h.databaseProvider.GetUser(c.Request.Context(), userID)
+	if err != nil || user == nil || user.CompanyID != c.GetString("scim_company_id") {
+		scimError(c, http.StatusNotFound, "", "User not found")
+		return nil, false
+	}
+	return user, true
+}
+
+// notOwner reports whether a user does not own the token's company, responding with the
+// given error otherwise
+func (h *SCIMHandler) notOwner(c *gin.Context, user *database.User, detail string) bool {
+	owner, err := companyOwner(c.Request.Context(), h.databaseProvider, user.CompanyID, user.ID)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to get company")
+		return false
+	}
+	if owner {
+		scimError(c, http.StatusConflict, "", detail)
+		return false
+	}
+	return true
+}
+
+// saveUser persists a SCIM update, ending active sessions when the user is deactivated
+func (h *SCIMHandler) saveUser(c *gin.Context, user *database.User, wasActive bool, status int) {
+	// Email addresses must stay unique across the portal
+	if existingUser, err := h.databaseProvider.GetUserByEmail(c.Request.Context(), user.Email); err == nil && existingUser != nil && existingUser.ID != user.ID {
+		scimError(c, http.StatusConflict, "uniqueness", "Another user already uses this email")
+		return
+	}
+
+	now := time.Now()
+	if wasActive && !user.IsActive {
+		if !h.notOwner(c, user, "Cannot deactivate the company owner, transfer ownership first") {
+			return
+		}
+		user.SessionsRevokedAt = now
+	}
+	user.UpdatedAt = now
+
+	if err := h.databaseProvider.UpdateUser(c.Request.Context(), user); err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to update user")
+		return
+	}
+
+	scimJSON(c, status, h.userResource(user))
+}
+
+// updateGroupMembers adds, removes or replaces the members of a role group
+func (h *SCIMHandler) updateGroupMembers(c *gin.Context, role, op string, memberIDs []string) bool {
+	companyID := c.GetString("scim_company_id")
+
+	users, err := h.databaseProvider.GetUsersByCompany(c.Request.Context(), companyID)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", "Failed to get users")
+		return false
+	}
+
+	members := make(map[string]bool, len(memberIDs))
+	for _, id := range memberIDs {
+		members[id] = true
+	}
+
+	for _, user := range users {
+		newRole := user.Role
+		switch op {
+		case "add":
+			if members[user.ID] {
+				newRole = role
+			}
+		case "remove":
+			// Leaving the admin or guest group falls back to the regular user role
+			if members[user.ID] && user.Role == role {
+				newRole = string(auth.RoleUser)
+			}
+		case "replace":
+			if members[user.ID] {
+				newRole = role
+			} else if user.Role == role {
+				newRole = string(auth.RoleUser)
+			}
+		default:
+			scimError(c, http.StatusBadRequest, "invalidSyntax", "Unsupported operation "+op)
+			return false
+		}
+		delete(members, user.ID)
+
+		if newRole == user.Role {
+			continue
+		}
+		// The company owner stays an admin until ownership is transferred
+		if user.Role == string(auth.RoleAdmin) && !h.notOwner(c, user, "Cannot demote the company owner, transfer ownership first") {
+			return false
+		}
+		user.Role = newRole
+		user.UpdatedAt = time.Now()
+		if err := h.databaseProvider.UpdateUser(c.Request.Context(), user); err != nil {
+			scimError(c, http.StatusInternalServerError, "", "Failed to update user")
+			return false
+		}
+	}
+
+	// Members that are not users of this company cannot be added
+	if op != "remove" {
+		for id := range members {
+			scimError(c, http.StatusBadRequest, "invalidValue", "Unknown member "+id)
+			return false
+		}
+	}
+
+	return true
+}
+
+// userResource converts a user to a SCIM User resource
+func (h *SCIMHandler) userResource(user *database.User) models.SCIMUser {
+	active := user.IsActive
+	return models.SCIMUser{
+		Schemas:     []string{models.SCIMSchemaUser},
+		ID:          user.ID,
+		ExternalID:  user.ExternalID,
+		UserName:    user.Email,
+		Name:        &models.SCIMName{Formatted: user.Name},
+		DisplayName: user.Name,
+		Emails: []models.SCIMMultiValue{
+			{Value: user.Email, Type: "work", Primary: true},
+		},
+		Active: &active,
+		Groups: []models.SCIMMultiValue{
+			{Value: user.Role, Display: user.Role, Ref: h.baseURL + "/Groups/" + user.Role},
+		},
+		Meta: &models.SCIMMeta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     h.baseURL + "/Users/" + user.ID,
+		},
+	}
+}
+
+// groupResource converts a role and the company's users to a SCIM Group resource
+func (h *SCIMHandler) groupResource(role string, users []*database.User) models.SCIMGroup {
+	members := []models.SCIMMultiValue{}
+	for _, user := range users {
+		if user.Role == role {
+			members = append(members, models.SCIMMultiValue{
+				Value:   user.ID,
+				Display: user.Email,
+				Ref:     h.baseURL + "/Users/" + user.ID,
+			})
+		}
+	}
+
+	return models.SCIMGroup{
+		Schemas:     []string{models.SCIMSchemaGroup},
+		ID:          role,
+		DisplayName: role,
+		Members:     members,
+		Meta: &models.SCIMMeta{
+			ResourceType: "Group",
+			Location:     h.baseURL + "/Groups/" + role,
+		},
+	}
+}
+
+// applySCIMUserPatch applies a single PATCH operation to a user
+func applySCIMUserPatch(user *database.User, name *models.SCIMName, operation models.SCIMPatchOperation) error {
+	op := strings.ToLower(operation.Op)
+
+	switch op {
+	case "add", "replace":
+		if operation.Path == "" {
+			// Without a path the value is a partial resource
+			var attributes map[string]json.RawMessage
+			if err := json.Unmarshal(operation.Value, &attributes); err != nil {
+				return fmt.Errorf("value must be an object when no path is given")
+			}
+			for attribute, value := range attributes {
+				if err := setSCIMUserAttribute(user, name, attribute, value); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return setSCIMUserAttribute(user, name, operation.Path, operation.Value)
+	case "remove":
+		switch strings.ToLower(scimNormalizePath(operation.Path)) {
+		case "externalid":
+			user.ExternalID = ""
+		case "":
+			return fmt.Errorf("remove requires a path")
+		default:
+			return fmt.Errorf("attribute %s cannot be removed", operation.Path)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported operation %q", operation.Op)
+	}
+}
+
+// setSCIMUserAttribute sets a user attribute from a PATCH value. Unknown attributes
+// (such as enterprise extension fields) are ignored.
+func setSCIMUserAttribute(user *database.User, name *models.SCIMName, path string, value json.RawMessage) error {
+	switch strings.ToLower(scimNormalizePath(path)) {
+	case "active":
+		active, err := scimBool(value)
+		if err != nil {
+			return err
+		}
+		user.IsActive = active
+	case "username", "emails.value":
+		var email string
+		if err := json.Unmarshal(value, &email); err != nil || !strings.Contains(email, "@") {
+			return fmt.Errorf("%s must be an email address", path)
+		}
+		user.Email = strings.ToLower(email)
+	case "emails":
+		var emails []models.SCIMMultiValue
+		if err := json.Unmarshal(value, &emails); err != nil {
+			return fmt.Errorf("emails must be a list")
+		}
+		if email := scimPrimaryEmail(emails); email != "" {
+			user.Email = email
+		}
+	case "externalid":
+		if err := json.Unmarshal(value, &user.ExternalID); err != nil {
+			return fmt.Errorf("externalId must be a string")
+		}
+	case "displayname", "name.formatted":
+		var displayName string
+		if err := json.Unmarshal(value, &displayName); err != nil {
+			return fmt.Errorf("%s must be a string", path)
+		}
+		if displayName != "" {
+			user.Name = displayName
+		}
+	case "name.givenname":
+		if err := json.Unmarshal(value, &name.GivenName); err != nil {
+			return fmt.Errorf("name.givenName must be a string")
+		}
+	case "name.familyname":
+		if err := json.Unmarshal(value, &name.FamilyName); err != nil {
+			return fmt.Errorf("name.familyName must be a string")
+		}
+	case "name":
+		var parsed models.SCIMName
+		if err := json.Unmarshal(value, &parsed); err != nil {
+			return fmt.Errorf("name must be an object")
+		}
+		if parsed.Formatted != "" {
+			user.Name = parsed.Formatted
+		} else {
+			name.GivenName = parsed.GivenName
+			name.FamilyName = parsed.FamilyName
+		}
+	}
+	return nil
+}
+
+// scimQueryFilter parses the filter query parameter, responding with an error when it is invalid
+func scimQueryFilter(c *gin.Context) (scimFilter, bool) {
+	query := c.Query("filter")
+	if query == "" {
+		return nil, true
+	}
+
+	filter, err := parseSCIMFilter(query)
+	if err != nil {
+		scimError(c, http.StatusBadRequest, "invalidFilter", err.Error())
+		return nil, false
+	}
+	return filter, true
+}
+
+// scimGroupRole resolves a group ID to a role, responding with 404 for unknown groups
+func scimGroupRole(c *gin.Context, groupID string) (string, bool) {
+	for _, role := range scimRoleGroups {
+		if groupID == role {
+			return role, true
+		}
+	}
+	scimError(c, http.StatusNotFound, "", "Group not found")
+	return "", false
+}
+
+// scimEmail returns the user's email from userName or the primary email
+func scimEmail(user *models.SCIMUser) string {
+	if strings.Contains(user.UserName, "@") {
+		return strings.ToLower(strings.TrimSpace(user.UserName))
+	}
+	return scimPrimaryEmail(user.Emails)
+}
+
+// scimPrimaryEmail returns the primary email, or the first one when none is primary
+func scimPrimaryEmail(emails []models.SCIMMultiValue) string {
+	email := ""
+	for _, e := range emails {
+		if e.Primary || email == "" {
+			email = e.Value
+		}
+		if e.Primary {
+			break
+		}
+	}
+	if !strings.Contains(email, "@") {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// scimDisplayName returns the best display name for a SCIM user
+func scimDisplayName(user *models.SCIMUser, email string) string {
+	if user.Name != nil {
+		if user.Name.Formatted != "" {
+			return user.Name.Formatted
+		}
+		if full := strings.TrimSpace(user.Name.GivenName + " " + user.Name.FamilyName); full != "" {
+			return full
+		}
+	}
+	if user.DisplayName != "" {
+		return user.DisplayName
+	}
+	return email
+}
+
+// scimBool parses a boolean sent either as JSON boolean or as a string (Azure AD sends "False")
+func scimBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		if parsed, err := strconv.ParseBool(s); err == nil {
+			return parsed, nil
+		}
+	}
+	return false, fmt.Errorf("active must be a boolean")
+}
+
+// scimTokenResponse converts a SCIM token to the response format
+func scimTokenResponse(token *database.SCIMToken) gin.H {
+	return gin.H{
+		"id":           token.ID,
+		"name":         token.Name,
+		"token_prefix": token.TokenPrefix,
+		"created_by":   token.CreatedBy,
+		"created_at":   token.CreatedAt,
+		"last_used_at": token.LastUsedAt,
+	}
+}
+
+// scimList writes a paginated SCIM list response
+func scimList(c *gin.Context, resources []interface{}) {
+	startIndex, err := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(scimDefaultCount)))
+	if err != nil || count < 0 {
+		count = scimDefaultCount
+	}
+	if count > scimMaxCount {
+		count = scimMaxCount
+	}
+
+	total := len(resources)
+	start := startIndex - 1
+	if start > total {
+		start = total
+	}
+	end := start + count
+	if end > total {
+		end = total
+	}
+
+	page := resources[start:end]
+	if page == nil {
+		page = []interface{}{}
+	}
+
+	scimJSON(c, http.StatusOK, models.SCIMListResponse{
+		Schemas:      []string{models.SCIMSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(page),
+		Resources:    page,
+	})
+}
+
+// scimJSON writes a response with the SCIM media type
+func scimJSON(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", "application/scim+json")
+	c.JSON(status, body)
+}
+
+// scimError writes a SCIM error response
+func scimError(c *gin.Context, status int, scimType, detail string) {
+	scimJSON(c, status, models.SCIMError{
+		Schemas:  []string{models.SCIMSchemaError},
+		Status:   strconv.Itoa(status),
+		SCIMType: scimType,
+		Detail:   detail,
+	})
+}
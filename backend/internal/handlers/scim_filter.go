@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// scimFilter is a parsed SCIM filter: a disjunction of conjunctions of comparisons.
+// Grouping with parentheses and "not" are not supported.
+type scimFilter [][]scimComparison
+
+// scimComparison is a single "attrPath op value" expression
+type scimComparison struct {
+	path  string
+	op    string
+	value string
+}
+
+// parseSCIMFilter parses filters such as `userName eq "a@b.com" and active eq true`
+func parseSCIMFilter(filter string) (scimFilter, error) {
+	tokens, err := scimFilterTokens(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var result scimFilter
+	var group []scimComparison
+	for i := 0; i < len(tokens); {
+		if len(tokens)-i < 2 {
+			return nil, fmt.Errorf("incomplete filter expression")
+		}
+
+		comparison := scimComparison{
+			path: scimNormalizePath(tokens[i]),
+			op:   strings.ToLower(tokens[i+1]),
+		}
+		i += 2
+
+		switch comparison.op {
+		case "pr":
+		case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+			if i >= len(tokens) {
+				return nil, fmt.Errorf("missing value for %s", comparison.op)
+			}
+			comparison.value = tokens[i]
+			i++
+		default:
+			return nil, fmt.Errorf("unsupported operator %q", comparison.op)
+		}
+		group = append(group, comparison)
+
+		if i == len(tokens) {
+			break
+		}
+		switch strings.ToLower(tokens[i]) {
+		case "and":
+		case "or":
+			result = append(result, group)
+			group = nil
+		default:
+			return nil, fmt.Errorf("unexpected token %q", tokens[i])
+		}
+		i++
+		if i == len(tokens) {
+			return nil, fmt.Errorf("filter ends with a logical operator")
+		}
+	}
+
+	if len(group) == 0 {
+		return nil, fmt.Errorf("empty filter")
+	}
+	return append(result, group), nil
+}
+
+// Matches reports whether a resource satisfies the filter
+func (f scimFilter) Matches(resource interface{}) bool {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return false
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return false
+	}
+
+	for _, group := range f {
+		matched := true
+		for _, comparison := range group {
+			if !comparison.matches(document) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// matches evaluates the comparison against any of the attribute's values
+func (s scimComparison) matches(document map[string]interface{}) bool {
+	values := scimValues(document, strings.Split(s.path, "."))
+	if s.op == "pr" {
+		return len(values) > 0
+	}
+
+	for _, value := range values {
+		actual := strings.ToLower(value)
+		expected := strings.ToLower(s.value)
+		var ok bool
+		switch s.op {
+		case "eq":
+			ok = actual == expected
+		case "ne":
+			ok = actual != expected
+		case "co":
+			ok = strings.Contains(actual, expected)
+		case "sw":
+			ok = strings.HasPrefix(actual, expected)
+		case "ew":
+			ok = strings.HasSuffix(actual, expected)
+		case "gt":
+			ok = actual > expected
+		case "ge":
+			ok = actual >= expected
+		case "lt":
+			ok = actual < expected
+		case "le":
+			ok = actual <= expected
+		}
+		if ok {
+			return true
+		}
+	}
+
+	// "ne" also matches resources without the attribute
+	return s.op == "ne" && len(values) == 0
+}
+
+// scimValues resolves an attribute path to its string values, flattening multi-valued attributes
+func scimValues(node interface{}, path []string) []string {
+	switch v := node.(type) {
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			values = append(values, scimValues(item, path)...)
+		}
+		return values
+	case map[string]interface{}:
+		if len(path) == 0 {
+			// A complex attribute compares by its "value" sub-attribute
+			return scimValues(v, []string{"value"})
+		}
+		for key, child := range v {
+			if strings.EqualFold(key, path[0]) {
+				return scimValues(child, path[1:])
+			}
+		}
+		return nil
+	case nil:
+		return nil
+	default:
+		if len(path) > 0 {
+			return nil
+		}
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+// scimNormalizePath drops schema URN prefixes and value filters from an attribute path,
+// so `emails[type eq "work"].value` becomes `emails.value`
+func scimNormalizePath(path string) string {
+	if strings.HasPrefix(strings.ToLower(path), "urn:") {
+		if i := strings.LastIndex(path, ":"); i >= 0 {
+			path = path[i+1:]
+		}
+	}
+	if start := strings.Index(path, "["); start >= 0 {
+		if end := strings.Index(path[start:], "]"); end >= 0 {
+			path = path[:start] + path[start+end+1:]
+		}
+	}
+	return path
+}
+
+// scimFilterTokens splits a filter into attribute paths, operators and values
+func scimFilterTokens(filter string) ([]string, error) {
+	var tokens []string
+	var current strings.Builder
+	inQuotes := false
+	inBrackets := false
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for i := 0; i < len(filter); i++ {
+		ch := filter[i]
+		switch {
+		case inQuotes:
+			if ch == '\\' && i+1 < len(filter) {
+				i++
+				current.WriteByte(filter[i])
+			} else if ch == '"' {
+				inQuotes = false
+				tokens = append(tokens, current.String())
+				current.Reset()
+			} else {
+				current.WriteByte(ch)
+			}
+		case ch == '"' && !inBrackets:
+			flush()
+			inQuotes = true
+		case ch == '[':
+			inBrackets = true
+			current.WriteByte(ch)
+		case ch == ']':
+			inBrackets = false
+			current.WriteByte(ch)
+		case ch == '(' || ch == ')':
+			return nil, fmt.Errorf("grouping is not supported")
+		case (ch == ' ' || ch == '\t') && !inBrackets:
+			flush()
+		default:
+			current.WriteByte(ch)
+		}
+	}
+
+	if inQuotes || inBrackets {
+		return nil, fmt.Errorf("unterminated filter")
+	}
+	flush()
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty filter")
+	}
+	return tokens, nil
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+)
+
+// newSCIMTestDatabase returns a company with its owner and a member who belongs to a group
+// and is in the scope of a delegated admin assignment
+func newSCIMTestDatabase() *fakeDatabase {
+	db := newUserTestDatabase()
+	db.users["owner"] = &database.User{ID: "owner", Email: "owner@acme.com", CompanyID: "acme", Role: "admin", IsActive: true}
+	db.groups["sales"] = &database.Group{ID: "sales", CompanyID: "acme", Name: "Sales", UserIDs: []string{"member", "manager"}}
+	db.delegations["sales-admin"] = &database.DelegatedAdmin{ID: "sales-admin", CompanyID: "acme", UserID: "manager", Role: "user-manager", UserIDs: []string{"member"}}
+	return db
+}
+
+// scimRequest runs a SCIM user request for the company acme
+func scimRequest(h *SCIMHandler, method, userID, body string) int {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, "/scim/v2/Users/"+userID, strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/scim+json")
+	c.Params = gin.Params{{Key: "id", Value: userID}}
+	c.Set("scim_company_id", "acme")
+
+	switch method {
+	case http.MethodPut:
+		h.ReplaceUser(c)
+	case http.MethodDelete:
+		h.DeleteUser(c)
+	}
+	// Responses without a body are not written to the recorder
+	return c.Writer.Status()
+}
+
+func TestSCIMKeepsCompanyOwner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+	}{
+		{name: "delete", method: http.MethodDelete},
+		{name: "deactivate", method: http.MethodPut, body: `{"userName":"owner@acme.com","active":false}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newSCIMTestDatabase()
+			h := NewSCIMHandler(db, &fakeAuthProvider{}, "http://localhost")
+
+			if got := scimRequest(h, tt.method, "owner", tt.body); got != http.StatusConflict {
+				t.Fatalf("status = %d, want %d", got, http.StatusConflict)
+			}
+			if owner := db.users["owner"]; owner == nil || !owner.IsActive {
+				t.Fatalf("owner = %+v, want the active owner", owner)
+			}
+		})
+	}
+}
+
+func TestSCIMDeleteUserRemovesGroupsAndDelegations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := newSCIMTestDatabase()
+	h := NewSCIMHandler(db, &fakeAuthProvider{}, "http://localhost")
+
+	if got := scimRequest(h, http.MethodDelete, "member", ""); got != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", got, http.StatusNoContent)
+	}
+	if _, ok := db.users["member"]; ok {
+		t.Error("member was not deleted")
+	}
+	if members := db.groups["sales"].UserIDs; len(members) != 1 || members[0] != "manager" {
+		t.Errorf("group members = %v, want [manager]", members)
+	}
+	if scope := db.delegations["sales-admin"].UserIDs; len(scope) != 0 {
+		t.Errorf("delegation scope = %v, want none", scope)
+	}
+}
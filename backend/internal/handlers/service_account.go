@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/audit"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
+)
+
+// ServiceAccountHandler handles service account and API key management
+type ServiceAccountHandler struct {
+	databaseProvider database.DatabaseProvider
+}
+
+// NewServiceAccountHandler creates a new service account handler
+func NewServiceAccountHandler(databaseProvider database.DatabaseProvider) *ServiceAccountHandler {
+	return &ServiceAccountHandler{
+		databaseProvider: databaseProvider,
+	}
+}
+
+// CreateServiceAccount handles creating a service account for the admin's company
+func (h *ServiceAccountHandler) CreateServiceAccount(c *gin.Context) {
+	var req models.ServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	account := &database.ServiceAccount{
+		ID:          uuid.New().String(),
+		CompanyID:   user.CompanyID,
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedBy:   user.UserID,
+	}
+
+	if err := h.databaseProvider.CreateServiceAccount(c.Request.Context(), account); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to create service account",
+		})
+		return
+	}
+
+	audit.Record(c.Request.Context(), h.databaseProvider, audit.ForRequest(c, "service_account.created", "service_account", account.ID))
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Service account created successfully",
+		Data: gin.H{
+			"service_account": account,
+		},
+	})
+}
+
+// GetServiceAccounts handles listing the company's service accounts with their API keys
+func (h *ServiceAccountHandler) GetServiceAccounts(c *gin.Context) {
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	accounts, err := h.databaseProvider.GetServiceAccountsByCompany(c.Request.Context(), user.CompanyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get service accounts",
+		})
+		return
+	}
+
+	// Convert to response format
+	var accountList []gin.H
+	for _, account := range accounts {
+		keys, err := h.databaseProvider.GetAPIKeysByServiceAccount(c.Request.Context(), account.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to get API keys",
+			})
+			return
+		}
+
+		accountList = append(accountList, gin.H{
+			"id":          account.ID,
+			"name":        account.Name,
+			"description": account.Description,
+			"created_by":  account.CreatedBy,
+			"created_at":  account.CreatedAt,
+			"api_keys":    keys,
+		})
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"service_accounts": accountList,
+			"available_scopes": rbac.APIKeyScopes,
+		},
+	})
+}
+
+// DeleteServiceAccount handles deleting a service account and revoking all its API keys
+func (h *ServiceAccountHandler) DeleteServiceAccount(c *gin.Context) {
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	account, ok := h.companyServiceAccount(c, user)
+	if !ok {
+		return
+	}
+
+	keys, err := h.databaseProvider.GetAPIKeysByServiceAccount(c.Request.Context(), account.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to delete service account",
+		})
+		return
+	}
+	for _, key := range keys {
+		if err := h.databaseProvider.DeleteAPIKey(c.Request.Context(), key.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to delete service account",
+			})
+			return
+		}
+	}
+
+	if err := h.databaseProvider.DeleteServiceAccount(c.Request.Context(), account.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to delete service account",
+		})
+		return
+	}
+
+	audit.Record(c.Request.Context(), h.databaseProvider, audit.ForRequest(c, "service_account.deleted", "service_account", account.ID))
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Service account deleted successfully",
+	})
+}
+
+// CreateAPIKey handles issuing a scoped API key for a service account
+func (h *ServiceAccountHandler) CreateAPIKey(c *gin.Context) {
+	var req models.APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	for _, scope := range req.Scopes {
+		if !rbac.ValidAPIKeyScope(scope) {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "Unknown scope: " + scope,
+			})
+			return
+		}
+	}
+
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	// Keys cannot grant more than their creator holds
+	for _, scope := range req.Scopes {
+		if !user.Can(scope) {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "Cannot grant a scope you do not hold: " + scope,
+			})
+			return
+		}
+	}
+
+	account, ok := h.companyServiceAccount(c, user)
+	if !ok {
+		return
+	}
+
+	plaintext, err := auth.GenerateOpaqueToken(auth.APIKeyPrefix)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to generate API key",
+		})
+		return
+	}
+
+	key := &database.APIKey{
+		ID:               uuid.New().String(),
+		CompanyID:        user.CompanyID,
+		ServiceAccountID: account.ID,
+		Name:             req.Name,
+		KeyHash:          auth.HashToken(plaintext),
+		KeyPrefix:        plaintext[:len(auth.APIKeyPrefix)+6],
+		Scopes:           req.Scopes,
+		CreatedBy:        user.UserID,
+	}
+	if req.ExpiresInDays > 0 {
+		key.ExpiresAt = time.Now().AddDate(0, 0, req.ExpiresInDays)
+	}
+
+	if err := h.databaseProvider.CreateAPIKey(c.Request.Context(), key); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to create API key",
+		})
+		return
+	}
+
+	event := audit.ForRequest(c, "api_key.created", "api_key", key.ID)
+	event.Details["service_account_id"] = account.ID
+	event.Details["scopes"] = key.Scopes
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "API key created successfully. Store it now, it will not be shown again.",
+		Data: gin.H{
+			"key":     plaintext,
+			"api_key": key,
+		},
+	})
+}
+
+// DeleteAPIKey handles revoking an API key of a service account
+func (h *ServiceAccountHandler) DeleteAPIKey(c *gin.Context) {
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	account, ok := h.companyServiceAccount(c, user)
+	if !ok {
+		return
+	}
+
+	// Check that the key belongs to the service account
+	keys, err := h.databaseProvider.GetAPIKeysByServiceAccount(c.Request.Context(), account.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get API keys",
+		})
+		return
+	}
+
+	keyID := c.Param("keyID")
+	found := false
+	for _, key := range keys {
+		if key.ID == keyID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "API key not found",
+		})
+		return
+	}
+
+	if err := h.databaseProvider.DeleteAPIKey(c.Request.Context(), keyID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to revoke API key",
+		})
+		return
+	}
+
+	event := audit.ForRequest(c, "api_key.revoked", "api_key", keyID)
+	event.Details["service_account_id"] = account.ID
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "API key revoked successfully",
+	})
+}
+
+// companyServiceAccount loads the service account named in the URL, responding with
+// an error unless it belongs to the admin's company
+func (h *ServiceAccountHandler) companyServiceAccount(c *gin.Context, user models.UserContext) (*database.ServiceAccount, bool) {
+	account, err := h.databaseProvider.GetServiceAccount(c.Request.Context(), c.Param("id"))
+	if err != nil || account.CompanyID != user.CompanyID {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Service account not found",
+		})
+		return nil, false
+	}
+	return account, true
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
+)
+
+func TestAPIKeyScopesLimitedToCreator(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	accountManager := []string{rbac.CompanyRead, rbac.UsersRead, rbac.ServiceAccountsManage}
+
+	tests := []struct {
+		name   string
+		scopes []string
+		want   int
+	}{
+		{name: "scope the creator lacks", scopes: []string{rbac.UsersRead, rbac.UsersWrite}, want: http.StatusForbidden},
+		{name: "permission that is not a scope", scopes: []string{rbac.ServiceAccountsManage}, want: http.StatusBadRequest},
+		{name: "former scope name", scopes: []string{"invitations:write"}, want: http.StatusBadRequest},
+		{name: "scopes the creator holds", scopes: []string{rbac.CompanyRead, rbac.UsersRead}, want: http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeDatabase()
+			db.accounts["ci"] = &database.ServiceAccount{ID: "ci", CompanyID: "acme", Name: "CI"}
+			h := NewServiceAccountHandler(db)
+
+			body, _ := json.Marshal(models.APIKeyRequest{Name: "CI", Scopes: tt.scopes})
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/service-accounts/ci/api-keys", bytes.NewReader(body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Params = gin.Params{{Key: "id", Value: "ci"}}
+			principal.Set(c, models.UserContext{UserID: "manager", CompanyID: "acme", Role: "account-manager", Permissions: accountManager})
+			h.CreateAPIKey(c)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if created := len(db.apiKeys) == 1; created != (tt.want == http.StatusCreated) {
+				t.Errorf("API keys = %d", len(db.apiKeys))
+			}
+		})
+	}
+}
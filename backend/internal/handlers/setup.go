@@ -23,16 +23,10 @@ func NewSetupHandler(db database.DatabaseProvider) *SetupHandler {
 
 // GetSetupProgress returns the setup progress for a company
 func (h *SetupHandler) GetSetupProgress(c *gin.Context) {
-	userContext, exists := c.Get("user_context")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User context not found",
-		})
+	uc, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
-
-	uc := userContext.(models.UserContext)
 	
 	progress, err := h.databaseProvider.GetSetupProgress(c.Request.Context(), uc.CompanyID)
 	if err != nil {
@@ -51,17 +45,11 @@ func (h *SetupHandler) GetSetupProgress(c *gin.Context) {
 
 // UpdateSetupStep updates the setup step for a company
 func (h *SetupHandler) UpdateSetupStep(c *gin.Context) {
-	userContext, exists := c.Get("user_context")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User context not found",
-		})
+	uc, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
 
-	uc := userContext.(models.UserContext)
-
 	var req struct {
 		Step     string `json:"step" binding:"required"`
 		Progress int    `json:"progress" binding:"required,min=0,max=100"`
@@ -92,17 +80,11 @@ func (h *SetupHandler) UpdateSetupStep(c *gin.Context) {
 
 // GetCompanyStats returns company statistics
 func (h *SetupHandler) GetCompanyStats(c *gin.Context) {
-	userContext, exists := c.Get("user_context")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User context not found",
-		})
+	uc, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
 
-	uc := userContext.(models.UserContext)
-
 	// Get company
 	company, err := h.databaseProvider.GetCompany(c.Request.Context(), uc.CompanyID)
 	if err != nil {
@@ -201,17 +183,11 @@ func (h *SetupHandler) GetCompanyStats(c *gin.Context) {
 
 // UpdateConfigurationStatus updates configuration status for a feature
 func (h *SetupHandler) UpdateConfigurationStatus(c *gin.Context) {
-	userContext, exists := c.Get("user_context")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User context not found",
-		})
+	uc, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
 
-	uc := userContext.(models.UserContext)
-
 	var req models.ConfigurationStatusRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
@@ -238,17 +214,11 @@ func (h *SetupHandler) UpdateConfigurationStatus(c *gin.Context) {
 
 // GenerateShortcuts generates suggested shortcuts for a company domain
 func (h *SetupHandler) GenerateShortcuts(c *gin.Context) {
-	userContext, exists := c.Get("user_context")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User context not found",
-		})
+	uc, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
 
-	uc := userContext.(models.UserContext)
-
 	var req models.GenerateShortcutsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
@@ -275,17 +245,11 @@ func (h *SetupHandler) GenerateShortcuts(c *gin.Context) {
 
 // NudgeUsers sends reminders to invited users
 func (h *SetupHandler) NudgeUsers(c *gin.Context) {
-	userContext, exists := c.Get("user_context")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User context not found",
-		})
+	uc, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
 
-	uc := userContext.(models.UserContext)
-
 	var req models.NudgeUsersRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
@@ -322,17 +286,11 @@ func (h *SetupHandler) NudgeUsers(c *gin.Context) {
 
 // GetDownloadInfo returns download information for the custom browser
 func (h *SetupHandler) GetDownloadInfo(c *gin.Context) {
-	userContext, exists := c.Get("user_context")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User context not found",
-		})
+	uc, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
 
-	uc := userContext.(models.UserContext)
-
 	// Check if company setup is complete
 	company, err := h.databaseProvider.GetCompany(c.Request.Context(), uc.CompanyID)
 	if err != nil {
@@ -24,17 +24,11 @@ func NewBrowserShortcutHandler(databaseProvider database.DatabaseProvider) *Brow
 // GetShortcuts handles getting all browser shortcuts for a company
 func (h *BrowserShortcutHandler) GetShortcuts(c *gin.Context) {
 	// Get user from context
-	userContext, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User not authenticated",
-		})
+	user, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
 
-	user := userContext.(models.UserContext)
-
 	// Get shortcuts for the company
 	shortcuts, err := h.databaseProvider.GetBrowserShortcutsByCompany(c.Request.Context(), user.CompanyID)
 	if err != nil {
@@ -79,23 +73,8 @@ func (h *BrowserShortcutHandler) CreateShortcut(c *gin.Context) {
 	}
 
 	// Get user from context
-	userContext, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User not authenticated",
-		})
-		return
-	}
-
-	user := userContext.(models.UserContext)
-
-	// Check if user is admin
-	if user.Role != "admin" {
-		c.JSON(http.StatusForbidden, models.APIResponse{
-			Success: false,
-			Error:   "Only admins can create shortcuts",
-		})
+	user, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
 
@@ -148,27 +127,12 @@ func (h *BrowserShortcutHandler) UpdateShortcut(c *gin.Context) {
 	}
 
 	// Get user from context
-	userContext, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User not authenticated",
-		})
+	user, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
-
-	user := userContext.(models.UserContext)
 	shortcutID := c.Param("id")
 
-	// Check if user is admin
-	if user.Role != "admin" {
-		c.JSON(http.StatusForbidden, models.APIResponse{
-			Success: false,
-			Error:   "Only admins can update shortcuts",
-		})
-		return
-	}
-
 	// Get shortcut
 	shortcut, err := h.databaseProvider.GetBrowserShortcut(c.Request.Context(), shortcutID)
 	if err != nil {
@@ -234,27 +198,12 @@ func (h *BrowserShortcutHandler) UpdateShortcut(c *gin.Context) {
 // DeleteShortcut handles deleting a browser shortcut
 func (h *BrowserShortcutHandler) DeleteShortcut(c *gin.Context) {
 	// Get user from context
-	userContext, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User not authenticated",
-		})
+	user, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
-
-	user := userContext.(models.UserContext)
 	shortcutID := c.Param("id")
 
-	// Check if user is admin
-	if user.Role != "admin" {
-		c.JSON(http.StatusForbidden, models.APIResponse{
-			Success: false,
-			Error:   "Only admins can delete shortcuts",
-		})
-		return
-	}
-
 	// Get shortcut
 	shortcut, err := h.databaseProvider.GetBrowserShortcut(c.Request.Context(), shortcutID)
 	if err != nil {
@@ -0,0 +1,841 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/membership"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+)
+
+// oidcStateCookie holds the state and nonce of an outstanding OIDC login
+const oidcStateCookie = "sso_oidc_state"
+
+// errSSOCompanyMismatch is returned when an IdP asserts a user that belongs to another company
+var errSSOCompanyMismatch = errors.New("user belongs to another company")
+
+// errSSOEmailNotAllowed is returned when a company's IdP asserts an address outside the
+// company's domain that was not invited
+var errSSOEmailNotAllowed = errors.New("email address not allowed for this company")
+
+// oidcProviderEntry caches a company's OIDC relying party until its configuration changes
+type oidcProviderEntry struct {
+	updatedAt time.Time
+	provider  *auth.OIDCProvider
+}
+
+// SSOHandler handles per-company single sign-on and home-realm discovery
+type SSOHandler struct {
+	authProvider     auth.AuthProvider
+	databaseProvider database.DatabaseProvider
+	publicURL        string
+	redirectURL      string
+
+	mu            sync.Mutex
+	oidcProviders map[string]oidcProviderEntry
+}
+
+// NewSSOHandler creates a new SSO handler. publicURL is the externally
+// reachable base URL used to build per-company callback URLs.
+func NewSSOHandler(authProvider auth.AuthProvider, databaseProvider database.DatabaseProvider, publicURL, redirectURL string) *SSOHandler {
+	return &SSOHandler{
+		authProvider:     authProvider,
+		databaseProvider: databaseProvider,
+		publicURL:        strings.TrimSuffix(publicURL, "/"),
+		redirectURL:      redirectURL,
+		oidcProviders:    make(map[string]oidcProviderEntry),
+	}
+}
+
+// Discover maps an email domain to its company and reports how the user must sign in
+func (h *SSOHandler) Discover(c *gin.Context) {
+	var req models.SSODiscoveryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	company, config := companySSOConfig(c.Request.Context(), h.databaseProvider, req.Email)
+	if config == nil {
+		c.JSON(http.StatusOK, models.APIResponse{
+			Success: true,
+			Data: gin.H{
+				"sso_enabled": false,
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"sso_enabled": true,
+			"enforced":    config.Enforced,
+			"protocol":    config.Protocol,
+			"company_id":  company.ID,
+			"login_url":   ssoBasePath(company.ID) + "/login",
+		},
+	})
+}
+
+// Login redirects the browser to the company's identity provider
+func (h *SSOHandler) Login(c *gin.Context) {
+	companyID := c.Param("companyID")
+
+	config, ok := h.enabledConfig(c, companyID)
+	if !ok {
+		return
+	}
+
+	switch config.Protocol {
+	case "saml":
+		samlProvider, err := h.samlProvider(config)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Invalid SAML configuration",
+			})
+			return
+		}
+
+		redirectURL, requestID, err := samlProvider.AuthnRequestURL("")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to start SAML login",
+			})
+			return
+		}
+
+		// The IdP posts back cross-site, so the cookie must be SameSite=None
+		c.SetSameSite(http.SameSiteNoneMode)
+		c.SetCookie(samlRequestCookie, requestID, int((10 * time.Minute).Seconds()), ssoBasePath(companyID), "", true, true)
+
+		c.Redirect(http.StatusFound, redirectURL)
+	default:
+		state, err := randomToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to start SSO login",
+			})
+			return
+		}
+		nonce, err := randomToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to start SSO login",
+			})
+			return
+		}
+
+		redirectURL, err := h.oidcProvider(config).AuthCodeURL(c.Request.Context(), state, nonce)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, models.APIResponse{
+				Success: false,
+				Error:   "Failed to contact identity provider",
+			})
+			return
+		}
+
+		// The IdP redirects back with a top-level GET, which SameSite=Lax allows
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(oidcStateCookie, state+"."+nonce, int((10 * time.Minute).Seconds()), ssoBasePath(companyID), "", true, true)
+
+		c.Redirect(http.StatusFound, redirectURL)
+	}
+}
+
+// SAMLMetadata serves the SP metadata document for a company
+func (h *SSOHandler) SAMLMetadata(c *gin.Context) {
+	config, ok := h.protocolConfig(c, c.Param("companyID"), "saml")
+	if !ok {
+		return
+	}
+
+	samlProvider, err := h.samlProvider(config)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Invalid SAML configuration",
+		})
+		return
+	}
+
+	metadata, err := samlProvider.Metadata()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to generate SAML metadata",
+		})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// SAMLACS handles the POST binding assertion consumer service for a company
+func (h *SSOHandler) SAMLACS(c *gin.Context) {
+	companyID := c.Param("companyID")
+
+	var requestIDs []string
+	if requestID, err := c.Cookie(samlRequestCookie); err == nil && requestID != "" {
+		requestIDs = append(requestIDs, requestID)
+	}
+	c.SetSameSite(http.SameSiteNoneMode)
+	c.SetCookie(samlRequestCookie, "", -1, ssoBasePath(companyID), "", true, true)
+
+	config, ok := h.protocolConfig(c, companyID, "saml")
+	if !ok {
+		return
+	}
+
+	samlProvider, err := h.samlProvider(config)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Invalid SAML configuration",
+		})
+		return
+	}
+
+	identity, err := samlProvider.ParseResponse(c.Request, requestIDs)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Invalid SAML response",
+		})
+		return
+	}
+
+	h.completeLogin(c, companyID, identity.Email, identity.Name, identity.Role)
+}
+
+// OIDCCallback handles the authorization code redirect for a company
+func (h *SSOHandler) OIDCCallback(c *gin.Context) {
+	companyID := c.Param("companyID")
+
+	stateCookie, _ := c.Cookie(oidcStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, ssoBasePath(companyID), "", true, true)
+
+	config, ok := h.protocolConfig(c, companyID, "oidc")
+	if !ok {
+		return
+	}
+
+	if errorCode := c.Query("error"); errorCode != "" {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Identity provider returned an error: " + errorCode,
+		})
+		return
+	}
+
+	state, nonce, found := strings.Cut(stateCookie, ".")
+	if !found || state == "" || c.Query("state") != state {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Invalid SSO state",
+		})
+		return
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Authorization code is required",
+		})
+		return
+	}
+
+	identity, err := h.oidcProvider(config).Exchange(c.Request.Context(), code, nonce)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Invalid SSO response",
+		})
+		return
+	}
+
+	if identity.Email == "" {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Identity provider did not return an email address",
+		})
+		return
+	}
+	if _, present := identity.Claims["email_verified"]; present && !identity.EmailVerified {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Email address is not verified",
+		})
+		return
+	}
+
+	h.completeLogin(c, companyID, identity.Email, identity.Name, oidcRole(identity.Claims, config.AttributeMapping))
+}
+
+// GetConfig returns the current company's SSO configuration
+func (h *SSOHandler) GetConfig(c *gin.Context) {
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	config, err := h.databaseProvider.GetSSOConfig(c.Request.Context(), user.CompanyID)
+	if err != nil {
+		c.JSON(http.StatusOK, models.APIResponse{
+			Success: true,
+			Data: gin.H{
+				"sso_config":   nil,
+				"service_urls": h.serviceURLs(user.CompanyID),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"sso_config":   ssoConfigResponse(config),
+			"service_urls": h.serviceURLs(user.CompanyID),
+		},
+	})
+}
+
+// UpdateConfig creates or replaces the current company's SSO configuration
+func (h *SSOHandler) UpdateConfig(c *gin.Context) {
+	var req models.SSOConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	if req.Enforced && !req.Enabled {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "SSO must be enabled to be enforced",
+		})
+		return
+	}
+
+	config := &database.SSOConfig{
+		CompanyID:        user.CompanyID,
+		Protocol:         req.Protocol,
+		Enabled:          req.Enabled,
+		Enforced:         req.Enforced,
+		OIDCIssuer:       strings.TrimSpace(req.OIDCIssuer),
+		OIDCClientID:     strings.TrimSpace(req.OIDCClientID),
+		OIDCClientSecret: req.OIDCClientSecret,
+		SAMLMetadataXML:  req.SAMLMetadataXML,
+		SAMLEntityID:     strings.TrimSpace(req.SAMLEntityID),
+		SAMLSSOURL:       strings.TrimSpace(req.SAMLSSOURL),
+		SAMLCertificate:  req.SAMLCertificate,
+		AttributeMapping: req.AttributeMapping,
+	}
+
+	// Keep the stored client secret unless a new one is provided
+	existing, err := h.databaseProvider.GetSSOConfig(c.Request.Context(), user.CompanyID)
+	if err == nil && existing != nil {
+		config.CreatedAt = existing.CreatedAt
+		if config.OIDCClientSecret == "" {
+			config.OIDCClientSecret = existing.OIDCClientSecret
+		}
+	}
+
+	// Validate protocol settings
+	switch config.Protocol {
+	case "oidc":
+		if config.OIDCIssuer == "" || config.OIDCClientID == "" || config.OIDCClientSecret == "" {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "OIDC issuer, client ID and client secret are required",
+			})
+			return
+		}
+		if issuer, err := url.Parse(config.OIDCIssuer); err != nil || issuer.Scheme != "https" {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "OIDC issuer must be an https URL",
+			})
+			return
+		}
+	case "saml":
+		if _, err := h.samlProvider(config); err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "Invalid SAML configuration: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	if err := h.databaseProvider.UpdateSSOConfig(c.Request.Context(), config); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update SSO configuration",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "SSO configuration updated successfully",
+		Data: gin.H{
+			"sso_config":   ssoConfigResponse(config),
+			"service_urls": h.serviceURLs(user.CompanyID),
+		},
+	})
+}
+
+// DeleteConfig removes the current company's SSO configuration
+func (h *SSOHandler) DeleteConfig(c *gin.Context) {
+	user, ok := companyUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.databaseProvider.DeleteSSOConfig(c.Request.Context(), user.CompanyID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to delete SSO configuration",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "SSO configuration deleted successfully",
+	})
+}
+
+// completeLogin provisions the asserted user into the company and issues a session
+func (h *SSOHandler) completeLogin(c *gin.Context, companyID, email, name string, role auth.UserRole) {
+	dbUser, err := provisionSSOUser(c.Request.Context(), h.databaseProvider, email, name, role, companyID)
+	if err != nil {
+		if errors.Is(err, errSSOCompanyMismatch) {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "User belongs to another company",
+			})
+			return
+		}
+		if errors.Is(err, errSSOEmailNotAllowed) {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "Email address is not in the company's domain and has no invitation",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get user data",
+		})
+		return
+	}
+
+	respondSSOLogin(c, h.authProvider, dbUser, h.redirectURL)
+}
+
+// enabledConfig loads a company's SSO configuration, responding with 404 when SSO is not enabled
+func (h *SSOHandler) enabledConfig(c *gin.Context, companyID string) (*database.SSOConfig, bool) {
+	config, err := h.databaseProvider.GetSSOConfig(c.Request.Context(), companyID)
+	if err != nil || config == nil || !config.Enabled {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "SSO is not configured for this company",
+		})
+		return nil, false
+	}
+	return config, true
+}
+
+// protocolConfig loads an enabled SSO configuration using the given protocol
+func (h *SSOHandler) protocolConfig(c *gin.Context, companyID, protocol string) (*database.SSOConfig, bool) {
+	config, ok := h.enabledConfig(c, companyID)
+	if !ok {
+		return nil, false
+	}
+	if config.Protocol != protocol {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "SSO protocol mismatch",
+		})
+		return nil, false
+	}
+	return config, true
+}
+
+// samlProvider builds the SAML service provider for a company configuration
+func (h *SSOHandler) samlProvider(config *database.SSOConfig) (*auth.SAMLServiceProvider, error) {
+	urls := h.serviceURLs(config.CompanyID)
+	return auth.NewSAMLServiceProvider(auth.SAMLConfig{
+		MetadataURL:    urls["saml_metadata_url"],
+		ACSURL:         urls["saml_acs_url"],
+		IdPMetadataXML: config.SAMLMetadataXML,
+		IdPEntityID:    config.SAMLEntityID,
+		IdPSSOURL:      config.SAMLSSOURL,
+		IdPCertificate: config.SAMLCertificate,
+		AttributeMapping: auth.SAMLAttributeMapping{
+			Email:           config.AttributeMapping["email"],
+			Name:            config.AttributeMapping["name"],
+			Role:            config.AttributeMapping["role"],
+			AdminRoleValues: adminRoleValues(config.AttributeMapping),
+		},
+	})
+}
+
+// oidcProvider returns the cached OIDC relying party for a company configuration
+func (h *SSOHandler) oidcProvider(config *database.SSOConfig) *auth.OIDCProvider {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if entry, ok := h.oidcProviders[config.CompanyID]; ok && entry.updatedAt.Equal(config.UpdatedAt) {
+		return entry.provider
+	}
+
+	provider := auth.NewOIDCProvider(auth.OIDCConfig{
+		Issuer:       config.OIDCIssuer,
+		ClientID:     config.OIDCClientID,
+		ClientSecret: config.OIDCClientSecret,
+		RedirectURL:  h.serviceURLs(config.CompanyID)["oidc_redirect_url"],
+	})
+	h.oidcProviders[config.CompanyID] = oidcProviderEntry{
+		updatedAt: config.UpdatedAt,
+		provider:  provider,
+	}
+
+	return provider
+}
+
+// serviceURLs returns the URLs an admin registers with their identity provider
+func (h *SSOHandler) serviceURLs(companyID string) map[string]string {
+	base := h.publicURL + ssoBasePath(companyID)
+	return map[string]string{
+		"login_url":         base + "/login",
+		"saml_metadata_url": base + "/saml/metadata",
+		"saml_acs_url":      base + "/saml/acs",
+		"oidc_redirect_url": base + "/oidc/callback",
+	}
+}
+
+// ssoConfigResponse converts an SSO configuration to the response format without secrets
+func ssoConfigResponse(config *database.SSOConfig) gin.H {
+	return gin.H{
+		"protocol":               config.Protocol,
+		"enabled":                config.Enabled,
+		"enforced":               config.Enforced,
+		"oidc_issuer":            config.OIDCIssuer,
+		"oidc_client_id":         config.OIDCClientID,
+		"oidc_client_secret_set": config.OIDCClientSecret != "",
+		"saml_metadata_xml":      config.SAMLMetadataXML,
+		"saml_entity_id":         config.SAMLEntityID,
+		"saml_sso_url":           config.SAMLSSOURL,
+		"saml_certificate":       config.SAMLCertificate,
+		"attribute_mapping":      config.AttributeMapping,
+		"updated_at":             config.UpdatedAt,
+	}
+}
+
+// companySSOConfig returns the company owning an email's domain along with its enabled SSO configuration
+func companySSOConfig(ctx context.Context, databaseProvider database.DatabaseProvider, email string) (*database.Company, *database.SSOConfig) {
+	domain := emailDomain(email)
+	if domain == "" {
+		return nil, nil
+	}
+
+	company, err := databaseProvider.GetCompanyByDomain(ctx, domain)
+	if err != nil || company == nil {
+		return nil, nil
+	}
+
+	config, err := databaseProvider.GetSSOConfig(ctx, company.ID)
+	if err != nil || config == nil || !config.Enabled {
+		return nil, nil
+	}
+
+	return company, config
+}
+
+// enforcedSSOLoginPath returns the SSO login path when the email's company has made SSO mandatory
+func enforcedSSOLoginPath(ctx context.Context, databaseProvider database.DatabaseProvider, email string) (string, bool) {
+	company, config := companySSOConfig(ctx, databaseProvider, email)
+	if config == nil || !config.Enforced {
+		return "", false
+	}
+	return ssoBasePath(company.ID) + "/login", true
+}
+
+// ssoBasePath returns the API path prefix for a company's SSO endpoints
+func ssoBasePath(companyID string) string {
+	return "/api/v1/auth/sso/" + url.PathEscape(companyID)
+}
+
+// provisionSSOUser finds the user for an IdP-asserted identity, creating it on first login.
+// Without companyID the identity comes from the platform IdP: new users join the company
+// that owns their email domain with the asserted role, and existing users keep their role.
+// See provisionCompanySSOUser for company IdPs.
+func provisionSSOUser(ctx context.Context, databaseProvider database.DatabaseProvider, email, name string, role auth.UserRole, companyID string) (*database.User, error) {
+	if companyID != "" {
+		return provisionCompanySSOUser(ctx, databaseProvider, email, name, role, companyID)
+	}
+
+	now := time.Now()
+
+	dbUser, err := databaseProvider.GetUserByEmail(ctx, email)
+	if err == nil && dbUser != nil {
+		// The platform IdP is not tied to the user's company, so the asserted role only
+		// applies to new users
+		dbUser.LastLoginAt = now
+		if err := databaseProvider.UpdateUser(ctx, dbUser); err != nil {
+			return nil, err
+		}
+		return dbUser, nil
+	}
+
+	if role == "" {
+		role = auth.RoleUser
+	}
+
+	if company, err := databaseProvider.GetCompanyByDomain(ctx, emailDomain(email)); err == nil && company != nil {
+		companyID = company.ID
+	}
+
+	return createSSOUser(ctx, databaseProvider, email, name, role, companyID)
+}
+
+// provisionCompanySSOUser finds or creates the user for an identity asserted by a company's
+// own IdP. The IdP is only trusted for addresses in the company's domain and for invitees:
+// existing accounts must already be members of the company or hold an invitation to it,
+// and only the roles of members follow the IdP. Invitees join as users.
+func provisionCompanySSOUser(ctx context.Context, databaseProvider database.DatabaseProvider, email, name string, role auth.UserRole, companyID string) (*database.User, error) {
+	company, err := databaseProvider.GetCompany(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation := pendingCompanyInvitation(ctx, databaseProvider, email, companyID)
+	inDomain := company.Domain != "" && emailDomain(email) == strings.ToLower(company.Domain)
+	if !inDomain && invitation == nil {
+		return nil, errSSOEmailNotAllowed
+	}
+
+	dbUser, err := databaseProvider.GetUserByEmail(ctx, email)
+	if err != nil || dbUser == nil {
+		if !inDomain || role == "" {
+			role = auth.RoleUser
+		}
+		dbUser, err = createSSOUser(ctx, databaseProvider, email, name, role, companyID)
+		if err != nil {
+			return nil, err
+		}
+		return dbUser, acceptSSOInvitation(ctx, databaseProvider, invitation)
+	}
+
+	companyMembership, err := membership.Get(ctx, databaseProvider, dbUser, companyID)
+	switch {
+	case err == nil:
+		// The IdP is authoritative for the role of members when it asserts one
+		if role != "" && companyMembership.Role != string(role) {
+			if companyMembership.Home {
+				dbUser.Role = string(role)
+			} else {
+				companyMembership.Role = string(role)
+				if err := databaseProvider.UpdateCompanyMembership(ctx, companyMembership); err != nil {
+					return nil, err
+				}
+			}
+		}
+	case invitation != nil:
+		if err := membership.Add(ctx, databaseProvider, dbUser, companyID, string(auth.RoleUser)); err != nil {
+			return nil, err
+		}
+		if err := acceptSSOInvitation(ctx, databaseProvider, invitation); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errSSOCompanyMismatch
+	}
+
+	dbUser.LastLoginAt = time.Now()
+	if err := databaseProvider.UpdateUser(ctx, dbUser); err != nil {
+		return nil, err
+	}
+	return dbUser, nil
+}
+
+// createSSOUser creates an active user on their first SSO login
+func createSSOUser(ctx context.Context, databaseProvider database.DatabaseProvider, email, name string, role auth.UserRole, companyID string) (*database.User, error) {
+	now := time.Now()
+	dbUser := &database.User{
+		ID:               uuid.New().String(),
+		Email:            email,
+		Name:             name,
+		CompanyID:        companyID,
+		Role:             string(role),
+		IsActive:         true,
+		LastLoginAt:      now,
+		InvitationStatus: "active",
+		ActivatedAt:      now,
+	}
+
+	if err := databaseProvider.CreateUser(ctx, dbUser); err != nil {
+		return nil, err
+	}
+
+	return dbUser, nil
+}
+
+// pendingCompanyInvitation returns an invitation of the email address to a company that
+// can still be accepted
+func pendingCompanyInvitation(ctx context.Context, databaseProvider database.DatabaseProvider, email, companyID string) *database.Invitation {
+	invitations, err := databaseProvider.GetInvitationsByEmail(ctx, email)
+	if err != nil {
+		return nil
+	}
+	for _, invitation := range invitations {
+		if invitation.CompanyID == companyID && invitation.Status != "accepted" && invitation.Status != "expired" && time.Now().Before(invitation.ExpiresAt) {
+			return invitation
+		}
+	}
+	return nil
+}
+
+// acceptSSOInvitation marks an invitation used by an SSO login as accepted
+func acceptSSOInvitation(ctx context.Context, databaseProvider database.DatabaseProvider, invitation *database.Invitation) error {
+	if invitation == nil {
+		return nil
+	}
+	invitation.Status = "accepted"
+	invitation.AcceptedAt = time.Now()
+	return databaseProvider.UpdateInvitation(ctx, invitation)
+}
+
+// respondSSOLogin issues a session token for an SSO user and redirects or responds like Login
+func respondSSOLogin(c *gin.Context, authProvider auth.AuthProvider, dbUser *database.User, redirectURL string) {
+	if !dbUser.IsActive {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "User account is inactive",
+		})
+		return
+	}
+
+	// Issue a session through the regular JWT path
+	token, err := authProvider.GenerateToken(sessionUser(dbUser))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to generate token",
+		})
+		return
+	}
+
+	if redirectURL != "" {
+		c.Redirect(http.StatusSeeOther, redirectURL+"#token="+url.QueryEscape(token))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Login successful",
+		Data: gin.H{
+			"token": token,
+			"user": gin.H{
+				"id":         dbUser.ID,
+				"email":      dbUser.Email,
+				"name":       dbUser.Name,
+				"picture":    dbUser.Picture,
+				"company_id": dbUser.CompanyID,
+				"role":       dbUser.Role,
+			},
+		},
+	})
+}
+
+// oidcRole maps the configured role claim to a user role, returning "" when the claim is absent
+func oidcRole(claims jwt.MapClaims, mapping map[string]string) auth.UserRole {
+	claimName := mapping["role"]
+	if claimName == "" {
+		return ""
+	}
+
+	var values []string
+	switch claim := claims[claimName].(type) {
+	case string:
+		values = []string{claim}
+	case []interface{}:
+		for _, value := range claim {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+	default:
+		return ""
+	}
+
+	role := auth.RoleUser
+	for _, value := range values {
+		for _, adminValue := range adminRoleValues(mapping) {
+			if strings.EqualFold(value, adminValue) {
+				return auth.RoleAdmin
+			}
+		}
+		if strings.EqualFold(value, string(auth.RoleGuest)) {
+			role = auth.RoleGuest
+		}
+	}
+
+	return role
+}
+
+// adminRoleValues returns the role values that grant admin, defaulting to "admin"
+func adminRoleValues(mapping map[string]string) []string {
+	configured := mapping["admin_role_values"]
+	if configured == "" {
+		return []string{string(auth.RoleAdmin)}
+	}
+
+	var values []string
+	for _, value := range strings.Split(configured, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// randomToken returns a URL-safe random string
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
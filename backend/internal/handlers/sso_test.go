@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/membership"
+)
+
+// newSSOTestDatabase returns a database with the company acme.com and an unrelated account
+// without a company
+func newSSOTestDatabase() *fakeDatabase {
+	db := newFakeDatabase()
+	db.companies["acme"] = &database.Company{ID: "acme", Domain: "acme.com"}
+	db.users["outsider"] = &database.User{ID: "outsider", Email: "victim@example.com", Role: "user", IsActive: true}
+	return db
+}
+
+func TestProvisionCompanySSOUserRejectsForeignDomain(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		email string
+	}{
+		{name: "existing account without a company", email: "victim@example.com"},
+		{name: "new account", email: "someone@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newSSOTestDatabase()
+
+			_, err := provisionSSOUser(ctx, db, tt.email, "Mallory", auth.RoleAdmin, "acme")
+			if !errors.Is(err, errSSOEmailNotAllowed) {
+				t.Fatalf("provisionSSOUser() error = %v, want errSSOEmailNotAllowed", err)
+			}
+
+			outsider := db.users["outsider"]
+			if outsider.CompanyID != "" || outsider.Role != "user" {
+				t.Errorf("outside account changed to company %q role %q", outsider.CompanyID, outsider.Role)
+			}
+			if _, err := db.GetUserByEmail(ctx, "someone@example.com"); err == nil {
+				t.Error("user created for an address outside the company's domain")
+			}
+		})
+	}
+}
+
+func TestProvisionCompanySSOUserRequiresMembershipForExistingAccounts(t *testing.T) {
+	ctx := context.Background()
+	db := newSSOTestDatabase()
+	db.users["drifter"] = &database.User{ID: "drifter", Email: "drifter@acme.com", Role: "user", IsActive: true}
+
+	_, err := provisionSSOUser(ctx, db, "drifter@acme.com", "Drifter", auth.RoleAdmin, "acme")
+	if !errors.Is(err, errSSOCompanyMismatch) {
+		t.Fatalf("provisionSSOUser() error = %v, want errSSOCompanyMismatch", err)
+	}
+	if drifter := db.users["drifter"]; drifter.CompanyID != "" || drifter.Role != "user" {
+		t.Errorf("account without membership changed to company %q role %q", drifter.CompanyID, drifter.Role)
+	}
+}
+
+func TestProvisionCompanySSOUserInvitedOutsideDomain(t *testing.T) {
+	ctx := context.Background()
+	db := newSSOTestDatabase()
+	db.invitations["invite"] = &database.Invitation{
+		ID:        "invite",
+		Email:     "victim@example.com",
+		CompanyID: "acme",
+		Status:    "pending",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	dbUser, err := provisionSSOUser(ctx, db, "victim@example.com", "Invitee", auth.RoleAdmin, "acme")
+	if err != nil {
+		t.Fatalf("provisionSSOUser() error = %v", err)
+	}
+
+	// Invitees join as users whatever role the IdP asserts
+	companyMembership, err := membership.Get(ctx, db, dbUser, "acme")
+	if err != nil {
+		t.Fatalf("invitee is not a member: %v", err)
+	}
+	if companyMembership.Role != string(auth.RoleUser) {
+		t.Errorf("invitee role = %q, want %q", companyMembership.Role, auth.RoleUser)
+	}
+	if status := db.invitations["invite"].Status; status != "accepted" {
+		t.Errorf("invitation status = %q, want accepted", status)
+	}
+}
+
+func TestProvisionCompanySSOUserInDomain(t *testing.T) {
+	ctx := context.Background()
+	db := newSSOTestDatabase()
+	db.users["member"] = &database.User{ID: "member", Email: "member@acme.com", CompanyID: "acme", Role: "user", IsActive: true}
+
+	created, err := provisionSSOUser(ctx, db, "new@ACME.com", "New", auth.RoleAdmin, "acme")
+	if err != nil {
+		t.Fatalf("provisionSSOUser() new user error = %v", err)
+	}
+	if created.CompanyID != "acme" || created.Role != string(auth.RoleAdmin) {
+		t.Errorf("new user company %q role %q, want acme admin", created.CompanyID, created.Role)
+	}
+
+	// The IdP stays authoritative for the roles of members
+	member, err := provisionSSOUser(ctx, db, "member@acme.com", "Member", auth.RoleAdmin, "acme")
+	if err != nil {
+		t.Fatalf("provisionSSOUser() member error = %v", err)
+	}
+	if member.Role != string(auth.RoleAdmin) || db.users["member"].Role != string(auth.RoleAdmin) {
+		t.Errorf("member role = %q, want admin", member.Role)
+	}
+}
+
+func TestProvisionPlatformSSOUserOnlySetsRoleOfNewUsers(t *testing.T) {
+	ctx := context.Background()
+	db := newSSOTestDatabase()
+	db.users["member"] = &database.User{ID: "member", Email: "member@acme.com", CompanyID: "acme", Role: "user", IsActive: true}
+
+	created, err := provisionSSOUser(ctx, db, "new@acme.com", "New", auth.RoleAdmin, "")
+	if err != nil {
+		t.Fatalf("provisionSSOUser() new user error = %v", err)
+	}
+	if created.CompanyID != "acme" || created.Role != string(auth.RoleAdmin) {
+		t.Errorf("new user company %q role %q, want acme admin", created.CompanyID, created.Role)
+	}
+
+	member, err := provisionSSOUser(ctx, db, "member@acme.com", "Member", auth.RoleAdmin, "")
+	if err != nil {
+		t.Fatalf("provisionSSOUser() member error = %v", err)
+	}
+	if member.Role != string(auth.RoleUser) || db.users["member"].Role != string(auth.RoleUser) {
+		t.Errorf("member role = %q, want user", member.Role)
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/audit"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/membership"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+)
+
+// GetMemberships lists the companies the current user belongs to and their role in each
+func (h *AuthHandler) GetMemberships(c *gin.Context) {
+	dbUser, _, ok := h.mfaSubject(c, "")
+	if !ok {
+		return
+	}
+	caller, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+	activeCompanyID := caller.CompanyID
+
+	memberships, err := membership.List(c.Request.Context(), h.databaseProvider, dbUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get memberships",
+		})
+		return
+	}
+
+	membershipList := []gin.H{}
+	for _, companyMembership := range memberships {
+		entry := gin.H{
+			"company_id": companyMembership.CompanyID,
+			"role":       companyMembership.Role,
+			"home":       companyMembership.Home,
+			"active":     companyMembership.CompanyID == activeCompanyID,
+		}
+		if company, err := h.databaseProvider.GetCompany(c.Request.Context(), companyMembership.CompanyID); err == nil {
+			entry["company_name"] = company.Name
+			entry["company_domain"] = company.Domain
+		}
+		membershipList = append(membershipList, entry)
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"memberships":       membershipList,
+			"active_company_id": activeCompanyID,
+		},
+	})
+}
+
+// SwitchCompany issues a session token scoped to another company the user belongs to.
+// Companies requiring MFA refuse users who have not set it up.
+func (h *AuthHandler) SwitchCompany(c *gin.Context) {
+	var req models.SwitchCompanyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	dbUser, _, ok := h.mfaSubject(c, "")
+	if !ok {
+		return
+	}
+	caller, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	companyMembership, err := membership.Get(c.Request.Context(), h.databaseProvider, dbUser, req.CompanyID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "You are not a member of this company",
+		})
+		return
+	}
+
+	company, err := h.databaseProvider.GetCompany(c.Request.Context(), req.CompanyID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Company not found",
+		})
+		return
+	}
+
+	// Every login challenges users with MFA enabled, so only users without it can reach a
+	// company requiring MFA without a second factor
+	if !dbUser.MFAEnabled && h.companyRequiresMFAIn(c.Request.Context(), dbUser, company.ID) {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "This company requires multi-factor authentication, set it up before switching",
+			Data: gin.H{
+				"mfa_enrollment_required": true,
+			},
+		})
+		return
+	}
+
+	user := sessionUser(dbUser)
+	user.CompanyID = company.ID
+	user.Role = auth.UserRole(companyMembership.Role)
+	user.TenantID = company.ID
+	user.Connection = caller.Connection
+	token, err := h.authProvider.GenerateToken(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to generate token",
+		})
+		return
+	}
+
+	event := audit.ForRequest(c, "tenant.switched", "company", company.ID)
+	event.CompanyID = company.ID
+	event.Details["from_company_id"] = caller.CompanyID
+	audit.Record(c.Request.Context(), h.databaseProvider, event)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Switched company",
+		Data: gin.H{
+			"token": token,
+			"company": gin.H{
+				"id":     company.ID,
+				"name":   company.Name,
+				"domain": company.Domain,
+			},
+			"role": companyMembership.Role,
+		},
+	})
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/membership"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+)
+
+func TestSwitchCompanyEnforcesTargetMFARequirement(t *testing.T) {
+	tests := []struct {
+		name       string
+		mfaEnabled bool
+		companyID  string
+		want       int
+	}{
+		{name: "company requiring MFA without MFA", companyID: "strict", want: http.StatusForbidden},
+		{name: "company requiring MFA with MFA", mfaEnabled: true, companyID: "strict", want: http.StatusOK},
+		{name: "company without an MFA requirement", companyID: "relaxed", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db, _ := newMFATestHandler(t)
+			db.users["alice"].CompanyID = "home"
+			db.users["alice"].MFAEnabled = tt.mfaEnabled
+			db.companies["home"] = &database.Company{ID: "home"}
+			db.companies["strict"] = &database.Company{ID: "strict", MFARequirement: "all"}
+			db.companies["relaxed"] = &database.Company{ID: "relaxed"}
+			for _, companyID := range []string{"strict", "relaxed"} {
+				db.memberships[membership.ID("alice", companyID)] = &database.CompanyMembership{
+					ID:        membership.ID("alice", companyID),
+					UserID:    "alice",
+					CompanyID: companyID,
+					Role:      "user",
+				}
+			}
+
+			body, _ := json.Marshal(models.SwitchCompanyRequest{CompanyID: tt.companyID})
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/auth/switch-company", bytes.NewReader(body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			principal.Set(c, models.UserContext{UserID: "alice", CompanyID: "home"})
+			h.SwitchCompany(c)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			var resp struct {
+				Data struct {
+					Token string `json:"token"`
+				} `json:"data"`
+			}
+			_ = json.Unmarshal(w.Body.Bytes(), &resp)
+			if issued := resp.Data.Token != ""; issued != (tt.want == http.StatusOK) {
+				t.Errorf("token = %q", resp.Data.Token)
+			}
+		})
+	}
+}
@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
 	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/groups"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/membership"
 	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
 )
 
 // UserHandler handles user-related requests
@@ -26,36 +31,77 @@ func NewUserHandler(databaseProvider database.DatabaseProvider, authProvider aut
 // GetUsers handles getting all users for a company
 func (h *UserHandler) GetUsers(c *gin.Context) {
 	// Get user from context
-	userContext, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
+	user, ok := authenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	// Get users for the company, including members whose home company is another one
+	users, err := membership.Users(c.Request.Context(), h.databaseProvider, user.CompanyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
-			Error:   "User not authenticated",
+			Error:   "Failed to get users",
 		})
 		return
 	}
 
-	user := userContext.(models.UserContext)
-
-	// Get users for the company
-	users, err := h.databaseProvider.GetUsersByCompany(c.Request.Context(), user.CompanyID)
+	companyGroups, err := h.databaseProvider.GetGroupsByCompany(c.Request.Context(), user.CompanyID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
-			Error:   "Failed to get users",
+			Error:   "Failed to get groups",
 		})
 		return
 	}
 
+	// Optionally keep only the effective members of a group
+	groupID := c.Query("group")
+	var groupMembers []string
+	if groupID != "" {
+		if !groupExists(companyGroups, groupID) {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "Group not found",
+			})
+			return
+		}
+		groupMembers = groups.Members(companyGroups, groupID)
+	}
+
+	// Delegated admins only see the users in their scope, which never includes privileged users
+	var roles map[string][]string
+	if !user.Can(rbac.UsersRead) {
+		roles, err = rbac.CompanyRoles(c.Request.Context(), h.databaseProvider, user.CompanyID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to get roles",
+			})
+			return
+		}
+	}
+
 	// Convert to response format
 	var userList []gin.H
 	for _, u := range users {
+		if groupID != "" && !groups.Contains(groupMembers, u.ID) {
+			continue
+		}
+		if !user.Can(rbac.UsersRead) && (rbac.Privileged(roles[u.Role]) || !rbac.InDelegatedScope(user, rbac.UsersRead, u.ID, groups.Resolve(companyGroups, u.ID))) {
+			continue
+		}
+		userGroups := groups.Resolve(companyGroups, u.ID)
+		if userGroups == nil {
+			userGroups = []string{}
+		}
 		userList = append(userList, gin.H{
 			"id":           u.ID,
 			"email":        u.Email,
 			"name":         u.Name,
 			"picture":      u.Picture,
 			"role":         u.Role,
+			"groups":       userGroups,
 			"is_active":    u.IsActive,
 			"created_at":   u.CreatedAt,
 			"updated_at":   u.UpdatedAt,
@@ -75,34 +121,20 @@ func (h *UserHandler) GetUsers(c *gin.Context) {
 // GetUser handles getting a specific user
 func (h *UserHandler) GetUser(c *gin.Context) {
 	// Get user from context
-	userContext, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User not authenticated",
-		})
+	currentUser, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
-
-	currentUser := userContext.(models.UserContext)
 	userID := c.Param("id")
 
-	// Get user from database
-	user, err := h.databaseProvider.GetUser(c.Request.Context(), userID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, models.APIResponse{
-			Success: false,
-			Error:   "User not found",
-		})
+	// Get user from database with their role in the current company
+	user, ok := companyMember(c, h.databaseProvider, userID, currentUser.CompanyID)
+	if !ok {
 		return
 	}
 
-	// Check if user belongs to the same company
-	if user.CompanyID != currentUser.CompanyID {
-		c.JSON(http.StatusForbidden, models.APIResponse{
-			Success: false,
-			Error:   "Access denied",
-		})
+	// Users without access to the directory can only view themselves
+	if currentUser.UserID != userID && !authorizeUserTarget(c, h.databaseProvider, currentUser, rbac.UsersRead, user) {
 		return
 	}
 
@@ -137,44 +169,41 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	}
 
 	// Get user from context
-	userContext, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User not authenticated",
-		})
+	currentUser, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
-
-	currentUser := userContext.(models.UserContext)
 	userID := c.Param("id")
 
-	// Get user from database
-	user, err := h.databaseProvider.GetUser(c.Request.Context(), userID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, models.APIResponse{
-			Success: false,
-			Error:   "User not found",
-		})
+	// Get user from database with their role in the current company
+	user, ok := companyMember(c, h.databaseProvider, userID, currentUser.CompanyID)
+	if !ok {
 		return
 	}
 
-	// Check if user belongs to the same company
-	if user.CompanyID != currentUser.CompanyID {
-		c.JSON(http.StatusForbidden, models.APIResponse{
-			Success: false,
-			Error:   "Access denied",
-		})
-		return
+	// Users can update their own profile; updating others takes users:write over them,
+	// through the caller's role or a delegated admin assignment covering the user
+	canWrite := currentUser.Can(rbac.UsersWrite)
+	if currentUser.UserID != userID {
+		if !authorizeUserTarget(c, h.databaseProvider, currentUser, rbac.UsersWrite, user) {
+			return
+		}
+		canWrite = true
 	}
 
-	// Check if current user is admin or updating their own profile
-	if currentUser.Role != "admin" && currentUser.UserID != userID {
-		c.JSON(http.StatusForbidden, models.APIResponse{
-			Success: false,
-			Error:   "Only admins can update other users",
-		})
-		return
+	// The company owner stays an active admin until ownership is transferred
+	if (req.Role != "" && req.Role != models.RoleAdmin) || (req.IsActive != nil && !*req.IsActive) {
+		owner, ok := isCompanyOwner(c, h.databaseProvider, currentUser.CompanyID, userID)
+		if !ok {
+			return
+		}
+		if owner {
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Success: false,
+				Error:   "Cannot demote or deactivate the company owner, transfer ownership first",
+			})
+			return
+		}
 	}
 
 	// Update user fields
@@ -182,19 +211,45 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		user.Name = req.Name
 	}
 	if req.Role != "" {
-		// Only admins can change roles
-		if currentUser.Role != "admin" {
+		// Only admins can change roles, delegated admin assignments don't allow it
+		if !currentUser.Can(rbac.UsersWrite) {
 			c.JSON(http.StatusForbidden, models.APIResponse{
 				Success: false,
 				Error:   "Only admins can change user roles",
 			})
 			return
 		}
+		if currentUser.UserID == userID {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "You cannot change your own role",
+			})
+			return
+		}
+		// Roles are built in or defined by the company
+		permissions, err := rbac.Permissions(c.Request.Context(), h.databaseProvider, currentUser.CompanyID, string(req.Role))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "Unknown role",
+			})
+			return
+		}
+		// Admins can only assign roles granting permissions they hold themselves
+		for _, permission := range permissions {
+			if !currentUser.Can(permission) {
+				c.JSON(http.StatusForbidden, models.APIResponse{
+					Success: false,
+					Error:   "Cannot assign a role with permissions you do not hold",
+				})
+				return
+			}
+		}
 		user.Role = string(req.Role)
 	}
 	if req.IsActive != nil {
-		// Only admins can deactivate users
-		if currentUser.Role != "admin" {
+		// Only admins and delegated admins can deactivate users
+		if !canWrite {
 			c.JSON(http.StatusForbidden, models.APIResponse{
 				Success: false,
 				Error:   "Only admins can deactivate users",
@@ -204,8 +259,46 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		user.IsActive = *req.IsActive
 	}
 
-	// Save updated user
-	if err := h.databaseProvider.UpdateUser(c.Request.Context(), user); err != nil {
+	// Members from other home companies only hold a role here; their account
+	// belongs to their home company
+	if user.CompanyID != currentUser.CompanyID {
+		if (req.Name != "" && currentUser.UserID != userID) || req.IsActive != nil {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "Only the user's home company can change their account",
+			})
+			return
+		}
+		if req.Role != "" {
+			companyMembership, err := h.databaseProvider.GetCompanyMembership(c.Request.Context(), membership.ID(userID, currentUser.CompanyID))
+			if err == nil {
+				companyMembership.Role = user.Role
+				err = h.databaseProvider.UpdateCompanyMembership(c.Request.Context(), companyMembership)
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, models.APIResponse{
+					Success: false,
+					Error:   "Failed to update user",
+				})
+				return
+			}
+		}
+		if req.Name != "" {
+			account, err := h.databaseProvider.GetUser(c.Request.Context(), userID)
+			if err == nil {
+				account.Name = req.Name
+				err = h.databaseProvider.UpdateUser(c.Request.Context(), account)
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, models.APIResponse{
+					Success: false,
+					Error:   "Failed to update user",
+				})
+				return
+			}
+		}
+	} else if err := h.databaseProvider.UpdateUser(c.Request.Context(), user); err != nil {
+		// Save updated user
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
 			Error:   "Failed to update user",
@@ -233,49 +326,45 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 // DeleteUser handles deleting a user
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	// Get user from context
-	userContext, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "User not authenticated",
-		})
+	currentUser, ok := authenticatedUser(c)
+	if !ok {
 		return
 	}
-
-	currentUser := userContext.(models.UserContext)
 	userID := c.Param("id")
 
-	// Check if current user is admin
-	if currentUser.Role != "admin" {
-		c.JSON(http.StatusForbidden, models.APIResponse{
-			Success: false,
-			Error:   "Only admins can delete users",
-		})
+	// Get user from database with their role in the current company
+	user, ok := companyMember(c, h.databaseProvider, userID, currentUser.CompanyID)
+	if !ok {
+		return
+	}
+	if !authorizeUserTarget(c, h.databaseProvider, currentUser, rbac.UsersWrite, user) {
 		return
 	}
 
-	// Get user from database
-	user, err := h.databaseProvider.GetUser(c.Request.Context(), userID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, models.APIResponse{
+	// The owner can only leave once ownership is transferred
+	owner, ok := isCompanyOwner(c, h.databaseProvider, currentUser.CompanyID, userID)
+	if !ok {
+		return
+	}
+	if owner {
+		c.JSON(http.StatusConflict, models.APIResponse{
 			Success: false,
-			Error:   "User not found",
+			Error:   "Cannot delete the company owner, transfer ownership first",
 		})
 		return
 	}
 
-	// Check if user belongs to the same company
-	if user.CompanyID != currentUser.CompanyID {
-		c.JSON(http.StatusForbidden, models.APIResponse{
+	// Prevent deleting the last admin, whatever their role is called
+	roles, err := rbac.CompanyRoles(c.Request.Context(), h.databaseProvider, currentUser.CompanyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
-			Error:   "Access denied",
+			Error:   "Failed to get roles",
 		})
 		return
 	}
-
-	// Prevent deleting the last admin
-	if user.Role == "admin" {
-		users, err := h.databaseProvider.GetUsersByCompany(c.Request.Context(), currentUser.CompanyID)
+	if rbac.FullAdmin(roles[user.Role]) {
+		users, err := membership.Users(c.Request.Context(), h.databaseProvider, currentUser.CompanyID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, models.APIResponse{
 				Success: false,
@@ -286,7 +375,7 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 
 		adminCount := 0
 		for _, u := range users {
-			if u.Role == "admin" && u.IsActive {
+			if rbac.FullAdmin(roles[u.Role]) && u.IsActive {
 				adminCount++
 			}
 		}
@@ -300,20 +389,19 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		}
 	}
 
-	// Delete user from auth provider
-	if err := h.authProvider.DeleteUser(c.Request.Context(), userID); err != nil {
+	if err := removeCompanyUser(c.Request.Context(), h.databaseProvider, h.authProvider, currentUser.CompanyID, user); err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
-			Error:   "Failed to delete user from auth provider",
+			Error:   "Failed to delete user",
 		})
 		return
 	}
 
-	// Delete user from database
-	if err := h.databaseProvider.DeleteUser(c.Request.Context(), userID); err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
-			Success: false,
-			Error:   "Failed to delete user from database",
+	// Members from other home companies are only removed from this company
+	if user.CompanyID != currentUser.CompanyID {
+		c.JSON(http.StatusOK, models.APIResponse{
+			Success: true,
+			Message: "User removed from company",
 		})
 		return
 	}
@@ -323,3 +411,53 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		Message: "User deleted successfully",
 	})
 }
+
+// removeCompanyUser takes a user out of a company. They leave its groups and delegated
+// admin assignments; members from other home companies only lose their membership, while
+// users of their home company are deleted from the auth provider and the database.
+func removeCompanyUser(ctx context.Context, databaseProvider database.DatabaseProvider, authProvider auth.AuthProvider, companyID string, user *database.User) error {
+	if err := groups.RemoveUser(ctx, databaseProvider, companyID, user.ID); err != nil {
+		return fmt.Errorf("failed to remove user from groups: %w", err)
+	}
+	if err := removeDelegations(ctx, databaseProvider, companyID, user.ID); err != nil {
+		return fmt.Errorf("failed to remove delegated admin assignments: %w", err)
+	}
+
+	if user.CompanyID != companyID {
+		if err := databaseProvider.DeleteCompanyMembership(ctx, membership.ID(user.ID, companyID)); err != nil {
+			return fmt.Errorf("failed to remove user from company: %w", err)
+		}
+		return nil
+	}
+
+	if err := authProvider.DeleteUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to delete user from auth provider: %w", err)
+	}
+	if err := databaseProvider.DeleteUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to delete user from database: %w", err)
+	}
+	return nil
+}
+
+// removeDelegations deletes the delegated admin assignments a user holds in a company
+// and removes the user from the scope of the others
+func removeDelegations(ctx context.Context, databaseProvider database.DatabaseProvider, companyID, userID string) error {
+	assignments, err := databaseProvider.GetDelegatedAdminsByCompany(ctx, companyID)
+	if err != nil {
+		return err
+	}
+
+	for _, assignment := range assignments {
+		switch {
+		case assignment.UserID == userID:
+			err = databaseProvider.DeleteDelegatedAdmin(ctx, assignment.ID)
+		case groups.Contains(assignment.UserIDs, userID):
+			assignment.UserIDs = groups.Without(assignment.UserIDs, userID)
+			err = databaseProvider.UpdateDelegatedAdmin(ctx, assignment)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
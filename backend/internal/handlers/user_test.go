@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
+)
+
+// userManagerPermissions are the permissions of a custom role that manages users but
+// not roles or security settings
+var userManagerPermissions = []string{rbac.CompanyRead, rbac.UsersRead, rbac.UsersWrite}
+
+// newUserTestDatabase returns a company with a user manager and a plain user
+func newUserTestDatabase() *fakeDatabase {
+	db := newFakeDatabase()
+	db.companies["acme"] = &database.Company{ID: "acme", Domain: "acme.com", AdminUserID: "owner"}
+	db.roles["user-manager"] = &database.CustomRole{ID: "user-manager", CompanyID: "acme", Name: "User manager", Permissions: userManagerPermissions}
+	db.users["manager"] = &database.User{ID: "manager", Email: "manager@acme.com", CompanyID: "acme", Role: "user-manager", IsActive: true}
+	db.users["member"] = &database.User{ID: "member", Email: "member@acme.com", CompanyID: "acme", Role: "user", IsActive: true}
+	return db
+}
+
+// updateUserRole asks to give a user a role as the user manager
+func updateUserRole(h *UserHandler, userID string, role models.UserRole) int {
+	body, _ := json.Marshal(models.UpdateUserRequest{Role: role})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPut, "/users/"+userID, bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: userID}}
+	principal.Set(c, models.UserContext{UserID: "manager", CompanyID: "acme", Role: "user-manager", Permissions: userManagerPermissions})
+	h.UpdateUser(c)
+	return w.Code
+}
+
+func TestUpdateUserRoleEscalation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		userID string
+		role   models.UserRole
+		want   int
+	}{
+		{name: "own role", userID: "manager", role: models.RoleAdmin, want: http.StatusForbidden},
+		{name: "role granting more than the caller holds", userID: "member", role: models.RoleAdmin, want: http.StatusForbidden},
+		{name: "role within the caller's permissions", userID: "member", role: "user-manager", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newUserTestDatabase()
+			before := db.users[tt.userID].Role
+
+			if status := updateUserRole(NewUserHandler(db, &fakeAuthProvider{}), tt.userID, tt.role); status != tt.want {
+				t.Fatalf("status = %d, want %d", status, tt.want)
+			}
+			after := db.users[tt.userID].Role
+			if tt.want == http.StatusOK && after != string(tt.role) {
+				t.Errorf("role = %q, want %q", after, tt.role)
+			}
+			if tt.want != http.StatusOK && after != before {
+				t.Errorf("role changed from %q to %q", before, after)
+			}
+		})
+	}
+}
+
+func TestDelegatedAdminCannotManagePrivilegedUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		role string
+		want int
+	}{
+		{name: "custom role with security:manage", role: "security-officer", want: http.StatusForbidden},
+		{name: "admin", role: "admin", want: http.StatusForbidden},
+		{name: "plain user", role: "user", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newUserTestDatabase()
+			db.roles["security-officer"] = &database.CustomRole{ID: "security-officer", CompanyID: "acme", Name: "Security officer", Permissions: []string{rbac.CompanyRead, rbac.SecurityManage}}
+			db.users["member"].Role = tt.role
+
+			active := false
+			body, _ := json.Marshal(models.UpdateUserRequest{IsActive: &active})
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPut, "/users/member", bytes.NewReader(body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Params = gin.Params{{Key: "id", Value: "member"}}
+			principal.Set(c, models.UserContext{
+				UserID:      "helpdesk",
+				CompanyID:   "acme",
+				Role:        "user",
+				Permissions: []string{rbac.CompanyRead, rbac.UsersRead, rbac.ShortcutsRead},
+				Delegations: []models.Delegation{{ID: "helpdesk", Permissions: []string{rbac.UsersRead, rbac.UsersWrite}, AllUsers: true}},
+			})
+			NewUserHandler(db, &fakeAuthProvider{}).UpdateUser(c)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if deactivated := !db.users["member"].IsActive; deactivated != (tt.want == http.StatusOK) {
+				t.Errorf("member active = %v", db.users["member"].IsActive)
+			}
+		})
+	}
+}
+
+func TestDeleteUserKeepsLastFullAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newUserTestDatabase()
+	db.roles["superuser"] = &database.CustomRole{ID: "superuser", CompanyID: "acme", Name: "Superuser", Permissions: rbac.BuiltInRoles()["admin"]}
+	db.users["member"].Role = "superuser"
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodDelete, "/users/member", nil)
+	c.Params = gin.Params{{Key: "id", Value: "member"}}
+	principal.Set(c, models.UserContext{UserID: "manager", CompanyID: "acme", Role: "user-manager", Permissions: userManagerPermissions})
+	NewUserHandler(db, &fakeAuthProvider{}).DeleteUser(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("deleting the only user with every admin permission status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,624 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+)
+
+// SetWebAuthn enables passkey registration, passkey login and passkeys as a second factor
+func (h *AuthHandler) SetWebAuthn(relyingParty *webauthn.WebAuthn) {
+	h.webAuthn = relyingParty
+}
+
+// BeginPasskeyRegistration returns the options for creating a passkey. Like TOTP
+// enrollment it is used by signed-in users and, with an enrollment token, during login.
+func (h *AuthHandler) BeginPasskeyRegistration(c *gin.Context) {
+	if !h.requireWebAuthn(c) {
+		return
+	}
+
+	var req models.MFAEnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	dbUser, _, ok := h.mfaSubject(c, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	user, _, err := h.webAuthnUser(c.Request.Context(), dbUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get passkeys",
+		})
+		return
+	}
+
+	// Passkeys must be discoverable so they can be used without a password
+	creation, session, err := h.webAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(webauthn.Credentials(user.Credentials).CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to start passkey registration",
+		})
+		return
+	}
+
+	h.respondWebAuthnOptions(c, auth.PurposeWebAuthnRegistration, dbUser.ID, creation, session,
+		"Create a passkey with the returned options and confirm it")
+}
+
+// FinishPasskeyRegistration verifies and stores a new passkey. Registering the first
+// passkey enables MFA and returns recovery codes; during login the response also
+// contains the session token.
+func (h *AuthHandler) FinishPasskeyRegistration(c *gin.Context) {
+	if !h.requireWebAuthn(c) {
+		return
+	}
+
+	var req models.WebAuthnFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	dbUser, duringLogin, ok := h.mfaSubject(c, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	subject, session, err := h.consumeWebAuthnSession(c.Request.Context(), auth.PurposeWebAuthnRegistration, req.WebAuthnToken)
+	if err != nil || subject != dbUser.ID {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid or expired passkey registration",
+		})
+		return
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(req.Credential)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid passkey credential",
+		})
+		return
+	}
+
+	user, _, err := h.webAuthnUser(c.Request.Context(), dbUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get passkeys",
+		})
+		return
+	}
+
+	credential, err := h.webAuthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Passkey verification failed",
+		})
+		return
+	}
+
+	name := req.Name
+	if name == "" {
+		name = "Passkey"
+	}
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	passkey := &database.WebAuthnCredential{
+		ID:              uuid.New().String(),
+		UserID:          dbUser.ID,
+		CredentialID:    base64.RawURLEncoding.EncodeToString(credential.ID),
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		Transports:      transports,
+		SignCount:       int64(credential.Authenticator.SignCount),
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		Name:            name,
+	}
+	if err := h.databaseProvider.CreateWebAuthnCredential(c.Request.Context(), passkey); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to save passkey",
+		})
+		return
+	}
+
+	data := gin.H{
+		"passkey": passkey,
+	}
+
+	if !dbUser.MFAEnabled {
+		codes, hashes, err := auth.GenerateRecoveryCodes()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to generate recovery codes",
+			})
+			return
+		}
+
+		dbUser.RecoveryCodeHashes = hashes
+		dbUser.MFAEnabled = true
+		dbUser.MFAEnabledAt = time.Now()
+		if err := h.databaseProvider.UpdateUser(c.Request.Context(), dbUser); err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to enable MFA",
+			})
+			return
+		}
+		data["recovery_codes"] = codes
+	}
+
+	if duringLogin {
+		h.respondSession(c, dbUser, http.StatusOK, "Passkey registered, login successful", data)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Passkey registered",
+		Data:    data,
+	})
+}
+
+// GetPasskeys handles listing the current user's passkeys
+func (h *AuthHandler) GetPasskeys(c *gin.Context) {
+	dbUser, _, ok := h.mfaSubject(c, "")
+	if !ok {
+		return
+	}
+
+	passkeys, err := h.databaseProvider.GetWebAuthnCredentialsByUser(c.Request.Context(), dbUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get passkeys",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: gin.H{
+			"passkeys": passkeys,
+		},
+	})
+}
+
+// DeletePasskey handles removing one of the current user's passkeys. Removing the
+// last second factor disables MFA unless the company requires it.
+func (h *AuthHandler) DeletePasskey(c *gin.Context) {
+	dbUser, _, ok := h.mfaSubject(c, "")
+	if !ok {
+		return
+	}
+
+	passkeys, err := h.databaseProvider.GetWebAuthnCredentialsByUser(c.Request.Context(), dbUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get passkeys",
+		})
+		return
+	}
+
+	var passkey *database.WebAuthnCredential
+	for _, candidate := range passkeys {
+		if candidate.ID == c.Param("id") {
+			passkey = candidate
+			break
+		}
+	}
+	if passkey == nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Passkey not found",
+		})
+		return
+	}
+
+	lastFactor := dbUser.MFAEnabled && len(passkeys) == 1 && dbUser.TOTPSecret == ""
+	if lastFactor && h.companyRequiresMFA(c, dbUser) {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Your company requires MFA",
+		})
+		return
+	}
+
+	if err := h.databaseProvider.DeleteWebAuthnCredential(c.Request.Context(), passkey.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to delete passkey",
+		})
+		return
+	}
+
+	if lastFactor {
+		dbUser.MFAEnabled = false
+		dbUser.MFAEnabledAt = time.Time{}
+		dbUser.RecoveryCodeHashes = nil
+		if err := h.databaseProvider.UpdateUser(c.Request.Context(), dbUser); err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to disable MFA",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Passkey deleted",
+	})
+}
+
+// BeginPasskeyLogin returns the options for a passwordless login with any
+// discoverable passkey
+func (h *AuthHandler) BeginPasskeyLogin(c *gin.Context) {
+	if !h.requireWebAuthn(c) {
+		return
+	}
+
+	// The passkey replaces both the password and the second factor, so it must verify the user
+	assertion, session, err := h.webAuthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to start passkey login",
+		})
+		return
+	}
+
+	h.respondWebAuthnOptions(c, auth.PurposeWebAuthnLogin, session.Challenge, assertion, session,
+		"Sign in with a passkey using the returned options")
+}
+
+// FinishPasskeyLogin verifies a passkey assertion and issues a session
+func (h *AuthHandler) FinishPasskeyLogin(c *gin.Context) {
+	if !h.requireWebAuthn(c) {
+		return
+	}
+
+	var req models.WebAuthnFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	_, session, err := h.consumeWebAuthnSession(c.Request.Context(), auth.PurposeWebAuthnLogin, req.WebAuthnToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Invalid or expired passkey login",
+		})
+		return
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(req.Credential)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid passkey credential",
+		})
+		return
+	}
+
+	var dbUser *database.User
+	var passkeys []*database.WebAuthnCredential
+	credential, err := h.webAuthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		var err error
+		dbUser, err = h.databaseProvider.GetUser(c.Request.Context(), string(userHandle))
+		if err != nil {
+			return nil, err
+		}
+
+		user, stored, err := h.webAuthnUser(c.Request.Context(), dbUser)
+		passkeys = stored
+		return user, err
+	}, *session, parsed)
+	if err != nil || !h.recordPasskeyUse(c.Request.Context(), passkeys, credential) {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Passkey verification failed",
+		})
+		return
+	}
+
+	// Local credentials are disabled when the company requires SSO
+	if loginURL, required := enforcedSSOLoginPath(c.Request.Context(), h.databaseProvider, dbUser.Email); required {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Single sign-on is required for this domain",
+			Data: gin.H{
+				"login_url": loginURL,
+			},
+		})
+		return
+	}
+
+	h.respondSession(c, dbUser, http.StatusOK, "Login successful", nil)
+}
+
+// BeginMFAPasskey returns the options for completing a login with a passkey as the
+// second factor
+func (h *AuthHandler) BeginMFAPasskey(c *gin.Context) {
+	if !h.requireWebAuthn(c) {
+		return
+	}
+
+	var req models.MFAChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	dbUser, _, ok := h.mfaChallengeUser(c, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	user, _, err := h.webAuthnUser(c.Request.Context(), dbUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get passkeys",
+		})
+		return
+	}
+	if len(user.Credentials) == 0 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "No passkeys registered",
+		})
+		return
+	}
+
+	assertion, session, err := h.webAuthn.BeginLogin(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to start passkey verification",
+		})
+		return
+	}
+
+	h.respondWebAuthnOptions(c, auth.PurposeWebAuthnMFA, dbUser.ID, assertion, session,
+		"Verify with a passkey using the returned options")
+}
+
+// FinishMFAPasskey completes a login with a passkey as the second factor. Like a code,
+// the passkey completes the MFA challenge it was started for only once.
+func (h *AuthHandler) FinishMFAPasskey(c *gin.Context) {
+	if !h.requireWebAuthn(c) {
+		return
+	}
+
+	var req models.WebAuthnFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	dbUser, challengeKey, ok := h.mfaChallengeUser(c, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID, session, err := h.consumeWebAuthnSession(ctx, auth.PurposeWebAuthnMFA, req.WebAuthnToken)
+	if err != nil || userID != dbUser.ID {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Invalid or expired MFA token",
+		})
+		return
+	}
+
+	// Failed passkeys count towards the same lockouts as wrong codes
+	if retryAfter := h.loginLockout(ctx, ipThrottleKey(c.ClientIP()), accountThrottleKey(dbUser.Email)); retryAfter > 0 {
+		respondLockedOut(c, retryAfter)
+		return
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(req.Credential)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid passkey credential",
+		})
+		return
+	}
+
+	user, passkeys, err := h.webAuthnUser(c.Request.Context(), dbUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get passkeys",
+		})
+		return
+	}
+
+	credential, err := h.webAuthn.ValidateLogin(user, *session, parsed)
+	if err != nil || !h.recordPasskeyUse(ctx, passkeys, credential) {
+		h.recordMFAFailure(c, dbUser, challengeKey)
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "Passkey verification failed",
+		})
+		return
+	}
+
+	h.completeMFAChallenge(c, dbUser, req.MFAToken)
+}
+
+// requireWebAuthn responds with an error when passkeys are not configured
+func (h *AuthHandler) requireWebAuthn(c *gin.Context) bool {
+	if h.webAuthn == nil {
+		c.JSON(http.StatusNotImplemented, models.APIResponse{
+			Success: false,
+			Error:   "Passkeys are not configured",
+		})
+		return false
+	}
+	return true
+}
+
+// respondWebAuthnOptions writes the browser options for a ceremony together with the
+// token carrying its state. The challenge is stored so the ceremony can only be finished once.
+func (h *AuthHandler) respondWebAuthnOptions(c *gin.Context, purpose, subject string, options interface{}, session *webauthn.SessionData, message string) {
+	webAuthnToken, err := auth.SignWebAuthnSession(h.jwtSecret, purpose, subject, session)
+	if err == nil {
+		err = h.databaseProvider.CreateAuthToken(c.Request.Context(), &database.AuthToken{
+			ID:        uuid.New().String(),
+			UserID:    string(session.UserID),
+			Purpose:   purpose,
+			TokenHash: auth.HashToken(session.Challenge),
+			ExpiresAt: time.Now().Add(auth.WebAuthnCeremonyTTL),
+		})
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to generate token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: message,
+		Data: gin.H{
+			"options":        options,
+			"webauthn_token": webAuthnToken,
+		},
+	})
+}
+
+// consumeWebAuthnSession validates a ceremony token from respondWebAuthnOptions and marks
+// its challenge used, returning the subject and ceremony state
+func (h *AuthHandler) consumeWebAuthnSession(ctx context.Context, purpose, token string) (string, *webauthn.SessionData, error) {
+	subject, session, err := auth.ParseWebAuthnSession(h.jwtSecret, purpose, token)
+	if err != nil {
+		return "", nil, err
+	}
+
+	stored, err := h.databaseProvider.ConsumeAuthToken(ctx, auth.HashToken(session.Challenge))
+	if err != nil {
+		return "", nil, err
+	}
+	if stored.Purpose != purpose {
+		return "", nil, fmt.Errorf("invalid WebAuthn session")
+	}
+
+	return subject, session, nil
+}
+
+// webAuthnUser loads a user's passkeys and adapts them for the WebAuthn library
+func (h *AuthHandler) webAuthnUser(ctx context.Context, dbUser *database.User) (*auth.WebAuthnUser, []*database.WebAuthnCredential, error) {
+	passkeys, err := h.databaseProvider.GetWebAuthnCredentialsByUser(ctx, dbUser.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get passkeys: %w", err)
+	}
+
+	user := &auth.WebAuthnUser{
+		ID:    dbUser.ID,
+		Email: dbUser.Email,
+		Name:  dbUser.Name,
+	}
+	for _, passkey := range passkeys {
+		credentialID, err := base64.RawURLEncoding.DecodeString(passkey.CredentialID)
+		if err != nil {
+			continue
+		}
+
+		transports := make([]protocol.AuthenticatorTransport, 0, len(passkey.Transports))
+		for _, transport := range passkey.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+
+		user.Credentials = append(user.Credentials, webauthn.Credential{
+			ID:              credentialID,
+			PublicKey:       passkey.PublicKey,
+			AttestationType: passkey.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: passkey.BackupEligible,
+				BackupState:    passkey.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    passkey.AAGUID,
+				SignCount: uint32(passkey.SignCount),
+			},
+		})
+	}
+
+	return user, passkeys, nil
+}
+
+// recordPasskeyUse stores the new sign counter of a verified passkey. Assertions whose
+// counter did not increase are rejected because they suggest a cloned authenticator.
+func (h *AuthHandler) recordPasskeyUse(ctx context.Context, passkeys []*database.WebAuthnCredential, credential *webauthn.Credential) bool {
+	credentialID := base64.RawURLEncoding.EncodeToString(credential.ID)
+	for _, passkey := range passkeys {
+		if passkey.CredentialID != credentialID {
+			continue
+		}
+
+		if credential.Authenticator.CloneWarning {
+			log.Printf("Rejected passkey %s of user %s: sign counter did not increase", passkey.ID, passkey.UserID)
+			return false
+		}
+
+		passkey.SignCount = int64(credential.Authenticator.SignCount)
+		passkey.BackupState = credential.Flags.BackupState
+		passkey.LastUsedAt = time.Now()
+		return h.databaseProvider.UpdateWebAuthnCredential(ctx, passkey) == nil
+	}
+	return false
+}
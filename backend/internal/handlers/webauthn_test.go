@@ -0,0 +1,450 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:3000"
+)
+
+// softAuthenticator is a P-256 platform authenticator with "none" attestation. It holds
+// a single discoverable credential.
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatalf("rand.Read() error = %v", err)
+	}
+	return &softAuthenticator{key: key, credentialID: credentialID}
+}
+
+// authenticatorData builds the authenticator data for the test relying party. Flags are
+// user present and user verified, plus attested credential data when attested is set.
+func (a *softAuthenticator) authenticatorData(t *testing.T, attested bool) []byte {
+	t.Helper()
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append([]byte{}, rpIDHash[:]...)
+
+	flags := byte(protocol.FlagUserPresent | protocol.FlagUserVerified)
+	if attested {
+		flags |= byte(protocol.FlagAttestedCredentialData)
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+
+	if attested {
+		publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+			PublicKeyData: webauthncose.PublicKeyData{
+				KeyType:   int64(webauthncose.EllipticKey),
+				Algorithm: int64(webauthncose.AlgES256),
+			},
+			Curve:  int64(webauthncose.P256),
+			XCoord: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+			YCoord: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+		})
+		if err != nil {
+			t.Fatalf("marshal public key: %v", err)
+		}
+		data = append(data, make([]byte, 16)...) // AAGUID
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, publicKey...)
+	}
+	return data
+}
+
+// clientData returns the client data JSON the browser would send for a ceremony
+func clientData(t *testing.T, ceremony protocol.CeremonyType, challenge protocol.URLEncodedBase64) []byte {
+	t.Helper()
+	data, err := json.Marshal(protocol.CollectedClientData{
+		Type:      ceremony,
+		Challenge: base64.RawURLEncoding.EncodeToString(challenge),
+		Origin:    testOrigin,
+	})
+	if err != nil {
+		t.Fatalf("marshal client data: %v", err)
+	}
+	return data
+}
+
+// create answers registration options with a new credential
+func (a *softAuthenticator) create(t *testing.T, options protocol.PublicKeyCredentialCreationOptions) json.RawMessage {
+	t.Helper()
+	attestationObject, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": a.authenticatorData(t, true),
+	})
+	if err != nil {
+		t.Fatalf("marshal attestation object: %v", err)
+	}
+
+	return marshalCredential(t, a.credentialID, map[string]interface{}{
+		"clientDataJSON":    clientData(t, protocol.CreateCeremony, options.Challenge),
+		"attestationObject": attestationObject,
+	})
+}
+
+// get answers assertion options, signing with the current counter for userHandle
+func (a *softAuthenticator) get(t *testing.T, options protocol.PublicKeyCredentialRequestOptions, userHandle string) json.RawMessage {
+	t.Helper()
+	authData := a.authenticatorData(t, false)
+	clientDataJSON := clientData(t, protocol.AssertCeremony, options.Challenge)
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatalf("SignASN1() error = %v", err)
+	}
+
+	return marshalCredential(t, a.credentialID, map[string]interface{}{
+		"clientDataJSON":    clientDataJSON,
+		"authenticatorData": authData,
+		"signature":         signature,
+		"userHandle":        []byte(userHandle),
+	})
+}
+
+// marshalCredential wraps an authenticator response in a public key credential. Binary
+// fields are base64url encoded as browsers do.
+func marshalCredential(t *testing.T, credentialID []byte, response map[string]interface{}) json.RawMessage {
+	t.Helper()
+	encoded := map[string]string{}
+	for field, value := range response {
+		encoded[field] = base64.RawURLEncoding.EncodeToString(value.([]byte))
+	}
+
+	credential, err := json.Marshal(map[string]interface{}{
+		"id":       base64.RawURLEncoding.EncodeToString(credentialID),
+		"rawId":    base64.RawURLEncoding.EncodeToString(credentialID),
+		"type":     "public-key",
+		"response": encoded,
+	})
+	if err != nil {
+		t.Fatalf("marshal credential: %v", err)
+	}
+	return credential
+}
+
+// newWebAuthnTestHandler returns an auth handler with passkeys enabled and two users
+// without MFA
+func newWebAuthnTestHandler(t *testing.T) (*AuthHandler, *fakeDatabase) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	relyingParty, err := auth.NewWebAuthn(auth.WebAuthnConfig{
+		RPID:          testRPID,
+		RPDisplayName: "Test",
+		RPOrigins:     []string{testOrigin},
+	})
+	if err != nil {
+		t.Fatalf("NewWebAuthn() error = %v", err)
+	}
+
+	db := newFakeDatabase()
+	for _, id := range []string{"alice", "mallory"} {
+		db.users[id] = &database.User{ID: id, Email: id + "@example.com", Role: "user", IsActive: true}
+	}
+
+	h := NewAuthHandler(&fakeAuthProvider{}, db, "test-secret")
+	h.SetWebAuthn(relyingParty)
+	return h, db
+}
+
+// webAuthnResponse is the data of a ceremony response
+type webAuthnResponse struct {
+	Data struct {
+		Options       json.RawMessage `json:"options"`
+		WebAuthnToken string          `json:"webauthn_token"`
+		Token         string          `json:"token"`
+	} `json:"data"`
+}
+
+// callWebAuthn runs a handler with a JSON body, signed in as userID when it is set
+func callWebAuthn(t *testing.T, handler gin.HandlerFunc, userID string, body interface{}) (int, webAuthnResponse) {
+	t.Helper()
+	payload, _ := json.Marshal(body)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload))
+	c.Request.Header.Set("Content-Type", "application/json")
+	if userID != "" {
+		principal.Set(c, models.UserContext{UserID: userID})
+	}
+	handler(c)
+
+	var resp webAuthnResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+// registerPasskey runs the registration ceremony for a signed-in user
+func registerPasskey(t *testing.T, h *AuthHandler, userID string, authenticator *softAuthenticator) {
+	t.Helper()
+	status, begin := callWebAuthn(t, h.BeginPasskeyRegistration, userID, gin.H{})
+	if status != http.StatusOK {
+		t.Fatalf("BeginPasskeyRegistration() status = %d", status)
+	}
+
+	var creation protocol.CredentialCreation
+	if err := json.Unmarshal(begin.Data.Options, &creation); err != nil {
+		t.Fatalf("decode creation options: %v", err)
+	}
+
+	status, _ = callWebAuthn(t, h.FinishPasskeyRegistration, userID, models.WebAuthnFinishRequest{
+		WebAuthnToken: begin.Data.WebAuthnToken,
+		Credential:    authenticator.create(t, creation.Response),
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("FinishPasskeyRegistration() status = %d, want %d", status, http.StatusCreated)
+	}
+}
+
+// passkeyLogin runs the discoverable login ceremony, asserting as userHandle
+func passkeyLogin(t *testing.T, h *AuthHandler, authenticator *softAuthenticator, userHandle string) (int, webAuthnResponse) {
+	t.Helper()
+	status, begin := callWebAuthn(t, h.BeginPasskeyLogin, "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("BeginPasskeyLogin() status = %d", status)
+	}
+
+	var assertion protocol.CredentialAssertion
+	if err := json.Unmarshal(begin.Data.Options, &assertion); err != nil {
+		t.Fatalf("decode assertion options: %v", err)
+	}
+
+	return callWebAuthn(t, h.FinishPasskeyLogin, "", models.WebAuthnFinishRequest{
+		WebAuthnToken: begin.Data.WebAuthnToken,
+		Credential:    authenticator.get(t, assertion.Response, userHandle),
+	})
+}
+
+// mfaPasskey completes an MFA challenge with a passkey
+func mfaPasskey(t *testing.T, h *AuthHandler, mfaToken string, authenticator *softAuthenticator) int {
+	t.Helper()
+	status, begin := callWebAuthn(t, h.BeginMFAPasskey, "", models.MFAChallengeRequest{MFAToken: mfaToken})
+	if status != http.StatusOK {
+		t.Fatalf("BeginMFAPasskey() status = %d", status)
+	}
+
+	var assertion protocol.CredentialAssertion
+	if err := json.Unmarshal(begin.Data.Options, &assertion); err != nil {
+		t.Fatalf("decode assertion options: %v", err)
+	}
+
+	status, _ = callWebAuthn(t, h.FinishMFAPasskey, "", models.WebAuthnFinishRequest{
+		MFAToken:      mfaToken,
+		WebAuthnToken: begin.Data.WebAuthnToken,
+		Credential:    authenticator.get(t, assertion.Response, ""),
+	})
+	return status
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	h, db := newWebAuthnTestHandler(t)
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, h, "alice", authenticator)
+
+	if !db.users["alice"].MFAEnabled {
+		t.Error("registering the first passkey did not enable MFA")
+	}
+	if len(db.passkeys) != 1 {
+		t.Fatalf("stored passkeys = %d, want 1", len(db.passkeys))
+	}
+
+	authenticator.signCount = 1
+	status, resp := passkeyLogin(t, h, authenticator, "alice")
+	if status != http.StatusOK || resp.Data.Token != "session-alice" {
+		t.Fatalf("FinishPasskeyLogin() = %d with token %q, want a session for alice", status, resp.Data.Token)
+	}
+
+	for _, passkey := range db.passkeys {
+		if passkey.SignCount != 1 || passkey.LastUsedAt.IsZero() {
+			t.Errorf("passkey sign count = %d, last used %v; want the login recorded", passkey.SignCount, passkey.LastUsedAt)
+		}
+	}
+}
+
+func TestPasskeyLoginRejectsSignCountRegression(t *testing.T) {
+	h, db := newWebAuthnTestHandler(t)
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, h, "alice", authenticator)
+
+	authenticator.signCount = 5
+	if status, _ := passkeyLogin(t, h, authenticator, "alice"); status != http.StatusOK {
+		t.Fatalf("first login status = %d, want %d", status, http.StatusOK)
+	}
+
+	// A cloned authenticator replays an old counter
+	for _, count := range []uint32{5, 3} {
+		authenticator.signCount = count
+		if status, _ := passkeyLogin(t, h, authenticator, "alice"); status != http.StatusUnauthorized {
+			t.Errorf("login with counter %d status = %d, want %d", count, status, http.StatusUnauthorized)
+		}
+	}
+	for _, passkey := range db.passkeys {
+		if passkey.SignCount != 5 {
+			t.Errorf("stored sign count = %d, want 5", passkey.SignCount)
+		}
+	}
+
+	authenticator.signCount = 6
+	if status, _ := passkeyLogin(t, h, authenticator, "alice"); status != http.StatusOK {
+		t.Errorf("login with increased counter status = %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestPasskeyLoginRejectsCredentialOfAnotherUser(t *testing.T) {
+	h, _ := newWebAuthnTestHandler(t)
+	alice := newSoftAuthenticator(t)
+	mallory := newSoftAuthenticator(t)
+	registerPasskey(t, h, "alice", alice)
+	registerPasskey(t, h, "mallory", mallory)
+
+	// Mallory's valid passkey presented with Alice's user handle
+	mallory.signCount = 1
+	if status, _ := passkeyLogin(t, h, mallory, "alice"); status != http.StatusUnauthorized {
+		t.Errorf("login with another user's passkey status = %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	unknown := newSoftAuthenticator(t)
+	unknown.signCount = 1
+	if status, _ := passkeyLogin(t, h, unknown, "alice"); status != http.StatusUnauthorized {
+		t.Errorf("login with an unregistered passkey status = %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	alice.signCount = 1
+	if status, resp := passkeyLogin(t, h, alice, "alice"); status != http.StatusOK || resp.Data.Token != "session-alice" {
+		t.Errorf("login with Alice's passkey = %d with token %q, want a session for alice", status, resp.Data.Token)
+	}
+}
+
+func TestMFAPasskeyRejectsCredentialOfAnotherUser(t *testing.T) {
+	h, db := newWebAuthnTestHandler(t)
+	alice := newSoftAuthenticator(t)
+	mallory := newSoftAuthenticator(t)
+	registerPasskey(t, h, "alice", alice)
+	registerPasskey(t, h, "mallory", mallory)
+
+	mallory.signCount = 1
+	if status := mfaPasskey(t, h, mfaChallenge(t, h, db, "alice"), mallory); status != http.StatusUnauthorized {
+		t.Errorf("MFA with another user's passkey status = %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	alice.signCount = 1
+	if status := mfaPasskey(t, h, mfaChallenge(t, h, db, "alice"), alice); status != http.StatusOK {
+		t.Errorf("MFA with Alice's passkey status = %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestPasskeyCeremonyCannotBeReplayed(t *testing.T) {
+	h, _ := newWebAuthnTestHandler(t)
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, h, "alice", authenticator)
+
+	status, begin := callWebAuthn(t, h.BeginPasskeyLogin, "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("BeginPasskeyLogin() status = %d", status)
+	}
+	var assertion protocol.CredentialAssertion
+	if err := json.Unmarshal(begin.Data.Options, &assertion); err != nil {
+		t.Fatalf("decode assertion options: %v", err)
+	}
+
+	// A second assertion for the same challenge is refused even with a valid counter
+	for i, want := range []int{http.StatusOK, http.StatusUnauthorized} {
+		authenticator.signCount = uint32(i + 1)
+		status, _ := callWebAuthn(t, h.FinishPasskeyLogin, "", models.WebAuthnFinishRequest{
+			WebAuthnToken: begin.Data.WebAuthnToken,
+			Credential:    authenticator.get(t, assertion.Response, "alice"),
+		})
+		if status != want {
+			t.Errorf("finish %d status = %d, want %d", i+1, status, want)
+		}
+	}
+}
+
+func TestMFAPasskeyConsumesChallenge(t *testing.T) {
+	h, db := newWebAuthnTestHandler(t)
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, h, "alice", authenticator)
+
+	mfaToken := mfaChallenge(t, h, db, "alice")
+	authenticator.signCount = 1
+	if status := mfaPasskey(t, h, mfaToken, authenticator); status != http.StatusOK {
+		t.Fatalf("MFA with a passkey status = %d, want %d", status, http.StatusOK)
+	}
+
+	// The challenge completed its login and cannot start another passkey verification
+	authenticator.signCount = 2
+	status, begin := callWebAuthn(t, h.BeginMFAPasskey, "", models.MFAChallengeRequest{MFAToken: mfaToken})
+	if status != http.StatusOK {
+		t.Fatalf("BeginMFAPasskey() status = %d", status)
+	}
+	var assertion protocol.CredentialAssertion
+	if err := json.Unmarshal(begin.Data.Options, &assertion); err != nil {
+		t.Fatalf("decode assertion options: %v", err)
+	}
+	status, _ = callWebAuthn(t, h.FinishMFAPasskey, "", models.WebAuthnFinishRequest{
+		MFAToken:      mfaToken,
+		WebAuthnToken: begin.Data.WebAuthnToken,
+		Credential:    authenticator.get(t, assertion.Response, ""),
+	})
+	if status != http.StatusUnauthorized {
+		t.Errorf("reused challenge status = %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestMFAPasskeyFailuresExhaustChallenge(t *testing.T) {
+	h, db := newWebAuthnTestHandler(t)
+	alice := newSoftAuthenticator(t)
+	mallory := newSoftAuthenticator(t)
+	registerPasskey(t, h, "alice", alice)
+	registerPasskey(t, h, "mallory", mallory)
+
+	mfaToken := mfaChallenge(t, h, db, "alice")
+	for i := 0; i < 5; i++ {
+		mallory.signCount = uint32(i + 1)
+		if status := mfaPasskey(t, h, mfaToken, mallory); status != http.StatusUnauthorized {
+			t.Fatalf("attempt %d status = %d, want %d", i+1, status, http.StatusUnauthorized)
+		}
+	}
+
+	status, _ := callWebAuthn(t, h.BeginMFAPasskey, "", models.MFAChallengeRequest{MFAToken: mfaToken})
+	if status != http.StatusUnauthorized {
+		t.Errorf("BeginMFAPasskey() on an exhausted challenge status = %d, want %d", status, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,108 @@
+// Package membership resolves the companies a user belongs to. A user's home company
+// and role are stored on the user; memberships of further companies are stored as
+// CompanyMembership records.
+package membership
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+)
+
+// ID returns the ID of the membership of a user in a company
+func ID(userID, companyID string) string {
+	return userID + "_" + companyID
+}
+
+// home returns the membership derived from a user's home company
+func home(user *database.User) *database.CompanyMembership {
+	return &database.CompanyMembership{
+		ID:        ID(user.ID, user.CompanyID),
+		UserID:    user.ID,
+		CompanyID: user.CompanyID,
+		Role:      user.Role,
+		Home:      true,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+}
+
+// List returns all memberships of a user, the home company first
+func List(ctx context.Context, databaseProvider database.DatabaseProvider, user *database.User) ([]*database.CompanyMembership, error) {
+	var memberships []*database.CompanyMembership
+	if user.CompanyID != "" {
+		memberships = append(memberships, home(user))
+	}
+
+	stored, err := databaseProvider.GetCompanyMembershipsByUser(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get company memberships: %w", err)
+	}
+	for _, membership := range stored {
+		if membership.CompanyID != user.CompanyID {
+			memberships = append(memberships, membership)
+		}
+	}
+
+	return memberships, nil
+}
+
+// Get returns the membership of a user in a company
+func Get(ctx context.Context, databaseProvider database.DatabaseProvider, user *database.User, companyID string) (*database.CompanyMembership, error) {
+	if companyID == "" {
+		return nil, fmt.Errorf("company membership not found")
+	}
+	if user.CompanyID == companyID {
+		return home(user), nil
+	}
+	return databaseProvider.GetCompanyMembership(ctx, ID(user.ID, companyID))
+}
+
+// Add grants a user a role in a company. Users without a home company are moved into
+// it, everyone else gets a stored membership.
+func Add(ctx context.Context, databaseProvider database.DatabaseProvider, user *database.User, companyID, role string) error {
+	switch user.CompanyID {
+	case "":
+		user.CompanyID = companyID
+		user.Role = role
+		return databaseProvider.UpdateUser(ctx, user)
+	case companyID:
+		return nil
+	}
+
+	if existing, err := databaseProvider.GetCompanyMembership(ctx, ID(user.ID, companyID)); err == nil {
+		existing.Role = role
+		return databaseProvider.UpdateCompanyMembership(ctx, existing)
+	}
+
+	return databaseProvider.CreateCompanyMembership(ctx, &database.CompanyMembership{
+		ID:        ID(user.ID, companyID),
+		UserID:    user.ID,
+		CompanyID: companyID,
+		Role:      role,
+	})
+}
+
+// Users returns the members of a company with the role they hold in it
+func Users(ctx context.Context, databaseProvider database.DatabaseProvider, companyID string) ([]*database.User, error) {
+	users, err := databaseProvider.GetUsersByCompany(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	memberships, err := databaseProvider.GetCompanyMembershipsByCompany(ctx, companyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get company memberships: %w", err)
+	}
+	for _, membership := range memberships {
+		user, err := databaseProvider.GetUser(ctx, membership.UserID)
+		if err != nil || user.CompanyID == companyID {
+			continue
+		}
+		user.Role = membership.Role
+		users = append(users, user)
+	}
+
+	return users, nil
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
+)
+
+// fakeAPIKeyDatabase stores API keys by hash. Other operations panic through the nil
+// embedded interface.
+type fakeAPIKeyDatabase struct {
+	database.DatabaseProvider
+
+	keys map[string]*database.APIKey
+}
+
+func (f *fakeAPIKeyDatabase) GetAPIKeyByHash(ctx context.Context, keyHash string) (*database.APIKey, error) {
+	key, ok := f.keys[keyHash]
+	if !ok {
+		return nil, fmt.Errorf("API key not found")
+	}
+	copied := *key
+	return &copied, nil
+}
+
+func (f *fakeAPIKeyDatabase) UpdateAPIKey(ctx context.Context, key *database.APIKey) error {
+	return nil
+}
+
+func (f *fakeAPIKeyDatabase) GetCompany(ctx context.Context, companyID string) (*database.Company, error) {
+	return &database.Company{ID: companyID}, nil
+}
+
+func TestAPIKeyPermissionsComeFromScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := &fakeAPIKeyDatabase{keys: map[string]*database.APIKey{}}
+	for token, scopes := range map[string][]string{
+		"pak_writer": {rbac.UsersRead, rbac.UsersWrite, "invitations:write"},
+		"pak_reader": {rbac.UsersRead},
+	} {
+		db.keys[auth.HashToken(token)] = &database.APIKey{ID: token, CompanyID: "acme", ServiceAccountID: "ci", Scopes: scopes}
+	}
+	authMiddleware := NewAuthMiddleware(nil)
+	authMiddleware.SetDatabaseProvider(db)
+
+	var permissions []string
+	var role string
+	router := gin.New()
+	router.PUT("/api/v1/users/:id", authMiddleware.Authenticate(), authMiddleware.RequirePermission(rbac.UsersWrite), func(c *gin.Context) {
+		user, _ := principal.Get(c)
+		permissions, role = user.Permissions, user.Role
+		c.Status(http.StatusOK)
+	})
+
+	serve := func(token string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/users/member", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if status := serve("pak_writer"); status != http.StatusOK {
+		t.Fatalf("key with users:write status = %d, want %d", status, http.StatusOK)
+	}
+	if len(permissions) != 2 || !rbac.Has(permissions, rbac.UsersRead) || !rbac.Has(permissions, rbac.UsersWrite) {
+		t.Errorf("key permissions = %v, want only users:read and users:write", permissions)
+	}
+	if role != "" {
+		t.Errorf("key role = %q, want none", role)
+	}
+
+	if status := serve("pak_reader"); status != http.StatusForbidden {
+		t.Errorf("key without users:write status = %d, want %d", status, http.StatusForbidden)
+	}
+}
@@ -1,19 +1,28 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
 	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/groups"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/membership"
 	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
 )
 
 // AuthMiddleware handles authentication middleware
 type AuthMiddleware struct {
 	authProvider     auth.AuthProvider
 	databaseProvider database.DatabaseProvider
+	jwtSecret        string // Set when impersonation tokens are accepted
+	// Email addresses of the platform operators
+	platformOperators map[string]bool
 }
 
 // NewAuthMiddleware creates a new auth middleware
@@ -28,7 +37,7 @@ func (m *AuthMiddleware) SetDatabaseProvider(dbProvider database.DatabaseProvide
 	m.databaseProvider = dbProvider
 }
 
-// Authenticate middleware validates JWT tokens and sets user context
+// Authenticate middleware validates JWT tokens or API keys and sets user context
 func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get Authorization header
@@ -55,6 +64,20 @@ func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
 
 		token := tokenParts[1]
 
+		// Service account API keys are accepted alongside JWTs
+		if strings.HasPrefix(token, auth.APIKeyPrefix) {
+			m.authenticateAPIKey(c, token)
+			return
+		}
+
+		// Impersonation tokens carry both the admin and the impersonated user
+		if m.jwtSecret != "" {
+			if claims, err := auth.ParsePurposeToken(m.jwtSecret, auth.PurposeImpersonation, token); err == nil {
+				m.authenticateImpersonation(c, claims)
+				return
+			}
+		}
+
 		// Validate token
 		user, err := m.authProvider.ValidateToken(token)
 		if err != nil {
@@ -68,7 +91,7 @@ func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
 
 		// Get user from database to get complete user info
 		if m.databaseProvider != nil {
-			dbUser, err := m.databaseProvider.GetUser(c.Request.Context(), user.ID)
+			dbUser, err := m.lookupUser(c.Request.Context(), user)
 			if err != nil {
 				c.JSON(http.StatusUnauthorized, models.APIResponse{
 					Success: false,
@@ -88,22 +111,185 @@ func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
 				return
 			}
 
+			// Reject tokens issued before the user's sessions were revoked
+			if auth.SessionRevoked(user.IssuedAt, dbUser.SessionsRevokedAt) {
+				c.JSON(http.StatusUnauthorized, models.APIResponse{
+					Success: false,
+					Error:   "Session has been revoked",
+				})
+				c.Abort()
+				return
+			}
+
+			// The role comes from the membership of the active tenant
+			activeCompanyID, role, err := m.activeMembership(c.Request.Context(), dbUser, user)
+			if err != nil {
+				c.JSON(http.StatusForbidden, models.APIResponse{
+					Success: false,
+					Error:   "You are no longer a member of this company",
+				})
+				c.Abort()
+				return
+			}
+
+			// Suspended companies are locked out until an operator reactivates them
+			if m.companySuspended(c.Request.Context(), activeCompanyID) {
+				c.JSON(http.StatusForbidden, models.APIResponse{
+					Success: false,
+					Error:   "Company is suspended",
+				})
+				c.Abort()
+				return
+			}
+
 			// Set user context
 			userContext := models.UserContext{
-				UserID:    dbUser.ID,
-				Email:     dbUser.Email,
-				CompanyID: dbUser.CompanyID,
-				Role:      dbUser.Role,
+				UserID:           dbUser.ID,
+				Email:            dbUser.Email,
+				CompanyID:        activeCompanyID,
+				Role:             role,
+				Permissions:      m.permissions(c.Request.Context(), activeCompanyID, role),
+				Groups:           m.groups(c.Request.Context(), activeCompanyID, dbUser.ID),
+				Delegations:      m.delegations(c.Request.Context(), activeCompanyID, dbUser.ID),
+				Connection:       user.Connection,
+				PlatformOperator: m.platformOperator(c.Request.Context(), dbUser),
 			}
-			c.Set("user", userContext)
+			principal.Set(c, userContext)
 		} else {
 			// Fallback if database provider is not set
 			userContext := models.UserContext{
-				UserID: user.ID,
-				Email:  user.Email,
-				Role:   "user", // Default role
+				UserID:      user.ID,
+				Email:       user.Email,
+				Role:        "user", // Default role
+				Permissions: m.permissions(c.Request.Context(), "", "user"),
 			}
-			c.Set("user", userContext)
+			principal.Set(c, userContext)
+		}
+
+		c.Next()
+	}
+}
+
+// lookupUser returns the database user a validated token belongs to. Tokens signed by
+// an external provider carry the provider's subject, which is resolved through the
+// identities linked to our users.
+func (m *AuthMiddleware) lookupUser(ctx context.Context, user *auth.User) (*database.User, error) {
+	dbUser, err := m.databaseProvider.GetUser(ctx, user.ID)
+	if err == nil || user.Connection == "" {
+		return dbUser, err
+	}
+
+	identity, identityErr := m.databaseProvider.GetUserIdentity(ctx, auth.IdentityID(user.Connection, user.ID))
+	if identityErr != nil {
+		return nil, err
+	}
+	return m.databaseProvider.GetUser(ctx, identity.UserID)
+}
+
+// activeMembership returns the active company of a request and the user's role in it.
+// The active company is the home company unless the token was scoped to another one.
+func (m *AuthMiddleware) activeMembership(ctx context.Context, dbUser *database.User, user *auth.User) (string, string, error) {
+	companyID := dbUser.CompanyID
+	if user.TenantID != "" {
+		companyID = user.TenantID
+	}
+	if companyID == "" {
+		return "", dbUser.Role, nil
+	}
+
+	companyMembership, err := membership.Get(ctx, m.databaseProvider, dbUser, companyID)
+	if err != nil {
+		return "", "", err
+	}
+	return companyID, companyMembership.Role, nil
+}
+
+// permissions returns the permissions a role grants in the active company. Roles that
+// no longer exist grant none.
+func (m *AuthMiddleware) permissions(ctx context.Context, companyID, role string) []string {
+	permissions, err := rbac.Permissions(ctx, m.databaseProvider, companyID, role)
+	if err != nil {
+		return nil
+	}
+	return permissions
+}
+
+// groups returns the groups of the active company a user effectively belongs to. Groups
+// that can't be read are left out rather than failing the request.
+func (m *AuthMiddleware) groups(ctx context.Context, companyID, userID string) []string {
+	groupIDs, err := groups.Effective(ctx, m.databaseProvider, companyID, userID)
+	if err != nil {
+		return nil
+	}
+	return groupIDs
+}
+
+// delegations returns the delegated admin assignments a user holds in the active
+// company. Assignments that can't be read are left out rather than failing the request.
+func (m *AuthMiddleware) delegations(ctx context.Context, companyID, userID string) []models.Delegation {
+	delegations, err := rbac.Delegations(ctx, m.databaseProvider, companyID, userID)
+	if err != nil {
+		return nil
+	}
+	return delegations
+}
+
+// RequirePermission middleware checks that the user's role grants a permission
+func (m *AuthMiddleware) RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get user from context (set by Authenticate middleware)
+		user, err := principal.Get(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Error:   "User not authenticated",
+			})
+			c.Abort()
+			return
+		}
+
+		if !user.Can(permission) {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "Insufficient permissions",
+				Data: gin.H{
+					"required_permission": permission,
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireUserPermission middleware checks that the user's role or one of their delegated
+// admin assignments grants a user-management permission. Handlers check that the target
+// user is in scope.
+func (m *AuthMiddleware) RequireUserPermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get user from context (set by Authenticate middleware)
+		user, err := principal.Get(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Error:   "User not authenticated",
+			})
+			c.Abort()
+			return
+		}
+
+		if !user.CanForSomeUsers(permission) {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "Insufficient permissions",
+				Data: gin.H{
+					"required_permission": permission,
+				},
+			})
+			c.Abort()
+			return
 		}
 
 		c.Next()
@@ -114,8 +300,8 @@ func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
 func (m *AuthMiddleware) RequireRole(requiredRole string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get user from context (set by Authenticate middleware)
-		userContext, exists := c.Get("user")
-		if !exists {
+		user, err := principal.Get(c)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, models.APIResponse{
 				Success: false,
 				Error:   "User not authenticated",
@@ -124,8 +310,6 @@ func (m *AuthMiddleware) RequireRole(requiredRole string) gin.HandlerFunc {
 			return
 		}
 
-		user := userContext.(models.UserContext)
-
 		// Check if user has required role
 		if user.Role != requiredRole {
 			c.JSON(http.StatusForbidden, models.APIResponse{
@@ -144,8 +328,8 @@ func (m *AuthMiddleware) RequireRole(requiredRole string) gin.HandlerFunc {
 func (m *AuthMiddleware) RequireAnyRole(requiredRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get user from context (set by Authenticate middleware)
-		userContext, exists := c.Get("user")
-		if !exists {
+		user, err := principal.Get(c)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, models.APIResponse{
 				Success: false,
 				Error:   "User not authenticated",
@@ -154,8 +338,6 @@ func (m *AuthMiddleware) RequireAnyRole(requiredRoles ...string) gin.HandlerFunc
 			return
 		}
 
-		user := userContext.(models.UserContext)
-
 		// Check if user has any of the required roles
 		hasRole := false
 		for _, role := range requiredRoles {
@@ -181,9 +363,9 @@ func (m *AuthMiddleware) RequireAnyRole(requiredRoles ...string) gin.HandlerFunc
 // RequireCompanyAccess middleware ensures user has access to the specified company
 func (m *AuthMiddleware) RequireCompanyAccess() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get user from context (set by Authenticate middleware)
-		userContext, exists := c.Get("user")
-		if !exists {
+		// Check the user from context (set by Authenticate middleware) has a company ID
+		_, err := principal.CompanyID(c.Request.Context())
+		if errors.Is(err, principal.ErrUnauthenticated) {
 			c.JSON(http.StatusUnauthorized, models.APIResponse{
 				Success: false,
 				Error:   "User not authenticated",
@@ -191,11 +373,7 @@ func (m *AuthMiddleware) RequireCompanyAccess() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
-
-		user := userContext.(models.UserContext)
-
-		// Check if user has a company ID
-		if user.CompanyID == "" {
+		if err != nil {
 			c.JSON(http.StatusForbidden, models.APIResponse{
 				Success: false,
 				Error:   "User not associated with any company",
@@ -239,25 +417,32 @@ func (m *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 
 		// Get user from database to get complete user info
 		if m.databaseProvider != nil {
-			dbUser, err := m.databaseProvider.GetUser(c.Request.Context(), user.ID)
-			if err == nil && dbUser.IsActive {
-				// Set user context
-				userContext := models.UserContext{
-					UserID:    dbUser.ID,
-					Email:     dbUser.Email,
-					CompanyID: dbUser.CompanyID,
-					Role:      dbUser.Role,
+			dbUser, err := m.lookupUser(c.Request.Context(), user)
+			if err == nil && dbUser.IsActive && !auth.SessionRevoked(user.IssuedAt, dbUser.SessionsRevokedAt) {
+				if companyID, role, err := m.activeMembership(c.Request.Context(), dbUser, user); err == nil && !m.companySuspended(c.Request.Context(), companyID) {
+					// Set user context
+					userContext := models.UserContext{
+						UserID:      dbUser.ID,
+						Email:       dbUser.Email,
+						CompanyID:   companyID,
+						Role:        role,
+						Permissions: m.permissions(c.Request.Context(), companyID, role),
+						Groups:      m.groups(c.Request.Context(), companyID, dbUser.ID),
+						Delegations: m.delegations(c.Request.Context(), companyID, dbUser.ID),
+						Connection:  user.Connection,
+					}
+					principal.Set(c, userContext)
 				}
-				c.Set("user", userContext)
 			}
 		} else {
 			// Fallback if database provider is not set
 			userContext := models.UserContext{
-				UserID: user.ID,
-				Email:  user.Email,
-				Role:   "user", // Default role
+				UserID:      user.ID,
+				Email:       user.Email,
+				Role:        "user", // Default role
+				Permissions: m.permissions(c.Request.Context(), "", "user"),
 			}
-			c.Set("user", userContext)
+			principal.Set(c, userContext)
 		}
 
 		c.Next()
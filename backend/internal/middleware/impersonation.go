@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/audit"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/membership"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/rbac"
+)
+
+// EnableImpersonation lets Authenticate accept impersonation tokens signed with the JWT secret
+func (m *AuthMiddleware) EnableImpersonation(jwtSecret string) {
+	m.jwtSecret = jwtSecret
+}
+
+// authenticateImpersonation validates an impersonation token against the current state of
+// the admin and the impersonated user, sets a user context carrying both identities and
+// audits every change made with it against the admin
+func (m *AuthMiddleware) authenticateImpersonation(c *gin.Context, claims jwt.MapClaims) {
+	if m.databaseProvider == nil {
+		abortWithError(c, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	act, _ := claims["act"].(map[string]interface{})
+	actorID, _ := act["sub"].(string)
+	subjectID, _ := claims["sub"].(string)
+
+	actor, err := m.databaseProvider.GetUser(c.Request.Context(), actorID)
+	if err != nil {
+		abortWithError(c, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	subject, err := m.databaseProvider.GetUser(c.Request.Context(), subjectID)
+	if err != nil {
+		abortWithError(c, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	// The admin must still be allowed to impersonate users of the user's company and hold
+	// every permission the user holds, and ending the admin's sessions ends their
+	// impersonation too
+	issuedAt, _ := claims.GetIssuedAt()
+	actorMembership, err := membership.Get(c.Request.Context(), m.databaseProvider, actor, subject.CompanyID)
+	if err != nil || !actor.IsActive ||
+		issuedAt == nil || auth.SessionRevoked(issuedAt.Time, actor.SessionsRevokedAt) {
+		abortWithError(c, http.StatusUnauthorized, "Impersonation session is no longer valid")
+		return
+	}
+	actorPermissions := m.permissions(c.Request.Context(), subject.CompanyID, actorMembership.Role)
+	subjectPermissions, err := rbac.Held(c.Request.Context(), m.databaseProvider, subject.CompanyID, subject.ID, subject.Role)
+	if err != nil || !rbac.Has(actorPermissions, rbac.UsersImpersonate) || !rbac.HasAll(actorPermissions, subjectPermissions) {
+		abortWithError(c, http.StatusUnauthorized, "Impersonation session is no longer valid")
+		return
+	}
+	if !subject.IsActive {
+		abortWithError(c, http.StatusForbidden, "User account is inactive")
+		return
+	}
+	if m.companySuspended(c.Request.Context(), subject.CompanyID) {
+		abortWithError(c, http.StatusForbidden, "Company is suspended")
+		return
+	}
+
+	principal.Set(c, models.UserContext{
+		UserID:      subject.ID,
+		Email:       subject.Email,
+		CompanyID:   subject.CompanyID,
+		Role:        subject.Role,
+		Permissions: m.permissions(c.Request.Context(), subject.CompanyID, subject.Role),
+		Groups:      m.groups(c.Request.Context(), subject.CompanyID, subject.ID),
+		Delegations: m.delegations(c.Request.Context(), subject.CompanyID, subject.ID),
+		ActorID:     actor.ID,
+		ActorEmail:  actor.Email,
+	})
+	c.Next()
+
+	// Every change made while impersonating is recorded against the real admin
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return
+	}
+	event := audit.ForRequest(c, "impersonation.request", "user", subject.ID)
+	event.Details["method"] = c.Request.Method
+	event.Details["path"] = c.Request.URL.Path
+	event.Details["status"] = c.Writer.Status()
+	audit.Record(c.Request.Context(), m.databaseProvider, event)
+}
+
+// DenyImpersonation middleware blocks sensitive account actions while an admin is
+// impersonating the user
+func DenyImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if user, err := principal.Get(c); err == nil && user.Impersonating() {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "This action is not allowed while impersonating a user",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
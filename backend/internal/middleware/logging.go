@@ -18,7 +18,7 @@ func RequestLogger() gin.HandlerFunc {
 		requestID := uuid.New().String()
 
 		// Log format: [REQUEST_ID] METHOD PATH STATUS LATENCY SIZE CLIENT_IP
-		return fmt.Sprintf("[%s] %s %s %d %v %s %s\n",
+		return fmt.Sprintf("[%s] %s %s %d %v %d %s\n",
 			requestID,
 			param.Method,
 			param.Path,
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+)
+
+// SetPlatformOperators sets the email addresses of the platform operators. Operators
+// run the platform rather than a tenant, so their accounts must not belong to any company.
+func (m *AuthMiddleware) SetPlatformOperators(emails []string) {
+	m.platformOperators = make(map[string]bool, len(emails))
+	for _, email := range emails {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			m.platformOperators[email] = true
+		}
+	}
+}
+
+// platformOperator reports whether a user is a platform operator: their verified email
+// address is configured and they are not a member of any company
+func (m *AuthMiddleware) platformOperator(ctx context.Context, dbUser *database.User) bool {
+	if !m.platformOperators[strings.ToLower(dbUser.Email)] || dbUser.CompanyID != "" || dbUser.EmailVerificationPending {
+		return false
+	}
+
+	memberships, err := m.databaseProvider.GetCompanyMembershipsByUser(ctx, dbUser.ID)
+	return err == nil && len(memberships) == 0
+}
+
+// companySuspended reports whether a platform operator suspended a company
+func (m *AuthMiddleware) companySuspended(ctx context.Context, companyID string) bool {
+	if companyID == "" || m.databaseProvider == nil {
+		return false
+	}
+
+	company, err := m.databaseProvider.GetCompany(ctx, companyID)
+	return err == nil && company.Status == "suspended"
+}
+
+// RequirePlatformOperator middleware checks that the user is a platform operator. Tenant
+// roles and permissions never grant operator access.
+func (m *AuthMiddleware) RequirePlatformOperator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get user from context (set by Authenticate middleware)
+		user, err := principal.Get(c)
+		if err != nil {
+			abortWithError(c, http.StatusUnauthorized, "User not authenticated")
+			return
+		}
+
+		if !user.PlatformOperator || user.Impersonating() || user.ServiceAccountID != "" {
+			abortWithError(c, http.StatusForbidden, "Platform operator access required")
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/ratelimit"
+)
+
+// What a rate limit policy counts requests by
+const (
+	RateLimitByIP     = "ip"      // Client IP address
+	RateLimitByUser   = "user"    // Authenticated user, or the client IP before authentication
+	RateLimitByTenant = "tenant"  // Active company, or the client IP outside a company
+	RateLimitByAPIKey = "api_key" // Service account API key; other requests are not limited
+)
+
+// RateLimitPolicy limits the requests of a route group. Name separates the buckets of
+// policies that count by the same key.
+type RateLimitPolicy struct {
+	Name string
+	By   string
+	Rate ratelimit.Rate
+}
+
+// RateLimit limits requests with a token bucket per key of the policy. Limits counting by
+// user, tenant or API key must run after authentication. The X-RateLimit-* headers
+// describe the most constrained policy applied to the request. Requests are allowed
+// when the store fails, so an unavailable store never takes the API down.
+func RateLimit(store ratelimit.Store, policy RateLimitPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := rateLimitKey(c, policy.By)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		result, err := store.Take(c.Request.Context(), policy.Name+":"+key, policy.Rate)
+		if err != nil {
+			log.Printf("Rate limit %s unavailable: %v", policy.Name, err)
+			c.Next()
+			return
+		}
+
+		setRateLimitHeaders(c, result)
+		if !result.Allowed {
+			c.Header("Retry-After", ceilSeconds(result.RetryAfter))
+			c.JSON(http.StatusTooManyRequests, models.APIResponse{
+				Success: false,
+				Error:   "Too many requests, please try again later",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// rateLimitKey returns the key a request is counted by, or false if the policy does not
+// apply to it. Impersonated requests count against the real admin.
+func rateLimitKey(c *gin.Context, by string) (string, bool) {
+	user, err := principal.Get(c)
+	authenticated := err == nil
+
+	switch by {
+	case RateLimitByAPIKey:
+		if !authenticated || user.APIKeyID == "" {
+			return "", false
+		}
+		return "api_key:" + user.APIKeyID, true
+	case RateLimitByTenant:
+		if authenticated && user.CompanyID != "" {
+			return "tenant:" + user.CompanyID, true
+		}
+		// SCIM tokens identify their company without a principal
+		if companyID := c.GetString("scim_company_id"); companyID != "" {
+			return "tenant:" + companyID, true
+		}
+	case RateLimitByUser:
+		if authenticated && user.Impersonating() {
+			return "user:" + user.ActorID, true
+		}
+		if authenticated && user.UserID != "" {
+			return "user:" + user.UserID, true
+		}
+	}
+	return "ip:" + c.ClientIP(), true
+}
+
+// setRateLimitHeaders reports a policy's bucket unless an earlier policy of the request
+// has fewer requests remaining
+func setRateLimitHeaders(c *gin.Context, result ratelimit.Result) {
+	if current := c.Writer.Header().Get("X-RateLimit-Remaining"); current != "" {
+		if remaining, err := strconv.Atoi(current); err == nil && remaining <= result.Remaining {
+			return
+		}
+	}
+
+	c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("X-RateLimit-Reset", ceilSeconds(result.Reset))
+}
+
+// ceilSeconds formats a duration in whole seconds, rounded up
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
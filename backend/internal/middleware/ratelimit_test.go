@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/ratelimit"
+)
+
+// fakeRateLimitStore returns a fixed result or error and records the keys it was asked for
+type fakeRateLimitStore struct {
+	result ratelimit.Result
+	err    error
+	keys   []string
+}
+
+func (s *fakeRateLimitStore) Take(ctx context.Context, key string, rate ratelimit.Rate) (ratelimit.Result, error) {
+	s.keys = append(s.keys, key)
+	return s.result, s.err
+}
+
+// serveRateLimited runs a request through the handlers, signed in as user when it is set
+func serveRateLimited(user *models.UserContext, handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	if user != nil {
+		router.Use(func(c *gin.Context) {
+			principal.Set(c, *user)
+		})
+	}
+	handlers = append(handlers, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/", handlers...)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitHeadersAndRetryAfter(t *testing.T) {
+	limit := RateLimit(ratelimit.NewMemoryStore(), RateLimitPolicy{
+		Name: "api",
+		By:   RateLimitByIP,
+		Rate: ratelimit.Rate{Limit: 2, Period: time.Minute},
+	})
+
+	for _, want := range []struct{ remaining, reset string }{{"1", "30"}, {"0", "60"}} {
+		w := serveRateLimited(nil, limit)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+		}
+		if got := w.Header().Get("X-RateLimit-Limit"); got != "2" {
+			t.Errorf("X-RateLimit-Limit = %q, want 2", got)
+		}
+		if got := w.Header().Get("X-RateLimit-Remaining"); got != want.remaining {
+			t.Errorf("X-RateLimit-Remaining = %q, want %s", got, want.remaining)
+		}
+		if got := w.Header().Get("X-RateLimit-Reset"); got != want.reset {
+			t.Errorf("X-RateLimit-Reset = %q, want %s", got, want.reset)
+		}
+	}
+
+	w := serveRateLimited(nil, limit)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status over the limit = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	// The wait for the next token is rounded up to a whole second
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After = %q, want 30", got)
+	}
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("X-RateLimit-Remaining over the limit = %q, want 0", got)
+	}
+}
+
+func TestRateLimitRetryAfterRoundsUp(t *testing.T) {
+	store := &fakeRateLimitStore{result: ratelimit.Result{
+		Limit:      10,
+		Reset:      1500 * time.Millisecond,
+		RetryAfter: 100 * time.Millisecond,
+	}}
+	w := serveRateLimited(nil, RateLimit(store, RateLimitPolicy{Name: "api", By: RateLimitByIP}))
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+	if got := w.Header().Get("X-RateLimit-Reset"); got != "2" {
+		t.Errorf("X-RateLimit-Reset = %q, want 2", got)
+	}
+}
+
+func TestRateLimitFailsOpen(t *testing.T) {
+	store := &fakeRateLimitStore{err: errors.New("connection refused")}
+	w := serveRateLimited(nil, RateLimit(store, RateLimitPolicy{Name: "api", By: RateLimitByIP}))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status with the store down = %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := w.Header().Get("X-RateLimit-Limit"); got != "" {
+		t.Errorf("X-RateLimit-Limit = %q, want no header", got)
+	}
+}
+
+func TestRateLimitReportsMostConstrainedPolicy(t *testing.T) {
+	loose := &fakeRateLimitStore{result: ratelimit.Result{Allowed: true, Limit: 100, Remaining: 50, Reset: time.Minute}}
+	tight := &fakeRateLimitStore{result: ratelimit.Result{Allowed: true, Limit: 10, Remaining: 2, Reset: 10 * time.Second}}
+
+	looseLimit := RateLimit(loose, RateLimitPolicy{Name: "loose", By: RateLimitByIP})
+	tightLimit := RateLimit(tight, RateLimitPolicy{Name: "tight", By: RateLimitByIP})
+
+	for _, order := range [][]gin.HandlerFunc{{looseLimit, tightLimit}, {tightLimit, looseLimit}} {
+		w := serveRateLimited(nil, order...)
+		if got := w.Header().Get("X-RateLimit-Limit"); got != "10" {
+			t.Errorf("X-RateLimit-Limit = %q, want 10", got)
+		}
+		if got := w.Header().Get("X-RateLimit-Remaining"); got != "2" {
+			t.Errorf("X-RateLimit-Remaining = %q, want 2", got)
+		}
+		if got := w.Header().Get("X-RateLimit-Reset"); got != "10" {
+			t.Errorf("X-RateLimit-Reset = %q, want 10", got)
+		}
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		by   string
+		user *models.UserContext
+		want []string
+	}{
+		{name: "ip", by: RateLimitByIP, user: &models.UserContext{UserID: "alice"}, want: []string{"p:ip:192.0.2.1"}},
+		{name: "user", by: RateLimitByUser, user: &models.UserContext{UserID: "alice"}, want: []string{"p:user:alice"}},
+		{name: "anonymous user", by: RateLimitByUser, want: []string{"p:ip:192.0.2.1"}},
+		{name: "impersonation", by: RateLimitByUser, user: &models.UserContext{UserID: "alice", ActorID: "admin"}, want: []string{"p:user:admin"}},
+		{name: "tenant", by: RateLimitByTenant, user: &models.UserContext{UserID: "alice", CompanyID: "acme"}, want: []string{"p:tenant:acme"}},
+		{name: "no tenant", by: RateLimitByTenant, user: &models.UserContext{UserID: "alice"}, want: []string{"p:ip:192.0.2.1"}},
+		{name: "api key", by: RateLimitByAPIKey, user: &models.UserContext{UserID: "svc", APIKeyID: "key1"}, want: []string{"p:api_key:key1"}},
+		{name: "no api key", by: RateLimitByAPIKey, user: &models.UserContext{UserID: "alice"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeRateLimitStore{result: ratelimit.Result{Allowed: true}}
+			serveRateLimited(tt.user, RateLimit(store, RateLimitPolicy{Name: "p", By: tt.by}))
+
+			if len(store.keys) != len(tt.want) || (len(tt.want) == 1 && store.keys[0] != tt.want[0]) {
+				t.Errorf("keys = %v, want %v", store.keys, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+)
+
+// SCIMAuth middleware authenticates SCIM requests with a per-company bearer token
+// and sets the token's company ID as "scim_company_id"
+func SCIMAuth(databaseProvider database.DatabaseProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Extract token from "Bearer <token>"
+		tokenParts := strings.Split(c.GetHeader("Authorization"), " ")
+		if len(tokenParts) != 2 || !strings.EqualFold(tokenParts[0], "Bearer") {
+			scimUnauthorized(c)
+			return
+		}
+
+		token, err := databaseProvider.GetSCIMTokenByHash(c.Request.Context(), auth.HashToken(tokenParts[1]))
+		if err != nil || token == nil {
+			scimUnauthorized(c)
+			return
+		}
+
+		// Suspended companies can't provision users until they are reactivated
+		if company, err := databaseProvider.GetCompany(c.Request.Context(), token.CompanyID); err == nil && company.Status == "suspended" {
+			c.Header("Content-Type", "application/scim+json")
+			c.AbortWithStatusJSON(http.StatusForbidden, models.SCIMError{
+				Schemas: []string{models.SCIMSchemaError},
+				Status:  "403",
+				Detail:  "Company is suspended",
+			})
+			return
+		}
+
+		// Record usage at most once a minute to limit writes
+		if time.Since(token.LastUsedAt) > time.Minute {
+			token.LastUsedAt = time.Now()
+			_ = databaseProvider.UpdateSCIMToken(c.Request.Context(), token)
+		}
+
+		c.Set("scim_company_id", token.CompanyID)
+		c.Next()
+	}
+}
+
+// scimUnauthorized aborts with a SCIM error response
+func scimUnauthorized(c *gin.Context) {
+	c.Header("WWW-Authenticate", "Bearer")
+	c.Header("Content-Type", "application/scim+json")
+	c.AbortWithStatusJSON(http.StatusUnauthorized, models.SCIMError{
+		Schemas: []string{models.SCIMSchemaError},
+		Status:  "401",
+		Detail:  "Invalid SCIM bearer token",
+	})
+}
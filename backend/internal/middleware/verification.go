@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/principal"
+)
+
+// RequireVerifiedEmail middleware blocks users whose email address is still unverified
+func RequireVerifiedEmail(databaseProvider database.DatabaseProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get user from context (set by Authenticate middleware)
+		user, err := principal.Get(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Error:   "User not authenticated",
+			})
+			c.Abort()
+			return
+		}
+
+		// Service accounts have no email address to verify
+		if user.ServiceAccountID != "" {
+			c.Next()
+			return
+		}
+
+		dbUser, err := databaseProvider.GetUser(c.Request.Context(), user.UserID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Error:   "User not found",
+			})
+			c.Abort()
+			return
+		}
+
+		if dbUser.EmailVerificationPending {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "Please verify your email address first",
+				Data: gin.H{
+					"email_verification_required": true,
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
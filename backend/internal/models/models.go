@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -139,17 +140,162 @@ type APIResponse struct {
 
 // LoginRequest represents a login request
 type LoginRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Email      string `json:"email" binding:"required,email"`
+	Password   string `json:"password" binding:"required"`
+	Connection string `json:"connection"` // Auth connection to sign in with, the default one when empty
+}
+
+// SwitchCompanyRequest represents a request to make another company the active tenant
+type SwitchCompanyRequest struct {
+	CompanyID string `json:"company_id" binding:"required"`
+}
+
+// ConnectionTokenRequest represents a token issued by an auth connection, used to
+// sign in with or link an external identity
+type ConnectionTokenRequest struct {
+	Connection string `json:"connection" binding:"required"`
+	Token      string `json:"token" binding:"required"`
 }
 
 // RegisterRequest represents a registration request
 type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=8"`
+	Password string `json:"password" binding:"required,max=72"`
 	Name     string `json:"name" binding:"required"`
 }
 
+// SSODiscoveryRequest represents a home-realm discovery request
+type SSODiscoveryRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// SSOConfigRequest represents a company SSO configuration update
+type SSOConfigRequest struct {
+	Protocol         string            `json:"protocol" binding:"required,oneof=oidc saml"`
+	Enabled          bool              `json:"enabled"`
+	Enforced         bool              `json:"enforced"`
+	OIDCIssuer       string            `json:"oidc_issuer,omitempty"`
+	OIDCClientID     string            `json:"oidc_client_id,omitempty"`
+	OIDCClientSecret string            `json:"oidc_client_secret,omitempty"` // Leave empty to keep the stored secret
+	SAMLMetadataXML  string            `json:"saml_metadata_xml,omitempty"`
+	SAMLEntityID     string            `json:"saml_entity_id,omitempty"`
+	SAMLSSOURL       string            `json:"saml_sso_url,omitempty"`
+	SAMLCertificate  string            `json:"saml_certificate,omitempty"`
+	AttributeMapping map[string]string `json:"attribute_mapping,omitempty"`
+}
+
+// MFAVerifyRequest represents the second step of an MFA login
+type MFAVerifyRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// MFAEnrollRequest represents a TOTP enrollment request. MFAToken is only used when
+// enrolling during login because the company requires MFA.
+type MFAEnrollRequest struct {
+	MFAToken string `json:"mfa_token,omitempty"`
+}
+
+// MFACodeRequest represents a request confirmed with a TOTP or recovery code
+type MFACodeRequest struct {
+	MFAToken string `json:"mfa_token,omitempty"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// MFAChallengeRequest represents a request to start a passkey second-factor challenge
+type MFAChallengeRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// WebAuthnFinishRequest represents the second step of a passkey ceremony. Credential is
+// the PublicKeyCredential returned by the browser. MFAToken is only used when registering
+// a passkey during login because the company requires MFA.
+type WebAuthnFinishRequest struct {
+	WebAuthnToken string          `json:"webauthn_token" binding:"required"`
+	Credential    json.RawMessage `json:"credential" binding:"required"`
+	Name          string          `json:"name,omitempty"`
+	MFAToken      string          `json:"mfa_token,omitempty"`
+}
+
+// MagicLinkRequest represents a request for a sign-in link
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// MagicLinkVerifyRequest represents the exchange of a sign-in link token for a session
+type MagicLinkVerifyRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// PasswordResetRequest represents a request for a password reset link
+type PasswordResetRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// PasswordResetConfirmRequest represents setting a new password with a reset token
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,max=72"`
+}
+
+// VerifyEmailRequest represents an email verification
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// CompanySecurityRequest represents a company security settings update
+type CompanySecurityRequest struct {
+	MFARequirement string `json:"mfa_requirement" binding:"required,oneof=none admins all"`
+	JITDefaultRole string `json:"jit_default_role" binding:"omitempty,oneof=admin user guest"`
+	EntraTenantID  *string `json:"entra_tenant_id,omitempty"` // Empty string lifts the restriction
+}
+
+// PasswordPolicyRequest represents a company password policy update
+type PasswordPolicyRequest struct {
+	MinLength        int  `json:"min_length" binding:"omitempty,min=8,max=72"`
+	RequireUppercase bool `json:"require_uppercase"`
+	RequireLowercase bool `json:"require_lowercase"`
+	RequireDigit     bool `json:"require_digit"`
+	RequireSymbol    bool `json:"require_symbol"`
+	HistoryCount     int  `json:"history_count" binding:"min=0,max=24"`
+	MaxAgeDays       int  `json:"max_age_days" binding:"min=0,max=365"`
+	BlockBreached    bool `json:"block_breached"`
+}
+
+// PasswordPolicyViolation describes a password policy rule that a field failed
+type PasswordPolicyViolation struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ServiceAccountRequest represents a service account creation request
+type ServiceAccountRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// CustomRoleRequest represents a custom role creation or update request
+type CustomRoleRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" binding:"required,min=1"`
+}
+
+// APIKeyRequest represents an API key creation request
+type APIKeyRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=365"` // Omit for a key that does not expire
+}
+
+// ImpersonationRequest represents an admin's request to act as one of their users
+type ImpersonationRequest struct {
+	Reason          string `json:"reason" binding:"required"`
+	DurationMinutes int    `json:"duration_minutes" binding:"omitempty,min=1,max=60"`
+}
+
 // CompanyCreateRequest represents a company creation request
 type CompanyCreateRequest struct {
 	Name       string `json:"name" binding:"required"`
@@ -170,6 +316,17 @@ type UpdateCompanyRequest struct {
 	LogoURL    string `json:"logo_url,omitempty"`
 }
 
+// ConfirmChangeRequestRequest confirms a pending change request with the token emailed
+// to the admin who requested it
+type ConfirmChangeRequestRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// TransferOwnershipRequest nominates an admin as the new owner of a company
+type TransferOwnershipRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+}
+
 // UpdateUserRequest represents a user update request
 type UpdateUserRequest struct {
 	Name     string   `json:"name,omitempty"`
@@ -208,6 +365,72 @@ type UserContext struct {
 	Email     string `json:"email"`
 	CompanyID string `json:"company_id"`
 	Role      string `json:"role"`
+	// Permissions granted by the role in the active company
+	Permissions []string `json:"permissions,omitempty"`
+	// Groups of the active company the user effectively belongs to, including through nesting
+	Groups []string `json:"groups,omitempty"`
+	// Delegated admin assignments granting permissions over some users of the active company
+	Delegations []Delegation `json:"delegations,omitempty"`
+	// Auth connection that issued the token, such as "password" or "google"
+	Connection string `json:"connection,omitempty"`
+	// Set when the request was authenticated with a service account API key
+	ServiceAccountID string   `json:"service_account_id,omitempty"`
+	APIKeyID         string   `json:"api_key_id,omitempty"`
+	Scopes           []string `json:"scopes,omitempty"`
+	// Set while an admin impersonates the user above; the actor is the real admin
+	ActorID    string `json:"actor_id,omitempty"`
+	ActorEmail string `json:"actor_email,omitempty"`
+	// Set for platform operators, who belong to no company
+	PlatformOperator bool `json:"platform_operator,omitempty"`
+}
+
+// Can reports whether the user's role grants a permission
+func (u UserContext) Can(permission string) bool {
+	for _, p := range u.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// CanForSomeUsers reports whether the user's role or one of their delegated admin
+// assignments grants a permission. Delegated permissions only apply to the users in the
+// assignment's scope, which handlers check per target.
+func (u UserContext) CanForSomeUsers(permission string) bool {
+	if u.Can(permission) {
+		return true
+	}
+	for _, delegation := range u.Delegations {
+		if delegation.Grants(permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// Delegation is a delegated admin assignment held by the user
+type Delegation struct {
+	ID          string   `json:"id"`
+	Permissions []string `json:"permissions"`
+	AllUsers    bool     `json:"all_users"`
+	UserIDs     []string `json:"user_ids,omitempty"`
+	GroupIDs    []string `json:"group_ids,omitempty"`
+}
+
+// Grants reports whether the delegation grants a permission
+func (d Delegation) Grants(permission string) bool {
+	for _, p := range d.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Impersonating reports whether the request is made by an admin impersonating the user
+func (u UserContext) Impersonating() bool {
+	return u.ActorID != ""
 }
 
 // New request/response models for enhanced functionality
@@ -256,3 +479,35 @@ type DownloadInfoResponse struct {
 	SupportedOS    []string `json:"supported_os"`
 	InstallationInstructions string `json:"installation_instructions"`
 }
+
+// SuspendCompanyRequest represents a platform operator's request to suspend a tenant
+type SuspendCompanyRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// ExtendTrialRequest represents a platform operator's request to extend a tenant's trial
+type ExtendTrialRequest struct {
+	Days int `json:"days" binding:"required,min=1,max=90"`
+}
+
+// GroupRequest represents a group create or update request
+type GroupRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description,omitempty"`
+}
+
+// GroupMembersRequest represents a request to add users and nested groups to a group
+type GroupMembersRequest struct {
+	UserIDs  []string `json:"user_ids,omitempty"`
+	GroupIDs []string `json:"group_ids,omitempty"`
+}
+
+// DelegatedAdminRequest represents a delegated admin assignment request. The scope is
+// either all users or the listed users and groups.
+type DelegatedAdminRequest struct {
+	UserID   string   `json:"user_id" binding:"required"`
+	Role     string   `json:"role" binding:"required"`
+	AllUsers bool     `json:"all_users"`
+	UserIDs  []string `json:"user_ids,omitempty"`
+	GroupIDs []string `json:"group_ids,omitempty"`
+}
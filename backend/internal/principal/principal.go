@@ -0,0 +1,57 @@
+// Package principal carries the authenticated caller of a request in the request's
+// context. The auth middleware stores it once; handlers read it with the accessors,
+// which return errors instead of panicking when it is missing.
+package principal
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+)
+
+var (
+	// ErrUnauthenticated is returned when the request carries no principal
+	ErrUnauthenticated = errors.New("request is not authenticated")
+	// ErrNoCompany is returned when the principal is not associated with a company
+	ErrNoCompany = errors.New("user is not associated with a company")
+)
+
+type contextKey struct{}
+
+// WithPrincipal returns a context carrying the authenticated caller
+func WithPrincipal(ctx context.Context, user models.UserContext) context.Context {
+	return context.WithValue(ctx, contextKey{}, user)
+}
+
+// FromContext returns the authenticated caller stored in the context
+func FromContext(ctx context.Context) (models.UserContext, error) {
+	user, ok := ctx.Value(contextKey{}).(models.UserContext)
+	if !ok {
+		return models.UserContext{}, ErrUnauthenticated
+	}
+	return user, nil
+}
+
+// CompanyID returns the active company of the authenticated caller
+func CompanyID(ctx context.Context) (string, error) {
+	user, err := FromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if user.CompanyID == "" {
+		return "", ErrNoCompany
+	}
+	return user.CompanyID, nil
+}
+
+// Set stores the authenticated caller in the context of a Gin request
+func Set(c *gin.Context, user models.UserContext) {
+	c.Request = c.Request.WithContext(WithPrincipal(c.Request.Context(), user))
+}
+
+// Get returns the authenticated caller of a Gin request
+func Get(c *gin.Context) (models.UserContext, error) {
+	return FromContext(c.Request.Context())
+}
@@ -0,0 +1,155 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate is a token bucket refilled with Limit tokens per Period. The bucket holds up to
+// Burst tokens, or Limit when Burst is zero.
+type Rate struct {
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+// ParseRate parses a rate written as "<limit>/<period>", such as "100/1m". The period is a
+// Go duration, so "100/m" is not valid.
+func ParseRate(s string) (Rate, error) {
+	limit, period, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("invalid rate %q, expected <limit>/<period>", s)
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 1 {
+		return Rate{}, fmt.Errorf("invalid rate limit %q", limit)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate period %q", period)
+	}
+	return Rate{Limit: n, Period: d}, nil
+}
+
+// capacity returns the number of tokens a full bucket holds
+func (r Rate) capacity() float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	return float64(r.Limit)
+}
+
+// perSecond returns the number of tokens added to the bucket each second
+func (r Rate) perSecond() float64 {
+	return float64(r.Limit) / r.Period.Seconds()
+}
+
+// Result describes the state of a bucket after a request took a token from it
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until a token is available, set when the request was denied
+	RetryAfter time.Duration
+}
+
+// newResult builds the result of taking a token from a bucket left with tokens
+func newResult(rate Rate, allowed bool, tokens float64) Result {
+	result := Result{
+		Allowed:   allowed,
+		Limit:     int(rate.capacity()),
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((rate.capacity() - tokens) / rate.perSecond()),
+	}
+	if !allowed {
+		result.RetryAfter = seconds((1 - tokens) / rate.perSecond())
+	}
+	return result
+}
+
+// seconds converts a number of seconds to a duration
+func seconds(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+// Store keeps token buckets. Take removes a token from the bucket of key, creating a
+// full bucket if there is none.
+type Store interface {
+	Take(ctx context.Context, key string, rate Rate) (Result, error)
+}
+
+// MemoryStore keeps token buckets in memory, so limits apply per server instance
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time // Clock, replaced in tests
+}
+
+// bucket is the state of a token bucket at its last update
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // When the bucket is full again if no tokens are taken
+}
+
+// sweepInterval is how often idle buckets are dropped from a MemoryStore
+const sweepInterval = time.Minute
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Take removes a token from the bucket of key
+func (s *MemoryStore) Take(ctx context.Context, key string, rate Rate) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: rate.capacity(), updated: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(rate.capacity(), b.tokens+now.Sub(b.updated).Seconds()*rate.perSecond())
+	b.updated = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.full = now.Add(seconds((rate.capacity() - b.tokens) / rate.perSecond()))
+
+	return newResult(rate, allowed, b.tokens), nil
+}
+
+// sweep drops buckets that have refilled, at most once per sweepInterval. A missing
+// bucket is the same as a full one.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
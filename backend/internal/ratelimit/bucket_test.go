@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// testClock is a manually advanced clock
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// newTestMemoryStore returns a memory store reading the time from a test clock
+func newTestMemoryStore() (*MemoryStore, *testClock) {
+	clock := &testClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	store.now = clock.Now
+	store.lastSweep = clock.now
+	return store, clock
+}
+
+func take(t *testing.T, store Store, key string, rate Rate) Result {
+	t.Helper()
+	result, err := store.Take(context.Background(), key, rate)
+	if err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+	return result
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rate
+		wantErr bool
+	}{
+		{in: "100/1m", want: Rate{Limit: 100, Period: time.Minute}},
+		{in: " 5/30s ", want: Rate{Limit: 5, Period: 30 * time.Second}},
+		{in: "100/m", wantErr: true},
+		{in: "0/1m", wantErr: true},
+		{in: "10/-1s", wantErr: true},
+		{in: "10", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseRate(%q) = %+v, %v; want %+v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestMemoryStoreEmptiesAndRefills(t *testing.T) {
+	store, clock := newTestMemoryStore()
+	rate := Rate{Limit: 10, Period: 10 * time.Second} // One token per second
+
+	for i := 9; i >= 0; i-- {
+		result := take(t, store, "k", rate)
+		if !result.Allowed || result.Limit != 10 || result.Remaining != i {
+			t.Fatalf("take %d = %+v, want allowed with %d remaining", 10-i, result, i)
+		}
+	}
+
+	result := take(t, store, "k", rate)
+	if result.Allowed || result.RetryAfter != time.Second || result.Reset != 10*time.Second {
+		t.Fatalf("take on an empty bucket = %+v, want denied, retry after 1s, reset 10s", result)
+	}
+
+	clock.advance(500 * time.Millisecond)
+	result = take(t, store, "k", rate)
+	if result.Allowed || result.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("take after half a token = %+v, want denied, retry after 500ms", result)
+	}
+
+	clock.advance(500 * time.Millisecond)
+	result = take(t, store, "k", rate)
+	if !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("take after a refilled token = %+v, want allowed with 0 remaining", result)
+	}
+
+	// Refill stops at capacity
+	clock.advance(time.Hour)
+	result = take(t, store, "k", rate)
+	if !result.Allowed || result.Remaining != 9 || result.Reset != time.Second {
+		t.Fatalf("take after an hour = %+v, want allowed with 9 remaining, reset 1s", result)
+	}
+}
+
+func TestMemoryStoreBurst(t *testing.T) {
+	store, clock := newTestMemoryStore()
+	rate := Rate{Limit: 1, Period: time.Second, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		if result := take(t, store, "k", rate); !result.Allowed || result.Limit != 3 {
+			t.Fatalf("take %d = %+v, want allowed with limit 3", i+1, result)
+		}
+	}
+	if result := take(t, store, "k", rate); result.Allowed {
+		t.Fatalf("take past the burst = %+v, want denied", result)
+	}
+
+	clock.advance(time.Second)
+	if result := take(t, store, "k", rate); !result.Allowed {
+		t.Fatalf("take after a second = %+v, want allowed", result)
+	}
+}
+
+func TestMemoryStoreKeysAreIndependent(t *testing.T) {
+	store, _ := newTestMemoryStore()
+	rate := Rate{Limit: 1, Period: time.Minute}
+
+	take(t, store, "a", rate)
+	if result := take(t, store, "a", rate); result.Allowed {
+		t.Fatal("second take of a was allowed")
+	}
+	if result := take(t, store, "b", rate); !result.Allowed {
+		t.Fatal("first take of b was denied")
+	}
+}
+
+func TestMemoryStoreSweepsFullBuckets(t *testing.T) {
+	store, clock := newTestMemoryStore()
+
+	take(t, store, "short", Rate{Limit: 1, Period: time.Second})
+	take(t, store, "long", Rate{Limit: 1, Period: time.Hour})
+
+	clock.advance(sweepInterval)
+	take(t, store, "other", Rate{Limit: 1, Period: time.Second})
+
+	if _, ok := store.buckets["short"]; ok {
+		t.Error("refilled bucket was not swept")
+	}
+	if _, ok := store.buckets["long"]; !ok {
+		t.Error("bucket still refilling was swept")
+	}
+}
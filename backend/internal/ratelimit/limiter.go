@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows each key a fixed number of uses per time window. Counters are
+// kept in memory, so limits apply per server instance.
+type Limiter struct {
+	limit     int
+	window    time.Duration
+	mu        sync.Mutex
+	counters  map[string]*counter
+	lastSweep time.Time
+}
+
+// counter tracks the uses of a key in the current window
+type counter struct {
+	start time.Time
+	count int
+}
+
+// NewLimiter creates a limiter allowing limit uses per window
+func NewLimiter(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		limit:     limit,
+		window:    window,
+		counters:  make(map[string]*counter),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow records a use of key and reports whether it is within the limit. When it
+// is not, it also returns how long until the key may be used again.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	entry, ok := l.counters[key]
+	if !ok || now.Sub(entry.start) >= l.window {
+		entry = &counter{start: now}
+		l.counters[key] = entry
+	}
+
+	if entry.count >= l.limit {
+		return false, entry.start.Add(l.window).Sub(now)
+	}
+	entry.count++
+	return true, 0
+}
+
+// sweep drops expired counters at most once per window
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	for key, entry := range l.counters {
+		if now.Sub(entry.start) >= l.window {
+			delete(l.counters, key)
+		}
+	}
+	l.lastSweep = now
+}
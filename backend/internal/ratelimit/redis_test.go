@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedisStore returns a store backed by an in-process Redis whose clock is fixed
+func newTestRedisStore(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	server.SetTime(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisStore(client, "ratelimit:"), server
+}
+
+func TestRedisStoreEmptiesAndRefills(t *testing.T) {
+	store, server := newTestRedisStore(t)
+	rate := Rate{Limit: 2, Period: time.Minute} // One token per 30 seconds
+
+	for i := 1; i >= 0; i-- {
+		result := take(t, store, "k", rate)
+		if !result.Allowed || result.Limit != 2 || result.Remaining != i {
+			t.Fatalf("take %d = %+v, want allowed with %d remaining", 2-i, result, i)
+		}
+	}
+
+	result := take(t, store, "k", rate)
+	if result.Allowed || result.RetryAfter != 30*time.Second || result.Reset != time.Minute {
+		t.Fatalf("take on an empty bucket = %+v, want denied, retry after 30s, reset 1m", result)
+	}
+
+	// The bucket expires a second after it would be full again
+	if ttl := server.TTL("ratelimit:k"); ttl != 61*time.Second {
+		t.Errorf("bucket TTL = %v, want 61s", ttl)
+	}
+
+	server.SetTime(time.Date(2026, 1, 1, 0, 0, 30, 0, time.UTC))
+	result = take(t, store, "k", rate)
+	if !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("take after 30s = %+v, want allowed with 0 remaining", result)
+	}
+
+	// Refill stops at capacity
+	server.SetTime(time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC))
+	result = take(t, store, "k", rate)
+	if !result.Allowed || result.Remaining != 1 {
+		t.Fatalf("take after an hour = %+v, want allowed with 1 remaining", result)
+	}
+}
+
+func TestRedisStoreKeysAreIndependent(t *testing.T) {
+	store, server := newTestRedisStore(t)
+	rate := Rate{Limit: 1, Period: time.Minute}
+
+	take(t, store, "a", rate)
+	if result := take(t, store, "a", rate); result.Allowed {
+		t.Fatal("second take of a was allowed")
+	}
+	if result := take(t, store, "b", rate); !result.Allowed {
+		t.Fatal("first take of b was denied")
+	}
+	if !server.Exists("ratelimit:a") || !server.Exists("ratelimit:b") {
+		t.Errorf("buckets are not stored under the prefix, keys = %v", server.Keys())
+	}
+}
+
+func TestRedisStoreError(t *testing.T) {
+	store, server := newTestRedisStore(t)
+	server.Close()
+
+	if _, err := store.Take(t.Context(), "k", Rate{Limit: 1, Period: time.Minute}); err == nil {
+		t.Fatal("Take() with Redis down returned no error")
+	}
+}
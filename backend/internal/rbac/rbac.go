@@ -0,0 +1,316 @@
+// Package rbac maps roles to the permissions they grant. The built-in roles grant a
+// fixed set of permissions; companies can define custom roles granting any permissions
+// from the catalog.
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/auth"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/database"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/groups"
+	"github.com/mohilkhare21/pab-smb-poc-golang/backend/internal/models"
+)
+
+// Permissions in the catalog
+const (
+	CompanyRead           = "company:read"
+	CompanyWrite          = "company:write"
+	CompanyDelete         = "company:delete"
+	SecurityManage        = "security:manage"
+	UsersRead             = "users:read"
+	UsersWrite            = "users:write"
+	UsersInvite           = "users:invite"
+	UsersImpersonate      = "users:impersonate"
+	RolesManage           = "roles:manage"
+	GroupsManage          = "groups:manage"
+	ShortcutsRead         = "shortcuts:read"
+	ShortcutsWrite        = "shortcuts:write"
+	AuditRead             = "audit:read"
+	ServiceAccountsManage = "service_accounts:manage"
+	BillingManage         = "billing:manage"
+	PoliciesPublish       = "policies:publish"
+)
+
+// ErrUnknownRole is returned for roles that are neither built in nor defined by the company
+var ErrUnknownRole = errors.New("unknown role")
+
+// Permission describes a permission of the catalog
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Catalog lists every permission that can be granted
+var Catalog = []Permission{
+	{CompanyRead, "View the company profile and statistics"},
+	{CompanyWrite, "Update the company profile"},
+	{CompanyDelete, "Delete the company"},
+	{SecurityManage, "Manage security settings, password policy, SSO, SCIM tokens and lockouts"},
+	{UsersRead, "List users and view their profiles"},
+	{UsersWrite, "Change users' roles and status, unlock and remove users"},
+	{UsersInvite, "Invite users and manage invitations"},
+	{UsersImpersonate, "Sign in as another user for support"},
+	{RolesManage, "Define custom roles"},
+	{GroupsManage, "Create groups and manage their members"},
+	{ShortcutsRead, "View browser shortcuts"},
+	{ShortcutsWrite, "Create, update and delete browser shortcuts"},
+	{AuditRead, "View the audit log"},
+	{ServiceAccountsManage, "Manage service accounts and their API keys"},
+	{BillingManage, "Manage the subscription and billing details"},
+	{PoliciesPublish, "Publish browser security policies"},
+}
+
+// UserScoped lists the permissions delegated admin assignments grant. They act on
+// individual users, so they can be limited to a scope of users.
+var UserScoped = []string{UsersRead, UsersWrite, UsersInvite}
+
+// APIKeyScopes lists the permissions service account API keys can be granted as scopes.
+// A key holds only the permissions of its scopes.
+var APIKeyScopes = []string{CompanyRead, CompanyWrite, UsersRead, UsersWrite, UsersInvite, ShortcutsRead, ShortcutsWrite}
+
+// builtInRoles maps the built-in roles to the permissions they grant
+var builtInRoles = map[string][]string{
+	string(auth.RoleAdmin): allPermissions(),
+	string(auth.RoleUser):  {CompanyRead, UsersRead, ShortcutsRead},
+	string(auth.RoleGuest): {CompanyRead, ShortcutsRead},
+}
+
+// allPermissions returns the name of every permission in the catalog
+func allPermissions() []string {
+	permissions := make([]string, 0, len(Catalog))
+	for _, permission := range Catalog {
+		permissions = append(permissions, permission.Name)
+	}
+	return permissions
+}
+
+// BuiltInRoles returns the built-in roles and the permissions each grants
+func BuiltInRoles() map[string][]string {
+	roles := make(map[string][]string, len(builtInRoles))
+	for role, permissions := range builtInRoles {
+		roles[role] = append([]string(nil), permissions...)
+	}
+	return roles
+}
+
+// IsBuiltInRole reports whether role is one of the built-in roles
+func IsBuiltInRole(role string) bool {
+	_, ok := builtInRoles[role]
+	return ok
+}
+
+// ValidPermission reports whether permission is in the catalog
+func ValidPermission(permission string) bool {
+	for _, p := range Catalog {
+		if p.Name == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidAPIKeyScope reports whether a permission can be granted to an API key
+func ValidAPIKeyScope(scope string) bool {
+	return Has(APIKeyScopes, scope)
+}
+
+// Has reports whether permissions contains permission
+func Has(permissions []string, permission string) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAll reports whether permissions contains every permission in required
+func HasAll(permissions, required []string) bool {
+	for _, permission := range required {
+		if !Has(permissions, permission) {
+			return false
+		}
+	}
+	return true
+}
+
+// Privileged reports whether permissions go beyond the user-scoped permissions and those
+// of the built-in user role. Delegated admins never manage privileged users.
+func Privileged(permissions []string) bool {
+	for _, permission := range permissions {
+		if !Has(UserScoped, permission) && !Has(builtInRoles[string(auth.RoleUser)], permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// FullAdmin reports whether permissions include every permission of the built-in admin role
+func FullAdmin(permissions []string) bool {
+	return HasAll(permissions, builtInRoles[string(auth.RoleAdmin)])
+}
+
+// Permissions returns the permissions a role grants in a company. Roles that are not
+// built in must be custom roles of that company.
+func Permissions(ctx context.Context, databaseProvider database.DatabaseProvider, companyID, role string) ([]string, error) {
+	if permissions, ok := builtInRoles[role]; ok {
+		return append([]string(nil), permissions...), nil
+	}
+	if databaseProvider == nil || companyID == "" || role == "" {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownRole, role)
+	}
+
+	customRole, err := databaseProvider.GetCustomRole(ctx, role)
+	if err != nil || customRole.CompanyID != companyID {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownRole, role)
+	}
+	return append([]string(nil), customRole.Permissions...), nil
+}
+
+// CompanyRoles returns the permissions of the built-in roles and of a company's custom roles
+func CompanyRoles(ctx context.Context, databaseProvider database.DatabaseProvider, companyID string) (map[string][]string, error) {
+	roles := BuiltInRoles()
+	customRoles, err := databaseProvider.GetCustomRolesByCompany(ctx, companyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get custom roles: %w", err)
+	}
+	for _, customRole := range customRoles {
+		roles[customRole.ID] = append([]string(nil), customRole.Permissions...)
+	}
+	return roles, nil
+}
+
+// ValidateRole returns an error unless role can be assigned in a company
+func ValidateRole(ctx context.Context, databaseProvider database.DatabaseProvider, companyID, role string) error {
+	_, err := Permissions(ctx, databaseProvider, companyID, role)
+	return err
+}
+
+// DelegatedPermissions returns the user-scoped permissions a role grants in a company
+func DelegatedPermissions(ctx context.Context, databaseProvider database.DatabaseProvider, companyID, role string) ([]string, error) {
+	permissions, err := Permissions(ctx, databaseProvider, companyID, role)
+	if err != nil {
+		return nil, err
+	}
+
+	var delegated []string
+	for _, permission := range permissions {
+		if Has(UserScoped, permission) {
+			delegated = append(delegated, permission)
+		}
+	}
+	return delegated, nil
+}
+
+// Delegations returns the delegated admin assignments a user holds in a company with the
+// permissions they grant. Assignments whose role no longer exists grant nothing.
+func Delegations(ctx context.Context, databaseProvider database.DatabaseProvider, companyID, userID string) ([]models.Delegation, error) {
+	if databaseProvider == nil || companyID == "" {
+		return nil, nil
+	}
+
+	assignments, err := databaseProvider.GetDelegatedAdminsByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get delegated admin assignments: %w", err)
+	}
+
+	var delegations []models.Delegation
+	for _, assignment := range assignments {
+		if assignment.CompanyID != companyID {
+			continue
+		}
+		permissions, err := DelegatedPermissions(ctx, databaseProvider, companyID, assignment.Role)
+		if err != nil || len(permissions) == 0 {
+			continue
+		}
+		delegations = append(delegations, models.Delegation{
+			ID:          assignment.ID,
+			Permissions: permissions,
+			AllUsers:    assignment.AllUsers,
+			UserIDs:     assignment.UserIDs,
+			GroupIDs:    assignment.GroupIDs,
+		})
+	}
+	return delegations, nil
+}
+
+// Held returns every permission a user holds in a company through their role or any of
+// their delegated admin assignments, whatever its scope. A role that no longer exists
+// grants nothing.
+func Held(ctx context.Context, databaseProvider database.DatabaseProvider, companyID, userID, role string) ([]string, error) {
+	permissions, _ := Permissions(ctx, databaseProvider, companyID, role)
+
+	delegations, err := Delegations(ctx, databaseProvider, companyID, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, delegation := range delegations {
+		for _, permission := range delegation.Permissions {
+			if !Has(permissions, permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	return permissions, nil
+}
+
+// CanForAllUsers reports whether a user holds a permission over every user of the
+// company, through their role or a delegated admin assignment scoped to all users
+func CanForAllUsers(user models.UserContext, permission string) bool {
+	if user.Can(permission) {
+		return true
+	}
+	for _, delegation := range user.Delegations {
+		if delegation.AllUsers && delegation.Grants(permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanForUser reports whether a user holds a permission over a target user, through their
+// role or a delegated admin assignment whose scope includes the target directly or
+// through one of the target's groups
+func CanForUser(ctx context.Context, databaseProvider database.DatabaseProvider, user models.UserContext, permission, targetUserID string) (bool, error) {
+	if InDelegatedScope(user, permission, targetUserID, nil) {
+		return true, nil
+	}
+
+	// Only look up the target's groups when an assignment is scoped to groups
+	for _, delegation := range user.Delegations {
+		if delegation.Grants(permission) && len(delegation.GroupIDs) > 0 {
+			targetGroups, err := groups.Effective(ctx, databaseProvider, user.CompanyID, targetUserID)
+			if err != nil {
+				return false, err
+			}
+			return InDelegatedScope(user, permission, targetUserID, targetGroups), nil
+		}
+	}
+	return false, nil
+}
+
+// InDelegatedScope reports whether a user holds a permission over a target user, given
+// the groups the target effectively belongs to
+func InDelegatedScope(user models.UserContext, permission, targetUserID string, targetGroups []string) bool {
+	if CanForAllUsers(user, permission) {
+		return true
+	}
+
+	for _, delegation := range user.Delegations {
+		if !delegation.Grants(permission) {
+			continue
+		}
+		if Has(delegation.UserIDs, targetUserID) {
+			return true
+		}
+		for _, groupID := range delegation.GroupIDs {
+			if Has(targetGroups, groupID) {
+				return true
+			}
+		}
+	}
+	return false
+}